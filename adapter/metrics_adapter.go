@@ -14,22 +14,32 @@ type MetricsAdapter struct {
 	logger           *zap.Logger
 }
 
-// NewMetricsAdapter creates a new metrics adapter
+// NewMetricsAdapter creates a new metrics adapter registering its collectors
+// with the default Prometheus registry. It can only be called once per
+// process; use NewMetricsAdapterWithRegistry for more.
 func NewMetricsAdapter(logger *zap.Logger) *MetricsAdapter {
+	return NewMetricsAdapterWithRegistry(logger, prometheus.DefaultRegisterer)
+}
+
+// NewMetricsAdapterWithRegistry creates a new metrics adapter registering its
+// collectors with registry, so that runs given their own registries keep
+// separate counts
+func NewMetricsAdapterWithRegistry(logger *zap.Logger, registry prometheus.Registerer) *MetricsAdapter {
 	if logger == nil {
 		logger = zap.NewNop()
 	}
 
+	factory := promauto.With(registry)
 	return &MetricsAdapter{
-		filesAnalyzed: promauto.NewCounter(prometheus.CounterOpts{
+		filesAnalyzed: factory.NewCounter(prometheus.CounterOpts{
 			Name: "stackalloc_files_analyzed_total",
 			Help: "The total number of files analyzed by stackalloc",
 		}),
-		issuesFound: promauto.NewCounter(prometheus.CounterOpts{
+		issuesFound: factory.NewCounter(prometheus.CounterOpts{
 			Name: "stackalloc_issues_found_total",
 			Help: "The total number of allocation issues found by stackalloc",
 		}),
-		analysisDuration: promauto.NewHistogram(prometheus.HistogramOpts{
+		analysisDuration: factory.NewHistogram(prometheus.HistogramOpts{
 			Name:    "stackalloc_analysis_duration_seconds",
 			Help:    "Time spent analyzing files in seconds",
 			Buckets: prometheus.DefBuckets,
@@ -0,0 +1,45 @@
+package adapter
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// counterValue returns the value of the counter name in registry, or -1 if
+// it isn't registered there
+func counterValue(t *testing.T, registry *prometheus.Registry, name string) float64 {
+	t.Helper()
+
+	families, err := registry.Gather()
+	if err != nil {
+		t.Fatalf("Failed to gather metrics: %v", err)
+	}
+	for _, family := range families {
+		if family.GetName() == name {
+			return family.GetMetric()[0].GetCounter().GetValue()
+		}
+	}
+	return -1
+}
+
+func TestMetricsAdapterWithRegistry(t *testing.T) {
+	firstRegistry, secondRegistry := prometheus.NewRegistry(), prometheus.NewRegistry()
+	first := NewMetricsAdapterWithRegistry(nil, firstRegistry)
+	second := NewMetricsAdapterWithRegistry(nil, secondRegistry)
+
+	first.IncrementFilesAnalyzed()
+	first.IncrementIssuesFound()
+	first.IncrementIssuesFound()
+	second.IncrementFilesAnalyzed()
+
+	if got := counterValue(t, firstRegistry, "stackalloc_issues_found_total"); got != 2 {
+		t.Errorf("Expected 2 issues in the first registry, got %v", got)
+	}
+	if got := counterValue(t, secondRegistry, "stackalloc_issues_found_total"); got != 0 {
+		t.Errorf("Expected the second registry's counters to be separate, got %v", got)
+	}
+	if got := counterValue(t, secondRegistry, "stackalloc_files_analyzed_total"); got != 1 {
+		t.Errorf("Expected 1 file in the second registry, got %v", got)
+	}
+}
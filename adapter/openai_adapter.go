@@ -2,7 +2,9 @@ package adapter
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"net/http"
 	"strings"
 	"time"
 
@@ -13,22 +15,38 @@ import (
 // OpenAIAdapter implements the AIClient interface using OpenAI's API
 type OpenAIAdapter struct {
 	client      *openai.Client
+	apiKey      string
 	model       string
+	fallbacks   []string // models tried in order when the previous one is unavailable
 	maxTokens   int
 	temperature float32
 	logger      *zap.Logger
+	logging     RequestLogging
+}
+
+// RequestLogging controls debug logging of the prompts sent to OpenAI and the
+// responses received, for auditing. The API key is always redacted.
+type RequestLogging struct {
+	Enabled        bool // Log every prompt and response at debug level
+	RedactSnippets bool // Replace code snippets with a placeholder in logs
 }
 
 // NewOpenAIAdapter creates a new OpenAI adapter
 func NewOpenAIAdapter(apiKey, model string, maxTokens int, temperature float32, logger *zap.Logger) *OpenAIAdapter {
+	return newOpenAIAdapterWithConfig(openai.DefaultConfig(apiKey), apiKey, model, maxTokens, temperature, logger)
+}
+
+// newOpenAIAdapterWithConfig creates an OpenAI adapter using a custom client configuration
+func newOpenAIAdapterWithConfig(clientConfig openai.ClientConfig, apiKey, model string, maxTokens int, temperature float32, logger *zap.Logger) *OpenAIAdapter {
 	if logger == nil {
 		logger = zap.NewNop()
 	}
 
-	client := openai.NewClient(apiKey)
+	client := openai.NewClientWithConfig(clientConfig)
 
 	return &OpenAIAdapter{
 		client:      client,
+		apiKey:      apiKey,
 		model:       model,
 		maxTokens:   maxTokens,
 		temperature: temperature,
@@ -36,6 +54,17 @@ func NewOpenAIAdapter(apiKey, model string, maxTokens int, temperature float32,
 	}
 }
 
+// SetRequestLogging configures request and response logging
+func (a *OpenAIAdapter) SetRequestLogging(logging RequestLogging) {
+	a.logging = logging
+}
+
+// SetModelFallbacks sets the models to try, in order, when the primary model
+// is unavailable or rate limited
+func (a *OpenAIAdapter) SetModelFallbacks(models []string) {
+	a.fallbacks = models
+}
+
 // SuggestFix generates a code suggestion using OpenAI's API
 func (a *OpenAIAdapter) SuggestFix(ctx context.Context, snippet, issueMsg string) (string, error) {
 	if a.client == nil {
@@ -65,10 +94,31 @@ func (a *OpenAIAdapter) SuggestFix(ctx context.Context, snippet, issueMsg string
 	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
 	defer cancel()
 
-	// Make API call
-	resp, err := a.client.CreateChatCompletion(ctx, req)
+	// Make API call, moving down the fallback chain while models are unavailable
+	models := append([]string{a.model}, a.fallbacks...)
+	var resp openai.ChatCompletionResponse
+	var err error
+	for i, model := range models {
+		req.Model = model
+		if a.logging.Enabled {
+			a.logger.Debug("OpenAI request",
+				zap.String("model", model),
+				zap.String("prompt", a.redact(prompt, snippet)),
+			)
+		}
+
+		resp, err = a.client.CreateChatCompletion(ctx, req)
+		if err == nil || i == len(models)-1 || !isModelUnavailable(err) {
+			break
+		}
+		a.logger.Warn("OpenAI model unavailable, falling back",
+			zap.String("model", model),
+			zap.String("fallback", models[i+1]),
+			zap.String("error", a.redact(err.Error(), snippet)),
+		)
+	}
 	if err != nil {
-		a.logger.Error("OpenAI API call failed", zap.Error(err))
+		a.logger.Error("OpenAI API call failed", zap.String("error", a.redact(err.Error(), snippet)))
 		return "", fmt.Errorf("OpenAI API call failed: %w", err)
 	}
 
@@ -78,14 +128,58 @@ func (a *OpenAIAdapter) SuggestFix(ctx context.Context, snippet, issueMsg string
 
 	suggestion := strings.TrimSpace(resp.Choices[0].Message.Content)
 
+	if a.logging.Enabled {
+		a.logger.Debug("OpenAI response",
+			zap.String("model", resp.Model),
+			zap.String("response", a.redact(suggestion, snippet)),
+		)
+	}
+
 	a.logger.Debug("OpenAI suggestion generated",
-		zap.String("issue", issueMsg),
-		zap.String("suggestion", suggestion),
+		zap.String("model", req.Model),
+		zap.String("issue", a.redact(issueMsg, snippet)),
+		zap.String("suggestion", a.redact(suggestion, snippet)),
 	)
 
 	return suggestion, nil
 }
 
+// isModelUnavailable reports whether err means the requested model can't serve
+// the request right now, so another model might: it doesn't exist or isn't
+// available to the account, is rate limited or is overloaded
+func isModelUnavailable(err error) bool {
+	var apiErr *openai.APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.Code == "model_not_found" || isUnavailableStatus(apiErr.HTTPStatusCode)
+	}
+	var reqErr *openai.RequestError
+	if errors.As(err, &reqErr) {
+		return isUnavailableStatus(reqErr.HTTPStatusCode)
+	}
+	return false
+}
+
+// isUnavailableStatus reports whether an HTTP status means the model is
+// missing, rate limited or overloaded
+func isUnavailableStatus(status int) bool {
+	switch status {
+	case http.StatusNotFound, http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable:
+		return true
+	}
+	return false
+}
+
+// redact strips the API key from s, and the snippet too when RedactSnippets is set
+func (a *OpenAIAdapter) redact(s, snippet string) string {
+	if a.apiKey != "" {
+		s = strings.ReplaceAll(s, a.apiKey, "[REDACTED API KEY]")
+	}
+	if a.logging.RedactSnippets && strings.TrimSpace(snippet) != "" {
+		s = strings.ReplaceAll(s, snippet, fmt.Sprintf("[REDACTED SNIPPET: %d bytes]", len(snippet)))
+	}
+	return s
+}
+
 // buildPrompt constructs the prompt for OpenAI
 func (a *OpenAIAdapter) buildPrompt(snippet, issueMsg string) string {
 	return fmt.Sprintf(`Analyze this Go code snippet and provide a specific code fix for the memory allocation issue:
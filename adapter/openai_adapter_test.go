@@ -0,0 +1,177 @@
+package adapter
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/sashabaranov/go-openai"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+const (
+	testAPIKey  = "sk-test-0123456789abcdef"
+	testSnippet = "password := \"hunter2\"\ns := new(string)"
+)
+
+// newTestAdapter returns an adapter talking to a fake OpenAI server that
+// answers with reply, and the logs it writes
+func newTestAdapter(t *testing.T, status int, reply string, logging RequestLogging) (*OpenAIAdapter, *observer.ObservedLogs) {
+	t.Helper()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		fmt.Fprint(w, reply)
+	}))
+	t.Cleanup(server.Close)
+
+	clientConfig := openai.DefaultConfig(testAPIKey)
+	clientConfig.BaseURL = server.URL + "/v1"
+
+	core, logs := observer.New(zapcore.DebugLevel)
+	a := newOpenAIAdapterWithConfig(clientConfig, testAPIKey, "gpt-4", 64, 0.2, zap.New(core))
+	a.SetRequestLogging(logging)
+	return a, logs
+}
+
+// chatReply encodes a chat completion response with the given content
+func chatReply(content string) string {
+	return fmt.Sprintf(`{"model":"gpt-4","choices":[{"index":0,"message":{"role":"assistant","content":%q}}]}`, content)
+}
+
+// logText flattens every logged message and field into one string
+func logText(logs *observer.ObservedLogs) string {
+	var b strings.Builder
+	for _, entry := range logs.All() {
+		b.WriteString(entry.Message)
+		for key, value := range entry.ContextMap() {
+			fmt.Fprintf(&b, " %s=%v", key, value)
+		}
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+func TestRequestLoggingNeverLogsAPIKey(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  int
+		reply   string
+		logging RequestLogging
+	}{
+		{"success with redaction", http.StatusOK, chatReply("Before: " + testSnippet + " uses " + testAPIKey), RequestLogging{Enabled: true, RedactSnippets: true}},
+		{"success without snippet redaction", http.StatusOK, chatReply("echo " + testAPIKey), RequestLogging{Enabled: true}},
+		{"API error echoing the key", http.StatusUnauthorized, `{"error":{"message":"Incorrect API key provided: ` + testAPIKey + `","type":"invalid_request_error"}}`, RequestLogging{Enabled: true}},
+		{"logging disabled", http.StatusOK, chatReply(testAPIKey), RequestLogging{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, logs := newTestAdapter(t, tt.status, tt.reply, tt.logging)
+			a.SuggestFix(context.Background(), testSnippet, "api key "+testAPIKey)
+
+			if logs.Len() == 0 {
+				t.Fatal("Expected the adapter to log")
+			}
+			if text := logText(logs); strings.Contains(text, testAPIKey) {
+				t.Errorf("Expected the API key to be redacted, got logs:\n%s", text)
+			}
+		})
+	}
+}
+
+func TestRequestLoggingRedactsSnippets(t *testing.T) {
+	a, logs := newTestAdapter(t, http.StatusOK, chatReply("Before:\n"+testSnippet), RequestLogging{Enabled: true, RedactSnippets: true})
+	if _, err := a.SuggestFix(context.Background(), testSnippet, "new(T) allocates"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if logs.FilterMessage("OpenAI request").Len() != 1 || logs.FilterMessage("OpenAI response").Len() != 1 {
+		t.Fatalf("Expected the request and response to be logged, got:\n%s", logText(logs))
+	}
+	text := logText(logs)
+	if strings.Contains(text, "hunter2") {
+		t.Errorf("Expected the snippet to be redacted, got logs:\n%s", text)
+	}
+	if !strings.Contains(text, "[REDACTED SNIPPET:") {
+		t.Errorf("Expected a snippet placeholder, got logs:\n%s", text)
+	}
+}
+
+func TestRequestLoggingKeepsSnippetsWhenAsked(t *testing.T) {
+	a, logs := newTestAdapter(t, http.StatusOK, chatReply("ok"), RequestLogging{Enabled: true})
+	if _, err := a.SuggestFix(context.Background(), testSnippet, "new(T) allocates"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if text := logText(logs.FilterMessage("OpenAI request")); !strings.Contains(text, "hunter2") {
+		t.Errorf("Expected the snippet in the logged prompt, got logs:\n%s", text)
+	}
+}
+
+func TestModelFallbacks(t *testing.T) {
+	var requested []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req openai.ChatCompletionRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("Failed to decode request: %v", err)
+		}
+		requested = append(requested, req.Model)
+
+		w.Header().Set("Content-Type", "application/json")
+		switch req.Model {
+		case "gpt-4":
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `{"error":{"message":"The model gpt-4 does not exist","type":"invalid_request_error","code":"model_not_found"}}`)
+		case "gpt-4o":
+			w.WriteHeader(http.StatusTooManyRequests)
+			fmt.Fprint(w, `{"error":{"message":"Rate limit reached","type":"requests","code":"rate_limit_exceeded"}}`)
+		default:
+			fmt.Fprint(w, chatReply("use a value"))
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	clientConfig := openai.DefaultConfig(testAPIKey)
+	clientConfig.BaseURL = server.URL + "/v1"
+	core, logs := observer.New(zapcore.DebugLevel)
+	a := newOpenAIAdapterWithConfig(clientConfig, testAPIKey, "gpt-4", 64, 0.2, zap.New(core))
+	a.SetModelFallbacks([]string{"gpt-4o", "gpt-4o-mini", "unused"})
+
+	suggestion, err := a.SuggestFix(context.Background(), testSnippet, "new(T) allocates")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if suggestion != "use a value" {
+		t.Errorf("Expected the fallback model's suggestion, got %q", suggestion)
+	}
+	if want := []string{"gpt-4", "gpt-4o", "gpt-4o-mini"}; strings.Join(requested, ",") != strings.Join(want, ",") {
+		t.Errorf("Expected models %v to be tried, got %v", want, requested)
+	}
+	if logs.FilterMessage("OpenAI model unavailable, falling back").Len() != 2 {
+		t.Errorf("Expected each fallback to be logged, got:\n%s", logText(logs))
+	}
+	served := logs.FilterMessage("OpenAI suggestion generated").All()
+	if len(served) != 1 || served[0].ContextMap()["model"] != "gpt-4o-mini" {
+		t.Errorf("Expected the serving model to be logged, got:\n%s", logText(logs))
+	}
+}
+
+func TestModelFallbacksStopOnOtherErrors(t *testing.T) {
+	a, logs := newTestAdapter(t, http.StatusUnauthorized, `{"error":{"message":"Incorrect API key provided","type":"invalid_request_error"}}`, RequestLogging{})
+	a.SetModelFallbacks([]string{"gpt-4o-mini"})
+
+	if _, err := a.SuggestFix(context.Background(), testSnippet, "new(T) allocates"); err == nil || !strings.Contains(err.Error(), "401") {
+		t.Errorf("Expected the authentication error, got %v", err)
+	}
+	if logs.FilterMessage("OpenAI model unavailable, falling back").Len() != 0 {
+		t.Errorf("Expected no fallback for an authentication error, got:\n%s", logText(logs))
+	}
+}
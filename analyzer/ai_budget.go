@@ -0,0 +1,114 @@
+package analyzer
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/harriteja/gostackallocator/internal"
+	"go.uber.org/zap"
+)
+
+// errAIBudgetExhausted is returned for suggestions asked for after the
+// -ai-time-budget has been spent
+var errAIBudgetExhausted = errors.New("AI time budget exhausted")
+
+// budgetedAIClient passes requests on to an AIClient until the time spent
+// waiting for it adds up to a budget, then fails the rest immediately, so
+// their issues are reported without suggestions. Each request is cut off when
+// the remaining budget runs out.
+type budgetedAIClient struct {
+	client AIClient
+	budget time.Duration
+	logger *zap.Logger
+
+	mu      sync.Mutex
+	spent   time.Duration
+	skipped int
+}
+
+// newBudgetedAIClient limits client to budget, or returns it unchanged if
+// budget isn't positive
+func newBudgetedAIClient(client AIClient, budget time.Duration, logger *zap.Logger) AIClient {
+	if client == nil || budget <= 0 {
+		return client
+	}
+	return &budgetedAIClient{client: client, budget: budget, logger: logger}
+}
+
+// SuggestFix implements AIClient
+func (b *budgetedAIClient) SuggestFix(ctx context.Context, snippet, issueMsg string) (string, error) {
+	b.mu.Lock()
+	remaining := b.budget - b.spent
+	if remaining <= 0 {
+		if b.skipped == 0 {
+			b.logger.Warn("AI time budget exhausted; reporting remaining issues without suggestions",
+				zap.Duration("budget", b.budget),
+				zap.Duration("spent", b.spent),
+			)
+		}
+		b.skipped++
+		b.mu.Unlock()
+		return "", errAIBudgetExhausted
+	}
+	b.mu.Unlock()
+
+	ctx, cancel := context.WithTimeout(ctx, remaining)
+	defer cancel()
+
+	start := time.Now()
+	suggestion, err := b.client.SuggestFix(ctx, snippet, issueMsg)
+	b.mu.Lock()
+	b.spent += time.Since(start)
+	b.mu.Unlock()
+	return suggestion, err
+}
+
+// aiClientKey identifies the settings an AI client is created from
+type aiClientKey struct {
+	apiKey      string
+	model       string
+	fallbacks   string
+	maxTokens   int
+	temperature float32
+	budget      time.Duration
+	logging     bool
+	snippets    bool
+}
+
+// aiClients caches the clients sharedAIClient creates by their settings, since
+// every package analyzed in a process should draw on the same -ai-time-budget
+// rather than each starting a budget of its own
+var aiClients = struct {
+	sync.Mutex
+	m map[aiClientKey]AIClient
+}{m: make(map[aiClientKey]AIClient)}
+
+// sharedAIClient returns the client created by newClient for config, limited
+// by its -ai-time-budget, creating it only on the first call with these
+// settings. The analyzers created with options share one through
+// analyzerOptions instead; this is for the flag-driven Analyzer, which is run
+// once per package with a fresh Config.
+func sharedAIClient(config *Config, newClient func() AIClient) AIClient {
+	key := aiClientKey{
+		apiKey:      config.OpenAIAPIKey,
+		model:       config.OpenAIModel,
+		fallbacks:   strings.Join(config.OpenAIFallbacks, ","),
+		maxTokens:   config.OpenAIMaxTokens,
+		temperature: config.OpenAITemperature,
+		budget:      config.AITimeBudget,
+		logging:     config.AILogRequests,
+		snippets:    config.AILogSnippets,
+	}
+
+	aiClients.Lock()
+	defer aiClients.Unlock()
+	if client, ok := aiClients.m[key]; ok {
+		return client
+	}
+	client := newBudgetedAIClient(newClient(), config.AITimeBudget, internal.GetLogger())
+	aiClients.m[key] = client
+	return client
+}
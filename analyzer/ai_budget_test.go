@@ -0,0 +1,123 @@
+package analyzer
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+// slowAIClient answers after delay, or fails when its context ends first
+type slowAIClient struct {
+	delay time.Duration
+	calls int
+}
+
+func (c *slowAIClient) SuggestFix(ctx context.Context, snippet, issueMsg string) (string, error) {
+	c.calls++
+	select {
+	case <-time.After(c.delay):
+		return "use a value", nil
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+}
+
+func TestAITimeBudget(t *testing.T) {
+	code := `package main
+
+func main() {
+	a := new(int)
+	b := new(int)
+	c := new(int)
+	d := new(int)
+	e := new(int)
+	f := new(int)
+	g := new(int)
+	h := new(int)
+	_, _, _, _, _, _, _, _ = a, b, c, d, e, f, g, h
+}
+`
+	client := &slowAIClient{delay: 40 * time.Millisecond}
+	config := DefaultConfig()
+	config.AITimeBudget = 100 * time.Millisecond
+	core, logs := observer.New(zapcore.WarnLevel)
+	options := newAnalyzerOptions(WithConfig(config), WithAIClient(client), WithLogger(zap.New(core)))
+
+	pass, diagnostics := newTestPass(t, code)
+	start := time.Now()
+	if _, err := runWithDeps(pass, options); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	elapsed := time.Since(start)
+
+	suggested := 0
+	for _, d := range *diagnostics {
+		if len(d.Related) > 0 {
+			suggested++
+		}
+	}
+	if len(*diagnostics) < 8 {
+		t.Fatalf("Expected every issue to be reported, got %d diagnostics", len(*diagnostics))
+	}
+	if client.calls > 3 || suggested < 1 || suggested > 2 {
+		t.Errorf("Expected at most 3 AI calls and 1 or 2 suggestions within the budget, got %d calls and %d suggestions", client.calls, suggested)
+	}
+	if elapsed > time.Second {
+		t.Errorf("Expected the run to stay near its 100ms AI budget, took %v", elapsed)
+	}
+	if logs.FilterMessage("AI time budget exhausted; reporting remaining issues without suggestions").Len() != 1 {
+		t.Errorf("Expected exhausting the budget to be logged once, got %v", logs.All())
+	}
+
+	// The budget is shared by later packages analyzed with the same options
+	calls := client.calls
+	pass, _ = newTestPass(t, code)
+	if _, err := runWithDeps(pass, options); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if client.calls != calls {
+		t.Errorf("Expected no AI calls once the budget is spent, got %d more", client.calls-calls)
+	}
+}
+
+func TestAITimeBudgetDisabled(t *testing.T) {
+	client := &slowAIClient{}
+	if newBudgetedAIClient(client, 0, zap.NewNop()) != AIClient(client) {
+		t.Error("Expected no budget to leave the client unchanged")
+	}
+	if newBudgetedAIClient(nil, time.Second, zap.NewNop()) != nil {
+		t.Error("Expected a nil client to stay nil")
+	}
+}
+
+func TestSharedAIClient(t *testing.T) {
+	created := 0
+	newClient := func() AIClient {
+		created++
+		return &slowAIClient{}
+	}
+
+	config := DefaultConfig()
+	config.OpenAIAPIKey = "test-key"
+	config.AITimeBudget = time.Minute
+
+	// Every package analyzed with the same settings draws on one budget
+	first := sharedAIClient(config, newClient)
+	if _, ok := first.(*budgetedAIClient); !ok {
+		t.Fatalf("Expected the client to be limited by the budget, got %T", first)
+	}
+	if again := sharedAIClient(config, newClient); again != first || created != 1 {
+		t.Errorf("Expected the client to be shared, got %d created", created)
+	}
+
+	other := DefaultConfig()
+	other.OpenAIAPIKey = "test-key"
+	other.AITimeBudget = 2 * time.Minute
+	if sharedAIClient(other, newClient) == first || created != 2 {
+		t.Errorf("Expected different settings to get their own client, got %d created", created)
+	}
+}
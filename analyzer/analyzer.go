@@ -7,11 +7,13 @@ import (
 	"go/ast"
 	"go/token"
 	"go/types"
+	"io"
+	"os"
 	"strconv"
 	"strings"
 	"time"
 
-	"github.com/harriteja/gostackallocator/adapter"
+	"github.com/harriteja/gostackallocator/internal"
 	"go.uber.org/zap"
 	"golang.org/x/tools/go/analysis"
 )
@@ -39,10 +41,11 @@ func (m *MockAIClient) SuggestFix(ctx context.Context, snippet, issueMsg string)
 
 // Analyzer is the main static analysis analyzer for stack allocation detection
 var Analyzer = &analysis.Analyzer{
-	Name:  "stackalloc",
-	Doc:   "detects small heap allocations and suggests stack-friendly alternatives",
-	Run:   run,
-	Flags: flag.FlagSet{},
+	Name:      "stackalloc",
+	Doc:       "detects small heap allocations and suggests stack-friendly alternatives",
+	Run:       run,
+	Flags:     flag.FlagSet{},
+	FactTypes: []analysis.Fact{new(EscapeFact)},
 }
 
 func init() {
@@ -51,23 +54,13 @@ func init() {
 	config.SetupFlags(&Analyzer.Flags)
 }
 
-// AnalyzerWithDeps creates an analyzer with injected dependencies
+// NewAnalyzer creates an analyzer with injected dependencies
 func NewAnalyzer(aiClient AIClient, metricsClient MetricsClient, config *Config) *analysis.Analyzer {
-	analyzer := &analysis.Analyzer{
-		Name: "stackalloc",
-		Doc:  "detects small heap allocations and suggests stack-friendly alternatives",
-		Run: func(pass *analysis.Pass) (interface{}, error) {
-			return runWithDeps(pass, aiClient, metricsClient, config)
-		},
-		Flags: flag.FlagSet{},
-	}
-
-	// Setup flags if config is provided
-	if config != nil {
-		config.SetupFlags(&analyzer.Flags)
-	}
-
-	return analyzer
+	return NewAnalyzerWithOptions(
+		WithAIClient(aiClient),
+		WithMetrics(metricsClient),
+		WithConfig(config),
+	)
 }
 
 // run is the main entry point for the analyzer
@@ -83,31 +76,39 @@ func run(pass *analysis.Pass) (interface{}, error) {
 	}
 
 	config.ParseFlags(&pass.Analyzer.Flags)
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	warnConfig(config, internal.GetLogger())
+	if _, err := loadProfile(config.Pprof); err != nil {
+		return nil, err
+	}
+	if err := checkTypesInfo(pass, config, internal.GetLogger()); err != nil {
+		return nil, err
+	}
+
+	sinks, err := formatSinks(config, os.Stdout)
+	if err != nil {
+		return nil, err
+	}
 
 	// Create metrics client (no-op for now)
 	metricsClient := &NoOpMetricsAdapter{}
 
-	// Create AI client if enabled (use mock for testing)
+	// Create an AI client to enrich fixes unless AI is disabled, in which case
+	// fixes come from the detectors and the AutoFixer alone. Validate has
+	// made sure there's an API key.
 	var aiClient AIClient
-	if config.AutoFix {
-		// Use mock AI client for testing when no real API key is provided
-		if config.OpenAIAPIKey == "" {
-			aiClient = &MockAIClient{}
-		} else {
-			// Create real OpenAI client when API key is provided
-			logger := zap.NewNop() // Use no-op logger in non-DI mode
-			aiClient = adapter.NewOpenAIAdapter(
-				config.OpenAIAPIKey,
-				config.OpenAIModel,
-				config.OpenAIMaxTokens,
-				config.OpenAITemperature,
-				logger,
-			)
-		}
+	if config.AIEnabled() {
+		aiClient = sharedAIClient(config, func() AIClient {
+			return newOpenAIClient(config)
+		})
 	}
 
 	// Create fix tracker for automatic fixes
 	fixTracker := NewFixTracker()
+	fixTracker.pkg = newFixPackage(pass)
+	defer reportUnreadableSources(pass, fixTracker)
 
 	// Track analysis start time
 	startTime := time.Now()
@@ -115,108 +116,322 @@ func run(pass *analysis.Pass) (interface{}, error) {
 		duration := time.Since(startTime).Seconds()
 		metricsClient.RecordAnalysisDuration(duration)
 
-		// Apply fixes if autofix is enabled
-		if config.AutoFix && len(fixTracker.GetFilesWithFixes()) > 0 {
-			autoFixer := NewAutoFixer(pass.Fset)
-			if err := fixTracker.ApplyAllFixes(autoFixer); err != nil {
-				// Log error but don't fail the analysis
-				pass.Reportf(token.NoPos, "Failed to apply automatic fixes: %v", err)
-			}
-		}
+		applyFixes(pass, config, fixTracker, fixConfirmer)
 	}()
 
 	// Analyze each file
-	for _, file := range pass.Files {
+	var issues []Issue
+	pkg := newPackageInfo(pass, config)
+	files := analyzedFiles(pass.Files, pass.Fset, config, internal.GetLogger())
+	for _, file := range files {
 		metricsClient.IncrementFilesAnalyzed()
 
-		// Use the existing InspectFile function
-		InspectFile(file, pass.TypesInfo, pass.Fset, func(pos token.Pos, msg string) {
+		fileIssues := capFileIssues(sampleIssues(analyzeFileWithLogger(file, pass.TypesInfo, pkg, pass.Fset, config, internal.GetLogger()), config), config)
+		if config.IncludeSource {
+			fileIssues = withSources(fileIssues, fixTracker.sources)
+		}
+		for _, issue := range fileIssues {
 			metricsClient.IncrementIssuesFound()
+			issues = append(issues, issue)
+		}
+	}
 
-			// Create issue
-			issue := Issue{
-				Pos:     pass.Fset.Position(pos),
-				Message: msg,
-			}
+	// Report issues with autofix support, according to the severity policy
+	if config.Report == "counts" {
+		countFiles(sinks, len(files))
+		reportFailingCounts(pass, issues, config)
+	} else {
+		reportIssues(pass, issues, aiClient, config, fixTracker, os.Stderr)
+	}
+	if config.SaveFixes != "" {
+		if err := fixTracker.SaveFixes(pass.Fset, config.SaveFixes); err != nil {
+			return nil, err
+		}
+	}
+	if err := reportToSinks(sinks, reportedIssues(issues, config)); err != nil {
+		return nil, err
+	}
 
-			// Report issue with autofix support
-			ReportIssueWithAutoFix(pass, issue, aiClient, config, fixTracker)
-		})
+	if config.FailOnFix {
+		return nil, checkPendingFixes(fixTracker, os.Stdout)
 	}
 
 	return nil, nil
 }
 
 // runWithDeps runs the analysis with injected dependencies
-func runWithDeps(pass *analysis.Pass, aiClient AIClient, metricsClient MetricsClient, config *Config) (interface{}, error) {
+func runWithDeps(pass *analysis.Pass, options *analyzerOptions) (interface{}, error) {
 	defer func() {
 		if r := recover(); r != nil {
 			pass.Reportf(token.NoPos, "stackalloc panicked: %v", r)
 		}
 	}()
 
+	config := options.config
+	metricsClient := options.metricsClient
+	if err := config.validate(options.aiClient != nil); err != nil {
+		return nil, err
+	}
+	warnConfig(config, options.logger)
+	if _, err := loadProfile(config.Pprof); err != nil {
+		return nil, err
+	}
+	if err := checkTypesInfo(pass, config, options.logger); err != nil {
+		return nil, err
+	}
+
+	sinks, err := formatSinks(config, os.Stdout)
+	if err != nil {
+		return nil, err
+	}
+	sinks = append(sinks, options.sinks...)
 	startTime := time.Now()
 
 	// Create fix tracker for automatic fixes
 	fixTracker := NewFixTracker()
+	fixTracker.sources = NewSourceCache(options.logger)
+	fixTracker.sources.overlay = options.overlay
+	fixTracker.pkg = newFixPackage(pass)
+	defer reportUnreadableSources(pass, fixTracker)
 
 	// Increment files analyzed metric
-	if metricsClient != nil {
-		metricsClient.IncrementFilesAnalyzed()
-		defer func() {
-			duration := time.Since(startTime).Seconds()
-			metricsClient.RecordAnalysisDuration(duration)
-
-			// Apply fixes if autofix is enabled
-			if config.AutoFix && len(fixTracker.GetFilesWithFixes()) > 0 {
-				autoFixer := NewAutoFixer(pass.Fset)
-				if err := fixTracker.ApplyAllFixes(autoFixer); err != nil {
-					// Log error but don't fail the analysis
-					pass.Reportf(token.NoPos, "Failed to apply automatic fixes: %v", err)
-				}
-			}
-		}()
-	}
+	metricsClient.IncrementFilesAnalyzed()
+	defer func() {
+		duration := time.Since(startTime).Seconds()
+		metricsClient.RecordAnalysisDuration(duration)
+
+		applyFixes(pass, config, fixTracker, options.confirmer)
+	}()
 
-	var issuesFound int
+	var issues []Issue
+	var results []Result
 
 	// Analyze each file in the package
-	for _, file := range pass.Files {
-		issues := analyzeFile(file, pass.TypesInfo, pass.Fset, config)
+	pkg := newPackageInfo(pass, config)
+	files := analyzedFiles(pass.Files, pass.Fset, config, options.logger)
+	for _, file := range files {
+		fileIssues := capFileIssues(sampleIssues(analyzeFileWithLogger(file, pass.TypesInfo, pkg, pass.Fset, config, options.logger), config), config)
+		if config.IncludeSource {
+			fileIssues = withSources(fileIssues, fixTracker.sources)
+		}
+		issues = append(issues, fileIssues...)
+		if options.results != nil {
+			results = append(results, newResults(file, pass.TypesInfo, pkg, pass.Fset, config, fileIssues)...)
+		}
+	}
 
+	if config.Report == "counts" {
+		countFiles(sinks, len(files))
+		reportFailingCounts(pass, issues, config)
+	} else {
+		reportIssues(pass, issues, options.suggester(), config, fixTracker, options.warnings)
+	}
+	if config.SaveFixes != "" {
+		if err := fixTracker.SaveFixes(pass.Fset, config.SaveFixes); err != nil {
+			return nil, err
+		}
+	}
+	if err := reportToSinks(sinks, reportedIssues(issues, config)); err != nil {
+		return nil, err
+	}
+	if options.reporter != nil {
 		for _, issue := range issues {
-			ReportIssueWithAutoFix(pass, issue, aiClient, config, fixTracker)
-			issuesFound++
+			options.reporter(issue)
 		}
 	}
+	for _, result := range results {
+		options.results(result)
+	}
 
 	// Record metrics
-	if metricsClient != nil && issuesFound > 0 {
-		for i := 0; i < issuesFound; i++ {
-			metricsClient.IncrementIssuesFound()
-		}
+	for range issues {
+		metricsClient.IncrementIssuesFound()
+	}
+
+	if config.FailOnFix {
+		return nil, checkPendingFixes(fixTracker, os.Stdout)
 	}
 
 	return nil, nil
 }
 
-// analyzeFile analyzes a single file for allocation patterns
+// reportIssues reports issues according to the severity policy in config:
+// error-level issues become diagnostics, which fail the run, warn-level issues
+// are written to warnings, and the rest are dropped. With -include-fixes, the
+// fixes of each reported issue are attached to it in issues.
+func reportIssues(pass *analysis.Pass, issues []Issue, aiClient AIClient, config *Config, fixTracker *FixTracker, warnings io.Writer) {
+	errors, warns := classifyIssues(issues, config)
+
+	// Issues are copied by classifyIssues, so fixes are matched back by
+	// position and message; identical issues get identical fixes
+	type issueKey struct {
+		pos     token.Position
+		message string
+	}
+	fixes := make(map[issueKey][]IssueFix)
+	record := func(issue Issue, diagnostic analysis.Diagnostic) {
+		if config.IncludeFixes {
+			fixes[issueKey{issue.Pos, issue.Message}] = issueFixes(diagnostic.SuggestedFixes, issue.Pos.Filename, pass.Fset, fixTracker.Sources())
+		}
+	}
+
+	// A node reported by two detectors gets the AutoFixer's fix twice; only
+	// the first diagnostic offers it, so applying every fix applies it once
+	offered := make(map[string]bool)
+	for _, issue := range errors {
+		diagnostic := FormatIssueWithFixTracker(issue, aiClient, pass.Fset, config, fixTracker)
+		record(issue, diagnostic)
+		var fixes []analysis.SuggestedFix
+		for _, fix := range diagnostic.SuggestedFixes {
+			key := fmt.Sprint(fix.Message, fix.TextEdits)
+			if !offered[key] {
+				offered[key] = true
+				fixes = append(fixes, fix)
+			}
+		}
+		diagnostic.SuggestedFixes = fixes
+		pass.Report(diagnostic)
+	}
+	for _, issue := range warns {
+		diagnostic := FormatIssueWithFixTracker(issue, aiClient, pass.Fset, config, fixTracker)
+		fmt.Fprintf(warnings, "%s: warning: %s\n", issue.Pos, diagnostic.Message)
+		record(issue, diagnostic)
+	}
+
+	if config.IncludeFixes {
+		for i := range issues {
+			issues[i].Fixes = fixes[issueKey{issues[i].Pos, issues[i].Message}]
+		}
+	}
+}
+
+// applyFixes writes tracked fixes back to their files under -autofix or
+// -annotate. Under -interactive each fix is offered to confirmer first.
+func applyFixes(pass *analysis.Pass, config *Config, fixTracker *FixTracker, confirmer FixConfirmer) {
+	if !config.WritesFixes() || len(fixTracker.GetFilesWithFixes()) == 0 {
+		return
+	}
+
+	autoFixer := NewAutoFixer(pass.Fset)
+	var err error
+	if config.Interactive && confirmer != nil {
+		err = fixTracker.ApplyConfirmedFixes(autoFixer, confirmer)
+		// Confirmers holding a terminal release it between packages
+		if closer, ok := confirmer.(io.Closer); ok {
+			closer.Close()
+		}
+	} else {
+		err = fixTracker.ApplyAllFixes(autoFixer)
+	}
+	if err != nil {
+		// Log error but don't fail the analysis
+		pass.Reportf(token.NoPos, "Failed to apply automatic fixes: %v", err)
+	}
+}
+
+// reportUnreadableSources surfaces one aggregated warning for source files that couldn't be read
+func reportUnreadableSources(pass *analysis.Pass, fixTracker *FixTracker) {
+	if warning := fixTracker.Sources().Warning(); warning != "" {
+		pass.Reportf(token.NoPos, "%s", warning)
+	}
+}
+
+// checkTypesInfo warns that results will be incomplete when pass carries no
+// type information, since most detectors need it to recognize anything. With
+// -require-types it returns an error instead.
+func checkTypesInfo(pass *analysis.Pass, config *Config, logger *zap.Logger) error {
+	if len(pass.Files) == 0 || hasTypesInfo(pass.TypesInfo) {
+		return nil
+	}
+
+	pkg := pass.Files[0].Name.Name
+	if pass.Pkg != nil {
+		pkg = pass.Pkg.Path()
+	}
+	if config.RequireTypes {
+		return fmt.Errorf("no type information for package %s", pkg)
+	}
+	logger.Warn("No type information for package; most detectors can't run, so results will be incomplete",
+		zap.String("package", pkg))
+	return nil
+}
+
+// hasTypesInfo reports whether info records anything about the package
+func hasTypesInfo(info *types.Info) bool {
+	return info != nil && (len(info.Defs) > 0 || len(info.Uses) > 0 || len(info.Types) > 0)
+}
+
+// analyzeFile analyzes a single file for allocation patterns, assuming gc's
+// type sizes for amd64 and nothing about the functions it calls
 func analyzeFile(file *ast.File, info *types.Info, fset *token.FileSet, config *Config) []Issue {
+	return analyzeFileWithLogger(file, info, nil, fset, config, internal.GetLogger())
+}
+
+// analyzeFileWithLogger analyzes a single file of the package described by
+// pkg, logging decisions to logger
+func analyzeFileWithLogger(file *ast.File, info *types.Info, pkg *packageInfo, fset *token.FileSet, config *Config, logger *zap.Logger) []Issue {
 	var issues []Issue
 
+	info, ok := fileTypesInfo(file, info, fset, config, logger)
+	if !ok {
+		return nil
+	}
+
 	// Collect issues using the inspector
-	InspectFile(file, info, fset, func(pos token.Pos, msg string) {
-		position := fset.Position(pos)
-		issue := Issue{
-			Pos:     position,
-			Message: msg,
-		}
+	inspectFile(file, info, pkg, fset, config, logger, func(issue Issue) {
 		issues = append(issues, issue)
 	})
 
 	return issues
 }
 
+// tooLarge reports whether file is larger than -max-file-size and should be
+// skipped, logging the skip. Such files are usually generated, and slow to
+// analyze for findings that rarely matter.
+func tooLarge(file *ast.File, fset *token.FileSet, config *Config, logger *zap.Logger) bool {
+	if config == nil || config.MaxFileSize <= 0 {
+		return false
+	}
+	tokenFile := fset.File(file.Pos())
+	if tokenFile == nil || tokenFile.Size() <= config.MaxFileSize*1024 {
+		return false
+	}
+
+	logger.Info("Skipping file larger than -max-file-size",
+		zap.String("file", tokenFile.Name()),
+		zap.Int("size_kb", (tokenFile.Size()+1023)/1024),
+		zap.Int("max_file_size_kb", config.MaxFileSize))
+	return true
+}
+
+// fileTypesInfo returns the type information to analyze file with, or false if
+// the file should be skipped. Type information for cgo files is unreliable, so
+// they are either skipped or analyzed with syntactic detectors only.
+func fileTypesInfo(file *ast.File, info *types.Info, fset *token.FileSet, config *Config, logger *zap.Logger) (*types.Info, bool) {
+	if !isCgoFile(file) {
+		return info, true
+	}
+
+	filename := fset.Position(file.Pos()).Filename
+	if config != nil && config.SkipCgo {
+		logger.Info("Skipping cgo file", zap.String("file", filename))
+		return nil, false
+	}
+
+	logger.Info("Running only syntactic detectors on cgo file", zap.String("file", filename))
+	return &types.Info{}, true
+}
+
+// isCgoFile reports whether file imports the C pseudo-package
+func isCgoFile(file *ast.File) bool {
+	for _, imp := range file.Imports {
+		if path, err := strconv.Unquote(imp.Path.Value); err == nil && path == "C" {
+			return true
+		}
+	}
+	return false
+}
+
 // GetVersion returns the analyzer version
 func GetVersion() string {
 	return "v0.1.0"
@@ -239,10 +454,55 @@ and can integrate with AI services for enhanced code suggestions.
 Flags:
   -max-alloc-size=N     Maximum bytes to consider 'small' allocation (default: 32)
   -disable-patterns=P   Comma-separated list of detectors to skip
+  -enable-patterns=P    Comma-separated list of off-by-default detectors to run
+  -only-patterns=P      Comma-separated list of detectors to run exclusively
   -metrics-enabled      Expose Prometheus metrics (default: false)
+  -autofix              Apply automatic code fixes to source files
+  -fail-on-fix          List files autofix would modify and fail without writing
+  -annotate             Insert a // TODO(stackalloc) comment above each finding
+  -interactive          Prompt before applying each fix from -autofix or -annotate
+  -validate-fixes       Discard AI and AutoFixer fixes that would break parsing or type checking
+  -save-fixes=FILE      Append generated fixes to FILE for later review
+  -apply-fixes=FILE     Apply fixes saved by -save-fixes (run directly, not via go vet)
+  -doctor               Print the environment and resolved configuration, then exit (run directly)
+  -skip-cgo             Skip cgo files instead of running syntactic detectors only
+  -include-init         Report findings in init code at info severity instead of dropping them
+  -require-types        Fail instead of warning when type information is missing
+  -format=F             Output format: text, json, jsonl, sarif, github or csv (default: text)
+  -report=counts        Print only files, issues and per-severity counts, as a line or JSON object
+  -include-source       Embed each issue's source lines in JSON and SARIF output
+  -include-fixes        Embed each issue's fix edits in JSON output without applying them
+  -show-fix             Print the replacement code of deterministic fixes after each message
+  -format-funcs=F1,F2   Fully qualified functions that allocate like fmt, e.g. example.com/log.Infof
+  -value-types=T1,T2    Fully qualified types to declare with var rather than new, besides bytes.Buffer, big.Int...
+  -exclude-files=P1,P2  Paths to leave out, in .gitignore syntax, after those in .stackallocignore
+  -verbose              Also report low-signal findings suppressed by default
+  -strict               Report only findings whose allocation is proven, not heuristic ones
+  -generic-instances    List generic instantiations in new(T) and make([]T) issues
+  -suggest-generics     Suggest type parameters in boxing issues where the declaration allows them
+  -error-severity=S     Minimum severity (info, possible, likely) that fails the run (default: info)
+  -warn-severity=S      Minimum severity printed as a warning only (default: info)
+  -rule-severity=R=S,...  Override the severity of rules, e.g. new-value-type=info
+  -path-tags=P=T,...    Tag findings in files under path prefixes, e.g. internal/billing=team-payments
+  -message-templates=F  JSON file of templates overriding the wording of findings by rule or message name
+  -max-issues=N         Error-level issues tolerated per package before failing (default: 0)
+  -max-issues-per-file=N  Report at most N issues per file, noting how many more were suppressed
+  -sample-rate=R        Report only the fraction R of issues, chosen by position (default: all)
+  -pprof=FILE           Report only findings in functions sampled by the pprof profile FILE, with their weight
+  -pprof-threshold=P    With -pprof, skip functions in less than P percent of the samples (default: 0)
+  -max-depth=N          Skip declarations nested deeper than N, with a warning (default: 10000)
+  -max-file-size=KB     Skip files larger than KB kilobytes, such as generated code (default: 0, no limit)
+  -min-loop-iterations=N  Ignore loops with a constant bound below N (default: 0, every loop)
   -openai-api-key=KEY   OpenAI API key for AI suggestions
   -openai-model=MODEL   OpenAI model to use (default: gpt-4)
+  -openai-model-fallbacks=M1,M2  Models to try in order when the model is unavailable or rate limited
   -openai-disable       Disable AI-powered suggestions (default: false)
+  -ai-time-budget=D     Skip AI suggestions once they've taken D in total, e.g. 2m (default: no limit)
+  -ai-log-requests      Log AI prompts and responses (API key and snippets redacted)
+  -ai-log-snippets      Keep code snippets in -ai-log-requests output
+
+Run directly with package patterns (stackalloc [flags] ./a/... ./b/...) to analyze
+them in one pass, including across the modules of a go.work workspace.
 
 Environment Variables:
   OPENAI_API_KEY        OpenAI API key (alternative to -openai-api-key flag)
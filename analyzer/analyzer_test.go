@@ -1,11 +1,24 @@
 package analyzer
 
 import (
+	"bytes"
 	"go/ast"
+	"go/importer"
 	"go/parser"
 	"go/token"
 	"go/types"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
 	"testing"
+
+	"github.com/harriteja/gostackallocator/internal"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/analysis/analysistest"
 )
 
 func TestInspectFile(t *testing.T) {
@@ -102,6 +115,92 @@ func localUse() {
 	}
 }
 
+func TestEscapeTracking(t *testing.T) {
+	code := `
+package main
+
+type pair struct{ a, b *int }
+
+func wrap(p *int) pair { return pair{a: p} }
+
+func two() (int, *int) { return 0, nil }
+
+func tuple() (*int, *int) {
+	a, b, c := 1, 2, 3
+	n, p := len("x"), &a
+	_, _ = n, p
+	_ = c
+	return &b, nil
+}
+
+func nested() pair {
+	x, y, z := 1, 2, 3
+	s := []*int{&x}
+	_ = s
+	w := wrap((&y))
+	_ = w
+	_, _ = two()
+	f := func() int {
+		k := z
+		return k
+	}
+	_ = f
+	return pair{b: &z}
+}
+
+func local() int {
+	v := 4
+	return v
+}
+`
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "test.go", code, 0)
+	if err != nil {
+		t.Fatalf("Failed to parse code: %v", err)
+	}
+	info := &types.Info{
+		Types: make(map[ast.Expr]types.TypeAndValue),
+		Defs:  make(map[*ast.Ident]types.Object),
+		Uses:  make(map[*ast.Ident]types.Object),
+	}
+	if _, err := (&types.Config{}).Check("test", fset, []*ast.File{file}, info); err != nil {
+		t.Fatalf("Failed to type check code: %v", err)
+	}
+
+	tracker := newUsageTracker()
+	ast.Inspect(file, func(n ast.Node) bool {
+		switch stmt := n.(type) {
+		case *ast.ReturnStmt:
+			for _, res := range stmt.Results {
+				checkEscapingAllocation(res, info, tracker, func(ast.Node, string) {})
+			}
+		case *ast.AssignStmt:
+			for _, rhs := range stmt.Rhs {
+				checkEscapingAllocation(rhs, info, tracker, func(ast.Node, string) {})
+			}
+		}
+		return true
+	})
+
+	escapes := make(map[string]bool)
+	for obj := range tracker.escapes {
+		escapes[obj.Name()] = true
+	}
+
+	// a is the second value of a tuple assignment, b a returned address and
+	// x, y and z are nested in a slice literal, call argument and struct literal
+	for _, name := range []string{"a", "b", "x", "y", "z"} {
+		if !escapes[name] {
+			t.Errorf("Expected %s to be marked as escaping, got %v", name, escapes)
+		}
+	}
+	for _, name := range []string{"c", "n", "k", "v"} {
+		if escapes[name] {
+			t.Errorf("Expected %s not to be marked as escaping, got %v", name, escapes)
+		}
+	}
+}
+
 func TestConfig(t *testing.T) {
 	config := DefaultConfig()
 
@@ -136,6 +235,222 @@ func TestConfigPatternDisabled(t *testing.T) {
 	}
 }
 
+func TestConfigPatternEnabled(t *testing.T) {
+	config := DefaultConfig()
+
+	if !config.IsPatternEnabled("slice-of-escaping-pointers") {
+		t.Error("Expected default-on rule to be enabled")
+	}
+
+	if config.IsPatternEnabled("repeated-key-sort") {
+		t.Error("Expected default-off rule to be disabled")
+	}
+
+	config.EnablePatterns = []string{"repeated-key-sort"}
+	if !config.IsPatternEnabled("repeated-key-sort") {
+		t.Error("Expected explicitly enabled rule to be enabled")
+	}
+
+	config.DisablePatterns = []string{"repeated-key-sort"}
+	if config.IsPatternEnabled("repeated-key-sort") {
+		t.Error("Expected disable-patterns to take precedence over enable-patterns")
+	}
+}
+
+func TestConfigOnlyPatterns(t *testing.T) {
+	code := `
+package main
+
+func handle(items []int, requests []struct{}) {
+	p := new(int)
+	_ = p
+	for range requests {
+		done := make(chan struct{})
+		close(done)
+	}
+	set := make(map[int]bool)
+	for _, i := range items {
+		set[i] = true
+	}
+	_ = len(set)
+}
+`
+	tests := []struct {
+		name     string
+		only     []string
+		expected []string
+	}{
+		{"single pattern", []string{"chan-in-loop"}, []string{"channel created on every loop iteration"}},
+		{"multiple patterns", []string{"chan-in-loop", "bool-set-map"}, []string{"channel created on every loop iteration", "map set is only assigned true"}},
+		{"off-by-default pattern", []string{"repeated-key-sort"}, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := DefaultConfig()
+			config.OnlyPatterns = tt.only
+
+			if err := config.ValidatePatterns(); err != nil {
+				t.Fatalf("Unexpected validation error: %v", err)
+			}
+
+			// Unnamed detectors, such as the new(T) check, don't run either
+			issues := inspectSource(t, code, config)
+			if len(issues) != len(tt.expected) {
+				t.Fatalf("Expected %d issues, got %d: %v", len(tt.expected), len(issues), issues)
+			}
+			for _, want := range tt.expected {
+				if countMatching(issues, want) != 1 {
+					t.Errorf("Expected an issue containing %q, got %v", want, issues)
+				}
+			}
+		})
+	}
+
+	config := DefaultConfig()
+	config.OnlyPatterns = []string{"repeated-key-sort"}
+	if !config.IsPatternEnabled("repeated-key-sort") || config.IsPatternEnabled("chan-in-loop") {
+		t.Error("Expected only-patterns to enable exactly the listed rules, including off-by-default ones")
+	}
+}
+
+func TestConfigValidatePatterns(t *testing.T) {
+	tests := []struct {
+		name    string
+		only    []string
+		disable []string
+		wantErr string
+	}{
+		{"no only-patterns", nil, []string{"chan-in-loop"}, ""},
+		{"unknown rule", []string{"chan-in-loop", "no-such-rule"}, nil, `unknown rule "no-such-rule"`},
+		{"contradicts disable-patterns", []string{"chan-in-loop"}, []string{"chan-in-loop"}, `"chan-in-loop" is listed in both`},
+		{"disjoint disable-patterns", []string{"chan-in-loop"}, []string{"bool-set-map"}, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := DefaultConfig()
+			config.OnlyPatterns = tt.only
+			config.DisablePatterns = tt.disable
+
+			err := config.ValidatePatterns()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("Unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestConfigValidate(t *testing.T) {
+	tests := []struct {
+		name     string
+		modify   func(c *Config)
+		wantErrs []string
+	}{
+		{"defaults", func(c *Config) {}, nil},
+		{"temperature out of range", func(c *Config) { c.OpenAITemperature = 2.5 }, []string{"-openai-temperature must be between 0 and 2"}},
+		{"negative size", func(c *Config) { c.MaxFileSize = -1 }, []string{"-max-file-size must not be negative"}},
+		{"unknown format", func(c *Config) { c.Format = "xml" }, []string{`unknown -format "xml"`}},
+		{"unknown disabled rule", func(c *Config) { c.DisablePatterns = []string{"chan-in-loop", "new-allocation"} }, []string{`unknown rule "new-allocation" in -disable-patterns`}},
+		{"AI option with AI disabled is only a warning", func(c *Config) {
+			c.OpenAIDisable = true
+			c.AILogRequests = true
+		}, nil},
+		{"AI fixes without a key", func(c *Config) {
+			c.AutoFix = true
+			c.OpenAIAPIKey = ""
+		}, []string{"no OpenAI API key is set"}},
+		{"AI fixes with a key", func(c *Config) {
+			c.AutoFix = true
+			c.OpenAIAPIKey = "sk-test"
+		}, nil},
+		{"deterministic fixes without a key", func(c *Config) {
+			c.AutoFix = true
+			c.OpenAIDisable = true
+		}, nil},
+		{"annotations without a key", func(c *Config) { c.Annotate = true }, nil},
+		{"every problem at once", func(c *Config) {
+			c.MaxAllocSize = -8
+			c.PprofThreshold = 150
+			c.EnablePatterns = []string{"chan-in-loop", "no-such-rule"}
+			c.SampleRate = 2
+		}, []string{
+			"-max-alloc-size must not be negative",
+			"-pprof-threshold must be a percentage between 0 and 100",
+			`unknown rule "no-such-rule" in -enable-patterns`,
+			"-sample-rate must be between 0 and 1",
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := DefaultConfig()
+			tt.modify(config)
+
+			err := config.Validate()
+			if len(tt.wantErrs) == 0 {
+				if err != nil {
+					t.Errorf("Unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatalf("Expected errors %q, got nil", tt.wantErrs)
+			}
+			for _, want := range tt.wantErrs {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("Expected error containing %q, got %v", want, err)
+				}
+			}
+			if lines := strings.Count(err.Error(), "\n") + 1; lines != len(tt.wantErrs) {
+				t.Errorf("Expected %d problems, got %d: %v", len(tt.wantErrs), lines, err)
+			}
+		})
+	}
+
+	// A configuration used with an injected AIClient needs no key
+	config := DefaultConfig()
+	config.AutoFix = true
+	if err := config.validate(true); err != nil {
+		t.Errorf("Expected no error with an AIClient of its own, got %v", err)
+	}
+}
+
+func TestConfigWarnings(t *testing.T) {
+	tests := []struct {
+		name     string
+		modify   func(c *Config)
+		warnings []string
+	}{
+		{"defaults", func(c *Config) {}, nil},
+		{"AI options with AI disabled", func(c *Config) {
+			c.OpenAIDisable = true
+			c.AILogRequests = true
+			c.OpenAIFallbacks = []string{"gpt-4o"}
+		}, []string{"-openai-model-fallbacks has no effect with -openai-disable", "-ai-log-requests has no effect with -openai-disable"}},
+		{"AI options without a key", func(c *Config) {
+			c.OpenAIAPIKey = ""
+			c.AILogRequests = true
+		}, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := DefaultConfig()
+			tt.modify(config)
+			if got := config.Warnings(); !reflect.DeepEqual(got, tt.warnings) {
+				t.Errorf("Expected warnings %q, got %q", tt.warnings, got)
+			}
+		})
+	}
+}
+
 func TestAnalyzeFile(t *testing.T) {
 	code := `
 package main
@@ -173,6 +488,599 @@ func test() *int {
 	}
 }
 
+// newTestPass writes code to a temporary file and builds a type-checked analysis
+// pass over it, collecting reported diagnostics
+func newTestPass(t *testing.T, code string) (*analysis.Pass, *[]analysis.Diagnostic) {
+	t.Helper()
+
+	filename := filepath.Join(t.TempDir(), "test.go")
+	if err := os.WriteFile(filename, []byte(code), 0644); err != nil {
+		t.Fatalf("Failed to write test file: %v", err)
+	}
+
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, filename, code, parser.ParseComments)
+	if err != nil {
+		t.Fatalf("Failed to parse code: %v", err)
+	}
+
+	info := &types.Info{
+		Types:     make(map[ast.Expr]types.TypeAndValue),
+		Defs:      make(map[*ast.Ident]types.Object),
+		Uses:      make(map[*ast.Ident]types.Object),
+		Instances: make(map[*ast.Ident]types.Instance),
+	}
+	typesConfig := &types.Config{Importer: importer.Default()}
+	pkg, err := typesConfig.Check("test", fset, []*ast.File{file}, info)
+	if err != nil {
+		t.Fatalf("Failed to type check code: %v", err)
+	}
+
+	var diagnostics []analysis.Diagnostic
+	pass := &analysis.Pass{
+		Analyzer:  Analyzer,
+		Fset:      fset,
+		Files:     []*ast.File{file},
+		Pkg:       pkg,
+		TypesInfo: info,
+		Report: func(d analysis.Diagnostic) {
+			diagnostics = append(diagnostics, d)
+		},
+	}
+	return pass, &diagnostics
+}
+
+func TestFailOnFix(t *testing.T) {
+	code := `package main
+
+func main() {
+	s := new(string)
+	_ = s
+}
+`
+	pass, _ := newTestPass(t, code)
+	filename := pass.Fset.File(pass.Files[0].Pos()).Name()
+
+	config := DefaultConfig()
+	config.FailOnFix = true
+
+	_, err := runWithDeps(pass, newAnalyzerOptions(WithAIClient(&MockAIClient{}), WithConfig(config)))
+	if err == nil {
+		t.Fatal("Expected -fail-on-fix to fail for a file needing a new(T) fix")
+	}
+
+	// The file must be left untouched
+	content, readErr := os.ReadFile(filename)
+	if readErr != nil {
+		t.Fatalf("Failed to read test file: %v", readErr)
+	}
+	if string(content) != code {
+		t.Errorf("Expected -fail-on-fix not to modify the file, got:\n%s", content)
+	}
+}
+
+func TestFailOnFixCleanFile(t *testing.T) {
+	pass, _ := newTestPass(t, "package main\n\nfunc main() {}\n")
+
+	config := DefaultConfig()
+	config.FailOnFix = true
+
+	if _, err := runWithDeps(pass, newAnalyzerOptions(WithAIClient(&MockAIClient{}), WithConfig(config))); err != nil {
+		t.Errorf("Expected no failure for a file without fixes, got %v", err)
+	}
+}
+
+func TestCheckPendingFixes(t *testing.T) {
+	fixTracker := NewFixTracker()
+	fixTracker.AddFix("b.go", []analysis.TextEdit{{Pos: 10, End: 20, NewText: []byte(`""`)}})
+	fixTracker.AddFix("a.go", []analysis.TextEdit{{Pos: 5, End: 8, NewText: []byte("0")}})
+
+	var out bytes.Buffer
+	if err := checkPendingFixes(fixTracker, &out); err == nil {
+		t.Error("Expected an error when fixes are pending")
+	}
+
+	if got := out.String(); got != "a.go\nb.go\n" {
+		t.Errorf("Expected sorted file list, got %q", got)
+	}
+
+	if err := checkPendingFixes(NewFixTracker(), &out); err != nil {
+		t.Errorf("Expected no error without fixes, got %v", err)
+	}
+}
+
+// scriptedConfirmer answers fix confirmations from a fixed list, then declines
+type scriptedConfirmer struct {
+	answers []bool
+	asked   []PendingFix
+}
+
+func (s *scriptedConfirmer) ConfirmFix(fix PendingFix) bool {
+	s.asked = append(s.asked, fix)
+	if len(s.answers) == 0 {
+		return false
+	}
+	answer := s.answers[0]
+	s.answers = s.answers[1:]
+	return answer
+}
+
+func TestAutoFixWithoutAI(t *testing.T) {
+	code := `package main
+
+func main() {
+	s := new(string)
+	*s = "x"
+	println(*s)
+}
+`
+	// AI disabled, and no AI client at all
+	for _, opts := range [][]Option{{WithAIClient(&MockAIClient{})}, nil} {
+		pass, _ := newTestPass(t, code)
+		filename := pass.Fset.File(pass.Files[0].Pos()).Name()
+
+		config := DefaultConfig()
+		config.AutoFix = true
+		config.OpenAIDisable = true
+		if _, err := runWithDeps(pass, newAnalyzerOptions(append(opts, WithConfig(config))...)); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		content, err := os.ReadFile(filename)
+		if err != nil {
+			t.Fatalf("Failed to read test file: %v", err)
+		}
+		if !strings.Contains(string(content), "\ts := \"\"\n\ts = \"x\"\n\tprintln(s)\n") {
+			t.Errorf("Expected new(string) to be rewritten without AI, got:\n%s", content)
+		}
+	}
+}
+
+func TestAutoFixWithMessageTemplates(t *testing.T) {
+	code := `package main
+
+func main() {
+	s := new(string)
+	*s = "x"
+	println(*s)
+}
+`
+	pass, _ := newTestPass(t, code)
+	filename := pass.Fset.File(pass.Files[0].Pos()).Name()
+
+	// The fix doesn't depend on the wording of the finding
+	config := DefaultConfig()
+	config.AutoFix = true
+	config.OpenAIDisable = true
+	config.MessageTemplates = map[string]string{"new-call": "new({{.Type}}) escapes"}
+	if _, err := runWithDeps(pass, newAnalyzerOptions(WithConfig(config))); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	content, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatalf("Failed to read test file: %v", err)
+	}
+	if !strings.Contains(string(content), "\ts := \"\"\n\ts = \"x\"\n\tprintln(s)\n") {
+		t.Errorf("Expected new(string) to be rewritten with a reworded message, got:\n%s", content)
+	}
+}
+
+func TestAutoFixNewTKeepsTypes(t *testing.T) {
+	tests := []struct {
+		name string
+		code string
+		want string
+	}{
+		{"only dereferenced", `func label() string {
+	s := new(string)
+	*s = "x"
+	return *s
+}`, "\ts := \"\"\n\ts = \"x\"\n\treturn s\n"},
+		{"returned", `func label() *string {
+	s := new(string)
+	*s = "x"
+	return s
+}`, "\tvar sValue string\n\ts := &sValue\n\t*s = \"x\"\n"},
+		{"passed", `func count() int {
+	n := new(int)
+	inc(n)
+	return *n
+}
+
+func inc(p *int) { *p++ }`, "\tvar nValue int\n\tn := &nValue\n"},
+		{"shadowed", `func count() int {
+	n := new(int)
+	*n = 1
+	if true {
+		n := 2
+		return n
+	}
+	return *n
+}`, "\tvar nValue int\n\tn := &nValue\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code := "package main\n\n" + tt.code + "\n"
+			pass, _ := newTestPass(t, code)
+			filename := pass.Fset.File(pass.Files[0].Pos()).Name()
+
+			config := DefaultConfig()
+			config.AutoFix = true
+			config.OpenAIDisable = true
+			if _, err := runWithDeps(pass, newAnalyzerOptions(WithConfig(config))); err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			content, err := os.ReadFile(filename)
+			if err != nil {
+				t.Fatalf("Failed to read test file: %v", err)
+			}
+			if !strings.Contains(string(content), tt.want) {
+				t.Errorf("Expected the fixed file to contain %q, got:\n%s", tt.want, content)
+			}
+
+			// The fixed file must still compile
+			fset := token.NewFileSet()
+			file, err := parser.ParseFile(fset, filename, content, 0)
+			if err != nil {
+				t.Fatalf("Failed to parse the fixed file: %v", err)
+			}
+			if _, err := (&types.Config{}).Check("main", fset, []*ast.File{file}, nil); err != nil {
+				t.Errorf("Expected the fixed file to type check, got %v:\n%s", err, content)
+			}
+		})
+	}
+}
+
+func TestShowFix(t *testing.T) {
+	code := `package main
+
+func main() {
+	s := new(string)
+	for i := 0; i < 3; i++ {
+		ch := make(chan int)
+		_ = ch
+	}
+	println(*s)
+}
+`
+	pass, diagnostics := newTestPass(t, code)
+	filename := pass.Fset.File(pass.Files[0].Pos()).Name()
+
+	config := DefaultConfig()
+	config.ShowFix = true
+	config.OpenAIDisable = true
+	if _, err := runWithDeps(pass, newAnalyzerOptions(WithConfig(config))); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	var fixed, unfixed int
+	for _, d := range *diagnostics {
+		switch {
+		case strings.Contains(d.Message, "new(T)"):
+			fixed++
+			if !strings.HasSuffix(d.Message, "\n\t→ replace with: \"\"\n\t→ replace with: s") {
+				t.Errorf("Expected the new(string) finding to show its replacement, got %q", d.Message)
+			}
+		case strings.Contains(d.Message, "channel created"):
+			unfixed++
+			if strings.Contains(d.Message, "→") {
+				t.Errorf("Expected no replacement for a finding without a fix, got %q", d.Message)
+			}
+		}
+	}
+	if fixed == 0 || unfixed != 1 {
+		t.Errorf("Expected findings both with and without a fix, got %d and %d: %v", fixed, unfixed, *diagnostics)
+	}
+
+	// The fix is only shown, never applied
+	if content, err := os.ReadFile(filename); err != nil || string(content) != code {
+		t.Errorf("Expected the file to be left unchanged, got %q (%v)", content, err)
+	}
+}
+
+func TestInteractiveAppliesConfirmedFixes(t *testing.T) {
+	code := `package main
+
+func main() {
+	s := new(string)
+	i := new(int)
+	_, _ = s, i
+}
+`
+	pass, _ := newTestPass(t, code)
+	filename := pass.Fset.File(pass.Files[0].Pos()).Name()
+
+	config := DefaultConfig()
+	config.Annotate = true
+	config.Interactive = true
+	confirmer := &scriptedConfirmer{answers: []bool{false, true}}
+
+	if _, err := runWithDeps(pass, newAnalyzerOptions(WithConfig(config), WithFixConfirmer(confirmer))); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	// One fix per annotated line, offered in source order
+	if len(confirmer.asked) != 2 {
+		t.Fatalf("Expected to be asked about 2 fixes, got %d: %+v", len(confirmer.asked), confirmer.asked)
+	}
+	if first := confirmer.asked[0]; first.Line != 4 || !strings.HasPrefix(first.Diff, "+\t// TODO(stackalloc): ") || strings.Contains(first.Diff, "\n-") {
+		t.Errorf("Expected the first fix to add comments above line 4, got line %d:\n%s", first.Line, first.Diff)
+	}
+
+	content, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatalf("Failed to read test file: %v", err)
+	}
+	lines := strings.Split(string(content), "\n")
+	if len(lines) < 5 || lines[3] != "\ts := new(string)" || !strings.Contains(lines[4], "TODO(stackalloc)") {
+		t.Errorf("Expected only the confirmed fix to be applied, got:\n%s", content)
+	}
+}
+
+func TestInitFindings(t *testing.T) {
+	code := `package main
+
+var defaults = make([]int, 2)
+
+var handlers []func()
+
+func init() {
+	p := new(int)
+	_ = p
+	handlers = append(handlers, func() {
+		q := new(string)
+		_ = q
+	})
+}
+
+func handle() {
+	r := new(float64)
+	_ = r
+}
+`
+	pass, _ := newTestPass(t, code)
+
+	lines := func(issues []Issue) map[int]Severity {
+		found := make(map[int]Severity)
+		for _, issue := range issues {
+			found[issue.Pos.Line] = issue.Severity
+		}
+		return found
+	}
+
+	// By default only the function literal and the normal function are reported,
+	// since the literal runs whenever it is called
+	found := lines(analyzeFile(pass.Files[0], pass.TypesInfo, pass.Fset, DefaultConfig()))
+	for _, line := range []int{3, 8} {
+		if _, ok := found[line]; ok {
+			t.Errorf("Expected the finding on line %d to be dropped, got %v", line, found)
+		}
+	}
+	for _, line := range []int{11, 17} {
+		if _, ok := found[line]; !ok {
+			t.Errorf("Expected a finding on line %d, got %v", line, found)
+		}
+	}
+
+	// -include-init reports them at info severity
+	config := DefaultConfig()
+	config.IncludeInit = true
+	found = lines(analyzeFile(pass.Files[0], pass.TypesInfo, pass.Fset, config))
+	for _, line := range []int{3, 8} {
+		if severity, ok := found[line]; !ok || severity != SeverityInfo {
+			t.Errorf("Expected an info finding on line %d, got %v", line, found)
+		}
+	}
+	if severity := found[17]; severity == SeverityInfo {
+		t.Errorf("Expected the normal function's finding to keep its severity, got %v", severity)
+	}
+}
+
+func TestAllowAllocDirective(t *testing.T) {
+	code := `package main
+
+// pool hands out buffers, which it has to allocate.
+//
+//stackalloc:allow-alloc buffers outlive the call
+func pool() *[]byte {
+	b := new([]byte)
+	go func() {
+		_ = new(int)
+	}()
+	return b
+}
+
+//stackalloc:allow-alloc
+func (s *server) start() {
+	s.p = new(int)
+}
+
+// Not a directive: stackalloc:allow-alloc
+func handle() {
+	r := new(float64)
+	_ = r
+}
+
+type server struct{ p *int }
+
+func other() {
+	q := new(string)
+	_ = q
+}
+`
+	pass, _ := newTestPass(t, code)
+
+	found := make(map[int]bool)
+	for _, issue := range analyzeFile(pass.Files[0], pass.TypesInfo, pass.Fset, DefaultConfig()) {
+		found[issue.Pos.Line] = true
+	}
+	for _, line := range []int{7, 9, 16} {
+		if found[line] {
+			t.Errorf("Expected no finding on line %d in a function marked allow-alloc, got %v", line, found)
+		}
+	}
+	for _, line := range []int{21, 28} {
+		if !found[line] {
+			t.Errorf("Expected a finding on line %d, got %v", line, found)
+		}
+	}
+}
+
+func TestAnalyzeCgoFile(t *testing.T) {
+	code := `
+package main
+
+// #include <stdlib.h>
+import "C"
+
+import "unsafe"
+
+func free(p unsafe.Pointer) {
+	release := func() { C.free(p) }
+	release()
+}
+`
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "cgo.go", code, parser.ParseComments)
+	if err != nil {
+		t.Fatalf("Failed to parse code: %v", err)
+	}
+
+	// Type checking a cgo file without the cgo tool leaves partial information behind
+	info := &types.Info{
+		Types: make(map[ast.Expr]types.TypeAndValue),
+		Defs:  make(map[*ast.Ident]types.Object),
+		Uses:  make(map[*ast.Ident]types.Object),
+	}
+	typesConfig := &types.Config{Importer: importer.Default(), Error: func(error) {}}
+	typesConfig.Check("test", fset, []*ast.File{file}, info)
+
+	core, logs := observer.New(zapcore.InfoLevel)
+	previous := internal.Logger
+	internal.Logger = zap.New(core)
+	defer func() { internal.Logger = previous }()
+
+	issues := analyzeFile(file, info, fset, DefaultConfig())
+	if len(issues) == 0 {
+		t.Error("Expected syntactic detectors to still run on the cgo file")
+	}
+	if logs.FilterMessage("Running only syntactic detectors on cgo file").Len() != 1 {
+		t.Errorf("Expected the syntactic-only decision to be logged, got %v", logs.All())
+	}
+
+	config := DefaultConfig()
+	config.SkipCgo = true
+	if issues := analyzeFile(file, info, fset, config); len(issues) != 0 {
+		t.Errorf("Expected -skip-cgo to skip the file, got %v", issues)
+	}
+	if logs.FilterMessage("Skipping cgo file").Len() != 1 {
+		t.Errorf("Expected the skip decision to be logged, got %v", logs.All())
+	}
+}
+
+func TestMaxFileSize(t *testing.T) {
+	const header = `package main
+
+func main() {
+	s := new(string)
+	_ = s
+}
+`
+	core, logs := observer.New(zapcore.InfoLevel)
+	previous := internal.Logger
+	internal.Logger = zap.New(core)
+	defer func() { internal.Logger = previous }()
+
+	config := DefaultConfig()
+	config.MaxFileSize = 1
+
+	tests := []struct {
+		name    string
+		size    int
+		skipped bool
+	}{
+		{"just under the limit", 1024, false},
+		{"just over the limit", 1025, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Pad the file with a comment to exactly tt.size bytes
+			code := header + "//" + strings.Repeat("x", tt.size-len(header)-3) + "\n"
+
+			fset := token.NewFileSet()
+			file, err := parser.ParseFile(fset, "big.go", code, parser.ParseComments)
+			if err != nil {
+				t.Fatalf("Failed to parse code: %v", err)
+			}
+			if size := fset.File(file.Pos()).Size(); size != tt.size {
+				t.Fatalf("Expected a %d-byte file, got %d bytes", tt.size, size)
+			}
+			info := &types.Info{
+				Types: make(map[ast.Expr]types.TypeAndValue),
+				Defs:  make(map[*ast.Ident]types.Object),
+				Uses:  make(map[*ast.Ident]types.Object),
+			}
+			if _, err := (&types.Config{Importer: importer.Default()}).Check("test", fset, []*ast.File{file}, info); err != nil {
+				t.Fatalf("Failed to type check code: %v", err)
+			}
+
+			skips := logs.FilterMessage("Skipping file larger than -max-file-size").Len()
+			files := analyzedFiles([]*ast.File{file}, fset, config, internal.GetLogger())
+			if skipped := len(files) == 0; skipped != tt.skipped {
+				t.Errorf("Expected skipped to be %v, got %d files", tt.skipped, len(files))
+			}
+			// Files are only filtered out, not skipped once being analyzed,
+			// so oversized files aren't counted as analyzed
+			if issues := analyzeFile(file, info, fset, config); len(issues) == 0 {
+				t.Error("Expected analyzeFile to analyze the file it is given")
+			}
+			if logged := logs.FilterMessage("Skipping file larger than -max-file-size").Len() > skips; logged != tt.skipped {
+				t.Errorf("Expected the skip to be logged only when skipping, got %v", logs.All())
+			}
+		})
+	}
+}
+
+func TestMissingTypesInfo(t *testing.T) {
+	code := `package main
+
+func main() {
+	s := new(string)
+	_ = s
+}
+`
+	const warning = "No type information for package; most detectors can't run, so results will be incomplete"
+
+	pass, _ := newTestPass(t, code)
+	core, logs := observer.New(zapcore.WarnLevel)
+	options := newAnalyzerOptions(WithLogger(zap.New(core)))
+	if _, err := runWithDeps(pass, options); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if logs.FilterMessage(warning).Len() != 0 {
+		t.Errorf("Expected no warning with type information, got %v", logs.All())
+	}
+
+	pass.TypesInfo = &types.Info{}
+	if _, err := runWithDeps(pass, options); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if logs.FilterMessage(warning).Len() != 1 {
+		t.Errorf("Expected a warning without type information, got %v", logs.All())
+	}
+
+	config := DefaultConfig()
+	config.RequireTypes = true
+	options = newAnalyzerOptions(WithConfig(config), WithLogger(zap.New(core)))
+	if _, err := runWithDeps(pass, options); err == nil || !strings.Contains(err.Error(), "no type information for package test") {
+		t.Errorf("Expected -require-types to fail without type information, got %v", err)
+	}
+}
+
 // Helper function to check if a string contains a substring
 func contains(s, substr string) bool {
 	return len(s) >= len(substr) &&
@@ -191,3 +1099,83 @@ func containsSubstring(s, substr string) bool {
 	}
 	return false
 }
+
+func TestIssueEndPositions(t *testing.T) {
+	code := `package main
+
+func main() {
+	s := new(string)
+	_ = s
+	m := make(map[string]int)
+	_ = m
+}
+`
+	pass, diagnostics := newTestPass(t, code)
+	issues := analyzeFile(pass.Files[0], pass.TypesInfo, pass.Fset, DefaultConfig())
+
+	tests := []struct {
+		contains string
+		line     int
+		col      int
+		endCol   int
+		node     string
+	}{
+		{"new(T) always allocates", 4, 7, 18, "CallExpr"},
+		{"make(map[K]V) without size hint", 6, 7, 27, "CallExpr"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.contains, func(t *testing.T) {
+			for _, issue := range issues {
+				if !contains(issue.Message, tt.contains) {
+					continue
+				}
+				if issue.Pos.Line != tt.line || issue.Pos.Column != tt.col || issue.End.Line != tt.line || issue.End.Column != tt.endCol {
+					t.Errorf("Expected %d:%d-%d:%d, got %s-%s", tt.line, tt.col, tt.line, tt.endCol, issue.Pos, issue.End)
+				}
+				if issue.Node != tt.node {
+					t.Errorf("Expected node kind %s, got %s", tt.node, issue.Node)
+				}
+				return
+			}
+			t.Errorf("Expected an issue containing %q, got %+v", tt.contains, issues)
+		})
+	}
+
+	// Ranges flow through to the reported diagnostics
+	if _, err := run(pass); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	for _, d := range *diagnostics {
+		if !contains(d.Message, "new(T) always allocates") {
+			continue
+		}
+		start, end := pass.Fset.Position(d.Pos), pass.Fset.Position(d.End)
+		if start.Line != 4 || start.Column != 7 || end.Column != 18 {
+			t.Errorf("Expected the diagnostic to span 4:7-4:18, got %s-%s", start, end)
+		}
+		return
+	}
+	t.Error("Expected a diagnostic for new(string)")
+}
+
+// TestSuggestedFixesApply runs the analyzer over testdata/src/fixes the way
+// gopls would and applies every suggested fix, checking the result against
+// fixes.go.golden
+func TestSuggestedFixesApply(t *testing.T) {
+	// testdata is a GOPATH tree, where -mod can't be set
+	t.Setenv("GOFLAGS", "")
+
+	config := DefaultConfig()
+	config.OnlyPatterns = []string{"append-spread-temp", "append-copy-idiom", "format-then-convert", "oversplit", "new-value-type"}
+	analysistest.RunWithSuggestedFixes(t, analysistest.TestData(), NewAnalyzerWithOptions(WithConfig(config)), "fixes")
+
+	// The AutoFixer's fixes, such as new(string) to "" with each *s rewritten,
+	// belong to findings without a rule name, which -only-patterns turns off.
+	// They are checked on a package without imports, since with every
+	// detector on the standard library would be analyzed too.
+	config = DefaultConfig()
+	config.IncludeFixes = true
+	config.OpenAIDisable = true
+	analysistest.RunWithSuggestedFixes(t, analysistest.TestData(), NewAnalyzerWithOptions(WithConfig(config)), "newcall")
+}
@@ -0,0 +1,65 @@
+package analyzer
+
+import (
+	"bytes"
+	"go/token"
+	"strings"
+
+	"golang.org/x/tools/go/analysis"
+)
+
+// annotationPrefix starts every comment inserted by -annotate
+const annotationPrefix = "// TODO(stackalloc): "
+
+// annotationFix returns a fix inserting a TODO comment for issue on its own line
+// above the finding, indented like the line it annotates. It returns false if
+// the source can't be read or the comment is already there from an earlier run.
+func annotationFix(issue Issue, fset *token.FileSet, sources *SourceCache) (analysis.SuggestedFix, bool) {
+	src, ok := sources.Read(issue.Pos.Filename)
+	if !ok || issue.Pos.Offset < 0 || issue.Pos.Offset > len(src) {
+		return analysis.SuggestedFix{}, false
+	}
+
+	lineStart := bytes.LastIndexByte(src[:issue.Pos.Offset], '\n') + 1
+	line := src[lineStart:]
+	indent := line[:len(line)-len(bytes.TrimLeft(line, " \t"))]
+	comment := annotationPrefix + strings.ReplaceAll(issue.Message, "\n", " ")
+
+	if hasAnnotation(src[:lineStart], comment) {
+		return analysis.SuggestedFix{}, false
+	}
+
+	pos := tokenPos(fset, token.Position{Filename: issue.Pos.Filename, Offset: lineStart, Line: issue.Pos.Line, Column: 1})
+	if !pos.IsValid() {
+		return analysis.SuggestedFix{}, false
+	}
+
+	text := make([]byte, 0, len(indent)+len(comment)+1)
+	text = append(text, indent...)
+	text = append(text, comment...)
+	text = append(text, '\n')
+
+	return analysis.SuggestedFix{
+		Message: "Annotate with a TODO comment",
+		TextEdits: []analysis.TextEdit{
+			{Pos: pos, End: pos, NewText: text},
+		},
+	}, true
+}
+
+// hasAnnotation reports whether comment is among the annotations directly
+// above the end of before, i.e. the block of TODO(stackalloc) lines an earlier
+// run inserted above the finding
+func hasAnnotation(before []byte, comment string) bool {
+	lines := strings.Split(strings.TrimSuffix(string(before), "\n"), "\n")
+	for i := len(lines) - 1; i >= 0; i-- {
+		line := strings.TrimSpace(lines[i])
+		if !strings.HasPrefix(line, annotationPrefix) {
+			return false
+		}
+		if line == comment {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,90 @@
+package analyzer
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"golang.org/x/tools/go/analysis"
+)
+
+func TestAnnotate(t *testing.T) {
+	code := `package main
+
+func main() {
+	s := new(string)
+	_ = s
+	if true {
+		n := new(int)
+		_ = n
+	}
+}
+`
+	pass, _ := newTestPass(t, code)
+	filename := pass.Fset.File(pass.Files[0].Pos()).Name()
+
+	config := DefaultConfig()
+	config.Annotate = true
+
+	if _, err := runWithDeps(pass, newAnalyzerOptions(WithAIClient(&MockAIClient{}), WithConfig(config))); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	content, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatalf("Failed to read annotated file: %v", err)
+	}
+	lines := strings.Split(string(content), "\n")
+
+	// Each finding gets a comment on the line above it, indented to match, and
+	// the code itself is left alone
+	tests := []struct {
+		stmt   string
+		indent string
+	}{
+		{"s := new(string)", "\t"},
+		{"n := new(int)", "\t\t"},
+	}
+	for _, tt := range tests {
+		i := 0
+		for i < len(lines) && lines[i] != tt.indent+tt.stmt {
+			i++
+		}
+		if i == len(lines) {
+			t.Fatalf("Expected %q to be left in place, got:\n%s", tt.stmt, content)
+		}
+		if want := tt.indent + "// TODO(stackalloc): new(T) always allocates on heap"; !strings.HasPrefix(lines[i-1], want) {
+			t.Errorf("Expected the line above %q to start with %q, got:\n%s", tt.stmt, want, content)
+		}
+	}
+	if got := strings.Count(string(content), "// TODO(stackalloc): "); got != 4 {
+		t.Errorf("Expected one comment per finding, got %d:\n%s", got, content)
+	}
+
+	// Running again over the annotated file must not duplicate the comments
+	pass, _ = newTestPass(t, string(content))
+	filename = pass.Fset.File(pass.Files[0].Pos()).Name()
+	if _, err := runWithDeps(pass, newAnalyzerOptions(WithAIClient(&MockAIClient{}), WithConfig(config))); err != nil {
+		t.Fatalf("Unexpected error on re-run: %v", err)
+	}
+
+	rerun, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatalf("Failed to read re-annotated file: %v", err)
+	}
+	if string(rerun) != string(content) {
+		t.Errorf("Expected re-run to leave the annotated file unchanged, got:\n%s", rerun)
+	}
+}
+
+func TestAddFixCombinesInsertions(t *testing.T) {
+	fixTracker := NewFixTracker()
+	fixTracker.AddFix("a.go", []analysis.TextEdit{{Pos: 10, End: 10, NewText: []byte("// a\n")}})
+	fixTracker.AddFix("a.go", []analysis.TextEdit{{Pos: 10, End: 10, NewText: []byte("// b\n")}})
+	fixTracker.AddFix("a.go", []analysis.TextEdit{{Pos: 10, End: 10, NewText: []byte("// b\n")}})
+
+	edits := fixTracker.fixes["a.go"]
+	if len(edits) != 1 || string(edits[0].NewText) != "// a\n// b\n" {
+		t.Errorf("Expected insertions at one position to be combined, got %+v", edits)
+	}
+}
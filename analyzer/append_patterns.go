@@ -0,0 +1,681 @@
+package analyzer
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+	pathpkg "path"
+	"strings"
+
+	"golang.org/x/tools/go/analysis"
+)
+
+// detectAppendSpreadTemp detects append(a, b...) where b is built only to be
+// appended: a slice literal, whose elements can be passed to append directly,
+// or make([]T, n, c). Since Go 1.11 the compiler recognizes
+// append(a, make([]T, n)...) and extends a in place without allocating the
+// temporary, so that form, the one a loop or a single grow would replace, is
+// already as cheap as it gets and is left alone. With a capacity argument,
+// which the append ignores anyway, the optimization doesn't apply, so that's
+// what gets flagged.
+func (pd *PatternDetector) detectAppendSpreadTemp(call *ast.CallExpr, report func(node ast.Node, msg string)) {
+	if !pd.config.IsPatternEnabled("append-spread-temp") || len(call.Args) != 2 || !call.Ellipsis.IsValid() {
+		return
+	}
+	dst, ok := pd.nodeSource(call.Args[0])
+	if !ok {
+		return
+	}
+
+	switch spread := ast.Unparen(call.Args[1]).(type) {
+	case *ast.CompositeLit:
+		if len(spread.Elts) == 0 || pd.getCompositeLiteralType(spread) != "slice" {
+			return
+		}
+		msg := fmt.Sprintf("append(%s, []T{...}...) builds a temporary slice just to append its elements; consider passing them to append directly", dst)
+
+		// Keyed elements can't be passed as arguments, and neither can
+		// literals whose type the slice literal lets them elide, as in
+		// []point{{1, 2}}, without spelling the type out
+		args := []string{dst}
+		for _, elt := range spread.Elts {
+			src, ok := pd.nodeSource(elt)
+			_, keyed := elt.(*ast.KeyValueExpr)
+			if lit, isLit := elt.(*ast.CompositeLit); keyed || (isLit && lit.Type == nil) || !ok {
+				pd.reportRule(report, "append-spread-temp", call, msg)
+				return
+			}
+			args = append(args, src)
+		}
+		fun, ok := pd.nodeSource(call.Fun)
+		if !ok {
+			pd.reportRule(report, "append-spread-temp", call, msg)
+			return
+		}
+		replacement := fmt.Sprintf("%s(%s)", fun, strings.Join(args, ", "))
+		pd.reportRule(report, "append-spread-temp", call, msg, analysis.SuggestedFix{
+			Message: fmt.Sprintf("Replace with %s", replacement),
+			TextEdits: []analysis.TextEdit{
+				{
+					Pos:     call.Pos(),
+					End:     call.End(),
+					NewText: []byte(replacement),
+				},
+			},
+		})
+
+	case *ast.CallExpr:
+		if !pd.isMakeCall(spread) || len(spread.Args) != 3 {
+			return
+		}
+		msg := fmt.Sprintf("append(%s, make(T, n, c)...) allocates a temporary slice; without the capacity argument the compiler extends %s in place instead", dst, dst)
+
+		// Drop ", c" from make(T, n, c)
+		pd.reportRule(report, "append-spread-temp", call, msg, analysis.SuggestedFix{
+			Message: "Remove the capacity argument",
+			TextEdits: []analysis.TextEdit{
+				{
+					Pos:     spread.Args[1].End(),
+					End:     spread.Args[2].End(),
+					NewText: nil,
+				},
+			},
+		})
+	}
+}
+
+// detectSubsliceAppendAlias detects append to a two-index subslice a[i:j] of a
+// slice a that is still used afterwards. The subslice keeps a's capacity, so
+// the append writes over a[j:] in place instead of allocating. The fix is a
+// full slice expression a[i:j:j], which makes the append copy.
+func (pd *PatternDetector) detectSubsliceAppendAlias(call *ast.CallExpr, report func(node ast.Node, msg string)) {
+	if !pd.config.IsPatternEnabled("subslice-append-alias") || len(call.Args) < 2 {
+		return
+	}
+	fn := pd.enclosingFunc()
+	if fn == nil {
+		return
+	}
+
+	dst := ast.Unparen(call.Args[0])
+	subslice, ok := dst.(*ast.SliceExpr)
+	if !ok {
+		ident, isIdent := dst.(*ast.Ident)
+		if !isIdent {
+			return
+		}
+		subslice = pd.subsliceDecl(fn, pd.info.ObjectOf(ident), call.Pos())
+	}
+	// a[:0] is the usual way to reuse a's storage on purpose
+	if subslice == nil || subslice.Slice3 || subslice.High == nil || pd.isZeroConst(subslice.High) {
+		return
+	}
+	parent, ok := ast.Unparen(subslice.X).(*ast.Ident)
+	if !ok {
+		return
+	}
+	obj := pd.info.ObjectOf(parent)
+	if _, isSlice := pd.info.TypeOf(parent).Underlying().(*types.Slice); !isSlice || pd.assignsTo(call, obj) || !usedAfter(fn, pd.info, obj, call.End()) {
+		return
+	}
+	expr, ok := pd.nodeSource(subslice)
+	if !ok {
+		return
+	}
+
+	target := expr
+	if ident, ok := dst.(*ast.Ident); ok {
+		target = fmt.Sprintf("%s, which is %s,", ident.Name, expr)
+	}
+	msg := fmt.Sprintf("append to %s writes into %s's backing array when it has spare capacity, overwriting elements of %s that are used later; use a full slice expression to make the append copy, or copy the subslice", target, parent.Name, parent.Name)
+	high, ok := pd.nodeSource(subslice.High)
+	if !ok || hasCall(subslice.High) {
+		pd.reportRule(report, "subslice-append-alias", call, msg)
+		return
+	}
+	pd.reportRule(report, "subslice-append-alias", call, msg, analysis.SuggestedFix{
+		Message: fmt.Sprintf("Limit the capacity of %s", expr),
+		TextEdits: []analysis.TextEdit{
+			{
+				Pos:     subslice.High.End(),
+				End:     subslice.High.End(),
+				NewText: []byte(":" + high),
+			},
+		},
+	})
+}
+
+// subsliceDecl returns the slice expression obj is declared with in fn, if it
+// is one and obj isn't assigned again before pos
+func (pd *PatternDetector) subsliceDecl(fn ast.Node, obj types.Object, pos token.Pos) *ast.SliceExpr {
+	if !isLocalVar(obj) {
+		return nil
+	}
+
+	var decl *ast.SliceExpr
+	reassigned := false
+	ast.Inspect(fn, func(n ast.Node) bool {
+		assign, ok := n.(*ast.AssignStmt)
+		if !ok || reassigned {
+			return !reassigned
+		}
+		for i, lhs := range assign.Lhs {
+			ident, ok := lhs.(*ast.Ident)
+			if !ok || pd.info.ObjectOf(ident) != obj {
+				continue
+			}
+			if ident.Pos() == obj.Pos() && len(assign.Lhs) == len(assign.Rhs) {
+				decl, _ = ast.Unparen(assign.Rhs[i]).(*ast.SliceExpr)
+			} else if assign.End() < pos {
+				reassigned = true
+			}
+		}
+		return true
+	})
+	if reassigned {
+		return nil
+	}
+	return decl
+}
+
+// assignsTo reports whether the result of call is assigned straight back to
+// obj, as in a = append(a[:i], a[i+1:]...), which deletes from a on purpose
+func (pd *PatternDetector) assignsTo(call *ast.CallExpr, obj types.Object) bool {
+	assign, ok := pd.ancestor(0).(*ast.AssignStmt)
+	if !ok || len(assign.Lhs) != len(assign.Rhs) {
+		return false
+	}
+	for i, rhs := range assign.Rhs {
+		if ident, ok := assign.Lhs[i].(*ast.Ident); ok && rhs == call {
+			return pd.info.ObjectOf(ident) == obj
+		}
+	}
+	return false
+}
+
+// isZeroConst reports whether expr is the constant 0
+func (pd *PatternDetector) isZeroConst(expr ast.Expr) bool {
+	n, ok := pd.constInt(expr)
+	return ok && n == 0
+}
+
+// usedAfter reports whether obj is read anywhere in fn after pos. Being
+// assigned a new value doesn't count.
+func usedAfter(fn ast.Node, info *types.Info, obj types.Object, pos token.Pos) bool {
+	assigned := make(map[*ast.Ident]bool)
+	used := false
+	ast.Inspect(fn, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.AssignStmt:
+			for _, lhs := range n.Lhs {
+				if ident, ok := lhs.(*ast.Ident); ok {
+					assigned[ident] = true
+				}
+			}
+		case *ast.Ident:
+			if n.Pos() > pos && !assigned[n] && info.Uses[n] == obj {
+				used = true
+			}
+		}
+		return !used
+	})
+	return used
+}
+
+// hasCall reports whether expr contains a call, which mustn't be duplicated
+func hasCall(expr ast.Expr) bool {
+	found := false
+	ast.Inspect(expr, func(n ast.Node) bool {
+		if _, ok := n.(*ast.CallExpr); ok {
+			found = true
+		}
+		return !found
+	})
+	return found
+}
+
+// detectAppendCopyIdiom detects append(x, s...) where x is an empty slice:
+// []T(nil), []T{} or s[:0:0]. It's the usual way to copy s, but it allocates,
+// and the copy isn't always needed. When the file already imports slices
+// (Go 1.21+) and the types match, the fix is slices.Clone(s), which says what
+// it does.
+func (pd *PatternDetector) detectAppendCopyIdiom(call *ast.CallExpr, report func(node ast.Node, msg string)) {
+	if !pd.config.IsPatternEnabled("append-copy-idiom") || len(call.Args) != 2 || !call.Ellipsis.IsValid() || !pd.isEmptySlice(call.Args[0]) {
+		return
+	}
+	dst, ok := pd.nodeSource(call.Args[0])
+	if !ok {
+		return
+	}
+	src, ok := pd.nodeSource(call.Args[1])
+	if !ok {
+		return
+	}
+
+	msg := fmt.Sprintf("append(%s, %s...) allocates a copy of %s; if the copy is needed, slices.Clone(%s) (Go 1.21+) says so more clearly, otherwise use %s directly", dst, src, src, src, src)
+	slicesPkg, ok := pd.importName("slices")
+	if !ok || !types.Identical(pd.info.TypeOf(call), pd.info.TypeOf(call.Args[1])) {
+		pd.reportRule(report, "append-copy-idiom", call, msg)
+		return
+	}
+
+	replacement := fmt.Sprintf("%s.Clone(%s)", slicesPkg, src)
+	pd.reportRule(report, "append-copy-idiom", call, msg, analysis.SuggestedFix{
+		Message: fmt.Sprintf("Replace with %s", replacement),
+		TextEdits: []analysis.TextEdit{
+			{
+				Pos:     call.Pos(),
+				End:     call.End(),
+				NewText: []byte(replacement),
+			},
+		},
+	})
+}
+
+// detectMakeCopyClone detects the make-then-copy idiom for cloning a slice:
+//
+//	tmp := make([]T, len(src))
+//	copy(tmp, src)
+//
+// When the file already imports slices (Go 1.21+) and the types match, the
+// fix is tmp := slices.Clone(src). If neither slice is written to for the rest
+// of the function, the copy may not be needed at all.
+func (pd *PatternDetector) detectMakeCopyClone(block *ast.BlockStmt, report func(node ast.Node, msg string)) {
+	if !pd.config.IsPatternEnabled("make-copy-clone") {
+		return
+	}
+
+	for i := 0; i+1 < len(block.List); i++ {
+		assign, ok := block.List[i].(*ast.AssignStmt)
+		if !ok || len(assign.Lhs) != 1 || len(assign.Rhs) != 1 || (assign.Tok != token.DEFINE && assign.Tok != token.ASSIGN) {
+			continue
+		}
+		dst, ok := assign.Lhs[0].(*ast.Ident)
+		if !ok || dst.Name == "_" {
+			continue
+		}
+		copyStmt := block.List[i+1]
+		src := pd.clonedSlice(assign.Rhs[0], dst, copyStmt)
+		if src == nil {
+			continue
+		}
+
+		msg := fmt.Sprintf("make and copy clone %s into %s; slices.Clone(%s) (Go 1.21+) does the same in one call", src.Name, dst.Name, src.Name)
+		if fn := pd.enclosingFunc(); fn != nil && !pd.sliceWrittenIn(fn, pd.info.ObjectOf(dst), assign, copyStmt) && !pd.sliceWrittenIn(fn, pd.info.ObjectOf(src), assign, copyStmt) {
+			msg = fmt.Sprintf("make and copy clone %s into %s, but neither is written to or passed on elsewhere in the function; the copy may be avoidable by using %s directly, otherwise slices.Clone(%s) (Go 1.21+) says what it does", src.Name, dst.Name, src.Name, src.Name)
+		}
+
+		slicesPkg, ok := pd.importName("slices")
+		if !ok || !types.Identical(pd.info.TypeOf(dst), pd.info.TypeOf(src)) {
+			pd.reportRule(report, "make-copy-clone", assign, msg)
+			continue
+		}
+		clone := &ast.AssignStmt{
+			Lhs: []ast.Expr{ast.NewIdent(dst.Name)},
+			Tok: assign.Tok,
+			Rhs: []ast.Expr{&ast.CallExpr{
+				Fun:  &ast.SelectorExpr{X: ast.NewIdent(slicesPkg), Sel: ast.NewIdent("Clone")},
+				Args: []ast.Expr{ast.NewIdent(src.Name)},
+			}},
+		}
+		edit, ok := pd.replaceStmts(assign, copyStmt, clone)
+		if !ok {
+			pd.reportRule(report, "make-copy-clone", assign, msg)
+			continue
+		}
+		pd.reportRule(report, "make-copy-clone", assign, msg, analysis.SuggestedFix{
+			Message:   fmt.Sprintf("Replace with %s.Clone(%s)", slicesPkg, src.Name),
+			TextEdits: []analysis.TextEdit{edit},
+		})
+	}
+}
+
+// clonedSlice returns the slice variable that rhs and next clone into dst,
+// when rhs is make([]T, len(src)) or make([]T, len(src), len(src)) and next
+// is copy(dst, src) with src a slice, or nil if they aren't
+func (pd *PatternDetector) clonedSlice(rhs ast.Expr, dst *ast.Ident, next ast.Stmt) *ast.Ident {
+	call, ok := ast.Unparen(rhs).(*ast.CallExpr)
+	if !ok || !pd.isBuiltinCall(call, "make") || len(call.Args) < 2 {
+		return nil
+	}
+	src := pd.lenOf(call.Args[1])
+	if src == nil {
+		return nil
+	}
+	if len(call.Args) == 3 {
+		if capOf := pd.lenOf(call.Args[2]); capOf == nil || pd.info.ObjectOf(capOf) != pd.info.ObjectOf(src) {
+			return nil
+		}
+	}
+
+	stmt, ok := next.(*ast.ExprStmt)
+	if !ok {
+		return nil
+	}
+	cp, ok := stmt.X.(*ast.CallExpr)
+	if !ok || !pd.isBuiltinCall(cp, "copy") || len(cp.Args) != 2 {
+		return nil
+	}
+	to, ok := ast.Unparen(cp.Args[0]).(*ast.Ident)
+	if !ok || pd.info.ObjectOf(to) != pd.info.ObjectOf(dst) {
+		return nil
+	}
+	from, ok := ast.Unparen(cp.Args[1]).(*ast.Ident)
+	if !ok || pd.info.ObjectOf(from) != pd.info.ObjectOf(src) {
+		return nil
+	}
+	// copy also copies a string into a []byte, which slices.Clone can't do
+	if t := pd.info.TypeOf(from); t == nil {
+		return nil
+	} else if _, ok := t.Underlying().(*types.Slice); !ok {
+		return nil
+	}
+	return from
+}
+
+// lenOf returns the variable in len(x), or nil if expr isn't a call of len on
+// a variable
+func (pd *PatternDetector) lenOf(expr ast.Expr) *ast.Ident {
+	call, ok := ast.Unparen(expr).(*ast.CallExpr)
+	if !ok || !pd.isBuiltinCall(call, "len") || len(call.Args) != 1 {
+		return nil
+	}
+	ident, ok := ast.Unparen(call.Args[0]).(*ast.Ident)
+	if !ok {
+		return nil
+	}
+	if _, ok := pd.info.ObjectOf(ident).(*types.Var); !ok {
+		return nil
+	}
+	return ident
+}
+
+// detectManualGrow detects a slice grown by hand, by making one of twice its
+// length or capacity and copying it over:
+//
+//	tmp := make([]T, len(s), 2*cap(s))
+//	copy(tmp, s)
+//	s = tmp
+//
+// append already grows the backing array by amortized doubling when it runs
+// out of room, and slices.Grow (Go 1.21+) reserves room for a known number of
+// elements in one step.
+func (pd *PatternDetector) detectManualGrow(block *ast.BlockStmt, report func(node ast.Node, msg string)) {
+	if !pd.config.IsPatternEnabled("manual-grow") {
+		return
+	}
+
+	for i := 0; i+1 < len(block.List); i++ {
+		assign, ok := block.List[i].(*ast.AssignStmt)
+		if !ok || len(assign.Lhs) != 1 || len(assign.Rhs) != 1 || (assign.Tok != token.DEFINE && assign.Tok != token.ASSIGN) {
+			continue
+		}
+		dst, ok := assign.Lhs[0].(*ast.Ident)
+		if !ok || dst.Name == "_" {
+			continue
+		}
+		src := pd.grownSlice(assign.Rhs[0], dst, block.List[i+1])
+		if src == nil {
+			continue
+		}
+
+		name := types.ExprString(src)
+		pd.reportRule(report, "manual-grow", assign, fmt.Sprintf("make and copy grow %s by hand into %s, twice its size; append(%s, ...) already grows the backing array by doubling when it runs out of room, and slices.Grow(%s, n) (Go 1.21+) reserves room for n more elements up front", name, dst.Name, name, name))
+	}
+}
+
+// grownSlice returns the slice that rhs and next grow into dst, when rhs is
+// make([]T, n, c) or make([]T, n) whose last size doubles the length or
+// capacity of a slice, as in 2*cap(s), and next is copy(dst, s), or nil if
+// they don't
+func (pd *PatternDetector) grownSlice(rhs ast.Expr, dst *ast.Ident, next ast.Stmt) ast.Expr {
+	call, ok := ast.Unparen(rhs).(*ast.CallExpr)
+	if !ok || !pd.isBuiltinCall(call, "make") || len(call.Args) < 2 {
+		return nil
+	}
+	src := pd.doubledSize(call.Args[len(call.Args)-1])
+	if src == nil {
+		return nil
+	}
+
+	stmt, ok := next.(*ast.ExprStmt)
+	if !ok {
+		return nil
+	}
+	cp, ok := stmt.X.(*ast.CallExpr)
+	if !ok || !pd.isBuiltinCall(cp, "copy") || len(cp.Args) != 2 {
+		return nil
+	}
+	to, ok := ast.Unparen(cp.Args[0]).(*ast.Ident)
+	if !ok || pd.info.ObjectOf(to) != pd.info.ObjectOf(dst) {
+		return nil
+	}
+	from := ast.Unparen(cp.Args[1])
+	root, srcRoot := fieldRoot(from), fieldRoot(src)
+	if root == nil || srcRoot == nil || pd.info.ObjectOf(root) != pd.info.ObjectOf(srcRoot) || types.ExprString(from) != types.ExprString(src) {
+		return nil
+	}
+	return from
+}
+
+// doubledSize returns the slice whose length or capacity size doubles, as in
+// 2*len(s), cap(s)*2 or cap(s)<<1, possibly plus a constant, or nil if it
+// doesn't
+func (pd *PatternDetector) doubledSize(size ast.Expr) ast.Expr {
+	bin, ok := ast.Unparen(size).(*ast.BinaryExpr)
+	if !ok {
+		return nil
+	}
+	switch bin.Op {
+	case token.ADD:
+		if _, ok := pd.constInt(bin.Y); ok {
+			return pd.doubledSize(bin.X)
+		}
+		if _, ok := pd.constInt(bin.X); ok {
+			return pd.doubledSize(bin.Y)
+		}
+	case token.MUL:
+		if n, ok := pd.constInt(bin.X); ok && n == 2 {
+			return pd.lenOrCapOf(bin.Y)
+		}
+		if n, ok := pd.constInt(bin.Y); ok && n == 2 {
+			return pd.lenOrCapOf(bin.X)
+		}
+	case token.SHL:
+		if n, ok := pd.constInt(bin.Y); ok && n == 1 {
+			return pd.lenOrCapOf(bin.X)
+		}
+	}
+	return nil
+}
+
+// lenOrCapOf returns the variable or field x in len(x) or cap(x), or nil if
+// expr isn't such a call
+func (pd *PatternDetector) lenOrCapOf(expr ast.Expr) ast.Expr {
+	call, ok := ast.Unparen(expr).(*ast.CallExpr)
+	if !ok || len(call.Args) != 1 || !(pd.isBuiltinCall(call, "len") || pd.isBuiltinCall(call, "cap")) {
+		return nil
+	}
+	x := ast.Unparen(call.Args[0])
+	if fieldRoot(x) == nil {
+		return nil
+	}
+	return x
+}
+
+// sliceWrittenIn reports whether the slice variable obj may be written to
+// within node, outside the statements in skip: assigned, written through an
+// index, appended or copied to, or passed on anywhere it could be written to
+// later, such as to a function, a return or another variable. Only reads of
+// its elements, its length, and uses as the source of copy, append or range
+// leave it untouched.
+func (pd *PatternDetector) sliceWrittenIn(node ast.Node, obj types.Object, skip ...ast.Node) bool {
+	if obj == nil {
+		return true
+	}
+	root := func(expr ast.Expr) bool {
+		for {
+			switch e := expr.(type) {
+			case *ast.ParenExpr:
+				expr = e.X
+			case *ast.IndexExpr:
+				expr = e.X
+			case *ast.SliceExpr:
+				expr = e.X
+			case *ast.SelectorExpr:
+				expr = e.X
+			case *ast.StarExpr:
+				expr = e.X
+			case *ast.Ident:
+				return pd.info.ObjectOf(e) == obj
+			default:
+				return false
+			}
+		}
+	}
+	read := make(map[ast.Expr]bool)
+	readIdent := func(expr ast.Expr) {
+		if ident, ok := ast.Unparen(expr).(*ast.Ident); ok {
+			read[ident] = true
+		}
+	}
+
+	written := false
+	ast.Inspect(node, func(n ast.Node) bool {
+		if written {
+			return false
+		}
+		for _, s := range skip {
+			if n == s {
+				return false
+			}
+		}
+
+		switch e := n.(type) {
+		case *ast.AssignStmt:
+			for _, lhs := range e.Lhs {
+				written = written || (e.Tok != token.DEFINE && root(lhs))
+			}
+		case *ast.IncDecStmt:
+			written = root(e.X)
+		case *ast.UnaryExpr:
+			written = e.Op == token.AND && root(e.X)
+		case *ast.CallExpr:
+			switch {
+			case pd.isBuiltinCall(e, "len") || pd.isBuiltinCall(e, "cap"):
+				for _, arg := range e.Args {
+					readIdent(arg)
+				}
+			case pd.isBuiltinCall(e, "copy") && len(e.Args) == 2:
+				readIdent(e.Args[1])
+			case pd.isBuiltinCall(e, "append") && e.Ellipsis.IsValid() && len(e.Args) == 2:
+				readIdent(e.Args[1])
+			}
+		case *ast.IndexExpr:
+			readIdent(e.X)
+		case *ast.RangeStmt:
+			readIdent(e.X)
+		case *ast.BinaryExpr:
+			readIdent(e.X)
+			readIdent(e.Y)
+		case *ast.Ident:
+			written = !read[e] && pd.info.Uses[e] == obj
+		}
+		return !written
+	})
+	return written
+}
+
+// detectAppendBoxing detects concrete values appended to a slice with an
+// interface element type, such as append(out, n) for out []any. Each value is
+// boxed on the way in, allocating on every call in addition to whatever the
+// append itself costs.
+func (pd *PatternDetector) detectAppendBoxing(call *ast.CallExpr, report func(node ast.Node, msg string)) {
+	if !pd.config.IsPatternEnabled("append-boxing") || call.Ellipsis.IsValid() {
+		return
+	}
+	container := pd.info.TypeOf(call.Args[0])
+	if container == nil {
+		return
+	}
+	slice, ok := container.Underlying().(*types.Slice)
+	if !ok || !types.IsInterface(slice.Elem()) {
+		return
+	}
+
+	var values []types.Type
+	for _, arg := range call.Args[1:] {
+		if value, ok := pd.boxedType(arg); ok {
+			values = append(values, value)
+		}
+	}
+	if len(values) == 0 {
+		return
+	}
+
+	qualifier := types.RelativeTo(namedPkg(append(values, container)...))
+	var boxed []string
+	seen := make(map[string]bool)
+	for _, value := range values {
+		if name := types.TypeString(value, qualifier); !seen[name] {
+			seen[name] = true
+			boxed = append(boxed, name)
+		}
+	}
+
+	each := "call"
+	if pd.isInLoop(call) {
+		each = "iteration"
+	}
+	msg := fmt.Sprintf("appending %s to %s boxes each value, allocating on every %s", strings.Join(boxed, " and "), types.TypeString(container, qualifier), each)
+	if len(boxed) == 1 {
+		msg += fmt.Sprintf("; consider %s as the element type if every value has that type", boxed[0])
+	}
+	pd.reportRule(report, "append-boxing", call, msg)
+}
+
+// isEmptySlice reports whether expr is an empty slice with no capacity,
+// spelled []T(nil), []T{} or s[:0:0]
+func (pd *PatternDetector) isEmptySlice(expr ast.Expr) bool {
+	switch e := ast.Unparen(expr).(type) {
+	case *ast.CallExpr:
+		tv, ok := pd.info.Types[e.Fun]
+		if !ok || !tv.IsType() || len(e.Args) != 1 {
+			return false
+		}
+		_, isSlice := tv.Type.Underlying().(*types.Slice)
+		arg, ok := pd.info.Types[e.Args[0]]
+		return isSlice && ok && arg.IsNil()
+	case *ast.CompositeLit:
+		return pd.getCompositeLiteralType(e) == "slice" && len(e.Elts) == 0
+	case *ast.SliceExpr:
+		return e.Slice3 && e.Low == nil && pd.isZeroConst(e.High) && pd.isZeroConst(e.Max)
+	}
+	return false
+}
+
+// importName returns the name the file being inspected imports the package
+// at path under, or false if it doesn't import it
+func (pd *PatternDetector) importName(path string) (string, bool) {
+	if len(pd.stack) == 0 {
+		return "", false
+	}
+	file, ok := pd.stack[0].(*ast.File)
+	if !ok {
+		return "", false
+	}
+	for _, imp := range file.Imports {
+		if strings.Trim(imp.Path.Value, "\"`") != path {
+			continue
+		}
+		if imp.Name == nil {
+			return pathpkg.Base(path), true
+		}
+		if imp.Name.Name == "_" || imp.Name.Name == "." {
+			return "", false
+		}
+		return imp.Name.Name, true
+	}
+	return "", false
+}
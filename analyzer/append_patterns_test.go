@@ -0,0 +1,426 @@
+package analyzer
+
+import "testing"
+
+func TestAppendSpreadTemp(t *testing.T) {
+	code := `package main
+
+type point struct{ x, y int }
+
+func extend(a []int, x, y, n int) []int {
+	a = append(a, []int{x, y}...)
+	a = append(a, make([]int, n, 2*n)...)
+	a = append(a, make([]int, n)...)
+	a = append(a, []int{2: x}...)
+	a = append(a, []int{}...)
+	b := []int{x}
+	return append(a, b...)
+}
+
+func extendPoints(ps []point, x, y int) []point {
+	return append(ps, []point{{x, y}}...)
+}
+`
+	pass, _ := newTestPass(t, code)
+
+	var found []Issue
+	for _, issue := range analyzeFile(pass.Files[0], pass.TypesInfo, pass.Fset, DefaultConfig()) {
+		if issue.Pattern == "append-spread-temp" {
+			found = append(found, issue)
+		}
+	}
+
+	expected := []struct {
+		line  int
+		msg   string
+		fixed string
+	}{
+		{6, "append(a, []T{...}...) builds a temporary slice just to append its elements; consider passing them to append directly", "a = append(a, x, y)"},
+		{7, "append(a, make(T, n, c)...) allocates a temporary slice; without the capacity argument the compiler extends a in place instead", "a = append(a, make([]int, n)...)"},
+		{9, "append(a, []T{...}...) builds a temporary slice just to append its elements; consider passing them to append directly", ""},
+		{16, "append(ps, []T{...}...) builds a temporary slice just to append its elements; consider passing them to append directly", ""},
+	}
+	if len(found) != len(expected) {
+		t.Fatalf("Expected %d append-spread-temp issues, got %d: %v", len(expected), len(found), found)
+	}
+
+	lines := splitLines([]byte(code))
+	for i, want := range expected {
+		issue := found[i]
+		if issue.Pos.Line != want.line || issue.Message != want.msg {
+			t.Errorf("Expected %q on line %d, got %q on line %d", want.msg, want.line, issue.Message, issue.Pos.Line)
+		}
+
+		if want.fixed == "" {
+			if len(issue.SuggestedFixes) != 0 {
+				t.Errorf("Expected no fix for keyed or elided elements, got %v", issue.SuggestedFixes)
+			}
+			continue
+		}
+		if len(issue.SuggestedFixes) != 1 || len(issue.SuggestedFixes[0].TextEdits) != 1 {
+			t.Fatalf("Expected a single-edit fix, got %v", issue.SuggestedFixes)
+		}
+		edit := issue.SuggestedFixes[0].TextEdits[0]
+		start, end := pass.Fset.Position(edit.Pos), pass.Fset.Position(edit.End)
+		fixed := code[:start.Offset] + string(edit.NewText) + code[end.Offset:]
+		if got := splitLines([]byte(fixed))[want.line-1]; got != "\t"+want.fixed {
+			t.Errorf("Expected line %d to become %q, got %q (was %q)", want.line, want.fixed, got, lines[want.line-1])
+		}
+	}
+}
+
+func TestSubsliceAppendAlias(t *testing.T) {
+	code := `package main
+
+func alias(a []int, x int) ([]int, []int) {
+	b := a[2:4]
+	b = append(b, x)
+	return a, b
+}
+
+func direct(a []int, x int) int {
+	c := append(a[1:3], x)
+	return a[3] + c[0]
+}
+
+func unused(a []int, x int) []int {
+	return append(a[1:3], x)
+}
+
+func fullSlice(a []int, x int) ([]int, []int) {
+	return append(a[2:4:4], x), a
+}
+
+func remove(a []int, i int) []int {
+	a = append(a[:i], a[i+1:]...)
+	return a
+}
+
+func filter(a []int) []int {
+	kept := a[:0]
+	for _, x := range a {
+		if x > 0 {
+			kept = append(kept, x)
+		}
+	}
+	return kept
+}
+`
+	pass, _ := newTestPass(t, code)
+	config := DefaultConfig()
+	config.EnablePatterns = []string{"subslice-append-alias"}
+
+	var found []Issue
+	for _, issue := range analyzeFile(pass.Files[0], pass.TypesInfo, pass.Fset, config) {
+		if issue.Pattern == "subslice-append-alias" {
+			found = append(found, issue)
+		}
+	}
+
+	expected := []struct {
+		line    int
+		msg     string
+		fixLine int
+		fixed   string
+	}{
+		{5, "append to b, which is a[2:4], writes into a's backing array", 4, "b := a[2:4:4]"},
+		{10, "append to a[1:3] writes into a's backing array", 10, "c := append(a[1:3:3], x)"},
+	}
+	if len(found) != len(expected) {
+		t.Fatalf("Expected %d subslice-append-alias issues, got %d: %v", len(expected), len(found), found)
+	}
+	for i, want := range expected {
+		issue := found[i]
+		if issue.Pos.Line != want.line || !contains(issue.Message, want.msg) {
+			t.Errorf("Expected %q on line %d, got %q on line %d", want.msg, want.line, issue.Message, issue.Pos.Line)
+		}
+		if len(issue.SuggestedFixes) != 1 || len(issue.SuggestedFixes[0].TextEdits) != 1 {
+			t.Fatalf("Expected a single-edit fix, got %v", issue.SuggestedFixes)
+		}
+		edit := issue.SuggestedFixes[0].TextEdits[0]
+		start, end := pass.Fset.Position(edit.Pos), pass.Fset.Position(edit.End)
+		fixed := code[:start.Offset] + string(edit.NewText) + code[end.Offset:]
+		if got := splitLines([]byte(fixed))[want.fixLine-1]; got != "\t"+want.fixed {
+			t.Errorf("Expected line %d to become %q, got %q", want.fixLine, want.fixed, got)
+		}
+	}
+
+	for _, issue := range analyzeFile(pass.Files[0], pass.TypesInfo, pass.Fset, DefaultConfig()) {
+		if issue.Pattern == "subslice-append-alias" {
+			t.Errorf("Expected subslice-append-alias to be off by default, got %q", issue.Message)
+		}
+	}
+}
+
+func TestAppendCopyIdiom(t *testing.T) {
+	code := `package main
+
+import "slices"
+
+type IDs []int
+
+func copies(a []int, ids IDs, s []string) {
+	b := append([]int(nil), a...)
+	c := append([]int{}, a...)
+	d := append(a[:0:0], a...)
+	e := append([]int(nil), ids...)
+	f := append(s[:0], s...)
+	g := append([]string(nil), "x")
+	_, _, _, _, _, _ = b, c, d, e, f, g
+	_ = slices.Clone(a)
+}
+`
+	pass, _ := newTestPass(t, code)
+
+	var found []Issue
+	for _, issue := range analyzeFile(pass.Files[0], pass.TypesInfo, pass.Fset, DefaultConfig()) {
+		if issue.Pattern == "append-copy-idiom" {
+			found = append(found, issue)
+		}
+	}
+
+	expected := []struct {
+		line  int
+		msg   string
+		fixed string
+	}{
+		{8, "append([]int(nil), a...) allocates a copy of a; if the copy is needed, slices.Clone(a) (Go 1.21+) says so more clearly, otherwise use a directly", "b := slices.Clone(a)"},
+		{9, "append([]int{}, a...) allocates a copy of a; if the copy is needed, slices.Clone(a) (Go 1.21+) says so more clearly, otherwise use a directly", "c := slices.Clone(a)"},
+		{10, "append(a[:0:0], a...) allocates a copy of a; if the copy is needed, slices.Clone(a) (Go 1.21+) says so more clearly, otherwise use a directly", "d := slices.Clone(a)"},
+		// slices.Clone(ids) would be an IDs, not a []int
+		{11, "append([]int(nil), ids...) allocates a copy of ids; if the copy is needed, slices.Clone(ids) (Go 1.21+) says so more clearly, otherwise use ids directly", ""},
+	}
+	if len(found) != len(expected) {
+		t.Fatalf("Expected %d append-copy-idiom issues, got %d: %v", len(expected), len(found), found)
+	}
+
+	for i, want := range expected {
+		issue := found[i]
+		if issue.Pos.Line != want.line || issue.Message != want.msg {
+			t.Errorf("Expected %q on line %d, got %q on line %d", want.msg, want.line, issue.Message, issue.Pos.Line)
+		}
+
+		if want.fixed == "" {
+			if len(issue.SuggestedFixes) != 0 {
+				t.Errorf("Expected no fix on line %d, got %v", want.line, issue.SuggestedFixes)
+			}
+			continue
+		}
+		if len(issue.SuggestedFixes) != 1 || len(issue.SuggestedFixes[0].TextEdits) != 1 {
+			t.Fatalf("Expected a single-edit fix, got %v", issue.SuggestedFixes)
+		}
+		edit := issue.SuggestedFixes[0].TextEdits[0]
+		start, end := pass.Fset.Position(edit.Pos), pass.Fset.Position(edit.End)
+		fixed := code[:start.Offset] + string(edit.NewText) + code[end.Offset:]
+		if got := splitLines([]byte(fixed))[want.line-1]; got != "\t"+want.fixed {
+			t.Errorf("Expected line %d to become %q, got %q", want.line, want.fixed, got)
+		}
+	}
+
+	// Without a slices import there's nothing to suggest a fix with
+	pass, _ = newTestPass(t, `package main
+
+func copies(a []int) []int {
+	return append([]int(nil), a...)
+}
+`)
+	found = nil
+	for _, issue := range analyzeFile(pass.Files[0], pass.TypesInfo, pass.Fset, DefaultConfig()) {
+		if issue.Pattern == "append-copy-idiom" {
+			found = append(found, issue)
+		}
+	}
+	if len(found) != 1 || len(found[0].SuggestedFixes) != 0 {
+		t.Errorf("Expected one append-copy-idiom issue without a fix, got %v", found)
+	}
+}
+
+func TestAppendBoxing(t *testing.T) {
+	code := `package main
+
+type point struct{ x, y int }
+
+func collect(n int, p point, s string, err error, ids []int) []any {
+	var out []any
+	out = append(out, n)
+	for _, id := range ids {
+		out = append(out, id, s)
+	}
+	out = append(out, 42, &p, err, nil)
+	var ps []interface{ String() string }
+	_ = ps
+	var ints []int
+	ints = append(ints, n)
+	_ = ints
+	items := []any{p}
+	return append(out, items...)
+}
+`
+	pass, _ := newTestPass(t, code)
+
+	var found []Issue
+	for _, issue := range analyzeFile(pass.Files[0], pass.TypesInfo, pass.Fset, DefaultConfig()) {
+		if issue.Pattern == "append-boxing" {
+			found = append(found, issue)
+		}
+	}
+
+	// Constants, pointers, interfaces and spread slices don't box on append
+	expected := []struct {
+		line int
+		msg  string
+	}{
+		{7, "appending int to []any boxes each value, allocating on every call; consider int as the element type if every value has that type"},
+		{9, "appending int and string to []any boxes each value, allocating on every iteration"},
+	}
+	if len(found) != len(expected) {
+		t.Fatalf("Expected %d append-boxing issues, got %d: %v", len(expected), len(found), found)
+	}
+	for i, want := range expected {
+		if found[i].Pos.Line != want.line || found[i].Message != want.msg {
+			t.Errorf("Expected %q on line %d, got %q on line %d", want.msg, want.line, found[i].Message, found[i].Pos.Line)
+		}
+	}
+}
+
+func TestMakeCopyClone(t *testing.T) {
+	code := `package main
+
+import (
+	"slices"
+	"sort"
+)
+
+func sorted(names []string) []string {
+	out := make([]string, len(names))
+	copy(out, names)
+	sort.Strings(out)
+	return out
+}
+
+func total(counts []int) int {
+	tmp := make([]int, len(counts), len(counts))
+	copy(tmp, counts)
+	sum := 0
+	for _, n := range tmp {
+		sum += n
+	}
+	return sum + len(counts)
+}
+
+func partial(a, b []int) []int {
+	c := make([]int, len(a))
+	copy(c, b)
+	d := make([]int, len(a), 2*len(a))
+	copy(d, a)
+	return slices.Concat(c, d)
+}
+
+func bytesOf(s string) []byte {
+	b := make([]byte, len(s))
+	copy(b, s)
+	return b
+}
+`
+	pass, _ := newTestPass(t, code)
+
+	var found []Issue
+	for _, issue := range analyzeFile(pass.Files[0], pass.TypesInfo, pass.Fset, DefaultConfig()) {
+		if issue.Pattern == "make-copy-clone" {
+			found = append(found, issue)
+		}
+	}
+
+	// Copying another slice, making room for more, or copying a string, which
+	// slices.Clone doesn't take, isn't a clone
+	expected := []struct {
+		line  int
+		msg   string
+		fixed string
+	}{
+		{9, "make and copy clone names into out; slices.Clone(names) (Go 1.21+) does the same in one call", "out := slices.Clone(names)"},
+		{16, "make and copy clone counts into tmp, but neither is written to or passed on elsewhere in the function; the copy may be avoidable by using counts directly, otherwise slices.Clone(counts) (Go 1.21+) says what it does", "tmp := slices.Clone(counts)"},
+	}
+	if len(found) != len(expected) {
+		t.Fatalf("Expected %d make-copy-clone issues, got %d: %v", len(expected), len(found), found)
+	}
+
+	for i, want := range expected {
+		issue := found[i]
+		if issue.Pos.Line != want.line || issue.Message != want.msg {
+			t.Errorf("Expected %q on line %d, got %q on line %d", want.msg, want.line, issue.Message, issue.Pos.Line)
+		}
+		if len(issue.SuggestedFixes) != 1 || len(issue.SuggestedFixes[0].TextEdits) != 1 {
+			t.Fatalf("Expected a single-edit fix, got %v", issue.SuggestedFixes)
+		}
+		filename := pass.Fset.Position(pass.Files[0].Pos()).Filename
+		fixed, err := applyFileEdits(pass.Fset, filename, []byte(code), issue.SuggestedFixes[0].TextEdits)
+		if err != nil {
+			t.Fatalf("Failed to apply fix: %v", err)
+		}
+		lines := splitLines(fixed)
+		if got := lines[want.line-1]; got != "\t"+want.fixed {
+			t.Errorf("Expected line %d to become %q, got %q", want.line, want.fixed, got)
+		}
+		if len(lines) != len(splitLines([]byte(code)))-1 {
+			t.Errorf("Expected the copy after line %d to be removed, got:\n%s", want.line, fixed)
+		}
+	}
+}
+
+func TestManualGrow(t *testing.T) {
+	code := `
+package main
+
+type buffer struct {
+	data []byte
+}
+
+func (b *buffer) write(p byte) {
+	if len(b.data) == cap(b.data) {
+		grown := make([]byte, len(b.data), 2*cap(b.data)+1)
+		copy(grown, b.data)
+		b.data = grown
+	}
+	b.data = append(b.data, p)
+}
+
+func push(stack []int, v int) []int {
+	if len(stack) == cap(stack) {
+		next := make([]int, cap(stack)<<1)
+		copy(next, stack)
+		stack = next[:len(stack)]
+	}
+	return append(stack, v)
+}
+
+func pushAppend(stack []int, v int) []int {
+	return append(stack, v)
+}
+
+func merge(a, b []int) []int {
+	out := make([]int, len(a), 2*cap(a))
+	copy(out, b)
+	return out
+}
+`
+	issues := inspectSource(t, code, DefaultConfig())
+
+	tests := []struct {
+		name     string
+		contains string
+		expected int
+	}{
+		{"doubled field capacity", "make and copy grow b.data by hand into grown, twice its size; append(b.data, ...) already grows the backing array by doubling when it runs out of room, and slices.Grow(b.data, n) (Go 1.21+) reserves room for n more elements up front", 1},
+		{"doubled by shifting", "make and copy grow stack by hand into next", 1},
+		{"not append, or copying another slice", "make and copy grow", 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := countMatching(issues, tt.contains); got != tt.expected {
+				t.Errorf("Expected %d issues containing %q, got %d: %v", tt.expected, tt.contains, got, issues)
+			}
+		})
+	}
+}
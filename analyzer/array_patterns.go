@@ -0,0 +1,84 @@
+package analyzer
+
+import (
+	"fmt"
+	"go/ast"
+	"go/types"
+)
+
+// largeArraySize is the size in bytes above which copying an array is worth
+// reporting; smaller arrays are as cheap to copy as a few words
+const largeArraySize = 256
+
+// largeArray returns the array type of expr, as written relative to its
+// package, and its size in bytes if expr is a variable, field or element
+// holding an array, not a pointer to one, larger than largeArraySize. Other
+// array values, such as call results, have no copy to avoid.
+func (pd *PatternDetector) largeArray(expr ast.Expr) (string, *types.Array, int64, bool) {
+	switch ast.Unparen(expr).(type) {
+	case *ast.Ident, *ast.SelectorExpr, *ast.IndexExpr:
+	default:
+		return "", nil, 0, false
+	}
+	t := pd.info.TypeOf(expr)
+	if t == nil {
+		return "", nil, 0, false
+	}
+	array, ok := t.Underlying().(*types.Array)
+	if !ok {
+		return "", nil, 0, false
+	}
+	size, ok := pd.sizeof(t)
+	if !ok || size <= largeArraySize {
+		return "", nil, 0, false
+	}
+	return types.TypeString(t, types.RelativeTo(namedPkg(t, array.Elem()))), array, size, true
+}
+
+// detectLargeArrayRange detects range loops with a value variable over a large
+// array. The range expression is then evaluated once, copying the whole array
+// before the first iteration, and every element is copied again into the
+// value variable. Ranging over a pointer to the array, or over its indexes
+// alone, copies neither.
+func (pd *PatternDetector) detectLargeArrayRange(rng *ast.RangeStmt, report func(node ast.Node, msg string)) {
+	if !pd.config.IsPatternEnabled("large-array-copy") || rng.Value == nil {
+		return
+	}
+	if ident, ok := rng.Value.(*ast.Ident); ok && ident.Name == "_" {
+		return
+	}
+	name, array, size, ok := pd.largeArray(rng.X)
+	if !ok {
+		return
+	}
+	x, ok := pd.nodeSource(rng.X)
+	if !ok {
+		return
+	}
+
+	msg := fmt.Sprintf("ranging over %s by value copies the whole %s (%d bytes) before the loop starts", x, name, size)
+	if elem, ok := pd.sizeof(array.Elem()); ok && !pd.isSmallAlloc(elem) {
+		msg += fmt.Sprintf(", and each %d-byte element on every iteration", elem)
+	}
+	pd.reportRule(report, "large-array-copy", rng.X, msg+fmt.Sprintf("; range over &%s, or over its indexes alone with for i := range %s and use %s[i]", x, x, x))
+}
+
+// detectLargeArrayReturn detects large arrays returned by value, which copies
+// every element into the caller's result. Only existing arrays, such as a
+// package-level table, are reported; a returned literal is built in place.
+func (pd *PatternDetector) detectLargeArrayReturn(ret *ast.ReturnStmt, report func(node ast.Node, msg string)) {
+	if !pd.config.IsPatternEnabled("large-array-copy") {
+		return
+	}
+	for _, result := range ret.Results {
+		name, _, size, ok := pd.largeArray(result)
+		if !ok {
+			continue
+		}
+		x, ok := pd.nodeSource(result)
+		if !ok {
+			continue
+		}
+		pd.reportRule(report, "large-array-copy", result, fmt.Sprintf("returning %s by value copies the whole %s (%d bytes); consider returning the slice %s[:] or a pointer to it", x, name, size, x))
+	}
+}
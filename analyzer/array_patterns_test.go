@@ -0,0 +1,81 @@
+package analyzer
+
+import "testing"
+
+func TestLargeArrayCopy(t *testing.T) {
+	code := `
+package main
+
+type entry struct {
+	name  string
+	score int64
+	flags [4]int64
+}
+
+var table [256]entry
+
+var small [8]int
+
+type codec struct {
+	lookup [512]byte
+}
+
+func total() int64 {
+	var sum int64
+	for _, e := range table {
+		sum += e.score
+	}
+	for i := range table {
+		sum += table[i].score
+	}
+	for i, _ := range table {
+		sum += table[i].score
+	}
+	for _, v := range &table {
+		sum += v.score
+	}
+	for _, v := range small {
+		sum += int64(v)
+	}
+	return sum
+}
+
+func (c *codec) decode(in []byte) {
+	for i, b := range c.lookup {
+		in[i%len(in)] ^= b
+	}
+}
+
+func snapshot() [256]entry {
+	return table
+}
+
+func (c *codec) table() *[512]byte {
+	return &c.lookup
+}
+
+func fresh() [256]entry {
+	return [256]entry{}
+}
+`
+	issues := inspectSource(t, code, DefaultConfig())
+
+	tests := []struct {
+		name     string
+		contains string
+		expected int
+	}{
+		{"range by value over a table of large elements", "ranging over table by value copies the whole [256]entry (14336 bytes) before the loop starts, and each 56-byte element on every iteration; range over &table, or over its indexes alone with for i := range table and use table[i]", 1},
+		{"range by value over a field of small elements", "ranging over c.lookup by value copies the whole [512]byte (512 bytes) before the loop starts; range over &c.lookup", 1},
+		{"return by value", "returning table by value copies the whole [256]entry (14336 bytes); consider returning the slice table[:] or a pointer to it", 1},
+		{"not by index, by pointer, small arrays or literals", "copies the whole", 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := countMatching(issues, tt.contains); got != tt.expected {
+				t.Errorf("Expected %d issues containing %q, got %d: %v", tt.expected, tt.contains, got, issues)
+			}
+		})
+	}
+}
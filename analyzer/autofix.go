@@ -3,9 +3,11 @@ package analyzer
 import (
 	"fmt"
 	"go/format"
+	"go/parser"
 	"go/token"
 	"io/ioutil"
 	"os"
+	"regexp"
 	"sort"
 	"strings"
 
@@ -28,23 +30,26 @@ func (w *RealFileWriter) WriteFile(filename string, data []byte, perm os.FileMod
 
 // AutoFixer handles automatic code fixes based on AI suggestions
 type AutoFixer struct {
-	fset   *token.FileSet
-	writer FileWriter
+	fset    *token.FileSet
+	writer  FileWriter
+	sources *SourceCache
 }
 
 // NewAutoFixer creates a new AutoFixer instance
 func NewAutoFixer(fset *token.FileSet) *AutoFixer {
 	return &AutoFixer{
-		fset:   fset,
-		writer: &RealFileWriter{},
+		fset:    fset,
+		writer:  &RealFileWriter{},
+		sources: NewSourceCache(nil),
 	}
 }
 
 // NewAutoFixerWithWriter creates a new AutoFixer instance with custom writer
 func NewAutoFixerWithWriter(fset *token.FileSet, writer FileWriter) *AutoFixer {
 	return &AutoFixer{
-		fset:   fset,
-		writer: writer,
+		fset:    fset,
+		writer:  writer,
+		sources: NewSourceCache(nil),
 	}
 }
 
@@ -132,103 +137,277 @@ func (af *AutoFixer) tokenPosToByteOffset(content []byte, pos token.Pos) int {
 	return offset
 }
 
-// GenerateAutoFixes creates suggested fixes based on AI suggestions and issue analysis
+// GenerateAutoFixes creates suggested fixes based on issue analysis, using
+// aiSuggestion where a fix depends on it. aiSuggestion is empty when AI is
+// disabled or unavailable, and fixes that can be derived from the issue alone,
+// such as new(string) to "", are generated either way.
 func (af *AutoFixer) GenerateAutoFixes(issue Issue, aiSuggestion string) []analysis.SuggestedFix {
 	var fixes []analysis.SuggestedFix
 
-	// Parse the issue to understand what kind of fix is needed
-	if strings.Contains(issue.Message, "new(T)") {
+	// Fixes are chosen by the node the issue is about rather than its
+	// message, which Config.MessageTemplates may have reworded
+	if issue.Node == "CallExpr" {
 		// Try to generate a fix for new(T) allocations
 		if fix := af.generateNewTFix(issue, aiSuggestion); fix != nil {
 			fixes = append(fixes, *fix)
 		}
 	}
 
+	// Otherwise the AI suggestion's code is the fix
+	if len(fixes) == 0 && aiSuggestion != "" {
+		if fix := af.generateAIFix(issue, aiSuggestion); fix != nil {
+			fixes = append(fixes, *fix)
+		}
+	}
+
 	return fixes
 }
 
-// generateNewTFix generates a fix for new(T) allocations
-func (af *AutoFixer) generateNewTFix(issue Issue, aiSuggestion string) *analysis.SuggestedFix {
-	// Read the source file to understand the context
-	if issue.Pos.Filename == "" {
+// aiCodeBlock matches a fenced code block of an AI suggestion
+var aiCodeBlock = regexp.MustCompile("(?s)```(?:go)?\n(.*?)```")
+
+// generateAIFix builds a fix from an AI suggestion showing the code before and
+// after the fix in two code blocks, as the OpenAI adapter asks for. The lines
+// of the first block, ignoring indentation, must appear exactly once in the
+// file around the issue's line; they are replaced by those of the second,
+// indented like the first line they replace. The suggestion is trusted as it
+// is, so -validate-fixes is what keeps code that doesn't compile out.
+func (af *AutoFixer) generateAIFix(issue Issue, suggestion string) *analysis.SuggestedFix {
+	blocks := aiCodeBlock.FindAllStringSubmatch(suggestion, -1)
+	if len(blocks) != 2 {
 		return nil
 	}
+	before := strings.Split(strings.Trim(blocks[0][1], "\n"), "\n")
+	after := strings.Split(strings.Trim(blocks[1][1], "\n"), "\n")
 
-	content, err := ioutil.ReadFile(issue.Pos.Filename)
-	if err != nil {
+	content, ok := af.sources.Read(issue.Pos.Filename)
+	if !ok {
+		return nil
+	}
+	lines := strings.Split(string(content), "\n")
+
+	match := -1
+	for first := max(0, issue.Pos.Line-len(before)); first < issue.Pos.Line && first+len(before) <= len(lines); first++ {
+		matches := true
+		for i, line := range before {
+			matches = matches && strings.TrimSpace(lines[first+i]) == strings.TrimSpace(line)
+		}
+		if matches {
+			if match != -1 {
+				return nil
+			}
+			match = first
+		}
+	}
+	if match == -1 {
+		return nil
+	}
+
+	// The replaced lines' own indentation is kept, and the suggestion's relative
+	// indentation added to it
+	indent := lines[match][:len(lines[match])-len(strings.TrimLeft(lines[match], " \t"))]
+	common := ""
+	for i, line := range after {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		lead := line[:len(line)-len(strings.TrimLeft(line, " \t"))]
+		if i == 0 || len(lead) < len(common) {
+			common = lead
+		}
+	}
+	var text []string
+	for _, line := range after {
+		if strings.TrimSpace(line) == "" {
+			text = append(text, "")
+			continue
+		}
+		text = append(text, indent+strings.TrimPrefix(line, common))
+	}
+
+	startOffset := 0
+	for i := 0; i < match; i++ {
+		startOffset += len(lines[i]) + 1 // +1 for newline
+	}
+	endOffset := startOffset
+	for i := match; i < match+len(before); i++ {
+		endOffset += len(lines[i]) + 1
+	}
+	endOffset-- // the last line's newline stays
+
+	start := tokenPos(af.fset, token.Position{Filename: issue.Pos.Filename, Offset: startOffset, Line: match + 1})
+	end := tokenPos(af.fset, token.Position{Filename: issue.Pos.Filename, Offset: endOffset, Line: match + len(before)})
+	if !start.IsValid() || !end.IsValid() {
+		return nil
+	}
+	return &analysis.SuggestedFix{
+		Message: "Apply AI suggestion",
+		TextEdits: []analysis.TextEdit{{
+			Pos:     start,
+			End:     end,
+			NewText: []byte(strings.Join(text, "\n")),
+		}},
+	}
+}
+
+// newTZeroValues are the zero values generateNewTFix replaces new(T) with
+var newTZeroValues = map[string]string{
+	"string":  `""`,
+	"int":     "0",
+	"bool":    "false",
+	"float64": "0.0",
+}
+
+// generateNewTFix generates a fix for `s := new(T)` where T is a basic type
+// with a literal zero value. When s is only ever dereferenced, it becomes
+// `s := <zero>` and each *s becomes s. Otherwise s keeps its pointer type, and
+// the fix declares the value separately as `var sValue T` and takes its
+// address, which the compiler can keep on the stack if s doesn't escape.
+func (af *AutoFixer) generateNewTFix(issue Issue, aiSuggestion string) *analysis.SuggestedFix {
+	// Read the source file to understand the context
+	content, ok := af.sources.Read(issue.Pos.Filename)
+	if !ok {
 		return nil
 	}
 
 	lines := strings.Split(string(content), "\n")
-	if issue.Pos.Line < 1 || issue.Pos.Line > len(lines) {
+	if issue.Pos.Line < 1 || issue.Pos.Line > len(lines) || issue.Pos.Column < 1 {
 		return nil
 	}
+	lineStart := 0
+	for i := 0; i < issue.Pos.Line-1; i++ {
+		lineStart += len(lines[i]) + 1 // +1 for newline
+	}
+	offset := lineStart + issue.Pos.Column - 1
 
-	line := lines[issue.Pos.Line-1]
+	// The source is parsed on its own, so offsets in it are offsets in the file
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, issue.Pos.Filename, content, 0)
+	if err != nil {
+		return nil
+	}
 
-	// Look for patterns like "s := new(string)" or "i := new(int)"
-	if strings.Contains(line, ":= new(") {
-		// Find the exact position of "new(Type)" in the line
-		newIndex := strings.Index(line, "new(")
-		if newIndex == -1 {
-			return nil
+	// Find the issue's new(T) call as the only value of a := in a statement list
+	var stack []ast.Node
+	var call *ast.CallExpr
+	var assign *ast.AssignStmt
+	var block ast.Node
+	ast.Inspect(file, func(n ast.Node) bool {
+		if n == nil {
+			stack = stack[:len(stack)-1]
+			return true
 		}
-
-		// Find the closing parenthesis
-		closeIndex := strings.Index(line[newIndex:], ")")
-		if closeIndex == -1 {
-			return nil
+		if call != nil {
+			return false
 		}
-		closeIndex += newIndex + 1 // Adjust for the offset and include the closing paren
-
-		// Extract the type
-		newExpr := line[newIndex:closeIndex]
-		if !strings.HasPrefix(newExpr, "new(") || !strings.HasSuffix(newExpr, ")") {
-			return nil
+		if c, ok := n.(*ast.CallExpr); ok && fset.Position(c.Pos()).Offset == offset && len(stack) >= 2 {
+			call = c
+			assign, _ = stack[len(stack)-1].(*ast.AssignStmt)
+			block = stack[len(stack)-2]
+			return false
 		}
+		stack = append(stack, n)
+		return true
+	})
+	if call == nil || assign == nil || assign.Tok != token.DEFINE || len(assign.Lhs) != 1 || len(assign.Rhs) != 1 || assign.Rhs[0] != call {
+		return nil
+	}
+	switch block.(type) {
+	case *ast.BlockStmt, *ast.CaseClause, *ast.CommClause:
+	default:
+		return nil
+	}
+	fun, ok := call.Fun.(*ast.Ident)
+	if !ok || fun.Name != "new" || len(call.Args) != 1 {
+		return nil
+	}
+	typeArg, ok := call.Args[0].(*ast.Ident)
+	if !ok {
+		return nil
+	}
+	typeName := typeArg.Name
+	replacement, ok := newTZeroValues[typeName]
+	if !ok {
+		return nil
+	}
+	name, ok := assign.Lhs[0].(*ast.Ident)
+	if !ok || name.Name == "_" {
+		return nil
+	}
 
-		typeName := newExpr[4 : len(newExpr)-1] // Remove "new(" and ")"
-
-		// Generate the replacement value
-		var replacement string
-		switch typeName {
-		case "string":
-			replacement = `""`
-		case "int":
-			replacement = "0"
-		case "bool":
-			replacement = "false"
-		case "float64":
-			replacement = "0.0"
-		default:
-			// For other types, we can't easily provide a zero value without more context
-			// So we'll skip this fix
-			return nil
+	// Every later mention of the name in the block must be a dereference for s
+	// to become a value; anything else, including a redeclaration shadowing
+	// it, keeps it a pointer
+	var derefs []*ast.StarExpr
+	onlyDerefs := true
+	stack = stack[:0]
+	ast.Inspect(block, func(n ast.Node) bool {
+		if n == nil {
+			stack = stack[:len(stack)-1]
+			return true
 		}
-
-		// Calculate the absolute positions in the file
-		lineStart := 0
-		for i := 0; i < issue.Pos.Line-1; i++ {
-			lineStart += len(lines[i]) + 1 // +1 for newline
+		if ident, ok := n.(*ast.Ident); ok && ident.Name == name.Name && ident.Pos() > assign.End() {
+			if star, ok := stack[len(stack)-1].(*ast.StarExpr); ok && star.X == ident {
+				derefs = append(derefs, star)
+			} else {
+				onlyDerefs = false
+			}
 		}
+		stack = append(stack, n)
+		return true
+	})
 
-		// Position of the new(Type) expression in the file
-		newExprStart := lineStart + newIndex
-		newExprEnd := lineStart + closeIndex
+	// Positions in the file, which must be in fset for editors to apply the fix
+	pos := func(n ast.Node) token.Pos {
+		return tokenPos(af.fset, token.Position{Filename: issue.Pos.Filename, Offset: fset.Position(n.Pos()).Offset, Line: fset.Position(n.Pos()).Line})
+	}
+	end := func(n ast.Node) token.Pos {
+		return tokenPos(af.fset, token.Position{Filename: issue.Pos.Filename, Offset: fset.Position(n.End()).Offset, Line: fset.Position(n.End()).Line})
+	}
 
+	if onlyDerefs {
+		edits := []analysis.TextEdit{{Pos: pos(call), End: end(call), NewText: []byte(replacement)}}
+		for _, star := range derefs {
+			edits = append(edits, analysis.TextEdit{Pos: pos(star), End: end(star), NewText: []byte(name.Name)})
+		}
+		for _, edit := range edits {
+			if !edit.Pos.IsValid() || !edit.End.IsValid() {
+				return nil
+			}
+		}
 		return &analysis.SuggestedFix{
-			Message: fmt.Sprintf("Replace new(%s) with zero value", typeName),
-			TextEdits: []analysis.TextEdit{
-				{
-					Pos:     token.Pos(newExprStart),
-					End:     token.Pos(newExprEnd),
-					NewText: []byte(replacement),
-				},
-			},
+			Message:   fmt.Sprintf("Replace new(%s) with %s", typeName, replacement),
+			TextEdits: edits,
 		}
 	}
 
-	return nil
+	// The value needs a name of its own, unused anywhere in the file, and the
+	// statement a line of its own to declare it on the line before
+	value := name.Name + "Value"
+	taken := false
+	ast.Inspect(file, func(n ast.Node) bool {
+		if ident, ok := n.(*ast.Ident); ok && ident.Name == value {
+			taken = true
+		}
+		return !taken
+	})
+	line := lines[issue.Pos.Line-1]
+	indent := line[:len(line)-len(strings.TrimLeft(line, " \t"))]
+	if taken || fset.Position(assign.Pos()).Offset != lineStart+len(indent) {
+		return nil
+	}
+	start, stop := pos(assign), end(assign)
+	if !start.IsValid() || !stop.IsValid() {
+		return nil
+	}
+	return &analysis.SuggestedFix{
+		Message: fmt.Sprintf("Declare %s as a %s variable and take its address", value, typeName),
+		TextEdits: []analysis.TextEdit{{
+			Pos:     start,
+			End:     stop,
+			NewText: []byte(fmt.Sprintf("var %s %s\n%s%s := &%s", value, typeName, indent, name.Name, value)),
+		}},
+	}
 }
 
 // FormatCode formats Go code using go/format
@@ -240,17 +419,23 @@ func (af *AutoFixer) FormatCode(code string) (string, error) {
 	return string(formatted), nil
 }
 
-// SmartReplace performs intelligent code replacement with context awareness
+// SmartReplace performs intelligent code replacement with context awareness.
+// It returns nil if issue's position isn't in the fixer's file set.
 func (af *AutoFixer) SmartReplace(issue Issue, oldPattern, newCode string) *analysis.SuggestedFix {
 	// This would implement more sophisticated replacement logic
 	// considering the AST context, variable scopes, etc.
 
+	start := tokenPos(af.fset, issue.Pos)
+	if !start.IsValid() {
+		return nil
+	}
+
 	return &analysis.SuggestedFix{
-		Message: "Smart replacement based on AI suggestion",
+		Message: fmt.Sprintf("Replace %s with %s", oldPattern, newCode),
 		TextEdits: []analysis.TextEdit{
 			{
-				Pos:     token.Pos(issue.Pos.Offset),
-				End:     token.Pos(issue.Pos.Offset + len(oldPattern)),
+				Pos:     start,
+				End:     start + token.Pos(len(oldPattern)),
 				NewText: []byte(newCode),
 			},
 		},
@@ -1,10 +1,16 @@
 package analyzer
 
 import (
+	"errors"
 	"flag"
+	"fmt"
 	"os"
 	"strconv"
 	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
 )
 
 // SetupFlags configures command-line flags for the analyzer
@@ -16,6 +22,14 @@ func (c *Config) SetupFlags(fs *flag.FlagSet) {
 	fs.StringVar(&disablePatterns, "disable-patterns", "",
 		"Comma-separated list of detectors to skip")
 
+	var enablePatterns string
+	fs.StringVar(&enablePatterns, "enable-patterns", "",
+		"Comma-separated list of off-by-default detectors to run")
+
+	var onlyPatterns string
+	fs.StringVar(&onlyPatterns, "only-patterns", "",
+		"Comma-separated list of detectors to run exclusively")
+
 	fs.BoolVar(&c.MetricsEnabled, "metrics-enabled", c.MetricsEnabled,
 		"Expose Prometheus metrics")
 
@@ -25,19 +39,134 @@ func (c *Config) SetupFlags(fs *flag.FlagSet) {
 	fs.StringVar(&c.OpenAIModel, "openai-model", c.OpenAIModel,
 		"OpenAI model to use for suggestions")
 
+	var modelFallbacks string
+	fs.StringVar(&modelFallbacks, "openai-model-fallbacks", "",
+		"Comma-separated list of models to try in order when -openai-model is unavailable or rate limited")
+
 	fs.IntVar(&c.OpenAIMaxTokens, "openai-max-tokens", c.OpenAIMaxTokens,
 		"Maximum tokens for OpenAI response")
 
 	var temperature string
 	fs.StringVar(&temperature, "openai-temperature", "0.2",
-		"Temperature for OpenAI requests (0.0-1.0)")
+		"Temperature for OpenAI requests (0.0-2.0)")
 
 	fs.BoolVar(&c.OpenAIDisable, "openai-disable", c.OpenAIDisable,
 		"Disable AI-powered suggestions")
 
+	fs.DurationVar(&c.AITimeBudget, "ai-time-budget", c.AITimeBudget,
+		"Stop asking for AI suggestions once waiting for them has taken this long in total, reporting the remaining issues without one; 0 means no limit")
+
+	fs.BoolVar(&c.AILogRequests, "ai-log-requests", c.AILogRequests,
+		"Log AI prompts and responses at debug level, with the API key and code snippets redacted")
+
+	fs.BoolVar(&c.AILogSnippets, "ai-log-snippets", c.AILogSnippets,
+		"Include code snippets in logs written by -ai-log-requests")
+
 	fs.BoolVar(&c.AutoFix, "autofix", c.AutoFix,
 		"Enable automatic code fixes (use with caution)")
 
+	fs.BoolVar(&c.FailOnFix, "fail-on-fix", c.FailOnFix,
+		"List files autofix would modify and fail without writing them")
+
+	fs.BoolVar(&c.Annotate, "annotate", c.Annotate,
+		"Insert a // TODO(stackalloc) comment above each finding instead of rewriting code")
+
+	fs.StringVar(&c.SaveFixes, "save-fixes", c.SaveFixes,
+		"Append the generated fixes to this file so they can be applied later with -apply-fixes")
+
+	fs.BoolVar(&c.Interactive, "interactive", c.Interactive,
+		"Show each fix from -autofix or -annotate and ask before applying it; without a terminal every fix is applied")
+
+	fs.BoolVar(&c.ValidateFixes, "validate-fixes", c.ValidateFixes,
+		"Apply each AutoFixer fix, including those built from AI suggestions, to an in-memory copy of the file and discard it if the result doesn't parse or type check")
+
+	fs.BoolVar(&c.IncludeInit, "include-init", c.IncludeInit,
+		"Report findings in init functions and package-level var initializers at info severity instead of dropping them")
+
+	fs.BoolVar(&c.SkipCgo, "skip-cgo", c.SkipCgo,
+		"Skip files importing \"C\" instead of running only syntactic detectors on them")
+
+	fs.BoolVar(&c.RequireTypes, "require-types", c.RequireTypes,
+		"Fail instead of warning when the package has no type information and most detectors can't run")
+
+	fs.BoolVar(&c.GenericInstances, "generic-instances", c.GenericInstances,
+		"List the type arguments generic functions are instantiated with in new(T) and make([]T) issues")
+
+	fs.BoolVar(&c.SuggestGenerics, "suggest-generics", c.SuggestGenerics,
+		"Suggest a type parameter in boxing issues when the function or struct declared in the file only uses the boxed value in ways one supports")
+
+	fs.BoolVar(&c.Verbose, "verbose", c.Verbose,
+		"Also report low-signal findings, such as one-off type assertions, that are suppressed by default")
+
+	fs.BoolVar(&c.Strict, "strict", c.Strict,
+		"Report only findings whose allocation is proven, such as returned addresses, dropping heuristic ones")
+
+	fs.StringVar(&c.Report, "report", c.Report,
+		"Set to counts to print only the number of files, issues and issues per severity, as one line or, with -format=json, one JSON object")
+
+	fs.StringVar(&c.Format, "format", c.Format,
+		"Output format: text, json, jsonl, sarif, github or csv; reports other than text are written to stdout, one per package under go vet")
+
+	fs.BoolVar(&c.IncludeSource, "include-source", c.IncludeSource,
+		"Embed the source line of each issue, and the lines around it, in JSON and SARIF output")
+
+	fs.BoolVar(&c.IncludeFixes, "include-fixes", c.IncludeFixes,
+		"Embed the edits of each issue's suggested fixes in JSON output, without applying them")
+
+	fs.BoolVar(&c.ShowFix, "show-fix", c.ShowFix,
+		"Print the replacement code of each issue's deterministic fix after its message, without applying it")
+
+	var formatFuncs string
+	fs.StringVar(&formatFuncs, "format-funcs", "",
+		"Comma-separated list of fully qualified functions that allocate like fmt, such as example.com/log.Infof or (*example.com/log.Logger).Infof")
+
+	var excludeFiles string
+	fs.StringVar(&excludeFiles, "exclude-files", "",
+		"Comma-separated list of paths to leave out of the analysis, in .gitignore syntax, applied after the patterns of the module's .stackallocignore file")
+
+	var valueTypes string
+	fs.StringVar(&valueTypes, "value-types", "",
+		"Comma-separated list of fully qualified types, such as example.com/pool.Buffer, that new-value-type suggests declaring with var, besides bytes.Buffer, big.Int and the like")
+
+	fs.Var(&c.ErrorSeverity, "error-severity",
+		"Minimum severity (info, possible, likely) that fails the run")
+
+	fs.Var(&c.WarnSeverity, "warn-severity",
+		"Minimum severity (info, possible, likely) printed as a warning")
+
+	fs.Var(ruleSeverities{&c.RuleSeverities}, "rule-severity",
+		"Comma-separated rule=severity pairs overriding the severity of those rules, such as new-value-type=info,chan-in-loop=likely")
+
+	fs.Var(pathTags{&c.PathTags}, "path-tags",
+		"Comma-separated prefix=tag pairs tagging the findings in files under each path prefix, relative to the module root, in JSON and SARIF output, such as internal/billing=team-payments")
+
+	fs.Var(&messageTemplatesFile{m: &c.MessageTemplates}, "message-templates",
+		"JSON file mapping rule names, or built-in message names such as new-call, to text/templates overriding the wording of their findings")
+
+	fs.IntVar(&c.MaxIssues, "max-issues", c.MaxIssues,
+		"Number of error-level issues tolerated per package before failing")
+
+	fs.IntVar(&c.MaxIssuesPerFile, "max-issues-per-file", c.MaxIssuesPerFile,
+		"Report at most this many issues per file and note how many more were suppressed; 0 means no limit")
+
+	fs.Float64Var(&c.SampleRate, "sample-rate", c.SampleRate,
+		"Report only this fraction of issues, chosen by position so the same ones are kept on every run; 0 or 1 reports all")
+
+	fs.StringVar(&c.Pprof, "pprof", c.Pprof,
+		"pprof profile, such as a CPU profile, to guide the analysis with: only findings in functions it samples are reported, annotated with the share of samples spent in them")
+
+	fs.Float64Var(&c.PprofThreshold, "pprof-threshold", c.PprofThreshold,
+		"With -pprof, the percentage of the profile's samples a function must appear in for its findings to be reported")
+
+	fs.IntVar(&c.MaxFileSize, "max-file-size", c.MaxFileSize,
+		"Skip files larger than this many KB, such as large generated files, with a log message; 0 means no limit")
+
+	fs.IntVar(&c.MaxDepth, "max-depth", c.MaxDepth,
+		"Skip declarations whose syntax nests deeper than this, with a warning; 0 means no limit")
+
+	fs.IntVar(&c.MinLoopIterations, "min-loop-iterations", c.MinLoopIterations,
+		"Don't report allocations in loops known to run fewer times than this, such as for i := 0; i < 3; i++; loops without a constant bound are always reported")
+
 	// Note: We don't call Parse here as the analysis framework handles that
 
 	// Process disable patterns if provided
@@ -48,6 +177,51 @@ func (c *Config) SetupFlags(fs *flag.FlagSet) {
 		}
 	}
 
+	// Process enable patterns if provided
+	if enablePatterns != "" {
+		c.EnablePatterns = strings.Split(enablePatterns, ",")
+		for i := range c.EnablePatterns {
+			c.EnablePatterns[i] = strings.TrimSpace(c.EnablePatterns[i])
+		}
+	}
+
+	// Process only patterns if provided
+	if onlyPatterns != "" {
+		c.OnlyPatterns = strings.Split(onlyPatterns, ",")
+		for i := range c.OnlyPatterns {
+			c.OnlyPatterns[i] = strings.TrimSpace(c.OnlyPatterns[i])
+		}
+	}
+
+	// Process format functions if provided
+	if formatFuncs != "" {
+		c.RegisterFormatFuncs(strings.Split(formatFuncs, ",")...)
+	}
+
+	// Process excluded files if provided
+	if excludeFiles != "" {
+		c.ExcludeFiles = strings.Split(excludeFiles, ",")
+		for i := range c.ExcludeFiles {
+			c.ExcludeFiles[i] = strings.TrimSpace(c.ExcludeFiles[i])
+		}
+	}
+
+	// Process value types if provided
+	if valueTypes != "" {
+		c.ValueTypes = strings.Split(valueTypes, ",")
+		for i := range c.ValueTypes {
+			c.ValueTypes[i] = strings.TrimSpace(c.ValueTypes[i])
+		}
+	}
+
+	// Process model fallbacks if provided
+	if modelFallbacks != "" {
+		c.OpenAIFallbacks = strings.Split(modelFallbacks, ",")
+		for i := range c.OpenAIFallbacks {
+			c.OpenAIFallbacks[i] = strings.TrimSpace(c.OpenAIFallbacks[i])
+		}
+	}
+
 	// Parse temperature
 	if temp, err := strconv.ParseFloat(temperature, 32); err == nil {
 		c.OpenAITemperature = float32(temp)
@@ -71,14 +245,173 @@ func (c *Config) ParseFlags(fs *flag.FlagSet) {
 					c.DisablePatterns[i] = strings.TrimSpace(c.DisablePatterns[i])
 				}
 			}
+		case "enable-patterns":
+			if f.Value.String() != "" {
+				c.EnablePatterns = strings.Split(f.Value.String(), ",")
+				for i := range c.EnablePatterns {
+					c.EnablePatterns[i] = strings.TrimSpace(c.EnablePatterns[i])
+				}
+			}
+		case "only-patterns":
+			if f.Value.String() != "" {
+				c.OnlyPatterns = strings.Split(f.Value.String(), ",")
+				for i := range c.OnlyPatterns {
+					c.OnlyPatterns[i] = strings.TrimSpace(c.OnlyPatterns[i])
+				}
+			}
 		case "openai-temperature":
 			if temp, err := strconv.ParseFloat(f.Value.String(), 32); err == nil {
 				c.OpenAITemperature = float32(temp)
 			}
+		case "ai-time-budget":
+			if val, err := time.ParseDuration(f.Value.String()); err == nil {
+				c.AITimeBudget = val
+			}
+		case "ai-log-requests":
+			if val, err := strconv.ParseBool(f.Value.String()); err == nil {
+				c.AILogRequests = val
+			}
+		case "ai-log-snippets":
+			if val, err := strconv.ParseBool(f.Value.String()); err == nil {
+				c.AILogSnippets = val
+			}
 		case "autofix":
 			if val, err := strconv.ParseBool(f.Value.String()); err == nil {
 				c.AutoFix = val
 			}
+		case "fail-on-fix":
+			if val, err := strconv.ParseBool(f.Value.String()); err == nil {
+				c.FailOnFix = val
+			}
+		case "annotate":
+			if val, err := strconv.ParseBool(f.Value.String()); err == nil {
+				c.Annotate = val
+			}
+		case "save-fixes":
+			c.SaveFixes = f.Value.String()
+		case "interactive":
+			if val, err := strconv.ParseBool(f.Value.String()); err == nil {
+				c.Interactive = val
+			}
+		case "validate-fixes":
+			if val, err := strconv.ParseBool(f.Value.String()); err == nil {
+				c.ValidateFixes = val
+			}
+		case "generic-instances":
+			if val, err := strconv.ParseBool(f.Value.String()); err == nil {
+				c.GenericInstances = val
+			}
+		case "suggest-generics":
+			if val, err := strconv.ParseBool(f.Value.String()); err == nil {
+				c.SuggestGenerics = val
+			}
+		case "include-init":
+			if val, err := strconv.ParseBool(f.Value.String()); err == nil {
+				c.IncludeInit = val
+			}
+		case "skip-cgo":
+			if val, err := strconv.ParseBool(f.Value.String()); err == nil {
+				c.SkipCgo = val
+			}
+		case "require-types":
+			if val, err := strconv.ParseBool(f.Value.String()); err == nil {
+				c.RequireTypes = val
+			}
+		case "verbose":
+			if val, err := strconv.ParseBool(f.Value.String()); err == nil {
+				c.Verbose = val
+			}
+		case "strict":
+			if val, err := strconv.ParseBool(f.Value.String()); err == nil {
+				c.Strict = val
+			}
+		case "format":
+			c.Format = f.Value.String()
+		case "report":
+			c.Report = f.Value.String()
+		case "include-source":
+			if val, err := strconv.ParseBool(f.Value.String()); err == nil {
+				c.IncludeSource = val
+			}
+		case "include-fixes":
+			if val, err := strconv.ParseBool(f.Value.String()); err == nil {
+				c.IncludeFixes = val
+			}
+		case "exclude-files":
+			if f.Value.String() != "" {
+				c.ExcludeFiles = strings.Split(f.Value.String(), ",")
+				for i := range c.ExcludeFiles {
+					c.ExcludeFiles[i] = strings.TrimSpace(c.ExcludeFiles[i])
+				}
+			}
+		case "show-fix":
+			if val, err := strconv.ParseBool(f.Value.String()); err == nil {
+				c.ShowFix = val
+			}
+		case "value-types":
+			if f.Value.String() != "" {
+				c.ValueTypes = strings.Split(f.Value.String(), ",")
+				for i := range c.ValueTypes {
+					c.ValueTypes[i] = strings.TrimSpace(c.ValueTypes[i])
+				}
+			}
+		case "format-funcs":
+			if f.Value.String() != "" {
+				c.FormatFuncs = nil
+				c.RegisterFormatFuncs(strings.Split(f.Value.String(), ",")...)
+			}
+		// Values parsed by fs.Var are copied rather than parsed again from
+		// their string form, for flags set up by a different Config
+		case "error-severity":
+			if v, ok := f.Value.(*Severity); ok {
+				c.ErrorSeverity = *v
+			}
+		case "warn-severity":
+			if v, ok := f.Value.(*Severity); ok {
+				c.WarnSeverity = *v
+			}
+		case "rule-severity":
+			if v, ok := f.Value.(ruleSeverities); ok {
+				c.RuleSeverities = *v.m
+			}
+		case "path-tags":
+			if v, ok := f.Value.(pathTags); ok {
+				c.PathTags = *v.m
+			}
+		case "message-templates":
+			if v, ok := f.Value.(*messageTemplatesFile); ok {
+				c.MessageTemplates = *v.m
+			}
+		case "max-issues":
+			if val, err := strconv.Atoi(f.Value.String()); err == nil {
+				c.MaxIssues = val
+			}
+		case "max-issues-per-file":
+			if val, err := strconv.Atoi(f.Value.String()); err == nil {
+				c.MaxIssuesPerFile = val
+			}
+		case "sample-rate":
+			if val, err := strconv.ParseFloat(f.Value.String(), 64); err == nil {
+				c.SampleRate = val
+			}
+		case "pprof":
+			c.Pprof = f.Value.String()
+		case "pprof-threshold":
+			if val, err := strconv.ParseFloat(f.Value.String(), 64); err == nil {
+				c.PprofThreshold = val
+			}
+		case "max-file-size":
+			if val, err := strconv.Atoi(f.Value.String()); err == nil {
+				c.MaxFileSize = val
+			}
+		case "max-depth":
+			if val, err := strconv.Atoi(f.Value.String()); err == nil {
+				c.MaxDepth = val
+			}
+		case "min-loop-iterations":
+			if val, err := strconv.Atoi(f.Value.String()); err == nil {
+				c.MinLoopIterations = val
+			}
 		case "max-alloc-size":
 			if val, err := strconv.Atoi(f.Value.String()); err == nil {
 				c.MaxAllocSize = val
@@ -91,6 +424,13 @@ func (c *Config) ParseFlags(fs *flag.FlagSet) {
 			c.OpenAIAPIKey = f.Value.String()
 		case "openai-model":
 			c.OpenAIModel = f.Value.String()
+		case "openai-model-fallbacks":
+			if f.Value.String() != "" {
+				c.OpenAIFallbacks = strings.Split(f.Value.String(), ",")
+				for i := range c.OpenAIFallbacks {
+					c.OpenAIFallbacks[i] = strings.TrimSpace(c.OpenAIFallbacks[i])
+				}
+			}
 		case "openai-max-tokens":
 			if val, err := strconv.Atoi(f.Value.String()); err == nil {
 				c.OpenAIMaxTokens = val
@@ -117,3 +457,221 @@ func (c *Config) IsPatternDisabled(pattern string) bool {
 	}
 	return false
 }
+
+// IsPatternEnabled checks if a named detector should run. Disabled patterns never
+// run; with OnlyPatterns set only the listed ones do, and otherwise off-by-default
+// rules run only when listed in EnablePatterns.
+func (c *Config) IsPatternEnabled(pattern string) bool {
+	if c.IsPatternDisabled(pattern) {
+		return false
+	}
+	if len(c.OnlyPatterns) > 0 {
+		for _, only := range c.OnlyPatterns {
+			if only == pattern {
+				return true
+			}
+		}
+		return false
+	}
+	if rule, ok := LookupRule(pattern); ok && !rule.DefaultEnabled {
+		for _, enabled := range c.EnablePatterns {
+			if enabled == pattern {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+// RegisterFormatFuncs adds functions that allocate like fmt, such as a logging
+// library's formatting wrappers, so calls to them are reported along with
+// fmt's. Names are fully qualified as by types.Func.FullName:
+// example.com/log.Infof for functions and (*example.com/log.Logger).Infof for
+// methods.
+func (c *Config) RegisterFormatFuncs(names ...string) {
+	for _, name := range names {
+		if name = strings.TrimSpace(name); name != "" {
+			c.FormatFuncs = append(c.FormatFuncs, name)
+		}
+	}
+}
+
+// isFormatFunc reports whether the fully qualified function name was
+// registered with RegisterFormatFuncs
+func (c *Config) isFormatFunc(name string) bool {
+	for _, registered := range c.FormatFuncs {
+		if registered == name {
+			return true
+		}
+	}
+	return false
+}
+
+// runsUnnamedDetectors reports whether detectors that aren't registered rules
+// run; -only-patterns restricts a run to the named ones
+func (c *Config) runsUnnamedDetectors() bool {
+	return len(c.OnlyPatterns) == 0
+}
+
+// ValidatePatterns checks that every -only-patterns entry is a registered rule
+// and that none of them is also disabled, and that every message template
+// names a built-in message or rule and renders
+func (c *Config) ValidatePatterns() error {
+	for _, pattern := range c.OnlyPatterns {
+		if _, ok := LookupRule(pattern); !ok {
+			return fmt.Errorf("unknown rule %q in -only-patterns", pattern)
+		}
+		if c.IsPatternDisabled(pattern) {
+			return fmt.Errorf("rule %q is listed in both -only-patterns and -disable-patterns", pattern)
+		}
+	}
+	if c.SampleRate < 0 || c.SampleRate > 1 {
+		return fmt.Errorf("-sample-rate must be between 0 and 1, got %g", c.SampleRate)
+	}
+	if c.Report != "" && c.Report != "counts" {
+		return fmt.Errorf("unknown -report mode %q (want counts)", c.Report)
+	}
+	for name := range c.RuleSeverities {
+		if _, ok := LookupRule(name); !ok {
+			return fmt.Errorf("unknown rule %q in -rule-severity", name)
+		}
+	}
+	return validateMessageTemplates(c.MessageTemplates)
+}
+
+// Validate checks every setting of the configuration, once it has been
+// assembled from flags and defaults, and returns all the problems found
+// joined into one error, or nil. It covers the checks of ValidatePatterns.
+func (c *Config) Validate() error {
+	return c.validate(false)
+}
+
+// validate is Validate for a configuration used with an AIClient of its own
+// when aiClient is set, which doesn't need an API key
+func (c *Config) validate(aiClient bool) error {
+	var errs []error
+	add := func(format string, args ...interface{}) {
+		errs = append(errs, fmt.Errorf(format, args...))
+	}
+
+	if err := c.ValidatePatterns(); err != nil {
+		errs = append(errs, err)
+	}
+	for _, list := range []struct {
+		flag     string
+		patterns []string
+	}{
+		{"-disable-patterns", c.DisablePatterns},
+		{"-enable-patterns", c.EnablePatterns},
+	} {
+		for _, pattern := range list.patterns {
+			if _, ok := LookupRule(pattern); !ok && pattern != "" {
+				add("unknown rule %q in %s", pattern, list.flag)
+			}
+		}
+	}
+
+	if c.AIEnabled() && c.OpenAIAPIKey == "" && !aiClient {
+		add("AI suggestions are enabled for fixes but no OpenAI API key is set; set OPENAI_API_KEY or -openai-api-key, or pass -openai-disable for deterministic fixes only")
+	}
+
+	if c.OpenAITemperature < 0 || c.OpenAITemperature > 2 {
+		add("-openai-temperature must be between 0 and 2, got %g", c.OpenAITemperature)
+	}
+	if c.PprofThreshold < 0 || c.PprofThreshold > 100 {
+		add("-pprof-threshold must be a percentage between 0 and 100, got %g", c.PprofThreshold)
+	}
+	for _, size := range []struct {
+		flag  string
+		value int
+	}{
+		{"-max-alloc-size", c.MaxAllocSize},
+		{"-max-issues", c.MaxIssues},
+		{"-max-issues-per-file", c.MaxIssuesPerFile},
+		{"-max-depth", c.MaxDepth},
+		{"-max-file-size", c.MaxFileSize},
+		{"-min-loop-iterations", c.MinLoopIterations},
+		{"-openai-max-tokens", c.OpenAIMaxTokens},
+	} {
+		if size.value < 0 {
+			add("%s must not be negative, got %d", size.flag, size.value)
+		}
+	}
+	if c.AITimeBudget < 0 {
+		add("-ai-time-budget must not be negative, got %v", c.AITimeBudget)
+	}
+
+	for prefix, tags := range c.PathTags {
+		for _, tag := range tags {
+			if strings.TrimSpace(tag) == "" {
+				add("empty tag for path prefix %q in -path-tags", prefix)
+			}
+		}
+	}
+
+	switch c.Format {
+	case "", "text", "json", "jsonl", "sarif", "github", "csv":
+	default:
+		add("unknown -format %q (want text, json, jsonl, sarif, github or csv)", c.Format)
+	}
+
+	return errors.Join(errs...)
+}
+
+// Warnings returns the settings of the configuration that are harmless but
+// probably not what was meant, which unlike the problems found by Validate
+// don't stop the run: options tuning AI suggestions do nothing with them
+// disabled.
+func (c *Config) Warnings() []string {
+	var warnings []string
+	if c.OpenAIDisable {
+		for _, option := range []struct {
+			flag string
+			set  bool
+		}{
+			{"-openai-model-fallbacks", len(c.OpenAIFallbacks) > 0},
+			{"-ai-time-budget", c.AITimeBudget > 0},
+			{"-ai-log-requests", c.AILogRequests},
+			{"-ai-log-snippets", c.AILogSnippets},
+		} {
+			if option.set {
+				warnings = append(warnings, fmt.Sprintf("%s has no effect with -openai-disable", option.flag))
+			}
+		}
+	}
+	return warnings
+}
+
+// configWarned makes warnConfig log the warnings of the configuration once
+// per process rather than once per package
+var configWarned sync.Once
+
+// warnConfig logs the warnings of config
+func warnConfig(config *Config, logger *zap.Logger) {
+	configWarned.Do(func() {
+		for _, warning := range config.Warnings() {
+			logger.Warn("Questionable configuration: " + warning)
+		}
+	})
+}
+
+// AIEnabled reports whether AI suggestions are asked for: fixes are generated
+// without -openai-disable, other than the comments of -annotate, which never
+// use them
+func (c *Config) AIEnabled() bool {
+	return c.GeneratesFixes() && !c.Annotate && !c.OpenAIDisable
+}
+
+// GeneratesFixes reports whether fixes should be generated and tracked, either
+// to be written by -autofix or -annotate, saved by -save-fixes or checked by
+// -fail-on-fix
+func (c *Config) GeneratesFixes() bool {
+	return c.AutoFix || c.Annotate || c.SaveFixes != "" || c.FailOnFix
+}
+
+// WritesFixes reports whether tracked fixes should be written back to files;
+// -fail-on-fix never writes
+func (c *Config) WritesFixes() bool {
+	return (c.AutoFix || c.Annotate) && !c.FailOnFix
+}
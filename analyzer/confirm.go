@@ -0,0 +1,122 @@
+package analyzer
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"golang.org/x/tools/go/analysis"
+)
+
+// PendingFix is a single tracked edit awaiting confirmation under -interactive
+type PendingFix struct {
+	Filename string
+	Line     int               // Line the edit starts on
+	Edit     analysis.TextEdit // Edit to apply
+	Diff     string            // Lines removed and added by the edit, prefixed with - and +
+}
+
+// FixConfirmer decides whether each pending fix is applied under -interactive.
+// Confirmers that also implement io.Closer are closed after the fixes of each
+// package have been offered to them.
+type FixConfirmer interface {
+	ConfirmFix(fix PendingFix) bool
+}
+
+// fixConfirmer is consulted by Analyzer under -interactive
+var fixConfirmer FixConfirmer
+
+// SetFixConfirmer sets the confirmer Analyzer consults before applying each fix
+// under -interactive. Analyzers created with options use WithFixConfirmer.
+func SetFixConfirmer(confirmer FixConfirmer) {
+	fixConfirmer = confirmer
+}
+
+// ApplyConfirmedFixes offers every tracked fix to confirmer, file by file and in
+// source order, and applies only the ones it accepts
+func (ft *FixTracker) ApplyConfirmedFixes(autoFixer *AutoFixer, confirmer FixConfirmer) error {
+	ft.mu.Lock()
+	defer ft.mu.Unlock()
+
+	filenames := make([]string, 0, len(ft.fixes))
+	for filename := range ft.fixes {
+		filenames = append(filenames, filename)
+	}
+	sort.Strings(filenames)
+
+	for _, filename := range filenames {
+		content, err := os.ReadFile(filename)
+		if err != nil {
+			return fmt.Errorf("failed to apply fixes to %s: %v", filename, err)
+		}
+
+		edits := append([]analysis.TextEdit(nil), ft.fixes[filename]...)
+		sort.Slice(edits, func(i, j int) bool {
+			return edits[i].Pos < edits[j].Pos
+		})
+
+		var accepted []analysis.TextEdit
+		for _, edit := range edits {
+			fix, ok := autoFixer.pendingFix(filename, content, edit)
+			if ok && confirmer.ConfirmFix(fix) {
+				accepted = append(accepted, edit)
+			}
+		}
+
+		if len(accepted) > 0 {
+			if err := autoFixer.ApplyFixesToFile(filename, accepted); err != nil {
+				return fmt.Errorf("failed to apply fixes to %s: %v", filename, err)
+			}
+		}
+	}
+	return nil
+}
+
+// pendingFix describes edit against content, or returns false if the edit's
+// positions don't fall within it
+func (af *AutoFixer) pendingFix(filename string, content []byte, edit analysis.TextEdit) (PendingFix, bool) {
+	start := af.tokenPosToByteOffset(content, edit.Pos)
+	end := af.tokenPosToByteOffset(content, edit.End)
+	if start < 0 || end < start || end > len(content) {
+		return PendingFix{}, false
+	}
+
+	// Widen the edit to whole lines so the diff shows its context
+	lineStart := bytes.LastIndexByte(content[:start], '\n') + 1
+	lineEnd := len(content)
+	if i := bytes.IndexByte(content[end:], '\n'); i >= 0 {
+		lineEnd = end + i
+	}
+	if isInsertion(edit) && start == lineStart {
+		// Insertions at a line start, like annotations, don't change that line
+		lineEnd = lineStart
+	}
+
+	before := string(content[lineStart:lineEnd])
+	after := string(content[lineStart:start]) + string(edit.NewText) + string(content[end:lineEnd])
+
+	var diff strings.Builder
+	for _, line := range diffLines(before) {
+		fmt.Fprintf(&diff, "-%s\n", line)
+	}
+	for _, line := range diffLines(after) {
+		fmt.Fprintf(&diff, "+%s\n", line)
+	}
+
+	return PendingFix{
+		Filename: filename,
+		Line:     af.fset.Position(edit.Pos).Line,
+		Edit:     edit,
+		Diff:     diff.String(),
+	}, true
+}
+
+// diffLines splits text into lines for a diff, without a trailing empty line
+func diffLines(text string) []string {
+	if text == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+}
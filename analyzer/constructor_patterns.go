@@ -0,0 +1,301 @@
+package analyzer
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"strings"
+	"unicode"
+
+	"golang.org/x/tools/go/analysis"
+)
+
+// detectConstructorAppend detects appends to a nil or unsized slice inside a
+// constructor: a function named NewXxx or one returning a slice. These are
+// where the slice is built up in full, so pre-sizing it pays off most. It
+// reports whether an issue was reported.
+func (pd *PatternDetector) detectConstructorAppend(call *ast.CallExpr, report func(node ast.Node, msg string)) bool {
+	if !pd.config.IsPatternEnabled("constructor-append") || len(call.Args) < 2 {
+		return false
+	}
+
+	fn := pd.enclosingFunc()
+	if !isConstructor(fn) {
+		return false
+	}
+
+	assign, ok := pd.ancestor(0).(*ast.AssignStmt)
+	if !ok || len(assign.Lhs) != 1 || len(assign.Rhs) != 1 || assign.Rhs[0] != call {
+		return false
+	}
+	target, ok := assign.Lhs[0].(*ast.Ident)
+	if !ok {
+		return false
+	}
+	dst, ok := call.Args[0].(*ast.Ident)
+	if !ok || pd.info.ObjectOf(dst) == nil || pd.info.ObjectOf(dst) != pd.info.ObjectOf(target) {
+		return false
+	}
+
+	decl := pd.unsizedSliceDecl(fn, pd.info.ObjectOf(dst))
+	if decl == nil {
+		return false
+	}
+
+	msg := fmt.Sprintf("constructor appends to unsized slice %s; consider pre-allocating its capacity with make()", dst.Name)
+	var fix *analysis.SuggestedFix
+	if loop, _ := pd.enclosingLoop(call); loop != nil {
+		if bound, ok := pd.loopBound(loop); ok {
+			msg = fmt.Sprintf("constructor appends to unsized slice %s; consider pre-allocating with make(%s, 0, %s)", dst.Name, decl.sliceType, bound)
+			fix = pd.constructorAppendFix(decl, loop, bound)
+		}
+	}
+
+	if fix != nil {
+		pd.reportRule(report, "constructor-append", call, msg, *fix)
+	} else {
+		pd.reportRule(report, "constructor-append", call, msg)
+	}
+	return true
+}
+
+// isConstructor reports whether fn is named NewXxx or newXxx, or returns a slice
+func isConstructor(fn ast.Node) bool {
+	if decl, ok := fn.(*ast.FuncDecl); ok {
+		name := decl.Name.Name
+		for _, prefix := range []string{"New", "new"} {
+			if rest := strings.TrimPrefix(name, prefix); rest != name && (rest == "" || unicode.IsUpper([]rune(rest)[0])) {
+				return true
+			}
+		}
+	}
+
+	ft := funcType(fn)
+	if ft == nil || ft.Results == nil {
+		return false
+	}
+	for _, field := range ft.Results.List {
+		if array, ok := field.Type.(*ast.ArrayType); ok && array.Len == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// sliceDecl describes the declaration of an unsized slice variable
+type sliceDecl struct {
+	stmt      ast.Stmt // the declaring statement
+	name      string
+	sliceType string   // source of the slice type, such as []Item
+	rhs       ast.Expr // initializer to replace, or nil for `var s []T`
+}
+
+// unsizedSliceDecl finds the declaration of obj in fn, returning it if obj
+// starts out as a nil or empty slice with no capacity
+func (pd *PatternDetector) unsizedSliceDecl(fn ast.Node, obj types.Object) *sliceDecl {
+	if !isLocalVar(obj) {
+		return nil
+	}
+
+	var decl *sliceDecl
+	ast.Inspect(fn, func(n ast.Node) bool {
+		if decl != nil {
+			return false
+		}
+		switch stmt := n.(type) {
+		case *ast.DeclStmt:
+			gen, ok := stmt.Decl.(*ast.GenDecl)
+			if !ok || gen.Tok != token.VAR || len(gen.Specs) != 1 {
+				return true
+			}
+			spec := gen.Specs[0].(*ast.ValueSpec)
+			if len(spec.Names) != 1 || spec.Names[0].Pos() != obj.Pos() || len(spec.Values) != 0 || spec.Type == nil {
+				return true
+			}
+			if sliceType, ok := pd.nodeSource(spec.Type); ok {
+				decl = &sliceDecl{stmt: stmt, name: obj.Name(), sliceType: sliceType}
+			}
+		case *ast.AssignStmt:
+			if stmt.Tok != token.DEFINE || len(stmt.Lhs) != 1 || len(stmt.Rhs) != 1 || stmt.Lhs[0].Pos() != obj.Pos() {
+				return true
+			}
+			if typeExpr := pd.unsizedSliceExpr(stmt.Rhs[0]); typeExpr != nil {
+				if sliceType, ok := pd.nodeSource(typeExpr); ok {
+					decl = &sliceDecl{stmt: stmt, name: obj.Name(), sliceType: sliceType, rhs: stmt.Rhs[0]}
+				}
+			}
+		}
+		return true
+	})
+	return decl
+}
+
+// unsizedSliceExpr returns the slice type of `[]T{}` or `make([]T, 0)`, or nil
+// if expr isn't one of those
+func (pd *PatternDetector) unsizedSliceExpr(expr ast.Expr) ast.Expr {
+	switch e := expr.(type) {
+	case *ast.CompositeLit:
+		if array, ok := e.Type.(*ast.ArrayType); ok && array.Len == nil && len(e.Elts) == 0 {
+			return e.Type
+		}
+	case *ast.CallExpr:
+		if pd.isMakeCall(e) && len(e.Args) == 2 {
+			if lit, ok := e.Args[1].(*ast.BasicLit); ok && lit.Value == "0" {
+				return e.Args[0]
+			}
+		}
+	}
+	return nil
+}
+
+// constructorAppendFix pre-sizes the slice declared by decl with bound, if
+// every identifier in bound is already in scope at the declaration
+func (pd *PatternDetector) constructorAppendFix(decl *sliceDecl, loop ast.Stmt, bound string) *analysis.SuggestedFix {
+	var boundExpr ast.Expr
+	switch l := loop.(type) {
+	case *ast.RangeStmt:
+		boundExpr = l.X
+	case *ast.ForStmt:
+		boundExpr = l.Cond.(*ast.BinaryExpr).Y
+	}
+
+	inScope := true
+	ast.Inspect(boundExpr, func(n ast.Node) bool {
+		if ident, ok := n.(*ast.Ident); ok {
+			if obj := pd.info.ObjectOf(ident); obj == nil || (isLocalVar(obj) && obj.Pos() >= decl.stmt.Pos()) {
+				inScope = false
+			}
+		}
+		return inScope
+	})
+	if !inScope {
+		return nil
+	}
+
+	replacement := fmt.Sprintf("make(%s, 0, %s)", decl.sliceType, bound)
+	edit := analysis.TextEdit{Pos: decl.stmt.Pos(), End: decl.stmt.End(), NewText: []byte(fmt.Sprintf("%s := %s", decl.name, replacement))}
+	if decl.rhs != nil {
+		edit = analysis.TextEdit{Pos: decl.rhs.Pos(), End: decl.rhs.End(), NewText: []byte(replacement)}
+	}
+
+	return &analysis.SuggestedFix{
+		Message:   fmt.Sprintf("Pre-allocate %s with %s", decl.name, replacement),
+		TextEdits: []analysis.TextEdit{edit},
+	}
+}
+
+// detectGetterAlloc detects getters, methods without parameters returning a
+// slice or map, that build a new collection on every call, usually as a
+// defensive copy. Callers that read the collection often pay for the copy each
+// time, where data that doesn't change could be cached or exposed as a view.
+// The getter is reported once, at the first collection it returns.
+func (pd *PatternDetector) detectGetterAlloc(decl *ast.FuncDecl, report func(node ast.Node, msg string)) {
+	if !pd.config.IsPatternEnabled("getter-alloc") || !isGetter(decl, pd.info) {
+		return
+	}
+
+	var fresh ast.Expr
+	ast.Inspect(decl.Body, func(n ast.Node) bool {
+		if fresh != nil {
+			return false
+		}
+		switch n := n.(type) {
+		case *ast.FuncLit:
+			return false
+		case *ast.ReturnStmt:
+			if len(n.Results) == 1 {
+				fresh = pd.freshCollection(decl, n.Results[0])
+			}
+		}
+		return true
+	})
+	if fresh == nil {
+		return
+	}
+
+	kind := "slice"
+	if _, ok := pd.info.TypeOf(fresh).Underlying().(*types.Map); ok {
+		kind = "map"
+	}
+	name := receiverName(decl.Recv.List[0].Type) + "." + decl.Name.Name
+	pd.reportRule(report, "getter-alloc", fresh, fmt.Sprintf("getter %s builds a new %s on every call; if the data doesn't change between calls, consider building it once and caching it, or exposing a read-only view such as an iterator or Len and At methods", name, kind))
+}
+
+// isGetter reports whether decl is a method without parameters returning
+// just a slice or a map
+func isGetter(decl *ast.FuncDecl, info *types.Info) bool {
+	if decl.Recv == nil || len(decl.Recv.List) == 0 || decl.Body == nil {
+		return false
+	}
+	if decl.Type.Params.NumFields() != 0 || decl.Type.Results.NumFields() != 1 {
+		return false
+	}
+	t := info.TypeOf(decl.Type.Results.List[0].Type)
+	if t == nil {
+		return false
+	}
+	switch t.Underlying().(type) {
+	case *types.Slice, *types.Map:
+		return true
+	}
+	return false
+}
+
+// freshCollection returns the expression allocating the collection result
+// evaluates to, if it's built on the spot: a make call, a composite literal, a
+// clone or an append to an empty slice, or a local variable of fn initialized
+// with one of these
+func (pd *PatternDetector) freshCollection(fn *ast.FuncDecl, result ast.Expr) ast.Expr {
+	isFresh := func(expr ast.Expr) bool {
+		switch e := ast.Unparen(expr).(type) {
+		case *ast.CompositeLit:
+			return true
+		case *ast.CallExpr:
+			return pd.isMakeCall(e) || pd.isPkgFunc(e, "slices", "Clone") || pd.isPkgFunc(e, "maps", "Clone") ||
+				(pd.isAppendCall(e) && len(e.Args) > 0 && pd.isEmptySlice(e.Args[0]))
+		}
+		return false
+	}
+	if isFresh(result) {
+		return ast.Unparen(result)
+	}
+
+	ident, ok := ast.Unparen(result).(*ast.Ident)
+	if !ok {
+		return nil
+	}
+	obj := pd.info.ObjectOf(ident)
+	if !isLocalVar(obj) {
+		return nil
+	}
+
+	var init ast.Expr
+	ast.Inspect(fn.Body, func(n ast.Node) bool {
+		if init != nil {
+			return false
+		}
+		switch n := n.(type) {
+		case *ast.AssignStmt:
+			if n.Tok != token.DEFINE || len(n.Lhs) != len(n.Rhs) {
+				return true
+			}
+			for i, lhs := range n.Lhs {
+				if id, ok := lhs.(*ast.Ident); ok && pd.info.Defs[id] == obj {
+					init = n.Rhs[i]
+				}
+			}
+		case *ast.ValueSpec:
+			for i, id := range n.Names {
+				if pd.info.Defs[id] == obj && i < len(n.Values) {
+					init = n.Values[i]
+				}
+			}
+		}
+		return true
+	})
+	if init != nil && isFresh(init) {
+		return ast.Unparen(init)
+	}
+	return nil
+}
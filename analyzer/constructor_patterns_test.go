@@ -0,0 +1,182 @@
+package analyzer
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestConstructorAppend(t *testing.T) {
+	code := `package main
+
+type Item struct{ Name string }
+
+type List struct{ items []Item }
+
+func NewList(names []string) *List {
+	var items []Item
+	for _, name := range names {
+		items = append(items, Item{Name: name})
+	}
+	return &List{items: items}
+}
+
+func evens(n int) []int {
+	out := []int{}
+	for i := 0; i < n; i++ {
+		if i%2 == 0 {
+			out = append(out, i)
+		}
+	}
+	return out
+}
+
+func NewTags(a, b string) []string {
+	tags := make([]string, 0)
+	tags = append(tags, a)
+	return append(tags, b)
+}
+
+func NewSized(names []string) []string {
+	out := make([]string, 0, len(names))
+	for _, name := range names {
+		out = append(out, name)
+	}
+	return out
+}
+
+func Newsletter(names []string) int {
+	var seen []string
+	for _, name := range names {
+		seen = append(seen, name)
+	}
+	return len(seen)
+}
+`
+	pass, _ := newTestPass(t, code)
+
+	var found []Issue
+	for _, issue := range analyzeFile(pass.Files[0], pass.TypesInfo, pass.Fset, DefaultConfig()) {
+		if issue.Pattern == "constructor-append" {
+			found = append(found, issue)
+		}
+	}
+
+	expected := []struct {
+		line int
+		msg  string
+		fix  string
+	}{
+		{10, "constructor appends to unsized slice items; consider pre-allocating with make([]Item, 0, len(names))", "items := make([]Item, 0, len(names))"},
+		{19, "constructor appends to unsized slice out; consider pre-allocating with make([]int, 0, n)", "make([]int, 0, n)"},
+		{27, "constructor appends to unsized slice tags; consider pre-allocating its capacity with make()", ""},
+	}
+	if len(found) != len(expected) {
+		t.Fatalf("Expected %d constructor-append issues, got %d: %v", len(expected), len(found), found)
+	}
+
+	for i, want := range expected {
+		issue := found[i]
+		if issue.Pos.Line != want.line || issue.Message != want.msg {
+			t.Errorf("Expected %q on line %d, got %q on line %d", want.msg, want.line, issue.Message, issue.Pos.Line)
+		}
+
+		if want.fix == "" {
+			if len(issue.SuggestedFixes) != 0 {
+				t.Errorf("Expected no fix without a loop bound, got %v", issue.SuggestedFixes)
+			}
+			continue
+		}
+		if len(issue.SuggestedFixes) != 1 || len(issue.SuggestedFixes[0].TextEdits) != 1 {
+			t.Fatalf("Expected a single-edit fix, got %v", issue.SuggestedFixes)
+		}
+		edit := issue.SuggestedFixes[0].TextEdits[0]
+		if string(edit.NewText) != want.fix {
+			t.Errorf("Expected replacement %q, got %q", want.fix, edit.NewText)
+		}
+
+		// The edited source must still declare the slice once
+		start, end := pass.Fset.Position(edit.Pos), pass.Fset.Position(edit.End)
+		fixed := code[:start.Offset] + string(edit.NewText) + code[end.Offset:]
+		if strings.Count(fixed, want.fix) != 1 {
+			t.Errorf("Expected the fix to replace the declaration, got:\n%s", fixed)
+		}
+	}
+
+	// The scoped rule replaces the generic append-in-loop message
+	issues := inspectSource(t, code, DefaultConfig())
+	if got := countMatching(issues, "append in loop may cause multiple reallocations"); got != 2 {
+		t.Errorf("Expected the generic message only for NewSized and Newsletter, got %d: %v", got, issues)
+	}
+}
+
+func TestGetterAlloc(t *testing.T) {
+	code := `
+package main
+
+import "slices"
+
+type store struct {
+	items []string
+	index map[string]int
+	cache []string
+}
+
+func (s *store) Items() []string {
+	out := make([]string, len(s.items))
+	copy(out, s.items)
+	return out
+}
+
+func (s *store) Index() map[string]int {
+	return map[string]int{"size": len(s.index)}
+}
+
+func (s store) Sorted() []string {
+	return slices.Clone(s.items)
+}
+
+func (s *store) Cached() []string {
+	return s.cache
+}
+
+func (s *store) Lookup(key string) []string {
+	return make([]string, 0, s.index[key])
+}
+
+func (s *store) Empty() []string {
+	if len(s.items) == 0 {
+		return nil
+	}
+	return append([]string(nil), s.items...)
+}
+`
+	const msg = "builds a new"
+
+	issues := inspectSource(t, code, DefaultConfig())
+
+	tests := []struct {
+		name     string
+		contains string
+		expected int
+	}{
+		{"make returned through a variable", "getter (*store).Items builds a new slice on every call; if the data doesn't change between calls, consider building it once and caching it", 1},
+		{"map literal", "getter (*store).Index builds a new map on every call", 1},
+		{"clone on a value receiver", "getter store.Sorted builds a new slice", 1},
+		{"append to an empty slice", "getter (*store).Empty builds a new slice", 1},
+		{"not fields or methods with parameters", msg, 4},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := countMatching(issues, tt.contains); got != tt.expected {
+				t.Errorf("Expected %d issues containing %q, got %d: %v", tt.expected, tt.contains, got, issues)
+			}
+		})
+	}
+
+	config := DefaultConfig()
+	config.DisablePatterns = []string{"getter-alloc"}
+	if got := countMatching(inspectSource(t, code, config), msg); got != 0 {
+		t.Errorf("Expected getter-alloc to be disabled, got %d issues", got)
+	}
+}
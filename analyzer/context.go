@@ -0,0 +1,286 @@
+package analyzer
+
+import (
+	"go/ast"
+	"go/constant"
+	"go/token"
+	"go/types"
+	"strings"
+)
+
+// enter pushes a node onto the ancestor stack
+func (pd *PatternDetector) enter(n ast.Node) {
+	pd.stack = append(pd.stack, n)
+}
+
+// leave pops the most recently entered node from the ancestor stack
+func (pd *PatternDetector) leave() {
+	if len(pd.stack) > 0 {
+		pd.stack = pd.stack[:len(pd.stack)-1]
+	}
+}
+
+// enclosingLoopBody returns the body of the innermost loop whose body contains
+// the node, stopping at function boundaries. Loop headers (init, condition,
+// post and range expressions) run once and are not considered part of the loop.
+func (pd *PatternDetector) enclosingLoopBody(node ast.Node) *ast.BlockStmt {
+	_, body := pd.enclosingLoop(node)
+	return body
+}
+
+// enclosingLoop returns the innermost *ast.ForStmt or *ast.RangeStmt whose body
+// contains the node, along with that body, with the same rules as enclosingLoopBody.
+// Loops known to run fewer than -min-loop-iterations times are passed over,
+// so the node only counts as repeating if a loop around them does.
+func (pd *PatternDetector) enclosingLoop(node ast.Node) (ast.Stmt, *ast.BlockStmt) {
+	for i := len(pd.stack) - 1; i >= 0; i-- {
+		var loop ast.Stmt
+		var body *ast.BlockStmt
+		switch n := pd.stack[i].(type) {
+		case *ast.FuncLit, *ast.FuncDecl:
+			return nil, nil
+		case *ast.ForStmt:
+			loop, body = n, n.Body
+		case *ast.RangeStmt:
+			loop, body = n, n.Body
+		}
+		if body == nil || !encloses(body, node) {
+			continue
+		}
+		if pd.config.MinLoopIterations > 0 {
+			if n, ok := pd.loopIterations(loop); ok && n < int64(pd.config.MinLoopIterations) {
+				continue
+			}
+		}
+		return loop, body
+	}
+	return nil, nil
+}
+
+// loopIterations returns the number of times loop runs if it is known
+// statically: a range over an array, a constant string or a constant integer,
+// or a for loop counting up by a constant step from one constant to another
+func (pd *PatternDetector) loopIterations(loop ast.Stmt) (int64, bool) {
+	switch l := loop.(type) {
+	case *ast.RangeStmt:
+		tv, ok := pd.info.Types[l.X]
+		if !ok || tv.Type == nil {
+			return 0, false
+		}
+		if tv.Value != nil {
+			switch tv.Value.Kind() {
+			case constant.String:
+				return int64(len(constant.StringVal(tv.Value))), true
+			case constant.Int:
+				return constant.Int64Val(tv.Value)
+			}
+			return 0, false
+		}
+		t := tv.Type.Underlying()
+		if ptr, ok := t.(*types.Pointer); ok {
+			t = ptr.Elem().Underlying()
+		}
+		if array, ok := t.(*types.Array); ok {
+			return array.Len(), true
+		}
+	case *ast.ForStmt:
+		init, ok := l.Init.(*ast.AssignStmt)
+		if !ok || len(init.Lhs) != 1 || len(init.Rhs) != 1 {
+			return 0, false
+		}
+		counter, ok := init.Lhs[0].(*ast.Ident)
+		if !ok {
+			return 0, false
+		}
+		cond, ok := l.Cond.(*ast.BinaryExpr)
+		if !ok || (cond.Op != token.LSS && cond.Op != token.LEQ) {
+			return 0, false
+		}
+		if x, ok := cond.X.(*ast.Ident); !ok || pd.info.ObjectOf(x) != pd.info.ObjectOf(counter) {
+			return 0, false
+		}
+		obj := pd.info.ObjectOf(counter)
+		step, ok := pd.loopStep(l.Post, obj)
+		if !ok || pd.isModifiedIn(l.Body, obj) {
+			return 0, false
+		}
+		from, ok := pd.constInt(init.Rhs[0])
+		if !ok {
+			return 0, false
+		}
+		to, ok := pd.constInt(cond.Y)
+		if !ok {
+			return 0, false
+		}
+		if cond.Op == token.LEQ {
+			to++
+		}
+		if to <= from {
+			return 0, true
+		}
+		return (to - from + step - 1) / step, true
+	}
+	return 0, false
+}
+
+// loopStep returns the constant amount post, the post statement of a for
+// loop, adds to counter
+func (pd *PatternDetector) loopStep(post ast.Stmt, counter types.Object) (int64, bool) {
+	switch p := post.(type) {
+	case *ast.IncDecStmt:
+		if x, ok := p.X.(*ast.Ident); ok && p.Tok == token.INC && pd.info.ObjectOf(x) == counter {
+			return 1, true
+		}
+	case *ast.AssignStmt:
+		if p.Tok != token.ADD_ASSIGN || len(p.Lhs) != 1 || len(p.Rhs) != 1 {
+			return 0, false
+		}
+		if x, ok := p.Lhs[0].(*ast.Ident); !ok || pd.info.ObjectOf(x) != counter {
+			return 0, false
+		}
+		if step, ok := pd.constInt(p.Rhs[0]); ok && step > 0 {
+			return step, true
+		}
+	}
+	return 0, false
+}
+
+// ancestor returns the nth ancestor of the node currently being visited, where
+// 0 is its parent, or nil if the stack isn't that deep
+func (pd *PatternDetector) ancestor(n int) ast.Node {
+	if n >= len(pd.stack) {
+		return nil
+	}
+	return pd.stack[len(pd.stack)-1-n]
+}
+
+// enclosingFunc returns the innermost *ast.FuncDecl or *ast.FuncLit on the ancestor stack
+func (pd *PatternDetector) enclosingFunc() ast.Node {
+	for i := len(pd.stack) - 1; i >= 0; i-- {
+		switch n := pd.stack[i].(type) {
+		case *ast.FuncDecl, *ast.FuncLit:
+			return n
+		}
+	}
+	return nil
+}
+
+// funcType returns the signature of a *ast.FuncDecl or *ast.FuncLit
+func funcType(fn ast.Node) *ast.FuncType {
+	switch f := fn.(type) {
+	case *ast.FuncDecl:
+		return f.Type
+	case *ast.FuncLit:
+		return f.Type
+	}
+	return nil
+}
+
+// isInHTTPHandler reports whether the enclosing function has the
+// func(http.ResponseWriter, *http.Request) signature and so runs once per request
+func (pd *PatternDetector) isInHTTPHandler() bool {
+	ft := funcType(pd.enclosingFunc())
+	if ft == nil || ft.Params == nil {
+		return false
+	}
+
+	var params []types.Type
+	for _, field := range ft.Params.List {
+		t := pd.info.TypeOf(field.Type)
+		names := len(field.Names)
+		if names == 0 {
+			names = 1
+		}
+		for i := 0; i < names; i++ {
+			params = append(params, t)
+		}
+	}
+	if len(params) != 2 {
+		return false
+	}
+
+	ptr, ok := params[1].(*types.Pointer)
+	return ok && isNamedType(params[0], "net/http", "ResponseWriter") && isNamedType(ptr.Elem(), "net/http", "Request")
+}
+
+// isNamedType reports whether t is the named type path.name
+func isNamedType(t types.Type, path, name string) bool {
+	named, ok := t.(*types.Named)
+	if !ok {
+		return false
+	}
+	obj := named.Obj()
+	return obj.Pkg() != nil && obj.Pkg().Path() == path && obj.Name() == name
+}
+
+// encloses reports whether node lies within the source range of outer
+func encloses(outer, node ast.Node) bool {
+	return outer != nil && outer.Pos() <= node.Pos() && node.End() <= outer.End()
+}
+
+// withinRange reports whether pos lies within the source range of node
+func withinRange(node ast.Node, pos token.Pos) bool {
+	return node.Pos() <= pos && pos < node.End()
+}
+
+// runsOnce reports whether pos is in code that runs once at program start: the
+// body of an init function or a package-level var initializer. Function
+// literals inside them run whenever they are called, so they don't count.
+func runsOnce(f *ast.File, pos token.Pos) bool {
+	for _, decl := range f.Decls {
+		if !withinRange(decl, pos) {
+			continue
+		}
+		switch d := decl.(type) {
+		case *ast.FuncDecl:
+			if d.Recv != nil || d.Name.Name != "init" || d.Body == nil || !withinRange(d.Body, pos) {
+				return false
+			}
+		case *ast.GenDecl:
+			if d.Tok != token.VAR {
+				return false
+			}
+		}
+
+		once := true
+		ast.Inspect(decl, func(n ast.Node) bool {
+			if lit, ok := n.(*ast.FuncLit); ok && withinRange(lit.Body, pos) {
+				once = false
+			}
+			return once
+		})
+		return once
+	}
+	return false
+}
+
+// allowAllocDirective, in a function's doc comment, marks the allocations in
+// its body as intentional, so none of them are reported
+const allowAllocDirective = "//stackalloc:allow-alloc"
+
+// allowsAlloc reports whether pos is in the body of a function declared with
+// the allowAllocDirective, including function literals within it
+func allowsAlloc(f *ast.File, pos token.Pos) bool {
+	for _, decl := range f.Decls {
+		fd, ok := decl.(*ast.FuncDecl)
+		if !ok || fd.Body == nil || !withinRange(fd.Body, pos) {
+			continue
+		}
+		return hasDirective(fd.Doc, allowAllocDirective)
+	}
+	return false
+}
+
+// hasDirective reports whether doc contains directive on a line of its own,
+// optionally followed by a reason
+func hasDirective(doc *ast.CommentGroup, directive string) bool {
+	if doc == nil {
+		return false
+	}
+	for _, c := range doc.List {
+		if c.Text == directive || strings.HasPrefix(c.Text, directive+" ") {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,201 @@
+package analyzer
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"strings"
+
+	"golang.org/x/tools/go/analysis"
+)
+
+// detectCompositeConversion detects conversions involving slice, map and
+// string types. Converting between string and []byte or []rune copies the
+// data into a new allocation; converting between types with identical
+// underlying types, such as a named slice type and its unnamed form, is free
+// and only reported with -verbose.
+func (pd *PatternDetector) detectCompositeConversion(call *ast.CallExpr, report func(node ast.Node, msg string)) {
+	if !pd.config.IsPatternEnabled("composite-conversion") || len(call.Args) != 1 {
+		return
+	}
+	tv, ok := pd.info.Types[call.Fun]
+	if !ok || !tv.IsType() {
+		return
+	}
+	to, from := tv.Type, pd.info.TypeOf(call.Args[0])
+	if from == nil || types.Identical(from, to) {
+		return
+	}
+
+	qualifier := types.RelativeTo(namedPkg(to, from))
+	toName, fromName := types.TypeString(to, qualifier), types.TypeString(from, qualifier)
+
+	switch {
+	case isString(from) && isByteOrRuneSlice(to), isByteOrRuneSlice(from) && isString(to):
+		if pd.isNonAllocatingConversion(call) {
+			return
+		}
+		pd.reportRule(report, "composite-conversion", call, fmt.Sprintf("converting %s to %s copies the data into a new allocation; consider keeping a single representation", fromName, toName))
+	case pd.config.Verbose && isCompositeType(to) && types.Identical(from.Underlying(), to.Underlying()):
+		pd.reportRule(report, "composite-conversion", call, fmt.Sprintf("converting %s to %s is free since both have underlying type %s; the conversion only changes the type's methods", fromName, toName, types.TypeString(to.Underlying(), qualifier)))
+	}
+}
+
+// appendFuncs maps fmt and strconv functions returning a string to the
+// functions that append the same text to a []byte instead
+var appendFuncs = map[string]map[string]string{
+	"fmt": {
+		"Sprint":   "Append",
+		"Sprintf":  "Appendf",
+		"Sprintln": "Appendln",
+	},
+	"strconv": {
+		"Itoa":        "AppendInt",
+		"FormatInt":   "AppendInt",
+		"FormatUint":  "AppendUint",
+		"FormatFloat": "AppendFloat",
+		"FormatBool":  "AppendBool",
+		"Quote":       "AppendQuote",
+	},
+}
+
+// detectFormatThenConvert detects []byte(fmt.Sprintf(...)) and similar
+// conversions of a formatted string, which allocate the string and then copy
+// it into a second allocation. The fmt calls are rewritten to fmt.Append,
+// fmt.Appendf or fmt.Appendln when the fmt package in use has them (Go 1.19+).
+// It reports whether the conversion was reported, so composite-conversion can
+// leave it alone.
+func (pd *PatternDetector) detectFormatThenConvert(call *ast.CallExpr, report func(node ast.Node, msg string)) bool {
+	if !pd.config.IsPatternEnabled("format-then-convert") || len(call.Args) != 1 {
+		return false
+	}
+	tv, ok := pd.info.Types[call.Fun]
+	if !ok || !tv.IsType() || !isByteSlice(tv.Type) {
+		return false
+	}
+	format, ok := ast.Unparen(call.Args[0]).(*ast.CallExpr)
+	if !ok {
+		return false
+	}
+	path, name := pd.pkgFunc(format)
+	appendName, ok := appendFuncs[path][name]
+	if !ok {
+		return false
+	}
+
+	msg := fmt.Sprintf("[]byte(%s.%s(...)) allocates the string and then copies it into a second allocation; consider %s.%s to format directly into a []byte, reusing a buffer where possible", path, name, path, appendName)
+	if path != "fmt" || !pd.hasPkgFunc(format, appendName) {
+		pd.reportRule(report, "format-then-convert", call, msg)
+		return true
+	}
+
+	args := []string{"nil"}
+	for _, arg := range format.Args {
+		src, ok := pd.nodeSource(arg)
+		if !ok {
+			pd.reportRule(report, "format-then-convert", call, msg)
+			return true
+		}
+		args = append(args, src)
+	}
+	if format.Ellipsis.IsValid() {
+		args[len(args)-1] += "..."
+	}
+
+	// Keep whatever name the fmt package was imported under
+	pkg := format.Fun.(*ast.SelectorExpr).X.(*ast.Ident).Name
+	replacement := fmt.Sprintf("%s.%s(%s)", pkg, appendName, strings.Join(args, ", "))
+
+	pd.reportRule(report, "format-then-convert", call, msg, analysis.SuggestedFix{
+		Message: fmt.Sprintf("Replace with %s.%s", pkg, appendName),
+		TextEdits: []analysis.TextEdit{
+			{
+				Pos:     call.Pos(),
+				End:     call.End(),
+				NewText: []byte(replacement),
+			},
+		},
+	})
+	return true
+}
+
+// hasPkgFunc reports whether the package call's function belongs to declares
+// a function called name, which isn't the case for fmt.Appendf before Go 1.19
+func (pd *PatternDetector) hasPkgFunc(call *ast.CallExpr, name string) bool {
+	ident := call.Fun.(*ast.SelectorExpr).X.(*ast.Ident)
+	pkgName, ok := pd.info.ObjectOf(ident).(*types.PkgName)
+	if !ok {
+		return false
+	}
+	_, ok = pkgName.Imported().Scope().Lookup(name).(*types.Func)
+	return ok
+}
+
+// isNonAllocatingConversion reports whether the compiler avoids copying for the
+// string conversion call because of where it appears: as a map key in a lookup,
+// as an operand of a comparison, or as a []byte range expression
+func (pd *PatternDetector) isNonAllocatingConversion(call *ast.CallExpr) bool {
+	switch parent := pd.ancestor(0).(type) {
+	case *ast.IndexExpr:
+		t := pd.info.TypeOf(parent.X)
+		if t == nil || parent.Index != call {
+			return false
+		}
+		if _, ok := t.Underlying().(*types.Map); !ok {
+			return false
+		}
+		// Storing under the key has to copy it
+		if assign, ok := pd.ancestor(1).(*ast.AssignStmt); ok {
+			for _, lhs := range assign.Lhs {
+				if lhs == parent {
+					return false
+				}
+			}
+		}
+		return true
+	case *ast.BinaryExpr:
+		switch parent.Op {
+		case token.EQL, token.NEQ, token.LSS, token.LEQ, token.GTR, token.GEQ:
+			return true
+		}
+	case *ast.RangeStmt:
+		return parent.X == call && isByteSlice(pd.info.TypeOf(call))
+	}
+	return false
+}
+
+// isString reports whether t is a string type
+func isString(t types.Type) bool {
+	basic, ok := t.Underlying().(*types.Basic)
+	return ok && basic.Info()&types.IsString != 0
+}
+
+// isByteOrRuneSlice reports whether t is a slice of bytes or runes
+func isByteOrRuneSlice(t types.Type) bool {
+	slice, ok := t.Underlying().(*types.Slice)
+	if !ok {
+		return false
+	}
+	basic, ok := slice.Elem().Underlying().(*types.Basic)
+	return ok && (basic.Kind() == types.Byte || basic.Kind() == types.Rune)
+}
+
+// isCompositeType reports whether t is a slice or map type
+func isCompositeType(t types.Type) bool {
+	switch t.Underlying().(type) {
+	case *types.Slice, *types.Map:
+		return true
+	}
+	return false
+}
+
+// namedPkg returns the package of the first named type among ts, or nil
+func namedPkg(ts ...types.Type) *types.Package {
+	for _, t := range ts {
+		if named, ok := t.(*types.Named); ok && named.Obj().Pkg() != nil {
+			return named.Obj().Pkg()
+		}
+	}
+	return nil
+}
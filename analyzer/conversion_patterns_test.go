@@ -0,0 +1,122 @@
+package analyzer
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCompositeConversion(t *testing.T) {
+	code := `
+package main
+
+type IDs []int
+
+type Header map[string][]string
+
+func convert(s string, b []byte, ids []int, h map[string][]string, counts map[string]int) {
+	_ = []byte(s)
+	_ = string(b)
+	_ = []rune(s)
+
+	_ = IDs(ids)
+	_ = Header(h)
+
+	_ = counts[string(b)]
+	counts[string(b)] = 1
+	_ = string(b) == s
+	for range []byte(s) {
+	}
+}
+`
+	tests := []struct {
+		name     string
+		verbose  bool
+		contains string
+		expected int
+	}{
+		{"string to []byte allocates", false, "converting string to []byte copies the data into a new allocation", 1},
+		{"[]byte to string allocates except in lookups and comparisons", false, "converting []byte to string copies the data", 2},
+		{"string to []rune allocates", false, "converting string to []rune copies the data", 1},
+		{"free conversions are quiet by default", false, "is free", 0},
+		{"named slice conversion is free", true, "converting []int to IDs is free since both have underlying type []int", 1},
+		{"named map conversion is free", true, "converting map[string][]string to Header is free", 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := DefaultConfig()
+			config.Verbose = tt.verbose
+			issues := inspectSource(t, code, config)
+			if got := countMatching(issues, tt.contains); got != tt.expected {
+				t.Errorf("Expected %d issues containing %q, got %d: %v", tt.expected, tt.contains, got, issues)
+			}
+		})
+	}
+}
+
+func TestFormatThenConvert(t *testing.T) {
+	code := `package main
+
+import (
+	"fmt"
+	"strconv"
+)
+
+func encode(id int, name string, args []any) [][]byte {
+	return [][]byte{
+		[]byte(fmt.Sprintf("%d:%s", id, name)),
+		[]byte(fmt.Sprint(id)),
+		[]byte(fmt.Sprintf("%v", args...)),
+		[]byte(strconv.Itoa(id)),
+		[]byte(name),
+	}
+}
+`
+	pass, _ := newTestPass(t, code)
+
+	var found []Issue
+	for _, issue := range analyzeFile(pass.Files[0], pass.TypesInfo, pass.Fset, DefaultConfig()) {
+		switch issue.Pattern {
+		case "format-then-convert":
+			found = append(found, issue)
+		case "composite-conversion":
+			if issue.Pos.Line != 14 {
+				t.Errorf("Expected composite-conversion to leave formatted strings alone, got %q on line %d", issue.Message, issue.Pos.Line)
+			}
+		}
+	}
+
+	expected := []struct {
+		line int
+		msg  string
+		fix  string
+	}{
+		{10, "[]byte(fmt.Sprintf(...)) allocates the string and then copies it into a second allocation; consider fmt.Appendf to format directly into a []byte, reusing a buffer where possible", `fmt.Appendf(nil, "%d:%s", id, name)`},
+		{11, "[]byte(fmt.Sprint(...)) allocates the string and then copies it into a second allocation; consider fmt.Append", "fmt.Append(nil, id)"},
+		{12, "[]byte(fmt.Sprintf(...))", `fmt.Appendf(nil, "%v", args...)`},
+		{13, "[]byte(strconv.Itoa(...)) allocates the string and then copies it into a second allocation; consider strconv.AppendInt", ""},
+	}
+	if len(found) != len(expected) {
+		t.Fatalf("Expected %d format-then-convert issues, got %d: %v", len(expected), len(found), found)
+	}
+
+	for i, want := range expected {
+		issue := found[i]
+		if issue.Pos.Line != want.line || !strings.Contains(issue.Message, want.msg) {
+			t.Errorf("Expected %q on line %d, got %q on line %d", want.msg, want.line, issue.Message, issue.Pos.Line)
+		}
+
+		if want.fix == "" {
+			if len(issue.SuggestedFixes) != 0 {
+				t.Errorf("Expected no fix for strconv, got %v", issue.SuggestedFixes)
+			}
+			continue
+		}
+		if len(issue.SuggestedFixes) != 1 || len(issue.SuggestedFixes[0].TextEdits) != 1 {
+			t.Fatalf("Expected a single-edit fix, got %v", issue.SuggestedFixes)
+		}
+		if edit := issue.SuggestedFixes[0].TextEdits[0]; string(edit.NewText) != want.fix {
+			t.Errorf("Expected replacement %q, got %q", want.fix, edit.NewText)
+		}
+	}
+}
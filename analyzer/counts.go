@@ -0,0 +1,119 @@
+package analyzer
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"golang.org/x/tools/go/analysis"
+)
+
+// Counts aggregates the issues of a run for -report=counts. Only issues that
+// the severity policy reports are counted.
+type Counts struct {
+	Files    int `json:"files"`
+	Issues   int `json:"issues"`
+	Info     int `json:"info"`
+	Possible int `json:"possible"`
+	Likely   int `json:"likely"`
+}
+
+// Add counts issue under its severity
+func (c *Counts) Add(issue Issue) {
+	c.Issues++
+	switch {
+	case issue.Severity >= SeverityLikely:
+		c.Likely++
+	case issue.Severity == SeverityPossible:
+		c.Possible++
+	default:
+		c.Info++
+	}
+}
+
+// String formats the counts as key=value pairs in a fixed order, such as
+// files=3 issues=5 info=1 possible=3 likely=1
+func (c Counts) String() string {
+	return fmt.Sprintf("files=%d issues=%d info=%d possible=%d likely=%d", c.Files, c.Issues, c.Info, c.Possible, c.Likely)
+}
+
+// ExitCode returns the exit status the severity policy in config assigns to
+// the counted issues, like ExitCode does for the issues themselves
+func (c Counts) ExitCode(config *Config) int {
+	errors := 0
+	for severity, n := range map[Severity]int{SeverityInfo: c.Info, SeverityPossible: c.Possible, SeverityLikely: c.Likely} {
+		if severity >= config.ErrorSeverity {
+			errors += n
+		}
+	}
+	if errors > 0 && errors > config.MaxIssues {
+		return 1
+	}
+	return 0
+}
+
+// WriteCounts writes counts to w as a single line in the "text" format or a
+// single JSON object in the "json" format
+func WriteCounts(w io.Writer, format string, counts Counts) error {
+	switch format {
+	case "", "text":
+		_, err := fmt.Fprintln(w, counts)
+		return err
+	case "json":
+		return json.NewEncoder(w).Encode(counts)
+	}
+	return fmt.Errorf("-report=counts writes text or json, not %s", format)
+}
+
+// CountsSink counts the issues it receives and writes the counts on Flush
+// instead of the issues themselves
+type CountsSink struct {
+	w      io.Writer
+	format string
+	counts Counts
+}
+
+// NewCountsSink creates a sink writing counts to w in format, "text" or "json"
+func NewCountsSink(w io.Writer, format string) (*CountsSink, error) {
+	if err := WriteCounts(io.Discard, format, Counts{}); err != nil {
+		return nil, err
+	}
+	return &CountsSink{w: w, format: format}, nil
+}
+
+// AddFiles adds n to the number of files analyzed
+func (s *CountsSink) AddFiles(n int) {
+	s.counts.Files += n
+}
+
+// Report counts the issue
+func (s *CountsSink) Report(issue Issue) {
+	s.counts.Add(issue)
+}
+
+// Flush writes the counts and starts counting again
+func (s *CountsSink) Flush() error {
+	counts := s.counts
+	s.counts = Counts{}
+	return WriteCounts(s.w, s.format, counts)
+}
+
+// countFiles adds the files of a package to every counting sink
+func countFiles(sinks []IssueSink, files int) {
+	for _, sink := range sinks {
+		if counter, ok := sink.(*CountsSink); ok {
+			counter.AddFiles(files)
+		}
+	}
+}
+
+// reportFailingCounts stands in for reportIssues under -report=counts: rather
+// than one diagnostic per issue, it reports a single one when the issues fail
+// the run, so the exit status still follows the severity policy
+func reportFailingCounts(pass *analysis.Pass, issues []Issue, config *Config) {
+	errors, _ := classifyIssues(issues, config)
+	if len(errors) == 0 || len(pass.Files) == 0 {
+		return
+	}
+	pass.Reportf(pass.Files[0].Package, "%d issue(s) at or above -error-severity=%s; rerun without -report=counts to list them", len(errors), config.ErrorSeverity)
+}
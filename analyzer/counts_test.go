@@ -0,0 +1,132 @@
+package analyzer
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+const countsTestCode = `package main
+
+import "encoding/json"
+
+type point struct{ x, y int }
+
+func origin() *point {
+	return &point{}
+}
+
+func encode(items []any) ([]any, error) {
+	for _, item := range items {
+		if _, err := json.Marshal(item); err != nil {
+			return nil, err
+		}
+	}
+	return append([]any(nil), items...), nil
+}
+`
+
+// countsTestConfig runs one rule of each severity
+func countsTestConfig() *Config {
+	config := DefaultConfig()
+	config.OnlyPatterns = []string{"return-address-of-literal", "json-in-loop", "append-copy-idiom"}
+	return config
+}
+
+func TestCountsSink(t *testing.T) {
+	pass, _ := newTestPass(t, countsTestCode)
+	config := countsTestConfig()
+	issues := reportedIssues(analyzeFile(pass.Files[0], pass.TypesInfo, pass.Fset, config), config)
+
+	tests := []struct {
+		format string
+		want   string
+	}{
+		{"text", "files=1 issues=3 info=1 possible=1 likely=1\n"},
+		{"json", `{"files":1,"issues":3,"info":1,"possible":1,"likely":1}` + "\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			var buf bytes.Buffer
+			sink, err := NewCountsSink(&buf, tt.format)
+			if err != nil {
+				t.Fatalf("NewCountsSink failed: %v", err)
+			}
+			sink.AddFiles(1)
+			for _, issue := range issues {
+				sink.Report(issue)
+			}
+			if err := sink.Flush(); err != nil {
+				t.Fatalf("Flush failed: %v", err)
+			}
+			if buf.String() != tt.want {
+				t.Errorf("Expected %q, got %q", tt.want, buf.String())
+			}
+
+			// Each flush starts counting again
+			buf.Reset()
+			sink.Flush()
+			if want := strings.ReplaceAll(strings.ReplaceAll(tt.want, "1", "0"), "3", "0"); buf.String() != want {
+				t.Errorf("Expected %q after flushing, got %q", want, buf.String())
+			}
+		})
+	}
+
+	if _, err := NewCountsSink(&bytes.Buffer{}, "sarif"); err == nil {
+		t.Error("Expected -report=counts to reject the sarif format")
+	}
+}
+
+func TestReportCountsSuppressesIssues(t *testing.T) {
+	config := countsTestConfig()
+	config.Report = "counts"
+	pass, diagnostics := newTestPass(t, countsTestCode)
+	if _, err := runWithDeps(pass, newAnalyzerOptions(WithConfig(config))); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	// A single diagnostic keeps go vet's exit status in line with the policy
+	if len(*diagnostics) != 1 || (*diagnostics)[0].Message != "3 issue(s) at or above -error-severity=info; rerun without -report=counts to list them" {
+		t.Errorf("Expected one diagnostic summarizing the failing issues, got %v", *diagnostics)
+	}
+
+	config.ErrorSeverity = SeverityLikely
+	config.MaxIssues = 1
+	pass, diagnostics = newTestPass(t, countsTestCode)
+	if _, err := runWithDeps(pass, newAnalyzerOptions(WithConfig(config))); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(*diagnostics) != 0 {
+		t.Errorf("Expected no diagnostics when the issues don't fail the run, got %v", *diagnostics)
+	}
+}
+
+func TestCountsExitCode(t *testing.T) {
+	counts := Counts{Files: 1, Issues: 3, Info: 1, Possible: 1, Likely: 1}
+	tests := []struct {
+		name      string
+		severity  Severity
+		maxIssues int
+		want      int
+	}{
+		{"every issue fails", SeverityInfo, 0, 1},
+		{"likely issues fail", SeverityLikely, 0, 1},
+		{"within the allowance", SeverityPossible, 2, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := DefaultConfig()
+			config.ErrorSeverity = tt.severity
+			config.MaxIssues = tt.maxIssues
+			if got := counts.ExitCode(config); got != tt.want {
+				t.Errorf("Expected exit code %d, got %d", tt.want, got)
+			}
+		})
+	}
+
+	config := DefaultConfig()
+	config.Report = "summary"
+	if err := config.ValidatePatterns(); err == nil || !strings.Contains(err.Error(), "unknown -report mode") {
+		t.Errorf("Expected an unknown -report mode to be rejected, got %v", err)
+	}
+}
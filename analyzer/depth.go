@@ -0,0 +1,69 @@
+package analyzer
+
+import (
+	"go/ast"
+	"go/token"
+
+	"go.uber.org/zap"
+)
+
+// defaultMaxDepth is the deepest AST nesting analyzed by default. Hand-written
+// code rarely nests more than a hundred levels; generated code can nest far
+// deeper, such as a long chain of + building a string.
+const defaultMaxDepth = 10000
+
+// exceedsDepth reports whether node nests deeper than max. It stops descending
+// once it reaches max, so it is safe to call on trees of any depth.
+func exceedsDepth(node ast.Node, max int) bool {
+	if max <= 0 {
+		return false
+	}
+
+	depth, deep := 0, false
+	ast.Inspect(node, func(n ast.Node) bool {
+		if deep {
+			return false
+		}
+		if n == nil {
+			depth--
+			return true
+		}
+		depth++
+		if depth > max {
+			deep = true
+			return false
+		}
+		return true
+	})
+	return deep
+}
+
+// withoutDeepDecls returns f, or a copy of it without the declarations that
+// nest deeper than config.MaxDepth. Detectors and their helpers recurse over
+// the syntax they are given, so a pathologically deep declaration would
+// overflow the stack; it is skipped as a whole, with a warning, instead.
+func withoutDeepDecls(f *ast.File, fset *token.FileSet, config *Config, logger *zap.Logger) *ast.File {
+	var decls []ast.Decl
+	for i, decl := range f.Decls {
+		if !exceedsDepth(decl, config.MaxDepth) {
+			if decls != nil {
+				decls = append(decls, decl)
+			}
+			continue
+		}
+
+		logger.Warn("Skipping declaration nested too deeply to analyze",
+			zap.String("position", fset.Position(decl.Pos()).String()),
+			zap.Int("max_depth", config.MaxDepth))
+		if decls == nil {
+			decls = append(make([]ast.Decl, 0, len(f.Decls)), f.Decls[:i]...)
+		}
+	}
+	if decls == nil {
+		return f
+	}
+
+	pruned := *f
+	pruned.Decls = decls
+	return &pruned
+}
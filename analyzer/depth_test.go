@@ -0,0 +1,82 @@
+package analyzer
+
+import (
+	"go/parser"
+	"go/token"
+	"go/types"
+	"runtime/debug"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestExceedsDepth(t *testing.T) {
+	// File, FuncDecl, BlockStmt, ReturnStmt, then one BinaryExpr per + and
+	// the identifiers they add
+	src := "package test\n\nfunc f(s string) string {\n\treturn s + s + s\n}\n"
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "test.go", src, 0)
+	if err != nil {
+		t.Fatalf("Failed to parse code: %v", err)
+	}
+
+	tests := []struct {
+		max  int
+		want bool
+	}{
+		{0, false},
+		{100, false},
+		{7, false},
+		{6, true},
+		{1, true},
+	}
+	for _, tt := range tests {
+		if got := exceedsDepth(file, tt.max); got != tt.want {
+			t.Errorf("exceedsDepth(file, %d) = %v, want %v", tt.max, got, tt.want)
+		}
+	}
+}
+
+func TestDeepExpression(t *testing.T) {
+	// The parser accepts a chain of + nesting one level per term up to about
+	// 100000 levels. Walking it recursively overflows a stack limited to 8MB,
+	// as the detectors did before declarations this deep were skipped.
+	const terms = 90000
+	src := "package test\n\nfunc deep(s string) string {\n\treturn s" +
+		strings.Repeat(" + s", terms-1) +
+		"\n}\n\nfunc shallow() *int {\n\treturn new(int)\n}\n"
+
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "deep.go", src, 0)
+	if err != nil {
+		t.Fatalf("Failed to parse code: %v", err)
+	}
+	defer debug.SetMaxStack(debug.SetMaxStack(8 << 20))
+	core, logs := observer.New(zap.WarnLevel)
+	var issues []Issue
+	// Parsing has already grown this goroutine's stack, so analyze on another
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		inspectFile(file, &types.Info{}, nil, fset, DefaultConfig(), zap.New(core), func(issue Issue) {
+			issues = append(issues, issue)
+		})
+	}()
+	<-done
+
+	if len(issues) != 1 || issues[0].Pos.Line != 8 {
+		t.Errorf("expected only the issue in shallow on line 8, got %v", issues)
+	}
+	warnings := logs.FilterMessage("Skipping declaration nested too deeply to analyze").All()
+	if len(warnings) != 1 {
+		t.Fatalf("expected one warning, got %v", logs.All())
+	}
+	if pos := warnings[0].ContextMap()["position"]; pos != "deep.go:3:1" {
+		t.Errorf("warning position = %v, want deep.go:3:1", pos)
+	}
+	if len(file.Decls) != 2 {
+		t.Errorf("the file's declarations were modified: %d left", len(file.Decls))
+	}
+}
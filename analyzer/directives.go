@@ -0,0 +1,69 @@
+package analyzer
+
+import (
+	"go/ast"
+	"go/token"
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+// disableDirective and enableDirective, followed by rule names, turn those
+// rules off and back on in a file, from the comment to the end of the file or
+// the next directive for the rule. enableDirective only undoes an earlier
+// disableDirective; it can't turn on a rule the configuration leaves off.
+const (
+	disableDirective = "//stackalloc:disable"
+	enableDirective  = "//stackalloc:enable"
+)
+
+// ruleToggle is a directive turning a rule off or back on from pos
+type ruleToggle struct {
+	pos     token.Pos
+	enabled bool
+}
+
+// ruleDirectives collects the disableDirective and enableDirective comments in
+// f by rule name, in file order. Unknown rule names are logged and ignored.
+func ruleDirectives(f *ast.File, fset *token.FileSet, logger *zap.Logger) map[string][]ruleToggle {
+	var toggles map[string][]ruleToggle
+	for _, group := range f.Comments {
+		for _, c := range group.List {
+			var names string
+			var enabled bool
+			if rest, ok := strings.CutPrefix(c.Text, disableDirective+" "); ok {
+				names = rest
+			} else if rest, ok := strings.CutPrefix(c.Text, enableDirective+" "); ok {
+				names, enabled = rest, true
+			} else {
+				continue
+			}
+
+			for _, name := range strings.FieldsFunc(names, func(r rune) bool { return r == ',' || r == ' ' }) {
+				if _, ok := LookupRule(name); !ok {
+					logger.Warn("Ignoring unknown rule in directive",
+						zap.String("position", fset.Position(c.Pos()).String()),
+						zap.String("rule", name))
+					continue
+				}
+				if toggles == nil {
+					toggles = make(map[string][]ruleToggle)
+				}
+				toggles[name] = append(toggles[name], ruleToggle{pos: c.Pos(), enabled: enabled})
+			}
+		}
+	}
+	return toggles
+}
+
+// ruleDisabled reports whether toggles turn rule off at pos
+func ruleDisabled(toggles map[string][]ruleToggle, rule string, pos token.Pos) bool {
+	disabled := false
+	for _, toggle := range toggles[rule] {
+		if toggle.pos > pos {
+			break
+		}
+		disabled = !toggle.enabled
+	}
+	return disabled
+}
@@ -0,0 +1,63 @@
+package analyzer
+
+import (
+	"testing"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestRuleDirectives(t *testing.T) {
+	code := `//stackalloc:disable chan-in-loop,no-such-rule
+
+package main
+
+import "encoding/json"
+
+func send(items []any) {
+	for _, item := range items {
+		done := make(chan struct{})
+		b, _ := json.Marshal(item)
+		_, _ = done, b
+	}
+}
+
+//stackalloc:enable chan-in-loop
+
+func wait(items []any) {
+	for range items {
+		done := make(chan struct{})
+		_ = done
+	}
+}
+
+// Not a directive: //stackalloc:disable chan-in-loop
+func poll(items []any) {
+	for range items {
+		done := make(chan struct{})
+		_ = done
+	}
+}
+`
+	pass, _ := newTestPass(t, code)
+	core, logs := observer.New(zapcore.WarnLevel)
+
+	found := make(map[string][]int)
+	for _, issue := range analyzeFileWithLogger(pass.Files[0], pass.TypesInfo, nil, pass.Fset, DefaultConfig(), zap.New(core)) {
+		found[issue.Pattern] = append(found[issue.Pattern], issue.Pos.Line)
+	}
+
+	// Disabled until the enable directive; other rules keep firing
+	if got := found["chan-in-loop"]; len(got) != 2 || got[0] != 19 || got[1] != 27 {
+		t.Errorf("Expected chan-in-loop only on lines 19 and 27, got %v", got)
+	}
+	if got := found["json-in-loop"]; len(got) != 1 || got[0] != 10 {
+		t.Errorf("Expected json-in-loop to still fire on line 10, got %v", got)
+	}
+
+	warnings := logs.FilterMessage("Ignoring unknown rule in directive").All()
+	if len(warnings) != 1 || warnings[0].ContextMap()["rule"] != "no-such-rule" {
+		t.Errorf("Expected a warning about no-such-rule, got %v", logs.All())
+	}
+}
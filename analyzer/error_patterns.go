@@ -0,0 +1,238 @@
+package analyzer
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+)
+
+// detectUnconditionalErrorf detects fmt.Errorf calls evaluated on every path
+// through a function whose error is then thrown away: discarded, compared
+// against another error, or stored in a variable that is only used on an error
+// path. Each of them formats and allocates an error even when the function
+// succeeds.
+func (pd *PatternDetector) detectUnconditionalErrorf(call *ast.CallExpr, report func(node ast.Node, msg string)) {
+	if !pd.config.IsPatternEnabled("unconditional-errorf") || !pd.isPkgFunc(call, "fmt", "Errorf") {
+		return
+	}
+	fn := pd.enclosingFunc()
+	if fn == nil || isConditional(append(pd.stack, call)) {
+		return
+	}
+
+	switch parent := pd.ancestor(0).(type) {
+	case *ast.ExprStmt:
+		pd.reportRule(report, "unconditional-errorf", call, "fmt.Errorf result is discarded, so the error it formats and allocates is never used")
+	case *ast.BinaryExpr:
+		if parent.Op == token.EQL || parent.Op == token.NEQ {
+			pd.reportRule(report, "unconditional-errorf", call, "fmt.Errorf allocates a new error on every call just to compare it, and it never equals another error; compare against a sentinel error with errors.Is")
+		}
+	case *ast.AssignStmt:
+		if len(parent.Lhs) != len(parent.Rhs) {
+			return
+		}
+		for i, rhs := range parent.Rhs {
+			if rhs != call {
+				continue
+			}
+			ident, ok := parent.Lhs[i].(*ast.Ident)
+			if !ok {
+				return
+			}
+			if ident.Name == "_" {
+				pd.reportRule(report, "unconditional-errorf", call, "fmt.Errorf result is discarded, so the error it formats and allocates is never used")
+				return
+			}
+			v, ok := pd.info.ObjectOf(ident).(*types.Var)
+			if ok && isLocalVar(v) && pd.onlyUsedConditionally(fn, v) {
+				pd.reportRule(report, "unconditional-errorf", call, fmt.Sprintf("fmt.Errorf runs on every call, but %s is only used on an error path, so the error is formatted and allocated even when the function succeeds; build it in the branch that returns it", v.Name()))
+			}
+			return
+		}
+	}
+}
+
+// onlyUsedConditionally reports whether every use of v within fn is in code
+// that doesn't run on every path through it
+func (pd *PatternDetector) onlyUsedConditionally(fn ast.Node, v *types.Var) bool {
+	var path []ast.Node
+	conditional := true
+	ast.Inspect(fn, func(n ast.Node) bool {
+		if n == nil {
+			path = path[:len(path)-1]
+			return true
+		}
+		if !conditional {
+			return false
+		}
+		if ident, ok := n.(*ast.Ident); ok && pd.info.Uses[ident] == v {
+			conditional = isConditional(append(path, ident))
+		}
+		path = append(path, n)
+		return true
+	})
+	return conditional
+}
+
+// isConditional reports whether the last node of path, which runs from the
+// root of a syntax tree down to the node, might not be evaluated on a path
+// through its enclosing function: it is in a branch of an if, switch or
+// select, on the right of && or ||, or after an if statement that returns.
+// Loop bodies count as evaluated, since they run on every iteration.
+func isConditional(path []ast.Node) bool {
+	for i := len(path) - 2; i >= 0; i-- {
+		child := path[i+1]
+		switch n := path[i].(type) {
+		case *ast.FuncDecl, *ast.FuncLit:
+			return false
+		case *ast.IfStmt:
+			if child == n.Body || child == n.Else {
+				return true
+			}
+		case *ast.CaseClause, *ast.CommClause:
+			return true
+		case *ast.BinaryExpr:
+			if (n.Op == token.LAND || n.Op == token.LOR) && child == n.Y {
+				return true
+			}
+		case *ast.BlockStmt:
+			for _, stmt := range n.List {
+				if stmt == child {
+					break
+				}
+				if ifStmt, ok := stmt.(*ast.IfStmt); ok && returnsEarly(ifStmt) {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// returnsEarly reports whether a branch of ifStmt returns from the enclosing
+// function, not counting returns in function literals
+func returnsEarly(ifStmt *ast.IfStmt) bool {
+	found := false
+	ast.Inspect(ifStmt, func(n ast.Node) bool {
+		switch n.(type) {
+		case *ast.FuncLit:
+			return false
+		case *ast.ReturnStmt:
+			found = true
+		}
+		return !found
+	})
+	return found
+}
+
+// detectErrorStringCompare detects err.Error() compared against a string, or
+// called inside a loop. Error may format and allocate a new string on every
+// call, and matching on the text breaks as soon as the message changes, where
+// errors.Is and errors.As compare the errors themselves.
+func (pd *PatternDetector) detectErrorStringCompare(call *ast.CallExpr, report func(node ast.Node, msg string)) {
+	if !pd.config.IsPatternEnabled("error-string-compare") || !pd.isErrorMethodCall(call) {
+		return
+	}
+	recv, ok := pd.nodeSource(call.Fun.(*ast.SelectorExpr).X)
+	if !ok {
+		return
+	}
+
+	switch {
+	case pd.isStringCompared(call):
+		pd.reportRule(report, "error-string-compare", call, fmt.Sprintf("%s.Error() formats the error into a new string just to compare its text, which breaks when the message changes; compare with errors.Is against a sentinel error, or errors.As for an error type", recv))
+	case pd.isInLoop(call):
+		pd.reportRule(report, "error-string-compare", call, fmt.Sprintf("%s.Error() in a loop may format and allocate a new string on every iteration; call it once outside the loop, or inspect the error with errors.Is or errors.As", recv))
+	}
+}
+
+// isErrorMethodCall reports whether call is x.Error() for an x whose method set
+// satisfies the error interface
+func (pd *PatternDetector) isErrorMethodCall(call *ast.CallExpr) bool {
+	sel, ok := call.Fun.(*ast.SelectorExpr)
+	if !ok || sel.Sel.Name != "Error" || len(call.Args) != 0 {
+		return false
+	}
+	fn, ok := pd.info.ObjectOf(sel.Sel).(*types.Func)
+	recv := pd.info.TypeOf(sel.X)
+	if !ok || recv == nil || fn.Type().(*types.Signature).Recv() == nil {
+		return false
+	}
+	errorIface := types.Universe.Lookup("error").Type().Underlying().(*types.Interface)
+	return types.Implements(recv, errorIface) || types.Implements(types.NewPointer(recv), errorIface)
+}
+
+// isStringCompared reports whether the string call returns is compared with ==
+// or !=, switched on, or matched with strings.Contains and the like
+func (pd *PatternDetector) isStringCompared(call *ast.CallExpr) bool {
+	switch parent := pd.ancestor(0).(type) {
+	case *ast.BinaryExpr:
+		return parent.Op == token.EQL || parent.Op == token.NEQ
+	case *ast.SwitchStmt:
+		return parent.Tag == call
+	case *ast.CallExpr:
+		return pd.isPkgFunc(parent, "strings", "Contains", "HasPrefix", "HasSuffix", "EqualFold", "Index")
+	}
+	return false
+}
+
+// detectPanicAllocArg detects panic with a value that allocates, such as
+// panic(fmt.Sprintf(...)) or panic(errors.New(...)). The value is only built
+// when panicking, which is fine for broken invariants, but when the enclosing
+// function recovers from its own panics they're part of normal control flow,
+// and an error return would cost less than formatting and unwinding.
+func (pd *PatternDetector) detectPanicAllocArg(call *ast.CallExpr, report func(node ast.Node, msg string)) {
+	if !pd.config.IsPatternEnabled("panic-alloc-arg") || !pd.isBuiltinCall(call, "panic") || len(call.Args) != 1 {
+		return
+	}
+	arg, ok := ast.Unparen(call.Args[0]).(*ast.CallExpr)
+	if !ok || (!pd.isPkgFunc(arg, "fmt", "Sprintf", "Sprint", "Sprintln", "Errorf") && !pd.isPkgFunc(arg, "errors", "New")) {
+		return
+	}
+
+	pkg := arg.Fun.(*ast.SelectorExpr).X.(*ast.Ident).Name
+	_, name := pd.pkgFunc(arg)
+	if fn := pd.enclosingFunc(); fn != nil && pd.defersRecover(fn) {
+		pd.reportRule(report, "panic-alloc-arg", call, fmt.Sprintf("panic(%s.%s(...)) allocates the value it panics with, and the enclosing function recovers it, so the panic is part of normal control flow; return an error instead", pkg, name))
+		return
+	}
+	pd.reportRule(report, "panic-alloc-arg", call, fmt.Sprintf("panic(%s.%s(...)) allocates the value it panics with; that's only paid when panicking, but if this panic can happen in normal operation, return an error instead", pkg, name))
+}
+
+// defersRecover reports whether the function fn defers a function literal
+// that calls recover, catching panics raised in fn itself
+func (pd *PatternDetector) defersRecover(fn ast.Node) bool {
+	var body *ast.BlockStmt
+	switch f := fn.(type) {
+	case *ast.FuncDecl:
+		body = f.Body
+	case *ast.FuncLit:
+		body = f.Body
+	}
+	if body == nil {
+		return false
+	}
+
+	found := false
+	ast.Inspect(body, func(n ast.Node) bool {
+		switch s := n.(type) {
+		case *ast.FuncLit:
+			// Deferred calls in nested functions run when those return
+			return false
+		case *ast.DeferStmt:
+			lit, ok := s.Call.Fun.(*ast.FuncLit)
+			if !ok {
+				return true
+			}
+			ast.Inspect(lit.Body, func(n ast.Node) bool {
+				if call, ok := n.(*ast.CallExpr); ok && pd.isBuiltinCall(call, "recover") {
+					found = true
+				}
+				return !found
+			})
+		}
+		return !found
+	})
+	return found
+}
@@ -0,0 +1,247 @@
+package analyzer
+
+import "testing"
+
+func TestUnconditionalErrorf(t *testing.T) {
+	code := `package main
+
+import (
+	"errors"
+	"fmt"
+)
+
+var errClosed = errors.New("closed")
+
+func discarded(name string) {
+	fmt.Errorf("bad %s", name)
+	_ = fmt.Errorf("bad %s", name)
+}
+
+func compared(name string, err error) bool {
+	return err == fmt.Errorf("bad %s", name)
+}
+
+func eager(name string, err error) error {
+	wrapped := fmt.Errorf("open %s: %w", name, err)
+	if err != nil {
+		return wrapped
+	}
+	return nil
+}
+
+func returned(name string, err error) error {
+	wrapped := fmt.Errorf("open %s: %w", name, err)
+	if err != nil {
+		println("failed")
+	}
+	return wrapped
+}
+
+func lazy(name string, err error) error {
+	if err != nil {
+		return fmt.Errorf("open %s: %w", name, err)
+	}
+	return nil
+}
+
+func afterReturn(name string, err error) error {
+	if err == nil {
+		return nil
+	}
+	wrapped := fmt.Errorf("open %s: %w", name, err)
+	if errors.Is(err, errClosed) {
+		return wrapped
+	}
+	return err
+}
+
+func shortCircuit(name string, err error) bool {
+	return err != nil && fmt.Errorf("bad %s", name) == err
+}
+`
+	pass, _ := newTestPass(t, code)
+
+	config := DefaultConfig()
+	config.EnablePatterns = []string{"unconditional-errorf"}
+
+	var found []Issue
+	for _, issue := range analyzeFile(pass.Files[0], pass.TypesInfo, pass.Fset, config) {
+		if issue.Pattern == "unconditional-errorf" {
+			found = append(found, issue)
+		}
+	}
+
+	expected := []struct {
+		line int
+		msg  string
+	}{
+		{11, "fmt.Errorf result is discarded, so the error it formats and allocates is never used"},
+		{12, "fmt.Errorf result is discarded, so the error it formats and allocates is never used"},
+		{16, "fmt.Errorf allocates a new error on every call just to compare it, and it never equals another error; compare against a sentinel error with errors.Is"},
+		{20, "fmt.Errorf runs on every call, but wrapped is only used on an error path, so the error is formatted and allocated even when the function succeeds; build it in the branch that returns it"},
+	}
+	if len(found) != len(expected) {
+		t.Fatalf("Expected %d unconditional-errorf issues, got %d: %v", len(expected), len(found), found)
+	}
+	for i, want := range expected {
+		if found[i].Pos.Line != want.line || found[i].Message != want.msg {
+			t.Errorf("Expected %q on line %d, got %q on line %d", want.msg, want.line, found[i].Message, found[i].Pos.Line)
+		}
+	}
+
+	// Off by default
+	for _, issue := range analyzeFile(pass.Files[0], pass.TypesInfo, pass.Fset, DefaultConfig()) {
+		if issue.Pattern == "unconditional-errorf" {
+			t.Errorf("Expected unconditional-errorf to be off by default, got %v", issue)
+		}
+	}
+}
+
+func TestErrorStringCompare(t *testing.T) {
+	code := `package main
+
+import (
+	"errors"
+	"os"
+	"strings"
+)
+
+type parseError struct{ line int }
+
+func (e *parseError) Error() string { return "parse error" }
+
+type message struct{}
+
+func (message) Error() {}
+
+func isNotExist(err error) bool {
+	return err.Error() == "file does not exist"
+}
+
+func isParse(err *parseError) bool {
+	return err.Error() != "parse error"
+}
+
+func classify(err error) int {
+	switch err.Error() {
+	case "timeout":
+		return 1
+	}
+	if strings.Contains(err.Error(), "refused") {
+		return 2
+	}
+	return 0
+}
+
+func messages(errs []error) []string {
+	var out []string
+	for _, err := range errs {
+		out = append(out, err.Error())
+	}
+	return out
+}
+
+func fine(err error, m message) string {
+	if errors.Is(err, os.ErrNotExist) {
+		return "missing"
+	}
+	m.Error()
+	return err.Error()
+}
+`
+	pass, _ := newTestPass(t, code)
+
+	var found []Issue
+	for _, issue := range analyzeFile(pass.Files[0], pass.TypesInfo, pass.Fset, DefaultConfig()) {
+		if issue.Pattern == "error-string-compare" {
+			found = append(found, issue)
+		}
+	}
+
+	compared := "err.Error() formats the error into a new string just to compare its text, which breaks when the message changes; compare with errors.Is against a sentinel error, or errors.As for an error type"
+	expected := []struct {
+		line int
+		msg  string
+	}{
+		{18, compared},
+		{22, compared},
+		{26, compared},
+		{30, compared},
+		{39, "err.Error() in a loop may format and allocate a new string on every iteration; call it once outside the loop, or inspect the error with errors.Is or errors.As"},
+	}
+	if len(found) != len(expected) {
+		t.Fatalf("Expected %d error-string-compare issues, got %d: %v", len(expected), len(found), found)
+	}
+	for i, want := range expected {
+		if found[i].Pos.Line != want.line || found[i].Message != want.msg {
+			t.Errorf("Expected %q on line %d, got %q on line %d", want.msg, want.line, found[i].Message, found[i].Pos.Line)
+		}
+	}
+}
+
+func TestPanicAllocArg(t *testing.T) {
+	code := `package main
+
+import (
+	"errors"
+	"fmt"
+)
+
+func mustPositive(n int) int {
+	if n < 0 {
+		panic(fmt.Sprintf("negative count %d", n))
+	}
+	return n
+}
+
+func parse(s string) (n int, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("parse %q: %v", s, r)
+		}
+	}()
+	if s == "" {
+		panic(errors.New("empty input"))
+	}
+	go func() {
+		panic(fmt.Sprint("in a goroutine"))
+	}()
+	panic("constant message")
+}
+`
+	pass, _ := newTestPass(t, code)
+
+	var found []Issue
+	for _, issue := range analyzeFile(pass.Files[0], pass.TypesInfo, pass.Fset, DefaultConfig()) {
+		if issue.Pattern == "panic-alloc-arg" {
+			found = append(found, issue)
+		}
+	}
+
+	// The goroutine's panic isn't caught by parse's recover
+	expected := []struct {
+		line int
+		msg  string
+	}{
+		{10, "panic(fmt.Sprintf(...)) allocates the value it panics with; that's only paid when panicking, but if this panic can happen in normal operation, return an error instead"},
+		{22, "panic(errors.New(...)) allocates the value it panics with, and the enclosing function recovers it, so the panic is part of normal control flow; return an error instead"},
+		{25, "panic(fmt.Sprint(...)) allocates the value it panics with; that's only paid when panicking, but if this panic can happen in normal operation, return an error instead"},
+	}
+	if len(found) != len(expected) {
+		t.Fatalf("Expected %d panic-alloc-arg issues, got %d: %v", len(expected), len(found), found)
+	}
+	for i, want := range expected {
+		if found[i].Pos.Line != want.line || found[i].Message != want.msg {
+			t.Errorf("Expected %q on line %d, got %q on line %d", want.msg, want.line, found[i].Message, found[i].Pos.Line)
+		}
+	}
+
+	// It can be turned off like any other rule
+	config := DefaultConfig()
+	config.DisablePatterns = []string{"panic-alloc-arg"}
+	for _, issue := range analyzeFile(pass.Files[0], pass.TypesInfo, pass.Fset, config) {
+		if issue.Pattern == "panic-alloc-arg" {
+			t.Errorf("Expected panic-alloc-arg to be disabled, got %v", issue)
+		}
+	}
+}
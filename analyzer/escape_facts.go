@@ -0,0 +1,388 @@
+package analyzer
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"strings"
+
+	"golang.org/x/tools/go/analysis"
+)
+
+// EscapeFact records which parameters of a function escape: they are
+// returned, stored somewhere that outlives the call, sent on a channel or
+// passed on to a parameter that escapes in turn. It is exported for the
+// package's exported functions so call sites in other packages can use it.
+type EscapeFact struct {
+	Params []bool // Params[i] reports whether the ith parameter escapes
+}
+
+// AFact marks EscapeFact as an analysis.Fact
+func (*EscapeFact) AFact() {}
+
+func (f *EscapeFact) String() string {
+	var escaping []string
+	for i, escapes := range f.Params {
+		if escapes {
+			escaping = append(escaping, fmt.Sprint(i))
+		}
+	}
+	return "escaping params: " + strings.Join(escaping, ", ")
+}
+
+// escapeFacts answers which parameters of a called function escape, from the
+// functions declared in the package being analyzed or from facts imported
+// from its dependencies
+type escapeFacts struct {
+	local      map[*types.Func][]bool
+	importFact func(obj types.Object, fact analysis.Fact) bool
+}
+
+// params returns which parameters of fn escape, or nil if that isn't known
+func (e *escapeFacts) params(fn *types.Func) []bool {
+	if e == nil {
+		return nil
+	}
+	if params, ok := e.local[fn]; ok {
+		return params
+	}
+	var fact EscapeFact
+	if e.importFact != nil && e.importFact(fn, &fact) {
+		return fact.Params
+	}
+	return nil
+}
+
+// computeEscapeFacts works out which parameters escape for every function
+// declared in the pass's files and exports them as facts for exported
+// functions. Calls between the package's functions are resolved by iterating
+// until nothing changes. Functions nested deeper than maxDepth are left out, as
+// they are by the detectors.
+func computeEscapeFacts(pass *analysis.Pass, maxDepth int) *escapeFacts {
+	facts := &escapeFacts{
+		local:      make(map[*types.Func][]bool),
+		importFact: pass.ImportObjectFact,
+	}
+	if pass.TypesInfo == nil {
+		return facts
+	}
+
+	decls := make(map[*types.Func]*ast.FuncDecl)
+	for _, file := range pass.Files {
+		for _, decl := range file.Decls {
+			fd, ok := decl.(*ast.FuncDecl)
+			if !ok || fd.Body == nil || exceedsDepth(fd, maxDepth) {
+				continue
+			}
+			if fn, ok := pass.TypesInfo.Defs[fd.Name].(*types.Func); ok {
+				decls[fn] = fd
+				facts.local[fn] = make([]bool, fn.Type().(*types.Signature).Params().Len())
+			}
+		}
+	}
+
+	for changed := true; changed; {
+		changed = false
+		for fn, fd := range decls {
+			if markEscapingParams(fn, fd, pass.TypesInfo, facts) {
+				changed = true
+			}
+		}
+	}
+
+	if pass.ExportObjectFact != nil {
+		for fn := range decls {
+			if fn.Exported() {
+				pass.ExportObjectFact(fn, &EscapeFact{Params: facts.local[fn]})
+			}
+		}
+	}
+	return facts
+}
+
+// markEscapingParams marks the parameters of fn that escape within its body,
+// reporting whether any were newly marked
+func markEscapingParams(fn *types.Func, fd *ast.FuncDecl, info *types.Info, facts *escapeFacts) bool {
+	sig := fn.Type().(*types.Signature)
+	index := make(map[types.Object]int)
+	for i := 0; i < sig.Params().Len(); i++ {
+		index[sig.Params().At(i)] = i
+	}
+
+	escaping := facts.local[fn]
+	changed := false
+	leak := func(expr ast.Expr) {
+		for _, obj := range leakedVars(expr, info) {
+			if i, ok := index[obj]; ok && !escaping[i] {
+				escaping[i] = true
+				changed = true
+			}
+		}
+	}
+
+	ast.Inspect(fd.Body, func(n ast.Node) bool {
+		switch s := n.(type) {
+		case *ast.ReturnStmt:
+			for _, res := range s.Results {
+				leak(res)
+			}
+		case *ast.AssignStmt:
+			if len(s.Lhs) != len(s.Rhs) {
+				break
+			}
+			for i, lhs := range s.Lhs {
+				if isOutlivingLocation(lhs, info) {
+					leak(s.Rhs[i])
+				}
+			}
+		case *ast.SendStmt:
+			leak(s.Value)
+		case *ast.CallExpr:
+			callee := calledFunc(s, info)
+			if callee == nil {
+				break
+			}
+			calleeParams := facts.params(callee)
+			for i, arg := range s.Args {
+				if j := min(i, len(calleeParams)-1); j >= 0 && calleeParams[j] {
+					leak(arg)
+				}
+			}
+		}
+		return true
+	})
+	return changed
+}
+
+// leakedVars returns the variables whose values flow directly into expr, such
+// as p in p, (p), T{p}, &T{f: p} and append(s, p)
+func leakedVars(expr ast.Expr, info *types.Info) []types.Object {
+	switch e := ast.Unparen(expr).(type) {
+	case *ast.CallExpr:
+		if ident, ok := ast.Unparen(e.Fun).(*ast.Ident); ok {
+			if builtin, ok := info.Uses[ident].(*types.Builtin); ok && builtin.Name() == "append" {
+				var vars []types.Object
+				for _, arg := range e.Args {
+					vars = append(vars, leakedVars(arg, info)...)
+				}
+				return vars
+			}
+		}
+	case *ast.Ident:
+		if v, ok := info.ObjectOf(e).(*types.Var); ok {
+			return []types.Object{v}
+		}
+	case *ast.UnaryExpr:
+		if e.Op == token.AND {
+			if lit, ok := ast.Unparen(e.X).(*ast.CompositeLit); ok {
+				return leakedVars(lit, info)
+			}
+		}
+	case *ast.CompositeLit:
+		var vars []types.Object
+		for _, elt := range e.Elts {
+			if kv, ok := elt.(*ast.KeyValueExpr); ok {
+				elt = kv.Value
+			}
+			vars = append(vars, leakedVars(elt, info)...)
+		}
+		return vars
+	}
+	return nil
+}
+
+// isOutlivingLocation reports whether a value assigned to lhs outlives the
+// enclosing function: a field, an element of a slice or map, a pointer
+// dereference or a package-level variable
+func isOutlivingLocation(lhs ast.Expr, info *types.Info) bool {
+	switch l := lhs.(type) {
+	case *ast.SelectorExpr, *ast.IndexExpr, *ast.StarExpr:
+		return true
+	case *ast.Ident:
+		v, ok := info.ObjectOf(l).(*types.Var)
+		return ok && v.Pkg() != nil && v.Parent() == v.Pkg().Scope()
+	}
+	return false
+}
+
+// calledFunc returns the function or method statically called by call, or nil
+// for calls through function values and interfaces
+func calledFunc(call *ast.CallExpr, info *types.Info) *types.Func {
+	var ident *ast.Ident
+	switch fun := ast.Unparen(call.Fun).(type) {
+	case *ast.Ident:
+		ident = fun
+	case *ast.SelectorExpr:
+		ident = fun.Sel
+	default:
+		return nil
+	}
+
+	fn, ok := info.Uses[ident].(*types.Func)
+	if !ok {
+		return nil
+	}
+	if recv := fn.Type().(*types.Signature).Recv(); recv != nil && types.IsInterface(recv.Type()) {
+		return nil
+	}
+	return fn.Origin()
+}
+
+// detectEscapingArguments detects &x passed to a parameter that escapes, which
+// moves the local x to the heap. Whether a parameter escapes comes from the
+// called function's EscapeFact, so this works across packages.
+func (pd *PatternDetector) detectEscapingArguments(call *ast.CallExpr, report func(node ast.Node, msg string)) {
+	if !pd.config.IsPatternEnabled("escaping-argument") || pd.escapes == nil {
+		return
+	}
+
+	callee := calledFunc(call, pd.info)
+	if callee == nil {
+		return
+	}
+	params := pd.escapes.params(callee)
+	sig := callee.Type().(*types.Signature)
+
+	for i, arg := range call.Args {
+		j := min(i, len(params)-1)
+		if j < 0 || !params[j] {
+			continue
+		}
+		unary, ok := ast.Unparen(arg).(*ast.UnaryExpr)
+		if !ok || unary.Op != token.AND {
+			continue
+		}
+		ident, ok := ast.Unparen(unary.X).(*ast.Ident)
+		if !ok {
+			continue
+		}
+		obj := pd.info.ObjectOf(ident)
+		if !isLocalVar(obj) {
+			continue
+		}
+
+		if pd.tracker != nil {
+			pd.tracker.escapes[obj] = true
+		}
+		pd.reportRule(report, "escaping-argument", unary, fmt.Sprintf("&%s passed to %s escapes through its parameter %s, so %s is allocated on the heap; consider passing a copy if %s doesn't need to retain it", ident.Name, funcName(callee, call), paramName(sig, j), ident.Name, callee.Name()))
+	}
+}
+
+// detectRetainedArguments detects slices allocated in the calling function,
+// by make or a slice literal, passed to a parameter that the called function
+// retains. Storing the slice moves its backing array to the heap even when it
+// is small enough to live on the caller's stack.
+func (pd *PatternDetector) detectRetainedArguments(call *ast.CallExpr, report func(node ast.Node, msg string)) {
+	if !pd.config.IsPatternEnabled("retained-argument") || pd.escapes == nil {
+		return
+	}
+
+	callee := calledFunc(call, pd.info)
+	if callee == nil {
+		return
+	}
+	params := pd.escapes.params(callee)
+	sig := callee.Type().(*types.Signature)
+
+	for i, arg := range call.Args {
+		j := min(i, len(params)-1)
+		if j < 0 || !params[j] {
+			continue
+		}
+		alloc := pd.localSliceAllocation(arg)
+		if alloc == nil {
+			continue
+		}
+
+		name := funcName(callee, call)
+		param := paramName(sig, j)
+		if ident, ok := ast.Unparen(arg).(*ast.Ident); ok {
+			line := pd.fset.Position(alloc.Pos()).Line
+			pd.reportRule(report, "retained-argument", arg, fmt.Sprintf("%s is retained by %s through its parameter %s, so the slice allocated for it on line %d is heap allocated; if %s doesn't need to keep it, have it copy what it needs instead", ident.Name, name, param, line, callee.Name()))
+		} else {
+			pd.reportRule(report, "retained-argument", arg, fmt.Sprintf("slice passed to %s is retained through its parameter %s, so it is heap allocated; if %s doesn't need to keep it, have it copy what it needs instead", name, param, callee.Name()))
+		}
+	}
+}
+
+// localSliceAllocation returns the make call or slice literal that allocates
+// expr, when expr is one of them or a local variable initialized with one in
+// the enclosing function, or nil otherwise
+func (pd *PatternDetector) localSliceAllocation(expr ast.Expr) ast.Expr {
+	expr = ast.Unparen(expr)
+	if _, ok := pd.info.TypeOf(expr).(*types.Slice); !ok {
+		return nil
+	}
+
+	switch e := expr.(type) {
+	case *ast.CallExpr:
+		if pd.isMakeCall(e) {
+			return e
+		}
+	case *ast.CompositeLit:
+		return e
+	case *ast.Ident:
+		obj := pd.info.ObjectOf(e)
+		fn := pd.enclosingFunc()
+		if !isLocalVar(obj) || fn == nil {
+			return nil
+		}
+		var init ast.Expr
+		ast.Inspect(fn, func(n ast.Node) bool {
+			switch n := n.(type) {
+			case *ast.AssignStmt:
+				if n.Tok == token.DEFINE && len(n.Lhs) == len(n.Rhs) {
+					for i, lhs := range n.Lhs {
+						if lhs.Pos() == obj.Pos() {
+							init = n.Rhs[i]
+						}
+					}
+				}
+			case *ast.ValueSpec:
+				if len(n.Names) == len(n.Values) {
+					for i, name := range n.Names {
+						if name.Pos() == obj.Pos() {
+							init = n.Values[i]
+						}
+					}
+				}
+			}
+			return init == nil
+		})
+		if init == nil {
+			return nil
+		}
+		if _, ok := ast.Unparen(init).(*ast.Ident); ok {
+			return nil
+		}
+		return pd.localSliceAllocation(init)
+	}
+	return nil
+}
+
+// paramName names the ith parameter of sig, or numbers it if it is unnamed
+func paramName(sig *types.Signature, i int) string {
+	name := sig.Params().At(i).Name()
+	if name == "" || name == "_" {
+		return fmt.Sprintf("#%d", i+1)
+	}
+	return name
+}
+
+// funcName names fn as it is spelled at call, such as pkg.F or T.M
+func funcName(fn *types.Func, call *ast.CallExpr) string {
+	if recv := fn.Type().(*types.Signature).Recv(); recv != nil {
+		t := recv.Type()
+		if ptr, ok := t.(*types.Pointer); ok {
+			t = ptr.Elem()
+		}
+		return types.TypeString(t, (*types.Package).Name) + "." + fn.Name()
+	}
+	if sel, ok := ast.Unparen(call.Fun).(*ast.SelectorExpr); ok {
+		if pkg, ok := sel.X.(*ast.Ident); ok {
+			return pkg.Name + "." + fn.Name()
+		}
+	}
+	return fn.Name()
+}
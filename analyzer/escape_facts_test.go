@@ -0,0 +1,204 @@
+package analyzer
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"testing"
+
+	"go.uber.org/zap"
+	"golang.org/x/tools/go/analysis"
+)
+
+// checkTestPackage parses and type-checks a single-file package, resolving
+// imports through imported
+func checkTestPackage(t *testing.T, fset *token.FileSet, path, code string, imported map[string]*types.Package) (*ast.File, *types.Package, *types.Info) {
+	t.Helper()
+
+	file, err := parser.ParseFile(fset, path+".go", code, 0)
+	if err != nil {
+		t.Fatalf("Failed to parse %s: %v", path, err)
+	}
+	info := &types.Info{
+		Types: make(map[ast.Expr]types.TypeAndValue),
+		Defs:  make(map[*ast.Ident]types.Object),
+		Uses:  make(map[*ast.Ident]types.Object),
+	}
+	config := &types.Config{
+		Importer: importerFunc(func(path string) (*types.Package, error) {
+			return imported[path], nil
+		}),
+	}
+	pkg, err := config.Check(path, fset, []*ast.File{file}, info)
+	if err != nil {
+		t.Fatalf("Failed to type check %s: %v", path, err)
+	}
+	return file, pkg, info
+}
+
+func TestCrossPackageEscapeFacts(t *testing.T) {
+	const storeCode = `
+package store
+
+type Registry struct{ last *int }
+
+type Box struct{ v *int }
+
+func (r *Registry) Keep(p *int) { r.last = p }
+
+// Save escapes p only through Keep
+func Save(r *Registry, p *int) { r.Keep(p) }
+
+func Peek(p *int) int { return *p }
+
+func Wrap(p *int) *Box { return &Box{v: p} }
+`
+	const mainCode = `
+package main
+
+import "example.com/store"
+
+func main() {
+	r := &store.Registry{}
+	a, b, c, d := 1, 2, 3, 4
+	store.Save(r, &a)
+	_ = store.Peek(&b)
+	_ = store.Wrap(&c)
+	r.Keep(&d)
+}
+`
+	fset := token.NewFileSet()
+	facts := make(map[types.Object]analysis.Fact)
+
+	// Analyze store first, as the driver would, exporting its facts
+	storeFile, storePkg, storeInfo := checkTestPackage(t, fset, "example.com/store", storeCode, nil)
+	computeEscapeFacts(&analysis.Pass{
+		Fset:      fset,
+		Files:     []*ast.File{storeFile},
+		Pkg:       storePkg,
+		TypesInfo: storeInfo,
+		ExportObjectFact: func(obj types.Object, fact analysis.Fact) {
+			facts[obj] = fact
+		},
+	}, defaultMaxDepth)
+
+	tests := []struct {
+		name     string
+		escaping []bool
+	}{
+		{"Save", []bool{false, true}},
+		{"Peek", []bool{false}},
+		{"Wrap", []bool{true}},
+	}
+	for _, tt := range tests {
+		fact, ok := facts[storePkg.Scope().Lookup(tt.name)].(*EscapeFact)
+		if !ok {
+			t.Errorf("Expected a fact for %s, got %v", tt.name, facts)
+			continue
+		}
+		if len(fact.Params) != len(tt.escaping) {
+			t.Errorf("Expected %d parameters for %s, got %v", len(tt.escaping), tt.name, fact)
+			continue
+		}
+		for i, escapes := range tt.escaping {
+			if fact.Params[i] != escapes {
+				t.Errorf("Expected parameter %d of %s to escape: %v, got %v", i, tt.name, escapes, fact)
+			}
+		}
+	}
+
+	// Then main, importing the facts at its call sites
+	mainFile, mainPkg, mainInfo := checkTestPackage(t, fset, "main", mainCode, map[string]*types.Package{"example.com/store": storePkg})
+	pass := &analysis.Pass{
+		Fset:      fset,
+		Files:     []*ast.File{mainFile},
+		Pkg:       mainPkg,
+		TypesInfo: mainInfo,
+		ImportObjectFact: func(obj types.Object, fact analysis.Fact) bool {
+			stored, ok := facts[obj]
+			if ok {
+				*fact.(*EscapeFact) = *stored.(*EscapeFact)
+			}
+			return ok
+		},
+	}
+
+	var messages []string
+	inspectFile(mainFile, mainInfo, newPackageInfo(pass, DefaultConfig()), fset, DefaultConfig(), zap.NewNop(), func(issue Issue) {
+		if issue.Pattern == "escaping-argument" {
+			messages = append(messages, issue.Message)
+		}
+	})
+
+	expected := []string{
+		"&a passed to store.Save escapes through its parameter p",
+		"&c passed to store.Wrap escapes through its parameter p",
+		"&d passed to store.Registry.Keep escapes through its parameter p",
+	}
+	if len(messages) != len(expected) {
+		t.Fatalf("Expected %d escaping-argument issues, got %v", len(expected), messages)
+	}
+	for i, want := range expected {
+		if !contains(messages[i], want) {
+			t.Errorf("Expected issue %d to contain %q, got %q", i, want, messages[i])
+		}
+	}
+}
+
+func TestRetainedArgument(t *testing.T) {
+	code := `package main
+
+var last []byte
+
+type Cache struct{ entries [][]int }
+
+func (c *Cache) Put(entry []int) { c.entries = append(c.entries, entry) }
+
+func remember(b []byte) { last = b }
+
+func sum(xs []int) int {
+	total := 0
+	for _, x := range xs {
+		total += x
+	}
+	return total
+}
+
+func main() {
+	c := &Cache{}
+	c.Put([]int{1, 2, 3})
+	buf := make([]byte, 64)
+	remember(buf)
+	var scratch = make([]int, 8)
+	_ = sum(scratch)
+	alias := buf
+	remember(alias)
+	remember(buf[:8])
+	_ = sum(make([]int, 4))
+}
+`
+	pass, _ := newTestPass(t, code)
+	var messages []string
+	options := newAnalyzerOptions(WithReporter(func(issue Issue) {
+		if issue.Pattern == "retained-argument" {
+			messages = append(messages, issue.Message)
+		}
+	}))
+	if _, err := runWithDeps(pass, options); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	expected := []string{
+		"slice passed to main.Cache.Put is retained through its parameter entry",
+		"buf is retained by remember through its parameter b, so the slice allocated for it on line 22",
+	}
+	if len(messages) != len(expected) {
+		t.Fatalf("Expected %d retained-argument issues, got %v", len(expected), messages)
+	}
+	for i, want := range expected {
+		if !contains(messages[i], want) {
+			t.Errorf("Expected issue %d to contain %q, got %q", i, want, messages[i])
+		}
+	}
+}
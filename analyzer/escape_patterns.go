@@ -0,0 +1,60 @@
+package analyzer
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+)
+
+// detectAddressOfLiteral detects &T{...} returned from a function or stored
+// somewhere that outlives it, which always moves the literal to the heap.
+// &T{...} bound to a local variable is left to escape analysis.
+func (pd *PatternDetector) detectAddressOfLiteral(lit *ast.CompositeLit, report func(node ast.Node, msg string)) {
+	if !pd.config.IsPatternEnabled("return-address-of-literal") || lit.Type == nil {
+		return
+	}
+
+	unary, ok := pd.ancestor(0).(*ast.UnaryExpr)
+	if !ok || unary.Op != token.AND || unary.X != lit {
+		return
+	}
+
+	name, ok := pd.nodeSource(lit.Type)
+	if !ok {
+		return
+	}
+
+	switch stmt := pd.ancestor(1).(type) {
+	case *ast.ReturnStmt:
+		msg := fmt.Sprintf("returning &%s{...} always allocates it on the heap", name)
+		if pd.isSmallValue(lit) {
+			msg += fmt.Sprintf("; consider returning %s by value if callers don't need to share or mutate it", name)
+		}
+		pd.reportRule(report, "return-address-of-literal", unary, msg)
+
+	case *ast.AssignStmt:
+		for i, rhs := range stmt.Rhs {
+			if rhs == unary && i < len(stmt.Lhs) && pd.isEscapingLocation(stmt.Lhs[i]) {
+				pd.reportRule(report, "return-address-of-literal", unary, fmt.Sprintf("&%s{...} stored in a location that outlives the function always allocates it on the heap", name))
+			}
+		}
+
+	case *ast.SendStmt:
+		if stmt.Value == unary {
+			pd.reportRule(report, "return-address-of-literal", unary, fmt.Sprintf("&%s{...} sent on a channel always allocates it on the heap", name))
+		}
+	}
+}
+
+// isEscapingLocation reports whether a value assigned to lhs outlives the
+// enclosing function: a field, an element of a slice or map, or a package-level variable
+func (pd *PatternDetector) isEscapingLocation(lhs ast.Expr) bool {
+	return isOutlivingLocation(lhs, pd.info)
+}
+
+// isSmallValue reports whether the literal's type is no larger than
+// MaxAllocSize, and so cheap enough to copy that returning it by value suffices
+func (pd *PatternDetector) isSmallValue(lit *ast.CompositeLit) bool {
+	size, ok := pd.sizeof(pd.info.TypeOf(lit))
+	return ok && pd.isSmallAlloc(size)
+}
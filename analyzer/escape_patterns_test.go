@@ -0,0 +1,69 @@
+package analyzer
+
+import "testing"
+
+func TestReturnAddressOfLiteral(t *testing.T) {
+	code := `
+package main
+
+type point struct{ x, y int }
+
+type big struct{ a, b, c, d, e, f int64 }
+
+type registry struct{ last *point }
+
+var current *point
+
+func newPoint() *point {
+	return &point{x: 1}
+}
+
+func newBig() (*big, error) {
+	return &big{}, nil
+}
+
+func store(r *registry, m map[string]*point, ch chan *point) {
+	r.last = &point{}
+	m["a"] = &point{}
+	current = &point{}
+	ch <- &point{}
+}
+
+func local() int {
+	p := &point{x: 2}
+	q := point{}
+	r := &q
+	return p.x + r.y
+}
+`
+	const rule = "always allocates it on the heap"
+
+	issues := inspectSource(t, code, DefaultConfig())
+
+	tests := []struct {
+		name     string
+		contains string
+		expected int
+	}{
+		{"returned small literal suggests a value", "returning &point{...} always allocates it on the heap; consider returning point by value", 1},
+		{"returned large literal has no value note", "returning &big{...} always allocates it on the heap", 1},
+		{"large literal note omitted", "consider returning big by value", 0},
+		{"stored in escaping locations", "stored in a location that outlives the function", 3},
+		{"sent on a channel", "sent on a channel", 1},
+		{"local uses are not flagged", rule, 6},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := countMatching(issues, tt.contains); got != tt.expected {
+				t.Errorf("Expected %d issues containing %q, got %d: %v", tt.expected, tt.contains, got, issues)
+			}
+		})
+	}
+
+	config := DefaultConfig()
+	config.DisablePatterns = []string{"return-address-of-literal"}
+	if got := countMatching(inspectSource(t, code, config), rule); got != 0 {
+		t.Errorf("Expected no return-address-of-literal issues when disabled, got %d", got)
+	}
+}
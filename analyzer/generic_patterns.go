@@ -0,0 +1,438 @@
+package analyzer
+
+import (
+	"fmt"
+	"go/ast"
+	"go/types"
+	"sort"
+	"strings"
+)
+
+// detectGenericCallPatterns runs detectCallPatterns, listing instantiations of
+// the enclosing generic function in issues about new(T) and make([]T, ...)
+// where T is one of its type parameters. With -generic-instances, allocations
+// that would otherwise go unreported are reported when an instantiation
+// exceeds -max-alloc-size.
+func (pd *PatternDetector) detectGenericCallPatterns(call *ast.CallExpr, report func(node ast.Node, msg string)) {
+	note, costly := pd.instantiationNote(call)
+	if note == "" {
+		pd.detectCallPatterns(call, report)
+		return
+	}
+
+	reported := false
+	pd.detectCallPatterns(call, func(node ast.Node, msg string) {
+		if node == call {
+			reported = true
+			msg += "; " + note
+		}
+		report(node, msg)
+	})
+
+	if !reported && costly && pd.config.IsPatternEnabled("generic-instance-alloc") {
+		pd.reportRule(report, "generic-instance-alloc", call, fmt.Sprintf("allocation of a type parameter is as costly as its largest instantiation; %s", note))
+	}
+}
+
+// instantiationNote describes the instantiations of the generic function
+// enclosing call when call allocates values of one of its type parameters. It
+// also reports whether any instantiation is larger than -max-alloc-size.
+func (pd *PatternDetector) instantiationNote(call *ast.CallExpr) (string, bool) {
+	if !pd.config.GenericInstances || len(call.Args) == 0 {
+		return "", false
+	}
+
+	var elem types.Type
+	switch {
+	case pd.isNewCall(call):
+		elem = pd.info.TypeOf(call.Args[0])
+	case pd.isMakeCall(call):
+		if t := pd.info.TypeOf(call.Args[0]); t != nil {
+			if slice, ok := t.Underlying().(*types.Slice); ok {
+				elem = slice.Elem()
+			}
+		}
+	}
+	tp, ok := elem.(*types.TypeParam)
+	if !ok {
+		return "", false
+	}
+
+	fn := pd.genericFunc(tp)
+	if fn == nil {
+		return "", false
+	}
+
+	qualifier := types.RelativeTo(fn.Pkg())
+	costly := false
+	var parts []string
+	for _, arg := range pd.instantiations(fn, tp.Index()) {
+		size, _ := pd.sizeof(arg)
+		part := fmt.Sprintf("%s=%s (%d bytes", tp.Obj().Name(), types.TypeString(arg, qualifier), size)
+		if size > int64(pd.config.MaxAllocSize) {
+			part += ", costly"
+			costly = true
+		}
+		parts = append(parts, part+")")
+	}
+	if len(parts) == 0 {
+		return "", false
+	}
+
+	return "instantiated with " + strings.Join(parts, ", "), costly
+}
+
+// genericFunc returns the function on the ancestor stack that declares tp
+func (pd *PatternDetector) genericFunc(tp *types.TypeParam) *types.Func {
+	for i := len(pd.stack) - 1; i >= 0; i-- {
+		decl, ok := pd.stack[i].(*ast.FuncDecl)
+		if !ok || decl.Type.TypeParams == nil {
+			continue
+		}
+		fn, ok := pd.info.Defs[decl.Name].(*types.Func)
+		if !ok {
+			return nil
+		}
+		sig := fn.Type().(*types.Signature)
+		if tp.Index() < sig.TypeParams().Len() && sig.TypeParams().At(tp.Index()) == tp {
+			return fn
+		}
+		return nil
+	}
+	return nil
+}
+
+// instantiations returns the distinct type arguments fn is instantiated with at
+// index within this package, in source order. Arguments that are themselves
+// generic have no fixed size and are left out.
+func (pd *PatternDetector) instantiations(fn *types.Func, index int) []types.Type {
+	var idents []*ast.Ident
+	for ident, inst := range pd.info.Instances {
+		if pd.info.Uses[ident] == fn && index < inst.TypeArgs.Len() {
+			idents = append(idents, ident)
+		}
+	}
+	sort.Slice(idents, func(i, j int) bool {
+		return idents[i].Pos() < idents[j].Pos()
+	})
+
+	seen := make(map[string]bool)
+	var args []types.Type
+	for _, ident := range idents {
+		arg := pd.info.Instances[ident].TypeArgs.At(index)
+		key := types.TypeString(arg, nil)
+		if seen[key] || hasTypeParam(arg) {
+			continue
+		}
+		seen[key] = true
+		args = append(args, arg)
+	}
+	return args
+}
+
+// hasTypeParam reports whether t mentions a type parameter
+func hasTypeParam(t types.Type) bool {
+	switch t := t.(type) {
+	case *types.TypeParam:
+		return true
+	case *types.Pointer:
+		return hasTypeParam(t.Elem())
+	case *types.Slice:
+		return hasTypeParam(t.Elem())
+	case *types.Array:
+		return hasTypeParam(t.Elem())
+	case *types.Chan:
+		return hasTypeParam(t.Elem())
+	case *types.Map:
+		return hasTypeParam(t.Key()) || hasTypeParam(t.Elem())
+	case *types.Struct:
+		for i := 0; i < t.NumFields(); i++ {
+			if hasTypeParam(t.Field(i).Type()) {
+				return true
+			}
+		}
+	case *types.Named:
+		args := t.TypeArgs()
+		for i := 0; i < args.Len(); i++ {
+			if hasTypeParam(args.At(i)) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// genericParamsNote suggests, under -suggest-generics, turning the interface
+// parameters of the function call calls into type parameters, when call boxes
+// the values it passes them and the function only uses them in ways a type
+// parameter supports: calling their methods, or passing them on to type
+// parameters of other functions. It returns "" when there's nothing to
+// suggest. Only plain functions declared in the file are considered, since
+// methods can't have type parameters of their own.
+func (pd *PatternDetector) genericParamsNote(call *ast.CallExpr) string {
+	if !pd.config.SuggestGenerics {
+		return ""
+	}
+	ident, ok := call.Fun.(*ast.Ident)
+	if !ok {
+		return ""
+	}
+	fn, ok := pd.info.Uses[ident].(*types.Func)
+	if !ok {
+		return ""
+	}
+	decl := pd.funcDecl(fn)
+	if decl == nil || decl.Recv != nil || decl.Type.TypeParams != nil || decl.Body == nil {
+		return ""
+	}
+
+	sig := fn.Type().(*types.Signature)
+	params := sig.Params()
+	generic := make(map[int]bool)
+	var names []string
+	for i, arg := range call.Args {
+		if i >= params.Len() || (sig.Variadic() && i == params.Len()-1) {
+			break
+		}
+		param := params.At(i)
+		if !types.IsInterface(param.Type()) || param.Name() == "" || param.Name() == "_" {
+			continue
+		}
+		if _, boxed := pd.boxedType(arg); !boxed || !pd.usedUniformly(decl.Body, param) {
+			continue
+		}
+		generic[i] = true
+		names = append(names, param.Name())
+	}
+	if len(generic) == 0 {
+		return ""
+	}
+
+	qualifier := types.RelativeTo(fn.Pkg())
+	var typeParams, list []string
+	for i := 0; i < params.Len(); i++ {
+		param := params.At(i)
+		t := types.TypeString(param.Type(), qualifier)
+		if sig.Variadic() && i == params.Len()-1 {
+			t = "..." + types.TypeString(param.Type().(*types.Slice).Elem(), qualifier)
+		}
+		if generic[i] {
+			name := "T"
+			if len(generic) > 1 {
+				name = fmt.Sprintf("T%d", len(typeParams)+1)
+			}
+			constraint := t
+			if iface, ok := param.Type().Underlying().(*types.Interface); ok && iface.Empty() {
+				constraint = "any"
+			}
+			typeParams = append(typeParams, name+" "+constraint)
+			t = name
+		}
+		list = append(list, strings.TrimSpace(param.Name()+" "+t))
+	}
+	return fmt.Sprintf("%s only calls methods of %s or passes it to type parameters, so with a type parameter, func %s[%s](%s), callers wouldn't box it", fn.Name(), strings.Join(names, " and "), fn.Name(), strings.Join(typeParams, ", "), strings.Join(list, ", "))
+}
+
+// funcDecl returns the declaration of fn in the file being inspected, or nil
+// if it's declared elsewhere
+func (pd *PatternDetector) funcDecl(fn *types.Func) *ast.FuncDecl {
+	if len(pd.stack) == 0 {
+		return nil
+	}
+	file, ok := pd.stack[0].(*ast.File)
+	if !ok {
+		return nil
+	}
+	for _, d := range file.Decls {
+		if decl, ok := d.(*ast.FuncDecl); ok && pd.info.Defs[decl.Name] == fn {
+			return decl
+		}
+	}
+	return nil
+}
+
+// usedUniformly reports whether every use of param within body is a call of
+// one of its methods or an argument passed to a type parameter of a generic
+// function that is inferred from it, neither of which needs its dynamic type
+func (pd *PatternDetector) usedUniformly(body *ast.BlockStmt, param *types.Var) bool {
+	allowed := make(map[*ast.Ident]bool)
+	uniform := true
+	ast.Inspect(body, func(n ast.Node) bool {
+		switch e := n.(type) {
+		case *ast.CallExpr:
+			if sel, ok := e.Fun.(*ast.SelectorExpr); ok {
+				if recv, ok := ast.Unparen(sel.X).(*ast.Ident); ok {
+					allowed[recv] = true
+				}
+			}
+			if callee := pd.calledFunc(e); callee != nil && !explicitlyInstantiated(e) {
+				params := callee.Type().(*types.Signature).Params()
+				for i, arg := range e.Args {
+					if ident, ok := ast.Unparen(arg).(*ast.Ident); ok && i < params.Len() {
+						if tp, ok := params.At(i).Type().(*types.TypeParam); ok && pd.inferredFrom(e, params, i, tp) {
+							allowed[ident] = true
+						}
+					}
+				}
+			}
+		case *ast.Ident:
+			if pd.info.Uses[e] == param && !allowed[e] {
+				uniform = false
+			}
+		}
+		return uniform
+	})
+	return uniform
+}
+
+// inferredFrom reports whether the type parameter tp of the function call
+// calls, which the parameter at index i has as its type, is inferred from
+// the argument at i alone. When another parameter mentions tp, its argument
+// could fix tp to an interface type, as seen []any does for
+// slices.Contains(seen, v), and v would still be boxed. Arguments passing the
+// same variable as the one at i are fine.
+func (pd *PatternDetector) inferredFrom(call *ast.CallExpr, params *types.Tuple, i int, tp *types.TypeParam) bool {
+	arg, _ := ast.Unparen(call.Args[i]).(*ast.Ident)
+	for j := 0; j < params.Len(); j++ {
+		if j == i || !mentionsTypeParam(params.At(j).Type(), tp, make(map[*types.TypeParam]bool)) {
+			continue
+		}
+		if j < len(call.Args) && arg != nil {
+			if other, ok := ast.Unparen(call.Args[j]).(*ast.Ident); ok && pd.info.Uses[other] == pd.info.Uses[arg] {
+				continue
+			}
+		}
+		return false
+	}
+	return true
+}
+
+// mentionsTypeParam reports whether t mentions tp, directly or through the
+// constraint of another type parameter, such as S ~[]E does for E
+func mentionsTypeParam(t types.Type, tp *types.TypeParam, seen map[*types.TypeParam]bool) bool {
+	switch t := t.(type) {
+	case *types.TypeParam:
+		if t == tp {
+			return true
+		}
+		if seen[t] {
+			return false
+		}
+		seen[t] = true
+		return mentionsTypeParam(t.Constraint().Underlying(), tp, seen)
+	case *types.Interface:
+		for i := 0; i < t.NumEmbeddeds(); i++ {
+			if mentionsTypeParam(t.EmbeddedType(i), tp, seen) {
+				return true
+			}
+		}
+	case *types.Union:
+		for i := 0; i < t.Len(); i++ {
+			if mentionsTypeParam(t.Term(i).Type(), tp, seen) {
+				return true
+			}
+		}
+	case *types.Pointer:
+		return mentionsTypeParam(t.Elem(), tp, seen)
+	case *types.Slice:
+		return mentionsTypeParam(t.Elem(), tp, seen)
+	case *types.Array:
+		return mentionsTypeParam(t.Elem(), tp, seen)
+	case *types.Chan:
+		return mentionsTypeParam(t.Elem(), tp, seen)
+	case *types.Map:
+		return mentionsTypeParam(t.Key(), tp, seen) || mentionsTypeParam(t.Elem(), tp, seen)
+	case *types.Signature:
+		for _, tuple := range []*types.Tuple{t.Params(), t.Results()} {
+			for i := 0; i < tuple.Len(); i++ {
+				if mentionsTypeParam(tuple.At(i).Type(), tp, seen) {
+					return true
+				}
+			}
+		}
+	case *types.Struct:
+		for i := 0; i < t.NumFields(); i++ {
+			if mentionsTypeParam(t.Field(i).Type(), tp, seen) {
+				return true
+			}
+		}
+	case *types.Named:
+		args := t.TypeArgs()
+		for i := 0; i < args.Len(); i++ {
+			if mentionsTypeParam(args.At(i), tp, seen) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// explicitlyInstantiated reports whether call gives the type arguments of the
+// generic function it calls, such as f[any](v), rather than leaving them to be
+// inferred
+func explicitlyInstantiated(call *ast.CallExpr) bool {
+	switch ast.Unparen(call.Fun).(type) {
+	case *ast.IndexExpr, *ast.IndexListExpr:
+		return true
+	}
+	return false
+}
+
+// calledFunc returns the function or method call calls, if it's a direct call
+// of a declared one
+func (pd *PatternDetector) calledFunc(call *ast.CallExpr) *types.Func {
+	switch fun := ast.Unparen(call.Fun).(type) {
+	case *ast.Ident:
+		fn, _ := pd.info.Uses[fun].(*types.Func)
+		return fn
+	case *ast.SelectorExpr:
+		fn, _ := pd.info.Uses[fun.Sel].(*types.Func)
+		return fn
+	case *ast.IndexExpr:
+		if ident, ok := fun.X.(*ast.Ident); ok {
+			fn, _ := pd.info.Uses[ident].(*types.Func)
+			return fn
+		}
+	}
+	return nil
+}
+
+// genericFieldNote suggests, under -suggest-generics, a type parameter for the
+// interface field of the struct type t, when t is declared in the file without
+// type parameters and nothing in the file asserts the field's dynamic type.
+// It returns "" when there's nothing to suggest.
+func (pd *PatternDetector) genericFieldNote(t types.Type, field *types.Var) string {
+	if !pd.config.SuggestGenerics || len(pd.stack) == 0 {
+		return ""
+	}
+	named, ok := t.(*types.Named)
+	if !ok || named.TypeParams().Len() > 0 {
+		return ""
+	}
+	file, ok := pd.stack[0].(*ast.File)
+	if !ok {
+		return ""
+	}
+
+	declared, asserted := false, false
+	ast.Inspect(file, func(n ast.Node) bool {
+		switch e := n.(type) {
+		case *ast.TypeSpec:
+			declared = declared || pd.info.Defs[e.Name] == named.Obj()
+		case *ast.TypeAssertExpr:
+			if sel, ok := ast.Unparen(e.X).(*ast.SelectorExpr); ok && pd.info.Uses[sel.Sel] == field {
+				asserted = true
+			}
+		}
+		return !asserted
+	})
+	if !declared || asserted {
+		return ""
+	}
+
+	constraint := types.TypeString(field.Type(), types.RelativeTo(field.Pkg()))
+	if iface, ok := field.Type().Underlying().(*types.Interface); ok && iface.Empty() {
+		constraint = "any"
+	}
+	return fmt.Sprintf("nothing in this file asserts the dynamic type of %s, so with a type parameter, type %s[T %s] struct { %s T; ... }, it would be stored without boxing", field.Name(), named.Obj().Name(), constraint, field.Name())
+}
@@ -0,0 +1,175 @@
+package analyzer
+
+import "testing"
+
+func TestGenericInstances(t *testing.T) {
+	code := `
+package main
+
+type Big struct{ buf [256]byte }
+
+func Fill[T any](n int) []T {
+	zero := new(T)
+	out := make([]T, n)
+	for i := range out {
+		out[i] = *zero
+	}
+	return out
+}
+
+func Wrap[T any](n int) []T {
+	return Fill[T](n)
+}
+
+func main() {
+	_ = Fill[int](4)
+	_ = Fill[Big](4)
+	_ = Fill[int](8)
+	_ = Wrap[string](1)
+}
+`
+	// Fill[T] inside Wrap has no fixed size, so only the instantiations in main count
+	const instances = "instantiated with T=int (8 bytes), T=Big (256 bytes, costly)"
+
+	// Off unless -generic-instances is set
+	if got := countMatching(inspectSource(t, code, DefaultConfig()), "instantiated with"); got != 0 {
+		t.Errorf("Expected no instantiation notes by default, got %d", got)
+	}
+
+	config := DefaultConfig()
+	config.GenericInstances = true
+	issues := inspectSource(t, code, config)
+
+	tests := []struct {
+		name     string
+		contains string
+		expected int
+	}{
+		{"new(T) lists each distinct instantiation", "new(T) always allocates on heap; consider using stack allocation if object doesn't escape; size not known statically; " + instances, 1},
+		{"make([]T, n) is reported for the costly instantiation", "allocation of a type parameter is as costly as its largest instantiation; " + instances, 1},
+		{"only allocations of type parameters", "instantiated with", 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := countMatching(issues, tt.contains); got != tt.expected {
+				t.Errorf("Expected %d issues containing %q, got %d: %v", tt.expected, tt.contains, got, issues)
+			}
+		})
+	}
+
+	pass, _ := newTestPass(t, code)
+	var found []Issue
+	for _, issue := range analyzeFile(pass.Files[0], pass.TypesInfo, pass.Fset, config) {
+		if issue.Pattern == "generic-instance-alloc" {
+			found = append(found, issue)
+		}
+	}
+	if len(found) != 1 || found[0].Pos.Line != 8 {
+		t.Errorf("Expected make([]T, n) on line 8 to be reported as generic-instance-alloc, got %v", found)
+	}
+
+	// The instantiation note stays on issues of other rules when the rule is disabled
+	config.DisablePatterns = []string{"generic-instance-alloc"}
+	issues = inspectSource(t, code, config)
+	if got := countMatching(issues, "allocation of a type parameter"); got != 0 {
+		t.Errorf("Expected no generic-instance-alloc issues when disabled, got %d: %v", got, issues)
+	}
+	if got := countMatching(issues, "instantiated with"); got != 1 {
+		t.Errorf("Expected the new(T) issue to keep its note, got %d: %v", got, issues)
+	}
+}
+
+func TestSuggestGenerics(t *testing.T) {
+	code := `
+package main
+
+import (
+	"fmt"
+	"slices"
+)
+
+type point struct{ x, y int }
+
+func (p point) String() string { return fmt.Sprint(p.x, p.y) }
+
+func describe(label string, v fmt.Stringer) string {
+	return label + v.String()
+}
+
+func contains(seen []any, v any) bool {
+	return slices.Contains(seen, v)
+}
+
+func keep[T any](v T) {}
+
+func record(v any) {
+	keep(v)
+}
+
+func kind(v any) string {
+	if _, ok := v.(fmt.Stringer); ok {
+		return "stringer"
+	}
+	return "value"
+}
+
+type entry struct {
+	key   string
+	value any
+}
+
+type tagged struct{ tag any }
+
+func tagName(t tagged) string {
+	s, _ := t.tag.(string)
+	return s
+}
+
+func use(p point, n int, keys []string) {
+	_ = describe("at ", p)
+	_ = contains(nil, n)
+	record(n)
+	_ = kind(p)
+	for _, k := range keys {
+		_ = entry{k, n}
+		_ = tagged{n}
+	}
+}
+`
+	const describeNote = "describe only calls methods of v or passes it to type parameters, so with a type parameter, func describe[T fmt.Stringer](label string, v T), callers wouldn't box it"
+	const containsNote = "contains only calls methods of v or passes it to type parameters, so with a type parameter, func contains[T any](seen []any, v T), callers wouldn't box it"
+	const recordNote = "record only calls methods of v or passes it to type parameters, so with a type parameter, func record[T any](v T), callers wouldn't box it"
+	const entryNote = "nothing in this file asserts the dynamic type of value, so with a type parameter, type entry[T any] struct { value T; ... }, it would be stored without boxing"
+
+	config := DefaultConfig()
+	config.EnablePatterns = []string{"interface-field-boxing"}
+	if got := countMatching(inspectSource(t, code, config), "with a type parameter"); got != 0 {
+		t.Errorf("Expected no generics suggestions without -suggest-generics, got %d", got)
+	}
+
+	config.SuggestGenerics = true
+	issues := inspectSource(t, code, config)
+
+	// kind needs v's dynamic type, seen fixes the type parameter contains
+	// passes v to at any, and tagName asserts tagged's field
+	tests := []struct {
+		name     string
+		contains string
+		expected int
+	}{
+		{"interface method calls", describeNote, 1},
+		{"type parameters of generic functions", recordNote, 1},
+		{"not type parameters inferred from an interface-typed argument", containsNote, 0},
+		{"struct fields", entryNote, 1},
+		{"only where the declaration allows it", "with a type parameter", 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := countMatching(issues, tt.contains); got != tt.expected {
+				t.Errorf("Expected %d issues containing %q, got %d: %v", tt.expected, tt.contains, got, issues)
+			}
+		})
+	}
+}
@@ -0,0 +1,196 @@
+package analyzer
+
+import (
+	"bufio"
+	"go/ast"
+	"go/token"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/harriteja/gostackallocator/internal"
+	"go.uber.org/zap"
+)
+
+// ignoreFileName is the file at the module root listing, one per line, the
+// paths to leave out of the analysis, in .gitignore syntax
+const ignoreFileName = ".stackallocignore"
+
+// ignorePattern is one line of an ignore file or one -exclude-files pattern
+type ignorePattern struct {
+	segments []string // slash-separated glob segments; "**" matches any number of them
+	negate   bool     // the pattern started with !, re-including what it matches
+	dirOnly  bool     // the pattern ended with /, matching only directories
+}
+
+// parseIgnorePattern parses line with .gitignore semantics: blank lines and
+// lines starting with # match nothing, a leading ! negates the pattern, a
+// trailing / restricts it to directories, and a pattern without any other /
+// matches at any depth, while one with a / is relative to the module root. A
+// leading \ escapes a # or !. It returns false for lines that match nothing,
+// including malformed globs.
+func parseIgnorePattern(line string) (ignorePattern, bool) {
+	line = strings.TrimRight(line, " \t\r")
+	if line == "" || strings.HasPrefix(line, "#") {
+		return ignorePattern{}, false
+	}
+
+	var p ignorePattern
+	if rest, ok := strings.CutPrefix(line, "!"); ok {
+		p.negate, line = true, rest
+	} else if strings.HasPrefix(line, `\#`) || strings.HasPrefix(line, `\!`) {
+		line = line[1:]
+	}
+	if rest, ok := strings.CutSuffix(line, "/"); ok {
+		p.dirOnly, line = true, rest
+	}
+	anchored := strings.Contains(line, "/")
+	line = strings.TrimPrefix(line, "/")
+	if line == "" {
+		return ignorePattern{}, false
+	}
+
+	if !anchored {
+		p.segments = append(p.segments, "**")
+	}
+	for _, segment := range strings.Split(line, "/") {
+		if _, err := path.Match(segment, ""); err != nil {
+			return ignorePattern{}, false
+		}
+		p.segments = append(p.segments, segment)
+	}
+	return p, true
+}
+
+// matches reports whether the pattern matches the slash-separated path segments
+func (p ignorePattern) matches(segments []string) bool {
+	return matchSegments(p.segments, segments)
+}
+
+// matchSegments matches path segments against glob segments, where "**"
+// matches zero or more whole segments
+func matchSegments(globs, segments []string) bool {
+	if len(globs) == 0 {
+		return len(segments) == 0
+	}
+	if globs[0] == "**" {
+		for i := 0; i <= len(segments); i++ {
+			if matchSegments(globs[1:], segments[i:]) {
+				return true
+			}
+		}
+		return false
+	}
+	if len(segments) == 0 {
+		return false
+	}
+	ok, _ := path.Match(globs[0], segments[0])
+	return ok && matchSegments(globs[1:], segments[1:])
+}
+
+// fileFilter decides which files are left out of the analysis, by the
+// patterns of the module's ignoreFileName followed by those of -exclude-files
+type fileFilter struct {
+	root     string
+	patterns []ignorePattern
+}
+
+// newFileFilter builds the filter for files in dir, reading ignoreFileName at
+// the root of dir's module, or dir itself outside a module. A missing file
+// just leaves the -exclude-files patterns; an unreadable one is logged.
+func newFileFilter(dir string, config *Config, logger *zap.Logger) *fileFilter {
+	root, err := internal.GetProjectRoot(dir)
+	if err != nil {
+		root = dir
+	}
+	filter := &fileFilter{root: root}
+
+	if f, err := os.Open(filepath.Join(root, ignoreFileName)); err == nil {
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			if p, ok := parseIgnorePattern(scanner.Text()); ok {
+				filter.patterns = append(filter.patterns, p)
+			}
+		}
+		if err := scanner.Err(); err != nil {
+			logger.Warn("Failed to read ignore file", zap.String("file", f.Name()), zap.Error(err))
+		}
+		f.Close()
+	} else if !os.IsNotExist(err) {
+		logger.Warn("Failed to read ignore file", zap.Error(err))
+	}
+
+	for _, pattern := range config.ExcludeFiles {
+		if p, ok := parseIgnorePattern(pattern); ok {
+			filter.patterns = append(filter.patterns, p)
+		}
+	}
+	return filter
+}
+
+// excluded reports whether filename is left out of the analysis. As with
+// .gitignore, the last pattern matching a path decides, and a file in an
+// excluded directory stays excluded whatever the patterns say about the file.
+// Files outside the root are never excluded.
+func (f *fileFilter) excluded(filename string) bool {
+	if len(f.patterns) == 0 {
+		return false
+	}
+	rel, err := filepath.Rel(f.root, filename)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return false
+	}
+
+	segments := strings.Split(filepath.ToSlash(rel), "/")
+	for i := 1; i < len(segments); i++ {
+		if f.lastMatch(segments[:i], true) {
+			return true
+		}
+	}
+	return f.lastMatch(segments, false)
+}
+
+// lastMatch applies the patterns to the path in order and reports whether the
+// last one matching excludes it
+func (f *fileFilter) lastMatch(segments []string, dir bool) bool {
+	excluded := false
+	for _, p := range f.patterns {
+		if p.dirOnly && !dir {
+			continue
+		}
+		if p.matches(segments) {
+			excluded = !p.negate
+		}
+	}
+	return excluded
+}
+
+// analyzedFiles returns the files to analyze, leaving out those excluded by
+// ignoreFileName or -exclude-files and those larger than -max-file-size, so
+// skipped files aren't counted as analyzed
+func analyzedFiles(files []*ast.File, fset *token.FileSet, config *Config, logger *zap.Logger) []*ast.File {
+	if len(files) == 0 {
+		return files
+	}
+	var filter *fileFilter
+	if filename := fset.Position(files[0].Pos()).Filename; filename != "" {
+		if abs, err := filepath.Abs(filename); err == nil {
+			filter = newFileFilter(filepath.Dir(abs), config, logger)
+		}
+	}
+
+	var kept []*ast.File
+	for _, file := range files {
+		name := fset.Position(file.Pos()).Filename
+		if abs, err := filepath.Abs(name); filter != nil && err == nil && filter.excluded(abs) {
+			logger.Info("Skipping excluded file", zap.String("file", name))
+			continue
+		}
+		if tooLarge(file, fset, config, logger) {
+			continue
+		}
+		kept = append(kept, file)
+	}
+	return kept
+}
@@ -0,0 +1,122 @@
+package analyzer
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+func TestFileFilter(t *testing.T) {
+	var patterns []ignorePattern
+	for _, line := range []string{
+		"# generated code",
+		"",
+		"*.pb.go",
+		"/vendor/",
+		"internal/legacy/**",
+		"testdata/",
+		"!testdata/keep.go",
+		"gen_*.go",
+		"!gen_keep.go",
+		`\#odd.go`,
+		"[bad",
+	} {
+		if p, ok := parseIgnorePattern(line); ok {
+			patterns = append(patterns, p)
+		}
+	}
+	filter := &fileFilter{root: "/repo", patterns: patterns}
+
+	tests := []struct {
+		path     string
+		excluded bool
+	}{
+		{"/repo/main.go", false},
+		{"/repo/api/v1/service.pb.go", true},
+		{"/repo/service.pb.go", true},
+		{"/repo/vendor/x/y.go", true},
+		{"/repo/pkg/vendor/y.go", false},
+		{"/repo/internal/legacy/old.go", true},
+		{"/repo/internal/legacy/deep/old.go", true},
+		{"/repo/internal/current.go", false},
+		{"/repo/testdata/a.go", true},
+		{"/repo/testdata/keep.go", true}, // its directory stays excluded
+		{"/repo/gen_types.go", true},
+		{"/repo/pkg/gen_keep.go", false},
+		{"/repo/#odd.go", true},
+		{"/repo/[bad", false},
+		{"/other/service.pb.go", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			if got := filter.excluded(tt.path); got != tt.excluded {
+				t.Errorf("excluded(%q) = %v, want %v", tt.path, got, tt.excluded)
+			}
+		})
+	}
+}
+
+func TestAnalyzedFiles(t *testing.T) {
+	root := t.TempDir()
+	writeFile := func(name, content string) {
+		t.Helper()
+		if err := os.MkdirAll(filepath.Dir(filepath.Join(root, name)), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(root, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeFile("go.mod", "module example.com/m\n")
+	writeFile(ignoreFileName, "# skip generated files\n*_gen.go\n!keep_gen.go\n")
+
+	fset := token.NewFileSet()
+	var files []*ast.File
+	for _, name := range []string{"main.go", "types_gen.go", "keep_gen.go", "mock_test.go"} {
+		file, err := parser.ParseFile(fset, filepath.Join(root, "pkg", name), "package pkg\n", 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		files = append(files, file)
+	}
+
+	names := func(files []*ast.File) []string {
+		var names []string
+		for _, file := range files {
+			names = append(names, filepath.Base(fset.Position(file.Pos()).Filename))
+		}
+		return names
+	}
+
+	tests := []struct {
+		name    string
+		exclude []string
+		want    []string
+	}{
+		{"ignore file", nil, []string{"main.go", "keep_gen.go", "mock_test.go"}},
+		{"with -exclude-files", []string{"*_test.go"}, []string{"main.go", "keep_gen.go"}},
+		{"-exclude-files negating the ignore file", []string{"!types_gen.go"}, []string{"main.go", "types_gen.go", "keep_gen.go", "mock_test.go"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := DefaultConfig()
+			config.ExcludeFiles = tt.exclude
+			got := names(analyzedFiles(files, fset, config, zap.NewNop()))
+			if len(got) != len(tt.want) {
+				t.Fatalf("Expected files %v, got %v", tt.want, got)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Fatalf("Expected files %v, got %v", tt.want, got)
+				}
+			}
+		})
+	}
+}
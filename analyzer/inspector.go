@@ -5,11 +5,16 @@ import (
 	"go/ast"
 	"go/token"
 	"go/types"
+	"strings"
+
+	"github.com/harriteja/gostackallocator/internal"
+	"go.uber.org/zap"
+	"golang.org/x/tools/go/analysis"
 )
 
 // usageTracker tracks allocation sites and their usage patterns
 type usageTracker struct {
-	allocSites map[types.Object]token.Pos
+	allocSites map[types.Object]ast.Node
 	useCounts  map[types.Object]int
 	escapes    map[types.Object]bool
 }
@@ -17,22 +22,122 @@ type usageTracker struct {
 // newUsageTracker creates a new usage tracker
 func newUsageTracker() *usageTracker {
 	return &usageTracker{
-		allocSites: make(map[types.Object]token.Pos),
+		allocSites: make(map[types.Object]ast.Node),
 		useCounts:  make(map[types.Object]int),
 		escapes:    make(map[types.Object]bool),
 	}
 }
 
-// InspectFile walks the AST and detects allocation patterns
+// InspectFile walks the AST and detects allocation patterns using the default configuration
 func InspectFile(f *ast.File, info *types.Info, fset *token.FileSet, report func(pos token.Pos, msg string)) {
+	InspectFileWithConfig(f, info, fset, DefaultConfig(), report)
+}
+
+// InspectFileWithConfig walks the AST and detects allocation patterns, honoring config
+func InspectFileWithConfig(f *ast.File, info *types.Info, fset *token.FileSet, config *Config, report func(pos token.Pos, msg string)) {
+	tokenFile := fset.File(f.Pos())
+	inspectFile(f, info, nil, fset, config, internal.GetLogger(), func(issue Issue) {
+		report(tokenFile.Pos(issue.Pos.Offset), issue.Message)
+	})
+}
+
+// packageInfo holds what the driver knows about the package a file belongs to,
+// beyond the file's own syntax and types
+type packageInfo struct {
+	sizes   types.Sizes        // sizes of the target platform; defaultSizes if nil
+	escapes *escapeFacts       // which parameters of called functions escape
+	weights map[string]float64 // profile weights of the package's functions under -pprof, by declKey
+}
+
+// newPackageInfo collects the package information for pass, exporting the
+// escape facts of its functions. The -pprof profile is expected to have been
+// loaded already, so that failing to load it was reported.
+func newPackageInfo(pass *analysis.Pass, config *Config) *packageInfo {
+	pkg := &packageInfo{
+		sizes:   pass.TypesSizes,
+		escapes: computeEscapeFacts(pass, config.MaxDepth),
+	}
+	if profile, err := loadProfile(config.Pprof); err == nil && profile != nil && pass.Pkg != nil {
+		pkg.weights = profile.packageWeights(pass.Pkg.Path(), pass.Pkg.Name())
+	}
+	return pkg
+}
+
+// inspectFile walks the AST and emits every detected issue, including the rule
+// name and any fixes attached by the detector. Declarations nested too deeply
+// to analyze are skipped with a warning to logger.
+func inspectFile(f *ast.File, info *types.Info, pkg *packageInfo, fset *token.FileSet, config *Config, logger *zap.Logger, emit func(issue Issue)) {
 	tracker := newUsageTracker()
 
-	// Create pattern detector with config (we'll need to add config parameter later)
-	config := &Config{} // Default config for now
+	if config == nil {
+		config = DefaultConfig()
+	}
+	f = withoutDeepDecls(f, fset, config, logger)
 	detector := NewPatternDetector(info, fset, config, tracker)
+	if pkg != nil {
+		detector.sizes = pkg.sizes
+		detector.escapes = pkg.escapes
+		detector.weights = pkg.weights
+	}
+	tokenFile := fset.File(f.Pos())
+	toggles := ruleDirectives(f, fset, logger)
+
+	// -path-tags routes findings to their owners by where their file is
+	var tags []string
+	if len(config.PathTags) > 0 && tokenFile != nil {
+		tags = fileTags(tokenFile.Name(), config.PathTags)
+	}
+
+	detector.emit = func(issue Issue) {
+		// Functions marked //stackalloc:allow-alloc allocate on purpose
+		if tokenFile != nil && allowsAlloc(f, tokenFile.Pos(issue.Pos.Offset)) {
+			return
+		}
+
+		// //stackalloc:disable turns rules off for the rest of the file
+		if tokenFile != nil && ruleDisabled(toggles, issue.Pattern, tokenFile.Pos(issue.Pos.Offset)) {
+			return
+		}
+
+		// -strict drops findings that are only a guess at an allocation
+		if config.Strict && !ruleVerified(issue.Pattern) {
+			return
+		}
+		issue.Severity = ruleSeverity(issue.Pattern, config.RuleSeverities)
+
+		// -pprof keeps only findings in functions the profile shows are hot
+		if pkg != nil && pkg.weights != nil && tokenFile != nil {
+			weight, ok := profileWeight(pkg.weights, f, tokenFile.Pos(issue.Pos.Offset))
+			if !hotEnough(weight, ok, config) {
+				return
+			}
+			issue.Message += fmt.Sprintf(" (pprof: %.1f%% of samples)", weight*100)
+		}
+
+		// Code that runs once at startup is rarely worth optimizing
+		if tokenFile != nil && runsOnce(f, tokenFile.Pos(issue.Pos.Offset)) {
+			if !config.IncludeInit {
+				return
+			}
+			issue.Severity = SeverityInfo
+		}
+		issue.Tags = tags
+		emit(issue)
+	}
+
+	report := func(node ast.Node, msg string) {
+		if config.runsUnnamedDetectors() {
+			detector.emit(newIssue(fset, node, msg))
+		}
+	}
 
 	// First pass: collect allocation sites and usage counts using enhanced pattern detection
 	ast.Inspect(f, func(n ast.Node) bool {
+		if n == nil {
+			detector.leave()
+			return true
+		}
+
 		// Use the new pattern detector for comprehensive analysis
 		detector.DetectPattern(n, report)
 
@@ -42,7 +147,7 @@ func InspectFile(f *ast.File, info *types.Info, fset *token.FileSet, report func
 			if expr.Op == token.AND {
 				if ident, ok := expr.X.(*ast.Ident); ok {
 					if obj := info.ObjectOf(ident); obj != nil && isLocalVar(obj) {
-						tracker.allocSites[obj] = expr.Pos()
+						tracker.allocSites[obj] = expr
 						tracker.useCounts[obj]++
 					}
 				}
@@ -77,36 +182,63 @@ func InspectFile(f *ast.File, info *types.Info, fset *token.FileSet, report func
 				checkEscapingAllocation(rhs, info, tracker, report)
 			}
 		}
+
+		// Track ancestors so detectors can reason about loop and function context
+		detector.enter(n)
 		return true
 	})
 
 	// Second pass: report single-use escaping allocations
-	for obj, pos := range tracker.allocSites {
+	for obj, site := range tracker.allocSites {
 		if tracker.useCounts[obj] <= 1 && tracker.escapes[obj] {
-			report(pos, fmt.Sprintf("pointer to %s escapes only once; consider using stack allocation", obj.Name()))
+			report(site, fmt.Sprintf("pointer to %s escapes only once; consider using stack allocation", obj.Name()))
 		}
 	}
 }
 
+// newIssue creates an issue spanning node
+func newIssue(fset *token.FileSet, node ast.Node, msg string) Issue {
+	return Issue{
+		Pos:     fset.Position(node.Pos()),
+		End:     fset.Position(node.End()),
+		Node:    strings.TrimPrefix(fmt.Sprintf("%T", node), "*ast."),
+		Message: msg,
+	}
+}
+
 // checkEscapingAllocation checks if an expression contains escaping allocations
-func checkEscapingAllocation(expr ast.Expr, info *types.Info, tracker *usageTracker, report func(pos token.Pos, msg string)) {
-	switch e := expr.(type) {
-	case *ast.UnaryExpr:
-		if e.Op == token.AND {
-			if ident, ok := e.X.(*ast.Ident); ok {
-				if obj := info.ObjectOf(ident); obj != nil {
-					if _, exists := tracker.allocSites[obj]; exists {
-						tracker.escapes[obj] = true
-					}
+func checkEscapingAllocation(expr ast.Expr, info *types.Info, tracker *usageTracker, report func(node ast.Node, msg string)) {
+	// Check if this is a new() call in return/assignment
+	if call, ok := expr.(*ast.CallExpr); ok && isNewCall(call, info) {
+		report(call, "new(T) in return/assignment always allocates on heap; consider stack allocation")
+	}
+
+	markEscapingAddresses(expr, info, tracker)
+}
+
+// markEscapingAddresses marks every local variable whose address is taken
+// within expr as escaping, including addresses nested in composite literals,
+// call arguments and other larger expressions. Statements are checked before
+// the expressions they contain are tracked as allocation sites, so escapes are
+// recorded for any local. Function literals are skipped, since the statements
+// in their bodies are checked on their own.
+func markEscapingAddresses(expr ast.Expr, info *types.Info, tracker *usageTracker) {
+	ast.Inspect(expr, func(n ast.Node) bool {
+		switch e := n.(type) {
+		case *ast.FuncLit:
+			return false
+		case *ast.UnaryExpr:
+			if e.Op != token.AND {
+				break
+			}
+			if ident, ok := ast.Unparen(e.X).(*ast.Ident); ok {
+				if obj := info.ObjectOf(ident); isLocalVar(obj) {
+					tracker.escapes[obj] = true
 				}
 			}
 		}
-	case *ast.CallExpr:
-		// Check if this is a new() call in return/assignment
-		if isNewCall(e, info) {
-			report(e.Pos(), "new(T) in return/assignment always allocates on heap; consider stack allocation")
-		}
-	}
+		return true
+	})
 }
 
 // isLocalVar checks if an object is a local variable
@@ -0,0 +1,1027 @@
+package analyzer
+
+import (
+	"fmt"
+	"go/ast"
+	"go/constant"
+	"go/token"
+	"go/types"
+	"strings"
+	"unicode/utf8"
+)
+
+// detectBlockPatterns detects allocation patterns spanning a sequence of statements
+func (pd *PatternDetector) detectBlockPatterns(block *ast.BlockStmt, report func(node ast.Node, msg string)) {
+	pd.detectRepeatedKeySort(block, report)
+	pd.detectBoolSetMap(block, report)
+	pd.detectDynamicContainerBoxing(block, report)
+	pd.detectMakeCopyClone(block, report)
+	pd.detectManualGrow(block, report)
+}
+
+// detectRepeatedKeySort detects the collect-map-keys-then-sort idiom when it
+// runs on every iteration of an outer loop. The idiom itself is fine, but
+// repeating it reallocates and re-sorts the same keys each time.
+func (pd *PatternDetector) detectRepeatedKeySort(block *ast.BlockStmt, report func(node ast.Node, msg string)) {
+	if !pd.config.IsPatternEnabled("repeated-key-sort") || !pd.isInLoop(block) {
+		return
+	}
+
+	for i, stmt := range block.List {
+		keys := pd.collectedMapKeys(stmt)
+		if keys == nil {
+			continue
+		}
+
+		for _, later := range block.List[i+1:] {
+			if pd.isSortOf(later, keys) {
+				pd.reportRule(report, "repeated-key-sort", stmt, "map keys are collected and sorted on every iteration of the enclosing loop; consider hoisting the sorted keys out of the loop or caching them")
+				break
+			}
+		}
+	}
+}
+
+// collectedMapKeys returns the slice variable populated by a loop of the form
+// `for k := range m { keys = append(keys, k) }`, or nil if stmt isn't one
+func (pd *PatternDetector) collectedMapKeys(stmt ast.Stmt) types.Object {
+	rng, ok := stmt.(*ast.RangeStmt)
+	if !ok || rng.Key == nil || len(rng.Body.List) != 1 {
+		return nil
+	}
+	if t := pd.info.TypeOf(rng.X); t == nil {
+		return nil
+	} else if _, ok := t.Underlying().(*types.Map); !ok {
+		return nil
+	}
+
+	key, ok := rng.Key.(*ast.Ident)
+	if !ok {
+		return nil
+	}
+
+	assign, ok := rng.Body.List[0].(*ast.AssignStmt)
+	if !ok || len(assign.Lhs) != 1 || len(assign.Rhs) != 1 {
+		return nil
+	}
+	target, ok := assign.Lhs[0].(*ast.Ident)
+	if !ok {
+		return nil
+	}
+	call, ok := assign.Rhs[0].(*ast.CallExpr)
+	if !ok || !pd.isAppendCall(call) || len(call.Args) != 2 {
+		return nil
+	}
+
+	dst, ok := call.Args[0].(*ast.Ident)
+	if !ok || pd.info.ObjectOf(dst) != pd.info.ObjectOf(target) {
+		return nil
+	}
+	elem, ok := call.Args[1].(*ast.Ident)
+	if !ok || pd.info.ObjectOf(elem) != pd.info.ObjectOf(key) {
+		return nil
+	}
+
+	return pd.info.ObjectOf(target)
+}
+
+// isSortOf reports whether stmt sorts the slice held in keys
+func (pd *PatternDetector) isSortOf(stmt ast.Stmt, keys types.Object) bool {
+	exprStmt, ok := stmt.(*ast.ExprStmt)
+	if !ok {
+		return false
+	}
+	call, ok := exprStmt.X.(*ast.CallExpr)
+	if !ok || len(call.Args) == 0 {
+		return false
+	}
+
+	if !pd.isPkgFunc(call, "sort", "Strings", "Ints", "Float64s", "Slice", "SliceStable") &&
+		!pd.isPkgFunc(call, "slices", "Sort", "SortFunc", "SortStableFunc") {
+		return false
+	}
+
+	ident, ok := call.Args[0].(*ast.Ident)
+	return ok && pd.info.ObjectOf(ident) == keys
+}
+
+// detectByteAppendLoop detects `buf = append(buf, b)` accumulating a []byte one
+// byte per iteration, which reallocates buf each time it outgrows its capacity.
+// It reports whether an issue was reported.
+func (pd *PatternDetector) detectByteAppendLoop(call *ast.CallExpr, report func(node ast.Node, msg string)) bool {
+	if !pd.config.IsPatternEnabled("byte-append-loop") || len(call.Args) != 2 || call.Ellipsis.IsValid() {
+		return false
+	}
+
+	loop, body := pd.enclosingLoop(call)
+	if loop == nil {
+		return false
+	}
+
+	buf, ok := call.Args[0].(*ast.Ident)
+	if !ok || !isByteSlice(pd.info.TypeOf(buf)) {
+		return false
+	}
+	obj := pd.info.ObjectOf(buf)
+	if obj == nil || withinRange(body, obj.Pos()) {
+		return false
+	}
+
+	assign, ok := pd.ancestor(0).(*ast.AssignStmt)
+	if !ok || len(assign.Lhs) != 1 || len(assign.Rhs) != 1 || assign.Rhs[0] != call {
+		return false
+	}
+	if target, ok := assign.Lhs[0].(*ast.Ident); !ok || pd.info.ObjectOf(target) != obj {
+		return false
+	}
+
+	if pd.isPreallocated(obj) {
+		return false
+	}
+
+	if bound, ok := pd.loopBound(loop); ok {
+		pd.reportRule(report, "byte-append-loop", call, fmt.Sprintf("appending to %s one byte at a time reallocates it as it grows; consider pre-allocating with make([]byte, 0, %s)", buf.Name, bound))
+	} else {
+		pd.reportRule(report, "byte-append-loop", call, fmt.Sprintf("appending to %s one byte at a time reallocates it as it grows; consider accumulating into a bytes.Buffer", buf.Name))
+	}
+	return true
+}
+
+// detectFieldAppendLoop detects `x.f = append(x.f, v)` growing a slice field
+// of a struct inside a loop, the field counterpart of appending to a local
+// slice: the field's backing array is reallocated each time it outgrows its
+// capacity. Fields grown with make or slices.Grow earlier in the function are
+// left alone. It reports whether an issue was reported.
+func (pd *PatternDetector) detectFieldAppendLoop(call *ast.CallExpr, report func(node ast.Node, msg string)) bool {
+	if !pd.config.IsPatternEnabled("field-append-loop") {
+		return false
+	}
+	field, ok := call.Args[0].(*ast.SelectorExpr)
+	if !ok {
+		return false
+	}
+	if v, ok := pd.info.ObjectOf(field.Sel).(*types.Var); !ok || !v.IsField() {
+		return false
+	}
+	loop, body := pd.enclosingLoop(call)
+	if loop == nil {
+		return false
+	}
+
+	// The same field must be updated on every iteration, so the struct has
+	// to be reached through variables declared outside the loop
+	root := fieldRoot(field)
+	if root == nil {
+		return false
+	}
+	if obj := pd.info.ObjectOf(root); obj == nil || withinRange(body, obj.Pos()) {
+		return false
+	}
+
+	assign, ok := pd.ancestor(0).(*ast.AssignStmt)
+	if !ok || len(assign.Lhs) != 1 || len(assign.Rhs) != 1 || assign.Rhs[0] != call {
+		return false
+	}
+	name, ok := pd.nodeSource(field)
+	if !ok {
+		return false
+	}
+	if target, ok := pd.nodeSource(assign.Lhs[0]); !ok || target != name {
+		return false
+	}
+	if pd.isFieldPreallocated(name, loop) {
+		return false
+	}
+
+	if bound, ok := pd.loopBound(loop); ok {
+		pd.reportRule(report, "field-append-loop", call, fmt.Sprintf("appending to field %s in a loop reallocates its backing array as it grows; consider growing it once before the loop with %s = slices.Grow(%s, %s)", name, name, name, bound))
+	} else {
+		pd.reportRule(report, "field-append-loop", call, fmt.Sprintf("appending to field %s in a loop reallocates its backing array as it grows; consider growing it before the loop with slices.Grow if the number of elements can be estimated", name))
+	}
+	return true
+}
+
+// fieldRoot returns the variable a chain of field selections such as a.b.c
+// starts from, or nil if the chain goes through anything else, such as a call
+// or an index expression
+func fieldRoot(expr ast.Expr) *ast.Ident {
+	for {
+		switch e := expr.(type) {
+		case *ast.Ident:
+			return e
+		case *ast.SelectorExpr:
+			expr = e.X
+		case *ast.StarExpr:
+			expr = e.X
+		case *ast.ParenExpr:
+			expr = e.X
+		default:
+			return nil
+		}
+	}
+}
+
+// isFieldPreallocated reports whether the field written as name is assigned a
+// make() call with an explicit capacity, or grown with slices.Grow, in the
+// enclosing function before loop
+func (pd *PatternDetector) isFieldPreallocated(name string, loop ast.Stmt) bool {
+	fn := pd.enclosingFunc()
+	if fn == nil {
+		return false
+	}
+
+	found := false
+	ast.Inspect(fn, func(n ast.Node) bool {
+		assign, ok := n.(*ast.AssignStmt)
+		if found || !ok || assign.Pos() >= loop.Pos() || len(assign.Lhs) != len(assign.Rhs) {
+			return !found
+		}
+		for i, lhs := range assign.Lhs {
+			if target, ok := pd.nodeSource(lhs); !ok || target != name {
+				continue
+			}
+			if call, ok := assign.Rhs[i].(*ast.CallExpr); ok && ((pd.isMakeCall(call) && len(call.Args) == 3) || pd.isPkgFunc(call, "slices", "Grow")) {
+				found = true
+			}
+		}
+		return !found
+	})
+	return found
+}
+
+// detectTypeSwitchInLoop detects type switches on interface{} values inside a
+// loop, the type switch counterpart of type-assertion-in-loop. Every value
+// switched on was boxed to be stored in the interface, and is dispatched on
+// again on every iteration. When the value is the element of a []any or
+// map[K]any being ranged over, the container is what boxes it.
+func (pd *PatternDetector) detectTypeSwitchInLoop(stmt *ast.TypeSwitchStmt, report func(node ast.Node, msg string)) {
+	if !pd.config.IsPatternEnabled("typeswitch-in-loop") {
+		return
+	}
+	var assert *ast.TypeAssertExpr
+	switch s := stmt.Assign.(type) {
+	case *ast.ExprStmt:
+		assert, _ = s.X.(*ast.TypeAssertExpr)
+	case *ast.AssignStmt:
+		if len(s.Rhs) == 1 {
+			assert, _ = s.Rhs[0].(*ast.TypeAssertExpr)
+		}
+	}
+	if assert == nil {
+		return
+	}
+	if t := pd.info.TypeOf(assert.X); t == nil || !isEmptyInterface(t) {
+		return
+	}
+	loop, _ := pd.enclosingLoop(stmt)
+	if loop == nil {
+		return
+	}
+	x, ok := pd.nodeSource(assert.X)
+	if !ok {
+		return
+	}
+
+	if container, ok := pd.rangedContainer(loop, assert.X); ok {
+		pd.reportRule(report, "typeswitch-in-loop", stmt, fmt.Sprintf("type switch on %s in a loop over %s dispatches on every element, each of which was boxed to be stored there; consider keeping values of each type in a container of that concrete type, or a type parameter, so no switch is needed", x, container))
+		return
+	}
+	pd.reportRule(report, "typeswitch-in-loop", stmt, fmt.Sprintf("type switch on interface{} value %s in a loop dispatches on its dynamic type on every iteration, and values stored in it were boxed; consider switching once before the loop if its type doesn't change, or using concrete types or a type parameter", x))
+}
+
+// isEmptyInterface reports whether t is interface{} or any
+func isEmptyInterface(t types.Type) bool {
+	iface, ok := t.Underlying().(*types.Interface)
+	return ok && iface.Empty()
+}
+
+// rangedContainer returns the type of the container loop ranges over, as
+// written relative to its package, if expr is the loop's value variable and
+// the container holds interface{} values
+func (pd *PatternDetector) rangedContainer(loop ast.Stmt, expr ast.Expr) (string, bool) {
+	rng, ok := loop.(*ast.RangeStmt)
+	if !ok || rng.Value == nil {
+		return "", false
+	}
+	value, ok := rng.Value.(*ast.Ident)
+	ident, isIdent := ast.Unparen(expr).(*ast.Ident)
+	if !ok || !isIdent || pd.info.ObjectOf(value) == nil || pd.info.ObjectOf(value) != pd.info.ObjectOf(ident) {
+		return "", false
+	}
+
+	t := pd.info.TypeOf(rng.X)
+	if t == nil {
+		return "", false
+	}
+	var elem types.Type
+	switch u := t.Underlying().(type) {
+	case *types.Slice:
+		elem = u.Elem()
+	case *types.Array:
+		elem = u.Elem()
+	case *types.Map:
+		elem = u.Elem()
+	default:
+		return "", false
+	}
+	if !isEmptyInterface(elem) {
+		return "", false
+	}
+	return types.TypeString(t, types.RelativeTo(namedPkg(t))), true
+}
+
+// isByteSlice reports whether t is a slice of bytes
+func isByteSlice(t types.Type) bool {
+	if t == nil {
+		return false
+	}
+	slice, ok := t.Underlying().(*types.Slice)
+	if !ok {
+		return false
+	}
+	basic, ok := slice.Elem().Underlying().(*types.Basic)
+	return ok && basic.Kind() == types.Byte
+}
+
+// isPreallocated reports whether obj is assigned a make() call with an explicit
+// capacity anywhere in the enclosing function
+func (pd *PatternDetector) isPreallocated(obj types.Object) bool {
+	fn := pd.enclosingFunc()
+	if fn == nil {
+		return false
+	}
+
+	sized := func(lhs []ast.Expr, rhs []ast.Expr) bool {
+		for i, expr := range lhs {
+			ident, ok := expr.(*ast.Ident)
+			if !ok || i >= len(rhs) || pd.info.ObjectOf(ident) != obj {
+				continue
+			}
+			if call, ok := rhs[i].(*ast.CallExpr); ok && pd.isMakeCall(call) && len(call.Args) == 3 {
+				return true
+			}
+		}
+		return false
+	}
+
+	found := false
+	ast.Inspect(fn, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.AssignStmt:
+			found = found || sized(n.Lhs, n.Rhs)
+		case *ast.ValueSpec:
+			lhs := make([]ast.Expr, len(n.Names))
+			for i, name := range n.Names {
+				lhs[i] = name
+			}
+			found = found || sized(lhs, n.Values)
+		}
+		return !found
+	})
+	return found
+}
+
+// loopBound returns the source of an expression bounding the number of
+// iterations of loop, or false if the loop looks unbounded
+func (pd *PatternDetector) loopBound(loop ast.Stmt) (string, bool) {
+	switch l := loop.(type) {
+	case *ast.RangeStmt:
+		t := pd.info.TypeOf(l.X)
+		if t == nil {
+			return "", false
+		}
+		x, ok := pd.nodeSource(l.X)
+		if !ok {
+			return "", false
+		}
+		switch u := t.Underlying().(type) {
+		case *types.Basic:
+			if u.Info()&types.IsString != 0 {
+				return fmt.Sprintf("len(%s)", x), true
+			}
+			if u.Info()&types.IsInteger != 0 {
+				return x, true
+			}
+		case *types.Slice, *types.Array, *types.Map:
+			return fmt.Sprintf("len(%s)", x), true
+		case *types.Pointer:
+			if _, ok := u.Elem().Underlying().(*types.Array); ok {
+				return fmt.Sprintf("len(%s)", x), true
+			}
+		}
+	case *ast.ForStmt:
+		cond, ok := l.Cond.(*ast.BinaryExpr)
+		if !ok || (cond.Op != token.LSS && cond.Op != token.LEQ) {
+			return "", false
+		}
+		if _, ok := cond.X.(*ast.Ident); !ok {
+			return "", false
+		}
+		return pd.nodeSource(cond.Y)
+	}
+	return "", false
+}
+
+// detectSortSliceClosure detects sort.Slice and sort.SliceStable called on every
+// loop iteration or request. Each call allocates the less closure and a
+// reflection-based swapper.
+func (pd *PatternDetector) detectSortSliceClosure(call *ast.CallExpr, report func(node ast.Node, msg string)) {
+	if !pd.config.IsPatternEnabled("sort-slice-closure") || !pd.isPkgFunc(call, "sort", "Slice", "SliceStable") {
+		return
+	}
+
+	_, name := pd.pkgFunc(call)
+
+	var where string
+	switch {
+	case pd.isInLoop(call):
+		where = "on every loop iteration"
+	case pd.isInHTTPHandler():
+		where = "on every request"
+	default:
+		return
+	}
+
+	pd.reportRule(report, "sort-slice-closure", call, fmt.Sprintf("sort.%s %s allocates its less closure and a reflection-based swapper each time; consider slices.SortFunc (Go 1.21+) or sort.Sort with a reusable sort.Interface type", name, where))
+}
+
+// detectJSONInLoop detects encoding/json marshalling and unmarshalling on every
+// loop iteration. Each call allocates its output or scratch buffers afresh.
+func (pd *PatternDetector) detectJSONInLoop(call *ast.CallExpr, report func(node ast.Node, msg string)) {
+	if !pd.config.IsPatternEnabled("json-in-loop") || !pd.isPkgFunc(call, "encoding/json", "Marshal", "MarshalIndent", "Unmarshal") {
+		return
+	}
+	if !pd.isInLoop(call) {
+		return
+	}
+
+	_, name := pd.pkgFunc(call)
+	if name == "Unmarshal" {
+		pd.reportRule(report, "json-in-loop", call, "json.Unmarshal in a loop allocates on every iteration; consider a json.Decoder reading from a reused buffer")
+		return
+	}
+	pd.reportRule(report, "json-in-loop", call, fmt.Sprintf("json.%s in a loop allocates a new []byte on every iteration; consider a json.Encoder writing to a reused bytes.Buffer, or pooling buffers with sync.Pool", name))
+}
+
+// detectRandSourceInLoop detects math/rand sources created inside a loop. Each
+// one allocates its state, and sources seeded from the clock inside a tight
+// loop tend to repeat the same numbers, so one source made before the loop
+// should be reused instead.
+func (pd *PatternDetector) detectRandSourceInLoop(call *ast.CallExpr, report func(node ast.Node, msg string)) {
+	if !pd.config.IsPatternEnabled("rand-source-in-loop") {
+		return
+	}
+	if !pd.isPkgFunc(call, "math/rand", "New", "NewSource") && !pd.isPkgFunc(call, "math/rand/v2", "New", "NewPCG", "NewChaCha8") {
+		return
+	}
+	if !pd.isInLoop(call) {
+		return
+	}
+
+	pkg := call.Fun.(*ast.SelectorExpr).X.(*ast.Ident).Name
+	_, name := pd.pkgFunc(call)
+	pd.reportRule(report, "rand-source-in-loop", call, fmt.Sprintf("%s.%s in a loop allocates a new random source on every iteration, and sources seeded alike produce the same numbers; create one source before the loop and reuse it", pkg, name))
+}
+
+// detectGetenvInLoop detects os.Getenv and os.LookupEnv called inside a loop
+// with a constant key. Each call locks and searches the environment for the
+// same variable, and the strconv parsing that often follows repeats too, so
+// the value should be read and parsed once before the loop.
+func (pd *PatternDetector) detectGetenvInLoop(call *ast.CallExpr, report func(node ast.Node, msg string)) {
+	if !pd.config.IsPatternEnabled("getenv-in-loop") || !pd.isPkgFunc(call, "os", "Getenv", "LookupEnv") || len(call.Args) != 1 {
+		return
+	}
+	tv, ok := pd.info.Types[call.Args[0]]
+	if !ok || tv.Value == nil || tv.Value.Kind() != constant.String {
+		return
+	}
+	loop, body := pd.enclosingLoop(call)
+	if loop == nil {
+		return
+	}
+
+	pkg := call.Fun.(*ast.SelectorExpr).X.(*ast.Ident).Name
+	_, name := pd.pkgFunc(call)
+	lookup := fmt.Sprintf("%s.%s(%s)", pkg, name, tv.Value.ExactString())
+	if parse := pd.envParse(call, body); parse != "" {
+		pd.reportRule(report, "getenv-in-loop", call, fmt.Sprintf("%s in a loop looks up and parses the same variable with %s on every iteration; read and parse it once before the loop", lookup, parse))
+		return
+	}
+	pd.reportRule(report, "getenv-in-loop", call, fmt.Sprintf("%s in a loop looks up the same variable on every iteration; read it once before the loop", lookup))
+}
+
+// envParse returns the parsing function, such as strconv.Atoi, applied to the
+// value the environment lookup call returns, either directly or through the
+// variable it's assigned to within body, or "" if there's none
+func (pd *PatternDetector) envParse(call *ast.CallExpr, body *ast.BlockStmt) string {
+	isParse := func(c *ast.CallExpr) bool {
+		return len(c.Args) > 0 && (pd.isPkgFunc(c, "strconv", "Atoi", "ParseInt", "ParseUint", "ParseFloat", "ParseBool") || pd.isPkgFunc(c, "time", "ParseDuration"))
+	}
+	parseName := func(c *ast.CallExpr) string {
+		sel := c.Fun.(*ast.SelectorExpr)
+		return sel.X.(*ast.Ident).Name + "." + sel.Sel.Name
+	}
+
+	switch parent := pd.ancestor(0).(type) {
+	case *ast.CallExpr:
+		if isParse(parent) && parent.Args[0] == call {
+			return parseName(parent)
+		}
+	case *ast.AssignStmt:
+		if len(parent.Rhs) != 1 || len(parent.Lhs) == 0 {
+			return ""
+		}
+		ident, ok := parent.Lhs[0].(*ast.Ident)
+		if !ok {
+			return ""
+		}
+		obj := pd.info.ObjectOf(ident)
+		if obj == nil {
+			return ""
+		}
+		parse := ""
+		ast.Inspect(body, func(n ast.Node) bool {
+			if c, ok := n.(*ast.CallExpr); ok && isParse(c) {
+				if arg, ok := ast.Unparen(c.Args[0]).(*ast.Ident); ok && pd.info.Uses[arg] == obj {
+					parse = parseName(c)
+				}
+			}
+			return parse == ""
+		})
+		return parse
+	}
+	return ""
+}
+
+// detectTimeFormatLoop detects time.Time.Format called inside a loop. Each
+// call allocates the formatted string, where AppendFormat writes it into a
+// buffer that can be reused across iterations.
+func (pd *PatternDetector) detectTimeFormatLoop(call *ast.CallExpr, report func(node ast.Node, msg string)) {
+	if !pd.config.IsPatternEnabled("time-format-loop") {
+		return
+	}
+	sel, ok := call.Fun.(*ast.SelectorExpr)
+	if !ok || sel.Sel.Name != "Format" {
+		return
+	}
+	fn, ok := pd.info.ObjectOf(sel.Sel).(*types.Func)
+	if !ok || fn.FullName() != "(time.Time).Format" || !pd.isInLoop(call) {
+		return
+	}
+	recv, ok := pd.nodeSource(sel.X)
+	if !ok {
+		return
+	}
+
+	pd.reportRule(report, "time-format-loop", call, fmt.Sprintf("%s.Format in a loop allocates a new string on every iteration; consider %s.AppendFormat into a []byte buffer reused across iterations", recv, recv))
+}
+
+// detectStrconvFormatHotPath detects strconv.FormatInt, FormatUint and
+// FormatFloat called in a loop, or in a function -pprof shows is hot. Each
+// call allocates the formatted string, where the matching Append function
+// writes it into a buffer that can be reused. FormatBool returns one of two
+// constant strings and never allocates. strconv.Itoa is reported by
+// format-itoa, and a conversion of the result to []byte by
+// format-then-convert.
+func (pd *PatternDetector) detectStrconvFormatHotPath(call *ast.CallExpr, report func(node ast.Node, msg string)) {
+	if !pd.config.IsPatternEnabled("strconv-format-hotpath") {
+		return
+	}
+	path, name := pd.pkgFunc(call)
+	appendName, ok := appendFuncs[path][name]
+	if path != "strconv" || !ok || !strings.HasPrefix(name, "Format") || name == "FormatBool" || !pd.isInHotPath(call) {
+		return
+	}
+	if parent, ok := pd.ancestor(0).(*ast.CallExpr); ok {
+		if tv, ok := pd.info.Types[parent.Fun]; ok && tv.IsType() && isByteSlice(tv.Type) {
+			return
+		}
+	}
+
+	args := []string{"buf[:0]"}
+	for _, arg := range call.Args {
+		src, ok := pd.nodeSource(arg)
+		if !ok {
+			return
+		}
+		args = append(args, src)
+	}
+	where := "in a loop"
+	if pd.weights != nil {
+		where = "in a hot function"
+	}
+	pd.reportRule(report, "strconv-format-hotpath", call, fmt.Sprintf("strconv.%s %s allocates a new string on every call; consider buf = strconv.%s(%s), appending to a []byte reused across calls", name, where, appendName, strings.Join(args, ", ")))
+}
+
+// detectChanSendLoop detects values sent on a channel one per loop iteration.
+// Each send synchronizes with the receiver, so sending slices of values can
+// cut the overhead when the receiver can process them in batches.
+func (pd *PatternDetector) detectChanSendLoop(send *ast.SendStmt, report func(node ast.Node, msg string)) {
+	if !pd.config.IsPatternEnabled("chan-send-loop") || !pd.isInLoop(send) {
+		return
+	}
+
+	pd.reportRule(report, "chan-send-loop", send, "channel send on every loop iteration pays for synchronization with the receiver each time; consider batching values into a slice and sending one slice per batch")
+}
+
+// detectRepeatInLoop detects strings.Repeat and bytes.Repeat called inside a
+// loop with arguments that don't change between iterations. Each call
+// allocates the same result again.
+func (pd *PatternDetector) detectRepeatInLoop(call *ast.CallExpr, report func(node ast.Node, msg string)) {
+	if !pd.config.IsPatternEnabled("repeat-in-loop") || len(call.Args) != 2 {
+		return
+	}
+	if !pd.isPkgFunc(call, "strings", "Repeat") && !pd.isPkgFunc(call, "bytes", "Repeat") {
+		return
+	}
+
+	loop, _ := pd.enclosingLoop(call)
+	if loop == nil {
+		return
+	}
+	for _, arg := range call.Args {
+		if !pd.isLoopInvariant(arg, loop) {
+			return
+		}
+	}
+
+	pkg, _ := pd.pkgFunc(call)
+	pd.reportRule(report, "repeat-in-loop", call, fmt.Sprintf("%s.Repeat with loop-invariant arguments allocates the same result on every iteration; consider computing it once before the loop", pkg))
+}
+
+// detectPathJoinLoop detects filepath.Join and path.Join inside a loop whose
+// leading arguments don't change between iterations. Each call allocates the
+// joined path, redoing the part that is the same every time; joining the
+// invariant prefix once before the loop leaves less work per iteration.
+func (pd *PatternDetector) detectPathJoinLoop(call *ast.CallExpr, report func(node ast.Node, msg string)) {
+	if !pd.config.IsPatternEnabled("path-join-loop") || call.Ellipsis.IsValid() {
+		return
+	}
+	if !pd.isPkgFunc(call, "path/filepath", "Join") && !pd.isPkgFunc(call, "path", "Join") {
+		return
+	}
+
+	loop, _ := pd.enclosingLoop(call)
+	if loop == nil {
+		return
+	}
+	invariant := 0
+	for _, arg := range call.Args {
+		if !pd.isLoopInvariant(arg, loop) {
+			break
+		}
+		invariant++
+	}
+
+	path, _ := pd.pkgFunc(call)
+	pkg := path[strings.LastIndex(path, "/")+1:]
+	switch {
+	case invariant == len(call.Args) && invariant > 0:
+		pd.reportRule(report, "path-join-loop", call, fmt.Sprintf("%s.Join with loop-invariant arguments builds the same path on every iteration; consider computing it once before the loop", pkg))
+	case invariant >= 2:
+		pd.reportRule(report, "path-join-loop", call, fmt.Sprintf("%s.Join joins the same first %d elements on every iteration; consider joining them once before the loop and joining only the elements that change inside it", pkg, invariant))
+	}
+}
+
+// isLoopInvariant reports whether expr evaluates to the same value on every
+// iteration of loop: it only refers to constants and to variables declared
+// outside the loop that the loop never assigns or takes the address of. Calls
+// other than conversions may have side effects, so they are never invariant.
+func (pd *PatternDetector) isLoopInvariant(expr ast.Expr, loop ast.Stmt) bool {
+	invariant := true
+	ast.Inspect(expr, func(n ast.Node) bool {
+		switch e := n.(type) {
+		case *ast.CallExpr:
+			if tv, ok := pd.info.Types[e.Fun]; !ok || !tv.IsType() {
+				invariant = false
+			}
+		case *ast.Ident:
+			obj, ok := pd.info.ObjectOf(e).(*types.Var)
+			if !ok {
+				break
+			}
+			if withinRange(loop, obj.Pos()) || pd.isModifiedIn(loop, obj) {
+				invariant = false
+			}
+		}
+		return invariant
+	})
+	return invariant
+}
+
+// isModifiedIn reports whether obj is assigned, incremented or has its address
+// taken anywhere within node
+func (pd *PatternDetector) isModifiedIn(node ast.Node, obj types.Object) bool {
+	refersTo := func(expr ast.Expr) bool {
+		ident, ok := ast.Unparen(expr).(*ast.Ident)
+		return ok && pd.info.ObjectOf(ident) == obj
+	}
+
+	modified := false
+	ast.Inspect(node, func(n ast.Node) bool {
+		switch s := n.(type) {
+		case *ast.AssignStmt:
+			for _, lhs := range s.Lhs {
+				modified = modified || refersTo(lhs)
+			}
+		case *ast.IncDecStmt:
+			modified = modified || refersTo(s.X)
+		case *ast.UnaryExpr:
+			modified = modified || (s.Op == token.AND && refersTo(s.X))
+		}
+		return !modified
+	})
+	return modified
+}
+
+// detectInterfaceWriteLoop detects concrete values written to elements of a
+// slice, array or map of interface type inside a loop, such as out[i] = n for
+// out []any. Each write boxes the value, allocating once per iteration.
+func (pd *PatternDetector) detectInterfaceWriteLoop(assign *ast.AssignStmt, report func(node ast.Node, msg string)) {
+	if !pd.config.IsPatternEnabled("interface-write-loop") || assign.Tok != token.ASSIGN || len(assign.Lhs) != len(assign.Rhs) {
+		return
+	}
+
+	loop, _ := pd.enclosingLoop(assign)
+	if loop == nil {
+		return
+	}
+
+	for i, lhs := range assign.Lhs {
+		index, ok := lhs.(*ast.IndexExpr)
+		if !ok {
+			continue
+		}
+		container := pd.info.TypeOf(index.X)
+		elem := pd.info.TypeOf(lhs)
+		if container == nil || elem == nil || !types.IsInterface(elem) {
+			continue
+		}
+		value, ok := pd.boxedType(assign.Rhs[i])
+		if !ok {
+			continue
+		}
+
+		qualifier := types.RelativeTo(namedPkg(value, container))
+		msg := fmt.Sprintf("storing %s in an element of %s boxes it, allocating on every iteration", types.TypeString(value, qualifier), types.TypeString(container, qualifier))
+		if bound, ok := pd.loopBound(loop); ok {
+			msg += fmt.Sprintf(" (%s allocations per run of the loop)", bound)
+		}
+		msg += fmt.Sprintf("; consider %s as the element type if every value has that type", types.TypeString(value, qualifier))
+		pd.reportRule(report, "interface-write-loop", assign, msg)
+	}
+}
+
+// detectInterfaceFieldBoxing detects struct literals built on every loop
+// iteration or request that store a concrete value in an interface-typed
+// field, such as Event{Payload: n} for Payload any. Each one boxes the value,
+// allocating it on the heap alongside the struct.
+func (pd *PatternDetector) detectInterfaceFieldBoxing(lit *ast.CompositeLit, report func(node ast.Node, msg string)) {
+	if !pd.config.IsPatternEnabled("interface-field-boxing") {
+		return
+	}
+	t := pd.info.TypeOf(lit)
+	if t == nil {
+		return
+	}
+	st, ok := t.Underlying().(*types.Struct)
+	if !ok {
+		return
+	}
+
+	var where string
+	switch {
+	case pd.isInLoop(lit):
+		where = "on every loop iteration"
+	case pd.isInHTTPHandler():
+		where = "on every request"
+	default:
+		return
+	}
+
+	for i, elt := range lit.Elts {
+		field, value := pd.structLitField(st, i, elt)
+		if field == nil || !types.IsInterface(field.Type()) {
+			continue
+		}
+		boxed, ok := pd.boxedType(value)
+		if !ok {
+			continue
+		}
+
+		qualifier := types.RelativeTo(namedPkg(boxed, t))
+		msg := fmt.Sprintf("storing %s in the interface field %s of %s boxes it, allocating %s; consider a concrete field type, or a type parameter, if %s doesn't need dynamic dispatch", types.TypeString(boxed, qualifier), field.Name(), types.TypeString(t, qualifier), where, field.Name())
+		if note := pd.genericFieldNote(t, field); note != "" {
+			msg += "; " + note
+		}
+		pd.reportRule(report, "interface-field-boxing", value, msg)
+	}
+}
+
+// structLitField returns the field of st that the ith element of a struct
+// literal initializes, along with the value it's given
+func (pd *PatternDetector) structLitField(st *types.Struct, i int, elt ast.Expr) (*types.Var, ast.Expr) {
+	kv, ok := elt.(*ast.KeyValueExpr)
+	if !ok {
+		if i >= st.NumFields() {
+			return nil, nil
+		}
+		return st.Field(i), elt
+	}
+	key, ok := kv.Key.(*ast.Ident)
+	if !ok {
+		return nil, nil
+	}
+	for j := 0; j < st.NumFields(); j++ {
+		if st.Field(j).Name() == key.Name {
+			return st.Field(j), kv.Value
+		}
+	}
+	return nil, nil
+}
+
+// boxedType returns the type of expr if converting it to an interface
+// allocates. Interfaces, pointer-shaped values, constants and values of
+// zero-sized or single-byte types are stored without allocating.
+func (pd *PatternDetector) boxedType(expr ast.Expr) (types.Type, bool) {
+	tv, ok := pd.info.Types[expr]
+	if !ok || tv.Type == nil || tv.Value != nil || tv.IsNil() || types.IsInterface(tv.Type) {
+		return nil, false
+	}
+
+	switch u := tv.Type.Underlying().(type) {
+	case *types.Pointer, *types.Map, *types.Chan, *types.Signature:
+		return nil, false
+	case *types.Basic:
+		if u.Kind() == types.UnsafePointer {
+			return nil, false
+		}
+	}
+	if size, ok := pd.sizeof(tv.Type); ok && size <= 1 {
+		return nil, false
+	}
+	return tv.Type, true
+}
+
+// detectBuilderNoGrow detects a strings.Builder or bytes.Buffer written to in
+// a loop with a known number of iterations, when the function never calls
+// Grow on it before the loop ends. It reallocates its buffer as it grows,
+// where one Grow call with the total size would allocate once.
+func (pd *PatternDetector) detectBuilderNoGrow(call *ast.CallExpr, report func(node ast.Node, msg string)) {
+	if !pd.config.IsPatternEnabled("builder-no-grow") {
+		return
+	}
+	sel, ok := call.Fun.(*ast.SelectorExpr)
+	if !ok {
+		return
+	}
+	switch sel.Sel.Name {
+	case "Write", "WriteString", "WriteByte", "WriteRune":
+	default:
+		return
+	}
+	ident, ok := ast.Unparen(sel.X).(*ast.Ident)
+	if !ok {
+		return
+	}
+	obj, ok := pd.info.ObjectOf(ident).(*types.Var)
+	if !ok {
+		return
+	}
+	kind, ok := builderType(obj.Type())
+	if !ok {
+		return
+	}
+
+	// Only builders declared in this function before the loop: parameters may
+	// have been grown by the caller
+	loop, body := pd.enclosingLoop(call)
+	fn := pd.enclosingFunc()
+	if loop == nil || fn == nil || withinRange(loop, obj.Pos()) || !withinRange(fn, obj.Pos()) || withinRange(funcType(fn), obj.Pos()) {
+		return
+	}
+	bound, ok := pd.loopBound(loop)
+	if !ok {
+		return
+	}
+
+	// Report each loop once, at its first write
+	writes, perIteration, known := pd.builderWrites(body, obj)
+	if len(writes) == 0 || writes[0] != call || pd.growsBefore(fn, obj, loop.End()) {
+		return
+	}
+
+	msg := fmt.Sprintf("%s %s is written to on each of %s iterations without calling Grow, reallocating as it grows", kind, ident.Name, bound)
+	if known {
+		if strings.ContainsAny(bound, " +-*/%&|^<>") {
+			bound = "(" + bound + ")"
+		}
+		size := bound
+		if perIteration != 1 {
+			size = fmt.Sprintf("%s*%d", bound, perIteration)
+		}
+		msg += fmt.Sprintf("; consider %s.Grow(%s) before the loop", ident.Name, size)
+	} else {
+		msg += fmt.Sprintf("; consider calling %s.Grow with the expected total size before the loop", ident.Name)
+	}
+	pd.reportRule(report, "builder-no-grow", call, msg)
+}
+
+// builderType returns "strings.Builder" or "bytes.Buffer" if t is one of
+// them or a pointer to one
+func builderType(t types.Type) (string, bool) {
+	if ptr, ok := t.(*types.Pointer); ok {
+		t = ptr.Elem()
+	}
+	named, ok := t.(*types.Named)
+	if !ok || named.Obj().Pkg() == nil {
+		return "", false
+	}
+	name := named.Obj().Pkg().Path() + "." + named.Obj().Name()
+	return name, name == "strings.Builder" || name == "bytes.Buffer"
+}
+
+// builderWrites returns the calls writing to the builder obj in body, in
+// order, along with the bytes they write per iteration when every one of them
+// writes a constant and none is in a nested loop
+func (pd *PatternDetector) builderWrites(body *ast.BlockStmt, obj types.Object) ([]*ast.CallExpr, int64, bool) {
+	var writes []*ast.CallExpr
+	var nested []ast.Node
+	var size int64
+	known := true
+
+	ast.Inspect(body, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.FuncLit:
+			return false
+		case *ast.ForStmt, *ast.RangeStmt:
+			nested = append(nested, n)
+		case *ast.CallExpr:
+			sel, ok := n.Fun.(*ast.SelectorExpr)
+			if !ok || len(n.Args) != 1 {
+				break
+			}
+			ident, ok := ast.Unparen(sel.X).(*ast.Ident)
+			if !ok || pd.info.ObjectOf(ident) != obj {
+				break
+			}
+			switch sel.Sel.Name {
+			case "Write", "WriteString", "WriteByte", "WriteRune":
+				writes = append(writes, n)
+				if written, ok := pd.constantWriteSize(sel.Sel.Name, n.Args[0]); ok {
+					size += written
+				} else {
+					known = false
+				}
+			}
+		}
+		return true
+	})
+
+	for _, loop := range nested {
+		for _, write := range writes {
+			known = known && !encloses(loop, write)
+		}
+	}
+	return writes, size, known
+}
+
+// constantWriteSize returns the bytes written by the named write method when
+// its argument is a constant
+func (pd *PatternDetector) constantWriteSize(method string, arg ast.Expr) (int64, bool) {
+	if method == "WriteByte" {
+		return 1, true
+	}
+	tv, ok := pd.info.Types[arg]
+	if !ok || tv.Value == nil {
+		return 0, false
+	}
+	switch method {
+	case "WriteString":
+		if tv.Value.Kind() == constant.String {
+			return int64(len(constant.StringVal(tv.Value))), true
+		}
+	case "WriteRune":
+		if r, ok := constant.Int64Val(tv.Value); ok {
+			return int64(utf8.RuneLen(rune(r))), true
+		}
+	}
+	return 0, false
+}
+
+// growsBefore reports whether fn calls Grow on the builder obj before pos
+func (pd *PatternDetector) growsBefore(fn ast.Node, obj types.Object, pos token.Pos) bool {
+	grows := false
+	ast.Inspect(fn, func(n ast.Node) bool {
+		call, ok := n.(*ast.CallExpr)
+		if !ok || grows || call.Pos() >= pos {
+			return !grows
+		}
+		if sel, ok := call.Fun.(*ast.SelectorExpr); ok && sel.Sel.Name == "Grow" {
+			ident, ok := ast.Unparen(sel.X).(*ast.Ident)
+			grows = ok && pd.info.ObjectOf(ident) == obj
+		}
+		return !grows
+	})
+	return grows
+}
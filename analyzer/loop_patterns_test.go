@@ -0,0 +1,952 @@
+package analyzer
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestRepeatedKeySort(t *testing.T) {
+	code := `
+package main
+
+import "sort"
+
+func report(batches []map[string]int, m map[string]int) {
+	for range batches {
+		keys := make([]string, 0, len(m))
+		for k := range m {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		_ = keys
+	}
+
+	once := make([]string, 0, len(m))
+	for k := range m {
+		once = append(once, k)
+	}
+	sort.Strings(once)
+}
+`
+	const msg = "map keys are collected and sorted on every iteration"
+
+	// Off by default
+	if got := countMatching(inspectSource(t, code, DefaultConfig()), msg); got != 0 {
+		t.Errorf("Expected repeated-key-sort to be off by default, got %d issues", got)
+	}
+
+	config := DefaultConfig()
+	config.EnablePatterns = []string{"repeated-key-sort"}
+	issues := inspectSource(t, code, config)
+	if got := countMatching(issues, msg); got != 1 {
+		t.Errorf("Expected 1 repeated-key-sort issue for the loop only, got %d: %v", got, issues)
+	}
+}
+
+func TestByteAppendLoop(t *testing.T) {
+	code := `
+package main
+
+import "bufio"
+
+func readLine(r *bufio.Reader) []byte {
+	var line []byte
+	for {
+		b, err := r.ReadByte()
+		if err != nil || b == '\n' {
+			break
+		}
+		line = append(line, b)
+	}
+	return line
+}
+
+func stripSpaces(s string) []byte {
+	var out []byte
+	for i := 0; i < len(s); i++ {
+		if s[i] != ' ' {
+			out = append(out, s[i])
+		}
+	}
+	return out
+}
+
+func presized(s string) []byte {
+	out := make([]byte, 0, len(s))
+	for i := 0; i < len(s); i++ {
+		out = append(out, s[i])
+	}
+	return out
+}
+
+func notBytes(words []string) []string {
+	var out []string
+	for _, w := range words {
+		out = append(out, w)
+	}
+	return out
+}
+
+func spread(chunks [][]byte) []byte {
+	var out []byte
+	for _, c := range chunks {
+		out = append(out, c...)
+	}
+	return out
+}
+`
+	const msg = "one byte at a time reallocates it as it grows"
+
+	issues := inspectSource(t, code, DefaultConfig())
+
+	tests := []struct {
+		name     string
+		contains string
+		expected int
+	}{
+		{"scanner-style loop suggests bytes.Buffer", "appending to line one byte at a time reallocates it as it grows; consider accumulating into a bytes.Buffer", 1},
+		{"bounded loop suggests the bound", "make([]byte, 0, len(s))", 1},
+		{"only unsized byte slices", msg, 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := countMatching(issues, tt.contains); got != tt.expected {
+				t.Errorf("Expected %d issues containing %q, got %d: %v", tt.expected, tt.contains, got, issues)
+			}
+		})
+	}
+
+	config := DefaultConfig()
+	config.DisablePatterns = []string{"byte-append-loop"}
+	if got := countMatching(inspectSource(t, code, config), msg); got != 0 {
+		t.Errorf("Expected no byte-append-loop issues when disabled, got %d", got)
+	}
+}
+
+func TestFieldAppendLoop(t *testing.T) {
+	code := `
+package main
+
+import "slices"
+
+type item struct{ id int }
+
+type batch struct {
+	items []item
+	meta  struct{ tags []string }
+}
+
+func (b *batch) addAll(ids []int) {
+	for _, id := range ids {
+		b.items = append(b.items, item{id})
+	}
+}
+
+func fill(b *batch, lines chan string) {
+	for line := range lines {
+		b.meta.tags = append(b.meta.tags, line)
+	}
+}
+
+func grown(b *batch, ids []int) {
+	b.items = slices.Grow(b.items, len(ids))
+	for _, id := range ids {
+		b.items = append(b.items, item{id})
+	}
+}
+
+func perIteration(ids []int) {
+	for _, id := range ids {
+		var b batch
+		b.items = append(b.items, item{id})
+	}
+}
+
+func copied(dst, src *batch, ids []int) {
+	for _, id := range ids {
+		dst.items = append(src.items, item{id})
+	}
+}
+`
+	const msg = "in a loop reallocates its backing array as it grows"
+
+	issues := inspectSource(t, code, DefaultConfig())
+
+	tests := []struct {
+		name     string
+		contains string
+		expected int
+	}{
+		{"bounded loop suggests the bound", "appending to field b.items in a loop reallocates its backing array as it grows; consider growing it once before the loop with b.items = slices.Grow(b.items, len(ids))", 1},
+		{"nested field in an unbounded loop", "appending to field b.meta.tags in a loop reallocates its backing array as it grows; consider growing it before the loop with slices.Grow", 1},
+		{"not grown, per-iteration or different fields", msg, 2},
+		{"replaces the generic message", "append in loop may cause multiple reallocations", 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := countMatching(issues, tt.contains); got != tt.expected {
+				t.Errorf("Expected %d issues containing %q, got %d: %v", tt.expected, tt.contains, got, issues)
+			}
+		})
+	}
+
+	config := DefaultConfig()
+	config.DisablePatterns = []string{"field-append-loop"}
+	if got := countMatching(inspectSource(t, code, config), msg); got != 0 {
+		t.Errorf("Expected no field-append-loop issues when disabled, got %d", got)
+	}
+}
+
+func TestSortSliceClosure(t *testing.T) {
+	code := `
+package main
+
+import (
+	"net/http"
+	srt "sort"
+)
+
+type sort struct{}
+
+func (sort) Slice(x interface{}, less func(i, j int) bool) {}
+
+func batches(groups [][]int) {
+	for _, g := range groups {
+		srt.Slice(g, func(i, j int) bool { return g[i] < g[j] })
+		srt.SliceStable(g, func(i, j int) bool { return g[i] < g[j] })
+		sort{}.Slice(g, func(i, j int) bool { return false })
+	}
+}
+
+func once(g []int) {
+	srt.Slice(g, func(i, j int) bool { return g[i] < g[j] })
+}
+
+func handler(w http.ResponseWriter, r *http.Request) {
+	ids := []int{3, 1, 2}
+	srt.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+}
+`
+	issues := inspectSource(t, code, DefaultConfig())
+
+	tests := []struct {
+		name     string
+		contains string
+		expected int
+	}{
+		{"in a loop", "sort.Slice on every loop iteration", 1},
+		{"stable in a loop", "sort.SliceStable on every loop iteration", 1},
+		{"in an HTTP handler", "sort.Slice on every request", 1},
+		{"outside a loop and method lookalikes", "allocates its less closure", 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := countMatching(issues, tt.contains); got != tt.expected {
+				t.Errorf("Expected %d issues containing %q, got %d: %v", tt.expected, tt.contains, got, issues)
+			}
+		})
+	}
+}
+
+func TestJSONInLoop(t *testing.T) {
+	code := `
+package main
+
+import (
+	enc "encoding/json"
+)
+
+type json struct{}
+
+func (json) Marshal(v interface{}) ([]byte, error) { return nil, nil }
+
+var defaults, _ = enc.Marshal(map[string]int{"a": 1})
+
+func init() {
+	var v map[string]int
+	_ = enc.Unmarshal(defaults, &v)
+}
+
+func encodeAll(items []map[string]int) [][]byte {
+	var out [][]byte
+	for _, item := range items {
+		b, _ := enc.Marshal(item)
+		out = append(out, b)
+		_, _ = json{}.Marshal(item)
+	}
+	return out
+}
+
+func decodeAll(blobs [][]byte) {
+	for _, blob := range blobs {
+		var v map[string]int
+		_ = enc.Unmarshal(blob, &v)
+	}
+}
+`
+	issues := inspectSource(t, code, DefaultConfig())
+
+	tests := []struct {
+		name     string
+		contains string
+		expected int
+	}{
+		{"marshal in a range loop", "json.Marshal in a loop allocates a new []byte on every iteration", 1},
+		{"unmarshal in a range loop", "json.Unmarshal in a loop allocates on every iteration", 1},
+		{"not at package init or for lookalikes", "in a loop allocates", 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := countMatching(issues, tt.contains); got != tt.expected {
+				t.Errorf("Expected %d issues containing %q, got %d: %v", tt.expected, tt.contains, got, issues)
+			}
+		})
+	}
+}
+
+func TestRandSourceInLoop(t *testing.T) {
+	code := `
+package main
+
+import (
+	"math/rand"
+	randv2 "math/rand/v2"
+	"time"
+)
+
+func shuffle(decks [][]int) {
+	for _, deck := range decks {
+		r := rand.New(rand.NewSource(time.Now().UnixNano()))
+		r.Shuffle(len(deck), func(i, j int) { deck[i], deck[j] = deck[j], deck[i] })
+	}
+}
+
+func rolls(n int) []uint64 {
+	var out []uint64
+	for i := 0; i < n; i++ {
+		out = append(out, randv2.New(randv2.NewPCG(1, uint64(i))).Uint64())
+	}
+	return out
+}
+
+func once(deck []int) {
+	r := rand.New(rand.NewSource(time.Now().UnixNano()))
+	for range deck {
+		_ = r.Intn(len(deck))
+	}
+}
+`
+	issues := inspectSource(t, code, DefaultConfig())
+
+	tests := []struct {
+		name     string
+		contains string
+		expected int
+	}{
+		{"math/rand", "rand.New in a loop allocates a new random source on every iteration", 1},
+		{"math/rand source", "rand.NewSource in a loop", 1},
+		{"math/rand/v2 under another name", "randv2.New in a loop", 1},
+		{"math/rand/v2 source", "randv2.NewPCG in a loop", 1},
+		{"not at the top of a function", "in a loop allocates a new random source", 4},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := countMatching(issues, tt.contains); got != tt.expected {
+				t.Errorf("Expected %d issues containing %q, got %d: %v", tt.expected, tt.contains, got, issues)
+			}
+		})
+	}
+}
+
+func TestGetenvInLoop(t *testing.T) {
+	code := `
+package main
+
+import (
+	"os"
+	"strconv"
+	"time"
+)
+
+const timeoutKey = "TIMEOUT"
+
+func process(jobs []string, key string) {
+	for _, job := range jobs {
+		workers, _ := strconv.Atoi(os.Getenv("WORKERS"))
+		debug := os.Getenv("DEBUG")
+		if v, ok := os.LookupEnv(timeoutKey); ok {
+			d, _ := time.ParseDuration(v)
+			_ = d
+		}
+		_ = os.Getenv(key)
+		_, _, _ = job, workers, debug
+	}
+	_ = os.Getenv("HOME")
+}
+`
+	issues := inspectSource(t, code, DefaultConfig())
+
+	tests := []struct {
+		name     string
+		contains string
+		expected int
+	}{
+		{"parsed directly", `os.Getenv("WORKERS") in a loop looks up and parses the same variable with strconv.Atoi on every iteration; read and parse it once before the loop`, 1},
+		{"not parsed", `os.Getenv("DEBUG") in a loop looks up the same variable on every iteration; read it once before the loop`, 1},
+		{"parsed through a variable", `os.LookupEnv("TIMEOUT") in a loop looks up and parses the same variable with time.ParseDuration`, 1},
+		{"only constant keys inside loops", "in a loop looks up", 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := countMatching(issues, tt.contains); got != tt.expected {
+				t.Errorf("Expected %d issues containing %q, got %d: %v", tt.expected, tt.contains, got, issues)
+			}
+		})
+	}
+}
+
+func TestStrconvFormatHotPath(t *testing.T) {
+	code := `
+package main
+
+import "strconv"
+
+func ids(values []int64) []string {
+	out := make([]string, 0, len(values))
+	for _, v := range values {
+		out = append(out, strconv.FormatInt(v, 10))
+	}
+	return out
+}
+
+func prices(values []float64, flags []bool) {
+	for i := range values {
+		_ = strconv.FormatFloat(values[i], 'f', 2, 64)
+		_ = []byte(strconv.FormatInt(int64(i), 10))
+		_ = strconv.FormatBool(flags[i])
+		_ = strconv.Quote("x")
+	}
+}
+
+func one(v int64) string {
+	return strconv.FormatInt(v, 16)
+}
+`
+	issues := inspectSource(t, code, DefaultConfig())
+
+	tests := []struct {
+		name     string
+		contains string
+		expected int
+	}{
+		{"FormatInt in a loop", "strconv.FormatInt in a loop allocates a new string on every call; consider buf = strconv.AppendInt(buf[:0], v, 10), appending to a []byte reused across calls", 1},
+		{"FormatFloat in a loop", "consider buf = strconv.AppendFloat(buf[:0], values[i], 'f', 2, 64)", 1},
+		{"FormatBool never allocates", "strconv.FormatBool", 0},
+		{"not outside loops, converted to []byte, or other functions", "allocates a new string on every call", 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := countMatching(issues, tt.contains); got != tt.expected {
+				t.Errorf("Expected %d issues containing %q, got %d: %v", tt.expected, tt.contains, got, issues)
+			}
+		})
+	}
+}
+
+func TestTimeFormatLoop(t *testing.T) {
+	code := `
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+type event struct{ at time.Time }
+
+func stamp(when time.Time) string {
+	return when.Format(time.RFC3339)
+}
+
+func logAll(events []event) {
+	for _, e := range events {
+		fmt.Println(e.at.Format(time.RFC3339), "event")
+	}
+	for i := 0; i < len(events); i++ {
+		_ = time.Now().Format("15:04:05")
+		_ = events[i].at.String()
+	}
+}
+`
+	found, err := RunDetector("time-format-loop", code)
+	if err != nil {
+		t.Fatalf("RunDetector failed: %v", err)
+	}
+	var issues []string
+	for _, issue := range found {
+		if issue.Pattern != "time-format-loop" || issue.Pos.Filename != "snippet.go" {
+			t.Errorf("Expected only time-format-loop issues in snippet.go, got %+v", issue)
+		}
+		issues = append(issues, issue.Message)
+	}
+
+	tests := []struct {
+		name     string
+		contains string
+		expected int
+	}{
+		{"in a range loop", "e.at.Format in a loop allocates a new string on every iteration; consider e.at.AppendFormat into a []byte buffer reused across iterations", 1},
+		{"on a call result", "time.Now().Format in a loop", 1},
+		{"not outside loops", "when.Format", 0},
+		{"only Format", ".Format in a loop allocates", 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := countMatching(issues, tt.contains); got != tt.expected {
+				t.Errorf("Expected %d issues containing %q, got %d: %v", tt.expected, tt.contains, got, issues)
+			}
+		})
+	}
+}
+
+func TestChanSendLoop(t *testing.T) {
+	code := `
+package main
+
+func produce(items []int, out chan<- int, batches chan<- []int) {
+	out <- len(items)
+	for _, item := range items {
+		out <- item
+	}
+	for i := 0; i < len(items); i += 8 {
+		batches <- items[i:min(i+8, len(items))]
+		go func() {
+			out <- i
+		}()
+	}
+}
+`
+	// Off by default
+	if got := countMatching(inspectSource(t, code, DefaultConfig()), "channel send on every loop iteration"); got != 0 {
+		t.Errorf("Expected no chan-send-loop issues by default, got %d", got)
+	}
+
+	config := DefaultConfig()
+	config.EnablePatterns = []string{"chan-send-loop"}
+	issues := inspectSource(t, code, config)
+
+	// Sends in both loops are reported, but not the one before the loops or
+	// the one in a goroutine started by the loop
+	if got := countMatching(issues, "channel send on every loop iteration"); got != 2 {
+		t.Errorf("Expected 2 chan-send-loop issues, got %d: %v", got, issues)
+	}
+}
+
+func TestRepeatInLoop(t *testing.T) {
+	code := `
+package main
+
+import (
+	"bytes"
+	str "strings"
+)
+
+const width = 8
+
+func render(rows []string, depth int, sep []byte) []string {
+	var out []string
+	for i, row := range rows {
+		pad := str.Repeat(" ", depth*2)
+		line := bytes.Repeat(sep, width)
+		indent := str.Repeat(" ", i)
+		out = append(out, pad+row+string(line)+indent)
+	}
+	for n := depth; n > 0; n-- {
+		out = append(out, str.Repeat("-", n))
+		out = append(out, str.Repeat("=", depth))
+	}
+	for _, row := range rows {
+		out = append(out, str.Repeat(row, len(rows)))
+		depth++
+		out = append(out, str.Repeat(" ", depth))
+	}
+	return append(out, str.Repeat(" ", depth))
+}
+`
+	issues := inspectSource(t, code, DefaultConfig())
+
+	tests := []struct {
+		name     string
+		contains string
+		expected int
+	}{
+		{"invariant strings.Repeat through an aliased import", "strings.Repeat with loop-invariant arguments", 2},
+		{"invariant bytes.Repeat", "bytes.Repeat with loop-invariant arguments", 1},
+		{"not with loop variables, modified variables, calls or outside loops", "with loop-invariant arguments", 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := countMatching(issues, tt.contains); got != tt.expected {
+				t.Errorf("Expected %d issues containing %q, got %d: %v", tt.expected, tt.contains, got, issues)
+			}
+		})
+	}
+}
+
+func TestPathJoinLoop(t *testing.T) {
+	code := `
+package main
+
+import (
+	"path"
+	fp "path/filepath"
+)
+
+func files(root string, names []string) []string {
+	var out []string
+	for i, name := range names {
+		out = append(out, fp.Join(root, "data", name))
+		out = append(out, fp.Join(root, "index"))
+		out = append(out, path.Join("/", root, "static", name, "v1"))
+		out = append(out, fp.Join(root, name))
+		out = append(out, fp.Join(name, root, "data"))
+		out = append(out, fp.Join(names[i], "data"))
+	}
+	for _, name := range names {
+		root = name
+		out = append(out, fp.Join(root, "data", name))
+	}
+	return append(out, fp.Join(root, "data", "all"))
+}
+`
+	issues := inspectSource(t, code, DefaultConfig())
+
+	tests := []struct {
+		name     string
+		contains string
+		expected int
+	}{
+		{"invariant leading elements through an aliased import", "filepath.Join joins the same first 2 elements on every iteration", 1},
+		{"all arguments invariant", "filepath.Join with loop-invariant arguments builds the same path", 1},
+		{"path.Join", "path.Join joins the same first 3 elements", 1},
+		{"not for a single invariant element, variant leading elements, modified variables or outside loops", "on every iteration", 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := countMatching(issues, tt.contains); got != tt.expected {
+				t.Errorf("Expected %d issues containing %q, got %d: %v", tt.expected, tt.contains, got, issues)
+			}
+		})
+	}
+}
+
+func TestInterfaceWriteLoop(t *testing.T) {
+	code := `
+package main
+
+type Point struct{ X, Y int }
+
+func populate(ids []int, names []string, points []Point, flags []bool, byName map[string]any) []any {
+	out := make([]any, len(ids))
+	for i, id := range ids {
+		out[i] = id
+		out[i] = &ids[i]
+		out[i] = flags[i]
+		out[i] = 42
+	}
+	for i := 0; i < len(names); i++ {
+		byName[names[i]] = points[i]
+		byName[names[i]] = nil
+	}
+	out[0] = ids[0]
+	return out
+}
+`
+	issues := inspectSource(t, code, DefaultConfig())
+
+	tests := []struct {
+		name     string
+		contains string
+		expected int
+	}{
+		{"ints written to []any in a range loop", "storing int in an element of []any boxes it, allocating on every iteration (len(ids) allocations per run of the loop); consider int as the element type", 1},
+		{"structs written to map[string]any in a for loop", "storing Point in an element of map[string]any boxes it, allocating on every iteration (len(names) allocations per run of the loop)", 1},
+		{"pointers, single bytes, constants, nil and writes outside loops are free", "boxes it", 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := countMatching(issues, tt.contains); got != tt.expected {
+				t.Errorf("Expected %d issues containing %q, got %d: %v", tt.expected, tt.contains, got, issues)
+			}
+		})
+	}
+}
+
+func TestInterfaceFieldBoxing(t *testing.T) {
+	code := `
+package main
+
+import (
+	"fmt"
+	"net/http"
+)
+
+type Event struct {
+	Name    string
+	Payload any
+	Source  fmt.Stringer
+}
+
+type Counter struct{ n int }
+
+func (c Counter) String() string { return "" }
+
+func emit(ids []int, counters []Counter, out chan<- Event) {
+	for i, id := range ids {
+		out <- Event{Name: "id", Payload: id}
+		out <- Event{"counter", nil, counters[i]}
+		out <- Event{Payload: &ids[i], Source: nil}
+		out <- Event{Payload: 42}
+	}
+	out <- Event{Payload: ids[0]}
+}
+
+func handle(w http.ResponseWriter, r *http.Request) {
+	e := Event{Payload: r.ContentLength}
+	_ = e
+}
+`
+	// Off by default
+	if got := countMatching(inspectSource(t, code, DefaultConfig()), "interface field"); got != 0 {
+		t.Errorf("Expected no interface-field-boxing issues by default, got %d", got)
+	}
+
+	config := DefaultConfig()
+	config.EnablePatterns = []string{"interface-field-boxing"}
+	issues := inspectSource(t, code, config)
+
+	tests := []struct {
+		name     string
+		contains string
+		expected int
+	}{
+		{"keyed field in a range loop", "storing int in the interface field Payload of Event boxes it, allocating on every loop iteration", 1},
+		{"positional field", "storing Counter in the interface field Source of Event boxes it", 1},
+		{"in an HTTP handler", "storing int64 in the interface field Payload of Event boxes it, allocating on every request", 1},
+		{"pointers, constants, nil and literals outside loops are free", "interface field", 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := countMatching(issues, tt.contains); got != tt.expected {
+				t.Errorf("Expected %d issues containing %q, got %d: %v", tt.expected, tt.contains, got, issues)
+			}
+		})
+	}
+}
+
+func TestTypeSwitchInLoop(t *testing.T) {
+	code := `
+package main
+
+import "io"
+
+func sum(values []any) (total float64) {
+	for _, v := range values {
+		switch n := v.(type) {
+		case int:
+			total += float64(n)
+		case float64:
+			total += n
+		}
+	}
+	return total
+}
+
+func drain(next func() interface{}) {
+	for i := 0; i < 10; i++ {
+		item := next()
+		switch item.(type) {
+		case string, []byte:
+		}
+	}
+}
+
+func close(values []io.Closer) {
+	for _, v := range values {
+		switch c := v.(type) {
+		case io.ReadCloser:
+			c.Close()
+		}
+	}
+}
+
+func once(v any) int {
+	switch n := v.(type) {
+	case int:
+		return n
+	}
+	return 0
+}
+`
+	issues := inspectSource(t, code, DefaultConfig())
+
+	tests := []struct {
+		name     string
+		contains string
+		expected int
+	}{
+		{"range value of a []any", "type switch on v in a loop over []any dispatches on every element, each of which was boxed to be stored there; consider keeping values of each type in a container of that concrete type", 1},
+		{"other interface{} values", "type switch on interface{} value item in a loop dispatches on its dynamic type on every iteration", 1},
+		{"not non-empty interfaces or outside loops", "type switch on", 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := countMatching(issues, tt.contains); got != tt.expected {
+				t.Errorf("Expected %d issues containing %q, got %d: %v", tt.expected, tt.contains, got, issues)
+			}
+		})
+	}
+}
+
+func TestBuilderNoGrow(t *testing.T) {
+	code := `
+package main
+
+import (
+	"bytes"
+	"strings"
+)
+
+func join(items []string, n int) string {
+	var b strings.Builder
+	for _, item := range items {
+		b.WriteString(item)
+		b.WriteByte(',')
+	}
+
+	var sep strings.Builder
+	for i := 0; i < n; i++ {
+		sep.WriteString("--")
+		sep.WriteByte('+')
+	}
+
+	var grown strings.Builder
+	grown.Grow(len(items) * 3)
+	for range items {
+		grown.WriteString("abc")
+	}
+
+	var buf bytes.Buffer
+	for i := 0; i < n-1; i++ {
+		buf.WriteRune('é')
+	}
+
+	for _, item := range items {
+		var local strings.Builder
+		local.WriteString(item)
+	}
+	return b.String() + sep.String() + grown.String() + buf.String()
+}
+
+func appendTo(b *strings.Builder, items []string) {
+	for _, item := range items {
+		b.WriteString(item)
+	}
+}
+`
+	issues := inspectSource(t, code, DefaultConfig())
+
+	tests := []struct {
+		name     string
+		contains string
+		expected int
+	}{
+		{"unknown sizes ask for an estimate", "strings.Builder b is written to on each of len(items) iterations without calling Grow, reallocating as it grows; consider calling b.Grow with the expected total size before the loop", 1},
+		{"constant writes give the total", "consider sep.Grow(n*3) before the loop", 1},
+		{"bytes.Buffer is covered, with composite bounds parenthesized", "bytes.Buffer buf is written to on each of n - 1 iterations without calling Grow, reallocating as it grows; consider buf.Grow((n - 1)*2) before the loop", 1},
+		{"each loop is reported once, and not after Grow, for loop-local builders or parameters", "without calling Grow", 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := countMatching(issues, tt.contains); got != tt.expected {
+				t.Errorf("Expected %d issues containing %q, got %d: %v", tt.expected, tt.contains, got, issues)
+			}
+		})
+	}
+}
+
+func TestMinLoopIterations(t *testing.T) {
+	code := `
+package main
+
+import "encoding/json"
+
+func encode(v any, items []any, n int) {
+	for i := 0; i < 3; i++ {
+		json.Marshal(v)
+	}
+	for i := 1; i <= 8; i += 4 {
+		json.Marshal(v)
+	}
+	for range [2]int{} {
+		json.Marshal(v)
+	}
+	for range 3 {
+		json.Marshal(v)
+	}
+	for i := 0; i < n; i++ {
+		json.Marshal(v)
+	}
+	for _, item := range items {
+		for i := 0; i < 3; i++ {
+			json.Marshal(item)
+		}
+	}
+	for i := 0; i < 3; i++ {
+		i = n
+		json.Marshal(v)
+	}
+	for i := 0; i < 100; i++ {
+		json.Marshal(v)
+	}
+}
+`
+	pass, _ := newTestPass(t, code)
+	lines := func(config *Config) []int {
+		var found []int
+		for _, issue := range analyzeFile(pass.Files[0], pass.TypesInfo, pass.Fset, config) {
+			if issue.Pattern == "json-in-loop" {
+				found = append(found, issue.Pos.Line)
+			}
+		}
+		return found
+	}
+
+	// Every loop counts by default
+	if got := lines(DefaultConfig()); len(got) != 8 {
+		t.Fatalf("Expected 8 json-in-loop issues by default, got %v", got)
+	}
+
+	// Small constant loops are dropped, but not dynamic bounds, small loops
+	// inside large ones or loops whose counter changes in the body
+	config := DefaultConfig()
+	config.MinLoopIterations = 4
+	want := []int{20, 24, 29, 32}
+	if got := lines(config); fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("Expected json-in-loop issues on lines %v with -min-loop-iterations=4, got %v", want, got)
+	}
+}
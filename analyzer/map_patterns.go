@@ -0,0 +1,295 @@
+package analyzer
+
+import (
+	"fmt"
+	"go/ast"
+	"go/constant"
+	"go/token"
+	"go/types"
+)
+
+// detectBoolSetMap detects local map[K]bool variables used purely as sets: values
+// are only ever assigned true and read back for membership. map[K]struct{}
+// holds the same information without storing a bool per entry.
+func (pd *PatternDetector) detectBoolSetMap(block *ast.BlockStmt, report func(node ast.Node, msg string)) {
+	if !pd.config.IsPatternEnabled("bool-set-map") {
+		return
+	}
+
+	for _, stmt := range block.List {
+		for _, decl := range pd.boolMapDecls(stmt) {
+			obj := pd.info.ObjectOf(decl)
+			if obj == nil || !pd.isBoolSet(block, obj) {
+				continue
+			}
+			key := obj.Type().Underlying().(*types.Map).Key()
+			set := fmt.Sprintf("map[%s]struct{}", types.TypeString(key, types.RelativeTo(obj.Pkg())))
+			pd.reportRule(report, "bool-set-map", decl, fmt.Sprintf("map %s is only assigned true and checked for membership; consider %s, which doesn't store a bool per entry", decl.Name, set))
+		}
+	}
+}
+
+// boolMapDecls returns the identifiers stmt declares as map[K]bool variables
+// initialised empty, with make() or with a literal whose values are all true
+func (pd *PatternDetector) boolMapDecls(stmt ast.Stmt) []*ast.Ident {
+	names, values := localDecls(stmt)
+	var decls []*ast.Ident
+	for i, name := range names {
+		if name.Name != "_" && isBoolMap(pd.info.TypeOf(name)) && pd.isSetValue(values[i]) {
+			decls = append(decls, name)
+		}
+	}
+	return decls
+}
+
+// localDecls returns the identifiers stmt declares with := or var, along with
+// their initial values, which are nil for var declarations without one
+func localDecls(stmt ast.Stmt) ([]*ast.Ident, []ast.Expr) {
+	var names []*ast.Ident
+	var values []ast.Expr
+
+	switch s := stmt.(type) {
+	case *ast.AssignStmt:
+		if s.Tok != token.DEFINE || len(s.Lhs) != len(s.Rhs) {
+			return nil, nil
+		}
+		for i, lhs := range s.Lhs {
+			if ident, ok := lhs.(*ast.Ident); ok {
+				names = append(names, ident)
+				values = append(values, s.Rhs[i])
+			}
+		}
+	case *ast.DeclStmt:
+		gen, ok := s.Decl.(*ast.GenDecl)
+		if !ok || gen.Tok != token.VAR {
+			return nil, nil
+		}
+		for _, spec := range gen.Specs {
+			vs := spec.(*ast.ValueSpec)
+			if len(vs.Values) != 0 && len(vs.Values) != len(vs.Names) {
+				continue
+			}
+			for i, name := range vs.Names {
+				var value ast.Expr
+				if len(vs.Values) != 0 {
+					value = vs.Values[i]
+				}
+				names = append(names, name)
+				values = append(values, value)
+			}
+		}
+	}
+	return names, values
+}
+
+// isBoolMap reports whether t is a map with bool values
+func isBoolMap(t types.Type) bool {
+	if t == nil {
+		return false
+	}
+	m, ok := t.Underlying().(*types.Map)
+	if !ok {
+		return false
+	}
+	basic, ok := m.Elem().Underlying().(*types.Basic)
+	return ok && basic.Kind() == types.Bool
+}
+
+// isSetValue reports whether expr initialises a bool map without storing
+// anything but true: nil (no initialiser), make() or an all-true literal
+func (pd *PatternDetector) isSetValue(expr ast.Expr) bool {
+	switch e := expr.(type) {
+	case nil:
+		return true
+	case *ast.CallExpr:
+		return pd.isMakeCall(e)
+	case *ast.CompositeLit:
+		for _, elt := range e.Elts {
+			kv, ok := elt.(*ast.KeyValueExpr)
+			if !ok || !pd.isTrue(kv.Value) {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// isTrue reports whether expr is the constant true
+func (pd *PatternDetector) isTrue(expr ast.Expr) bool {
+	tv, ok := pd.info.Types[expr]
+	return ok && tv.Value != nil && tv.Value.Kind() == constant.Bool && constant.BoolVal(tv.Value)
+}
+
+// isBoolSet reports whether every use of obj within block treats it as a set:
+// m[k] = true, membership reads, delete, len, clear and key-only range loops.
+// Passing the map anywhere else may observe its values, so it doesn't count.
+func (pd *PatternDetector) isBoolSet(block *ast.BlockStmt, obj types.Object) bool {
+	var stack []ast.Node
+	parent := func(n int) ast.Node {
+		if n >= len(stack) {
+			return nil
+		}
+		return stack[len(stack)-1-n]
+	}
+
+	isSet, used := true, false
+	ast.Inspect(block, func(n ast.Node) bool {
+		if !isSet {
+			return false
+		}
+		if n == nil {
+			stack = stack[:len(stack)-1]
+			return true
+		}
+
+		if ident, ok := n.(*ast.Ident); ok && pd.info.Uses[ident] == obj {
+			used = true
+			isSet = pd.isSetUse(ident, parent(0), parent(1))
+		}
+
+		stack = append(stack, n)
+		return true
+	})
+	return isSet && used
+}
+
+// isSetUse reports whether a single use of a bool map, given its parent and
+// grandparent nodes, is compatible with set semantics
+func (pd *PatternDetector) isSetUse(ident *ast.Ident, parent, grandparent ast.Node) bool {
+	switch p := parent.(type) {
+	case *ast.IndexExpr:
+		if p.X != ident {
+			return false
+		}
+		assign, ok := grandparent.(*ast.AssignStmt)
+		if !ok {
+			return true
+		}
+		for i, lhs := range assign.Lhs {
+			if lhs == p {
+				return assign.Tok == token.ASSIGN && len(assign.Lhs) == len(assign.Rhs) && pd.isTrue(assign.Rhs[i])
+			}
+		}
+		return true
+	case *ast.CallExpr:
+		if len(p.Args) == 0 || p.Args[0] != ident {
+			return false
+		}
+		return pd.isBuiltinCall(p, "len") || pd.isBuiltinCall(p, "clear") || pd.isBuiltinCall(p, "delete")
+	case *ast.RangeStmt:
+		return p.X == ident && (p.Value == nil || isBlank(p.Value))
+	case *ast.AssignStmt:
+		for i, lhs := range p.Lhs {
+			if lhs == ident {
+				return len(p.Lhs) == len(p.Rhs) && pd.isSetValue(p.Rhs[i])
+			}
+		}
+	}
+	return false
+}
+
+// isBlank reports whether expr is the blank identifier
+func isBlank(expr ast.Expr) bool {
+	ident, ok := expr.(*ast.Ident)
+	return ok && ident.Name == "_"
+}
+
+// detectDynamicContainerBoxing detects local maps and slices with interface
+// elements, such as map[string]any, that have concrete values written to them
+// at several places in a block. Each write boxes its value, so rather than
+// reporting them one by one, the writes are counted and reported once at the
+// container's declaration.
+func (pd *PatternDetector) detectDynamicContainerBoxing(block *ast.BlockStmt, report func(node ast.Node, msg string)) {
+	if !pd.config.IsPatternEnabled("dynamic-container-boxing") {
+		return
+	}
+
+	for _, stmt := range block.List {
+		names, _ := localDecls(stmt)
+		for _, name := range names {
+			obj := pd.info.ObjectOf(name)
+			if name.Name == "_" || obj == nil || !hasInterfaceElem(obj.Type()) {
+				continue
+			}
+
+			sites, values, bytes, sized := pd.boxedWrites(block, obj)
+			if sites < 2 {
+				continue
+			}
+			qualifier := types.RelativeTo(obj.Pkg())
+			msg := fmt.Sprintf("%s (%s) boxes %d values written at %d places", name.Name, types.TypeString(obj.Type(), qualifier), values, sites)
+			if sized {
+				msg += fmt.Sprintf(", allocating at least %d bytes", bytes)
+			}
+			msg += "; consider a struct with typed fields if the keys are known, or a concrete element type"
+			pd.reportRule(report, "dynamic-container-boxing", name, msg)
+		}
+	}
+}
+
+// hasInterfaceElem reports whether t is a map, slice or array whose elements
+// are interfaces
+func hasInterfaceElem(t types.Type) bool {
+	switch u := t.Underlying().(type) {
+	case *types.Map:
+		return types.IsInterface(u.Elem())
+	case *types.Slice:
+		return types.IsInterface(u.Elem())
+	case *types.Array:
+		return types.IsInterface(u.Elem())
+	}
+	return false
+}
+
+// boxedWrites counts the writes within block that box a value into obj's
+// elements, c[k] = v and c = append(c, v...), along with the number of values
+// they box and the bytes those values take, if all of their sizes are known
+func (pd *PatternDetector) boxedWrites(block *ast.BlockStmt, obj types.Object) (sites, values int, bytes int64, sized bool) {
+	sized = true
+	box := func(exprs ...ast.Expr) {
+		boxed := 0
+		for _, expr := range exprs {
+			t, ok := pd.boxedType(expr)
+			if !ok {
+				continue
+			}
+			boxed++
+			if size, ok := pd.sizeof(t); ok {
+				bytes += size
+			} else {
+				sized = false
+			}
+		}
+		if boxed > 0 {
+			sites++
+			values += boxed
+		}
+	}
+	refersTo := func(expr ast.Expr) bool {
+		ident, ok := ast.Unparen(expr).(*ast.Ident)
+		return ok && pd.info.Uses[ident] == obj
+	}
+
+	ast.Inspect(block, func(n ast.Node) bool {
+		assign, ok := n.(*ast.AssignStmt)
+		if !ok || len(assign.Lhs) != len(assign.Rhs) {
+			return true
+		}
+		for i, lhs := range assign.Lhs {
+			switch l := lhs.(type) {
+			case *ast.IndexExpr:
+				if refersTo(l.X) {
+					box(assign.Rhs[i])
+				}
+			case *ast.Ident:
+				call, ok := assign.Rhs[i].(*ast.CallExpr)
+				if ok && refersTo(l) && pd.isAppendCall(call) && len(call.Args) > 1 && !call.Ellipsis.IsValid() && refersTo(call.Args[0]) {
+					box(call.Args[1:]...)
+				}
+			}
+		}
+		return true
+	})
+	return sites, values, bytes, sized
+}
@@ -0,0 +1,149 @@
+package analyzer
+
+import "testing"
+
+func TestBoolSetMap(t *testing.T) {
+	code := `
+package main
+
+func dedupe(words []string) []string {
+	seen := make(map[string]bool, len(words))
+	out := make([]string, 0, len(words))
+	for _, w := range words {
+		if seen[w] {
+			continue
+		}
+		seen[w] = true
+		out = append(out, w)
+	}
+	return out
+}
+
+func literal(id int) bool {
+	var allowed = map[int]bool{1: true, 2: true}
+	if _, ok := allowed[id]; ok {
+		delete(allowed, id)
+	}
+	for k := range allowed {
+		_ = k
+	}
+	return len(allowed) > 0
+}
+
+func flags(names []string, enabled func(string) bool) map[string]bool {
+	state := make(map[string]bool)
+	for _, n := range names {
+		state[n] = enabled(n)
+	}
+	return state
+}
+
+func toggled(names []string) int {
+	on := map[string]bool{}
+	for _, n := range names {
+		on[n] = !on[n]
+	}
+	return len(on)
+}
+
+func escapes(names []string) {
+	set := make(map[string]bool)
+	for _, n := range names {
+		set[n] = true
+	}
+	consume(set)
+}
+
+func consume(map[string]bool) {}
+
+func values(names []string) {
+	set := make(map[string]bool)
+	for _, n := range names {
+		set[n] = true
+	}
+	for _, v := range set {
+		_ = v
+	}
+}
+`
+	issues := inspectSource(t, code, DefaultConfig())
+
+	tests := []struct {
+		name     string
+		contains string
+		expected int
+	}{
+		{"dedupe set", "map seen is only assigned true and checked for membership; consider map[string]struct{}", 1},
+		{"literal set with delete, range and len", "map allowed is only assigned true and checked for membership; consider map[int]struct{}", 1},
+		{"genuine bool values, escaping and value reads", "is only assigned true", 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := countMatching(issues, tt.contains); got != tt.expected {
+				t.Errorf("Expected %d issues containing %q, got %d: %v", tt.expected, tt.contains, got, issues)
+			}
+		})
+	}
+
+	config := DefaultConfig()
+	config.DisablePatterns = []string{"bool-set-map"}
+	if got := countMatching(inspectSource(t, code, config), "is only assigned true"); got != 0 {
+		t.Errorf("Expected no bool-set-map issues when disabled, got %d", got)
+	}
+}
+
+func TestDynamicContainerBoxing(t *testing.T) {
+	code := `
+package main
+
+type limits struct{ max, min int }
+
+func load(name string, port int, ratio float64, l limits) (map[string]interface{}, []any) {
+	settings := make(map[string]interface{})
+	settings["name"] = name
+	settings["port"] = port
+	settings["debug"] = true
+	settings["limits"] = &l
+	if ratio > 0 {
+		settings["ratio"] = ratio
+	}
+
+	var args []any
+	args = append(args, port, ratio)
+	args = append(args, name)
+	return settings, args
+}
+
+func single(port int) map[string]any {
+	m := map[string]any{}
+	m["port"] = port
+	return m
+}
+`
+	issues := inspectSource(t, code, DefaultConfig())
+
+	tests := []struct {
+		name     string
+		contains string
+		expected int
+	}{
+		{"map writes aggregated", "settings (map[string]interface{}) boxes 3 values written at 3 places, allocating at least 32 bytes", 1},
+		{"appends aggregated", "args ([]any) boxes 3 values written at 2 places, allocating at least 32 bytes", 1},
+		{"single write", "m (map[string]any)", 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := countMatching(issues, tt.contains); got != tt.expected {
+				t.Errorf("Expected %d issues containing %q, got %d: %v", tt.expected, tt.contains, got, issues)
+			}
+		})
+	}
+
+	config := DefaultConfig()
+	config.DisablePatterns = []string{"dynamic-container-boxing"}
+	if got := countMatching(inspectSource(t, code, config), "values written at"); got != 0 {
+		t.Errorf("Expected no dynamic-container-boxing issues when disabled, got %d", got)
+	}
+}
@@ -0,0 +1,161 @@
+package analyzer
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"go/types"
+	"io"
+	"os"
+	"text/template"
+)
+
+// MessageData is what message templates are rendered with
+type MessageData struct {
+	Pattern string // name of the message or rule, such as "new-call"
+	Message string // built-in message, for templates that only add to it
+	Type    string // type being allocated, if the message is about one
+	Size    int64  // estimated size of the allocation in bytes, or -1 if not known statically
+}
+
+// builtinMessages are the templates for messages of detectors that aren't
+// registered rules, keyed by the name Config.MessageTemplates overrides them
+// with. Rules build their messages themselves and are overridden by rule name.
+var builtinMessages = map[string]string{
+	"new-call":               "new(T) always allocates on heap; consider using stack allocation if object doesn't escape{{if lt .Size 0}}; size not known statically{{end}}",
+	"make-slice-small":       "small slice allocation with make(){{if ge .Size 0}} ({{.Size}} bytes){{end}}; consider using array or stack allocation{{if lt .Size 0}}; size not known statically{{end}}",
+	"make-slice-large":       "large slice allocation may cause GC pressure; consider pre-allocation or streaming",
+	"make-slice-empty":       "make([]T) creates zero-length slice; consider using nil slice or array",
+	"make-map-small":         "small map with known size; consider using struct or array for better performance",
+	"make-map-no-hint":       "make(map[K]V) without size hint; consider providing capacity for better performance",
+	"make-chan-small":        "unbuffered or small buffered channel; consider if synchronous communication is needed",
+	"reflect-new":            "reflection-based allocation always uses heap; consider avoiding if performance critical",
+	"boxing-call":            "value may be boxed when passed to interface; consider using pointer receiver if appropriate",
+	"slice-literal-small":    "small slice literal{{if ge .Size 0}} ({{.Size}} bytes){{end}}; consider using array for stack allocation{{if lt .Size 0}}; size not known statically{{end}}",
+	"slice-literal-complex":  "slice literal with complex elements may cause multiple allocations",
+	"map-literal-small":      "small map literal; consider using struct or switch statement for better performance",
+	"struct-literal-large":   "large struct literal; consider using pointer or breaking into smaller structs",
+	"struct-literal-address": "struct literal address taken; consider stack allocation if lifetime allows",
+	"string-concat":          "string concatenation with + operator allocates; consider using strings.Builder for multiple concatenations",
+	"type-assertion":         "type assertion may cause allocation if value was boxed; consider avoiding interface{} when possible",
+	"append-nil":             "appending to nil slice causes allocation; consider pre-allocating with make()",
+	"append-multiple":        "appending multiple elements may cause multiple reallocations; consider pre-allocating capacity",
+	"append-in-loop":         "append in loop may cause multiple reallocations; consider pre-allocating slice capacity",
+	"closure-capture":        "closure captures variables and may allocate; consider passing values as parameters",
+	"closure-to-interface":   "closure assigned to interface causes allocation; consider using concrete function type",
+	"format-simple":          "simple string formatting; consider using string concatenation or strings.Builder",
+	"format-sprint":          "fmt.Sprint family functions allocate; consider using strings.Builder or direct conversion",
+	"format-itoa":            "strconv.Itoa allocates; consider using strconv.AppendInt with pre-allocated buffer",
+	"format-custom":          "formatting function allocates like fmt, boxing its arguments and building a string; consider skipping the call when its output isn't needed",
+}
+
+// builtinTemplates holds builtinMessages parsed
+var builtinTemplates = func() map[string]*template.Template {
+	templates := make(map[string]*template.Template, len(builtinMessages))
+	for name, text := range builtinMessages {
+		templates[name] = template.Must(template.New(name).Parse(text))
+	}
+	return templates
+}()
+
+// messageTemplatesFile is a flag.Value for Config.MessageTemplates, loading
+// them from a JSON file mapping message or rule names to templates, such as
+// {"new-call": "new({{.Type}}) puts {{.Size}} bytes on the heap"}
+type messageTemplatesFile struct {
+	m    *map[string]string
+	path string
+}
+
+// String returns the path of the file the templates were loaded from
+func (f *messageTemplatesFile) String() string {
+	if f == nil {
+		return ""
+	}
+	return f.path
+}
+
+// Set loads the templates in the file at path, replacing any set before
+func (f *messageTemplatesFile) Set(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read message templates: %w", err)
+	}
+	var templates map[string]string
+	if err := json.Unmarshal(data, &templates); err != nil {
+		return fmt.Errorf("failed to parse message templates %s: %w", path, err)
+	}
+	*f.m = templates
+	f.path = path
+	return nil
+}
+
+// validateMessageTemplates checks that every template in templates names a
+// built-in message or rule and renders without error
+func validateMessageTemplates(templates map[string]string) error {
+	for name, text := range templates {
+		if _, ok := builtinMessages[name]; !ok {
+			if _, ok := LookupRule(name); !ok {
+				return fmt.Errorf("unknown message or rule %q in message templates", name)
+			}
+		}
+		tmpl, err := template.New(name).Parse(text)
+		if err != nil {
+			return fmt.Errorf("invalid message template for %q: %v", name, err)
+		}
+		if err := tmpl.Execute(io.Discard, MessageData{Pattern: name, Size: -1}); err != nil {
+			return fmt.Errorf("invalid message template for %q: %v", name, err)
+		}
+	}
+	return nil
+}
+
+// message renders the built-in message called name with data, or the
+// template configured for it in Config.MessageTemplates if there is one
+func (pd *PatternDetector) message(name string, data MessageData) string {
+	data.Pattern = name
+	data.Message = render(builtinTemplates[name], data)
+	return pd.override(data)
+}
+
+// override renders the template configured for data.Pattern, falling back to
+// data.Message if there is none or it doesn't render
+func (pd *PatternDetector) override(data MessageData) string {
+	text, ok := pd.config.MessageTemplates[data.Pattern]
+	if !ok {
+		return data.Message
+	}
+
+	if pd.templates == nil {
+		pd.templates = make(map[string]*template.Template)
+	}
+	tmpl, ok := pd.templates[data.Pattern]
+	if !ok {
+		// A template that doesn't parse is cached as nil so it isn't parsed again
+		tmpl, _ = template.New(data.Pattern).Parse(text)
+		pd.templates[data.Pattern] = tmpl
+	}
+	if msg := render(tmpl, data); msg != "" {
+		return msg
+	}
+	return data.Message
+}
+
+// render executes tmpl with data, returning "" if it fails
+func render(tmpl *template.Template, data MessageData) string {
+	if tmpl == nil {
+		return ""
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return ""
+	}
+	return buf.String()
+}
+
+// typeName renders t for a message, or "" if it isn't known
+func typeName(t types.Type) string {
+	if t == nil {
+		return ""
+	}
+	return types.TypeString(t, types.RelativeTo(namedPkg(t)))
+}
@@ -0,0 +1,135 @@
+package analyzer
+
+import (
+	"flag"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestMessageTemplates(t *testing.T) {
+	code := `
+package main
+
+type Point struct{ X, Y int }
+
+func main() {
+	p := new(Point)
+	_ = p
+	for i := 0; i < 3; i++ {
+		done := make(chan struct{})
+		_ = done
+	}
+}
+`
+	defaults := inspectSource(t, code, DefaultConfig())
+	if got := countMatching(defaults, "new(T) always allocates on heap; consider using stack allocation if object doesn't escape"); got != 1 {
+		t.Fatalf("Expected the built-in new(T) message without templates, got %v", defaults)
+	}
+
+	config := DefaultConfig()
+	config.MessageTemplates = map[string]string{
+		"new-call":     "new({{.Type}}) puts {{.Size}} bytes on the heap, see https://docs.example.com/alloc#{{.Pattern}}",
+		"chan-in-loop": "[team] {{.Message}}",
+	}
+	if err := config.ValidatePatterns(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	issues := inspectSource(t, code, config)
+
+	tests := []struct {
+		name     string
+		contains string
+		expected int
+	}{
+		{"new-call is rendered with the type and size", "new(Point) puts 16 bytes on the heap, see https://docs.example.com/alloc#new-call", 1},
+		{"the built-in new-call message is replaced", "new(T) always allocates", 0},
+		{"rule templates can wrap the built-in message", "[team] channel created on every loop iteration allocates each time", 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := countMatching(issues, tt.contains); got != tt.expected {
+				t.Errorf("Expected %d issues containing %q, got %d: %v", tt.expected, tt.contains, got, issues)
+			}
+		})
+	}
+}
+
+func TestValidateMessageTemplates(t *testing.T) {
+	tests := []struct {
+		name      string
+		templates map[string]string
+		err       string
+	}{
+		{"built-in message", map[string]string{"new-call": "{{.Type}}"}, ""},
+		{"rule", map[string]string{"json-in-loop": "{{.Message}}"}, ""},
+		{"unknown name", map[string]string{"new-cal": "{{.Type}}"}, `unknown message or rule "new-cal"`},
+		{"syntax error", map[string]string{"new-call": "{{.Type"}, `invalid message template for "new-call"`},
+		{"unknown field", map[string]string{"new-call": "{{.Name}}"}, `invalid message template for "new-call"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := DefaultConfig()
+			config.MessageTemplates = tt.templates
+			err := config.ValidatePatterns()
+			if tt.err == "" {
+				if err != nil {
+					t.Errorf("Unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("Expected error containing %q, got %v", tt.err, err)
+			}
+		})
+	}
+}
+
+func TestMessageTemplatesFlag(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "templates.json")
+	if err := os.WriteFile(file, []byte(`{"new-call": "new({{.Type}}) escapes", "chan-in-loop": "[team] {{.Message}}"}`), 0644); err != nil {
+		t.Fatalf("Failed to write templates: %v", err)
+	}
+
+	config := DefaultConfig()
+	fs := flag.NewFlagSet("stackalloc", flag.ContinueOnError)
+	config.SetupFlags(fs)
+	if err := fs.Parse([]string{"-message-templates=" + file}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	config.ParseFlags(fs)
+
+	if got := config.MessageTemplates["new-call"]; got != "new({{.Type}}) escapes" {
+		t.Errorf("Expected the new-call template from the file, got %q", got)
+	}
+	if len(config.MessageTemplates) != 2 {
+		t.Errorf("Expected 2 templates, got %v", config.MessageTemplates)
+	}
+
+	// A config parsing flags set up by another, as run does, gets the
+	// templates already read rather than reading the file again
+	if err := os.Remove(file); err != nil {
+		t.Fatal(err)
+	}
+	fresh := DefaultConfig()
+	fresh.ParseFlags(fs)
+	if len(fresh.MessageTemplates) != 2 {
+		t.Errorf("Expected the parsed templates to be copied, got %v", fresh.MessageTemplates)
+	}
+
+	invalid := filepath.Join(dir, "invalid.json")
+	if err := os.WriteFile(invalid, []byte(`["new-call"]`), 0644); err != nil {
+		t.Fatalf("Failed to write templates: %v", err)
+	}
+	fs = flag.NewFlagSet("stackalloc", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	DefaultConfig().SetupFlags(fs)
+	if err := fs.Parse([]string{"-message-templates=" + invalid}); err == nil || !strings.Contains(err.Error(), "failed to parse message templates") {
+		t.Errorf("Expected a parse error, got %v", err)
+	}
+}
@@ -0,0 +1,40 @@
+//go:build !js
+
+package analyzer
+
+import (
+	"github.com/harriteja/gostackallocator/adapter"
+	"go.uber.org/zap"
+)
+
+// newOpenAIClient creates the OpenAI client the flag-driven Analyzer asks for
+// suggestions, configured by config. It lives apart from the detectors, behind
+// a build constraint, so that WebAssembly builds of them don't link the OpenAI
+// and Prometheus dependencies of the adapter package.
+func newOpenAIClient(config *Config) AIClient {
+	logger := zap.NewNop() // Use no-op logger in non-DI mode
+	if config.AILogRequests {
+		if development, err := zap.NewDevelopment(); err == nil {
+			logger = development
+		}
+	}
+	openAI := adapter.NewOpenAIAdapter(
+		config.OpenAIAPIKey,
+		config.OpenAIModel,
+		config.OpenAIMaxTokens,
+		config.OpenAITemperature,
+		logger,
+	)
+	openAI.SetRequestLogging(config.RequestLogging())
+	openAI.SetModelFallbacks(config.OpenAIFallbacks)
+	return openAI
+}
+
+// RequestLogging returns the AI request logging settings derived from the
+// -ai-log-requests and -ai-log-snippets flags
+func (c *Config) RequestLogging() adapter.RequestLogging {
+	return adapter.RequestLogging{
+		Enabled:        c.AILogRequests,
+		RedactSnippets: !c.AILogSnippets,
+	}
+}
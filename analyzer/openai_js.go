@@ -0,0 +1,9 @@
+//go:build js
+
+package analyzer
+
+// newOpenAIClient returns nil in WebAssembly builds, which run only the
+// detectors and never ask OpenAI for suggestions
+func newOpenAIClient(config *Config) AIClient {
+	return nil
+}
@@ -0,0 +1,149 @@
+package analyzer
+
+import (
+	"flag"
+	"io"
+	"os"
+	"sync"
+
+	"github.com/harriteja/gostackallocator/internal"
+	"go.uber.org/zap"
+	"golang.org/x/tools/go/analysis"
+)
+
+// Option configures an analyzer created by NewAnalyzerWithOptions
+type Option func(*analyzerOptions)
+
+// analyzerOptions holds the dependencies of an analyzer created with options
+type analyzerOptions struct {
+	aiClient      AIClient
+	metricsClient MetricsClient
+	config        *Config
+	logger        *zap.Logger
+	overlay       map[string][]byte
+	reporter      func(Issue)
+	results       func(Result)
+	sinks         []IssueSink
+	confirmer     FixConfirmer // consulted before applying each fix under -interactive
+	warnings      io.Writer    // receives issues below the error severity
+
+	budgetOnce sync.Once
+	budgeted   AIClient // aiClient limited by -ai-time-budget, shared by every package
+}
+
+// newAnalyzerOptions applies opts over the defaults
+func newAnalyzerOptions(opts ...Option) *analyzerOptions {
+	options := &analyzerOptions{
+		metricsClient: &NoOpMetricsAdapter{},
+		config:        DefaultConfig(),
+		logger:        internal.GetLogger(),
+		warnings:      os.Stderr,
+	}
+	for _, opt := range opts {
+		opt(options)
+	}
+	return options
+}
+
+// suggester returns the AI client limited by the -ai-time-budget. It is
+// created on first use, once flags have been parsed.
+func (o *analyzerOptions) suggester() AIClient {
+	o.budgetOnce.Do(func() {
+		o.budgeted = newBudgetedAIClient(o.aiClient, o.config.AITimeBudget, o.logger)
+	})
+	return o.budgeted
+}
+
+// WithAIClient sets the client used for AI-powered suggestions
+func WithAIClient(aiClient AIClient) Option {
+	return func(o *analyzerOptions) {
+		o.aiClient = aiClient
+	}
+}
+
+// WithMetrics sets the client used to record telemetry
+func WithMetrics(metricsClient MetricsClient) Option {
+	return func(o *analyzerOptions) {
+		if metricsClient != nil {
+			o.metricsClient = metricsClient
+		}
+	}
+}
+
+// WithConfig sets the analyzer configuration
+func WithConfig(config *Config) Option {
+	return func(o *analyzerOptions) {
+		if config != nil {
+			o.config = config
+		}
+	}
+}
+
+// WithLogger sets the logger used for warnings and decisions made during analysis
+func WithLogger(logger *zap.Logger) Option {
+	return func(o *analyzerOptions) {
+		if logger != nil {
+			o.logger = logger
+		}
+	}
+}
+
+// WithOverlay provides in-memory file contents, keyed by filename, that take
+// precedence over the files on disk when reading source
+func WithOverlay(overlay map[string][]byte) Option {
+	return func(o *analyzerOptions) {
+		o.overlay = overlay
+	}
+}
+
+// WithReporter registers a callback invoked with every issue found
+func WithReporter(reporter func(Issue)) Option {
+	return func(o *analyzerOptions) {
+		o.reporter = reporter
+	}
+}
+
+// WithResultReporter registers a callback invoked with every issue found,
+// along with its rule, estimated size and enclosing function
+func WithResultReporter(reporter func(Result)) Option {
+	return func(o *analyzerOptions) {
+		o.results = reporter
+	}
+}
+
+// WithSink registers a sink that receives every reported issue and is flushed
+// after each package. It may be given more than once.
+func WithSink(sink IssueSink) Option {
+	return func(o *analyzerOptions) {
+		if sink != nil {
+			o.sinks = append(o.sinks, sink)
+		}
+	}
+}
+
+// WithFixConfirmer sets the confirmer consulted before applying each fix
+// under -interactive
+func WithFixConfirmer(confirmer FixConfirmer) Option {
+	return func(o *analyzerOptions) {
+		o.confirmer = confirmer
+	}
+}
+
+// NewAnalyzerWithOptions creates an analyzer configured by functional options
+func NewAnalyzerWithOptions(opts ...Option) *analysis.Analyzer {
+	options := newAnalyzerOptions(opts...)
+
+	analyzer := &analysis.Analyzer{
+		Name: "stackalloc",
+		Doc:  "detects small heap allocations and suggests stack-friendly alternatives",
+		Run: func(pass *analysis.Pass) (interface{}, error) {
+			return runWithDeps(pass, options)
+		},
+		Flags:     flag.FlagSet{},
+		FactTypes: []analysis.Fact{new(EscapeFact)},
+	}
+
+	options.config.SetupFlags(&analyzer.Flags)
+
+	return analyzer
+}
@@ -0,0 +1,149 @@
+package analyzer
+
+import (
+	"context"
+	"os"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+// recordingAIClient records the snippets it is asked about
+type recordingAIClient struct {
+	snippets []string
+}
+
+func (r *recordingAIClient) SuggestFix(ctx context.Context, snippet, issueMsg string) (string, error) {
+	r.snippets = append(r.snippets, snippet)
+	return "Consider optimizing this allocation", nil
+}
+
+// countingMetrics counts metric calls
+type countingMetrics struct {
+	files, issues, durations int
+}
+
+func (c *countingMetrics) IncrementFilesAnalyzed()                 { c.files++ }
+func (c *countingMetrics) IncrementIssuesFound()                   { c.issues++ }
+func (c *countingMetrics) RecordAnalysisDuration(duration float64) { c.durations++ }
+
+const optionsTestCode = `package main
+
+func main() {
+	s := new(string)
+	_ = s
+}
+`
+
+func TestNewAnalyzerWithOptionsDefaults(t *testing.T) {
+	a := NewAnalyzerWithOptions()
+
+	if a.Name != "stackalloc" {
+		t.Errorf("Expected analyzer name stackalloc, got %s", a.Name)
+	}
+	if a.Flags.Lookup("max-alloc-size") == nil {
+		t.Error("Expected configuration flags to be registered")
+	}
+
+	options := newAnalyzerOptions()
+	if options.config == nil || options.metricsClient == nil || options.logger == nil {
+		t.Errorf("Expected defaults for config, metrics and logger, got %+v", options)
+	}
+}
+
+func TestNewAnalyzerWithOptionsNilValuesKeepDefaults(t *testing.T) {
+	options := newAnalyzerOptions(WithConfig(nil), WithMetrics(nil), WithLogger(nil))
+
+	if options.config == nil || options.metricsClient == nil || options.logger == nil {
+		t.Errorf("Expected nil options to keep defaults, got %+v", options)
+	}
+}
+
+func TestNewAnalyzerWithOptionsConfigAndMetrics(t *testing.T) {
+	config := DefaultConfig()
+	config.MaxAllocSize = 64
+	metrics := &countingMetrics{}
+
+	a := NewAnalyzerWithOptions(WithConfig(config), WithMetrics(metrics))
+	if got := a.Flags.Lookup("max-alloc-size").Value.String(); got != "64" {
+		t.Errorf("Expected flags to reflect the provided config, got %s", got)
+	}
+
+	pass, diagnostics := newTestPass(t, optionsTestCode)
+	if _, err := a.Run(pass); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if metrics.issues != len(*diagnostics) || metrics.issues == 0 {
+		t.Errorf("Expected %d issues recorded, got %d", len(*diagnostics), metrics.issues)
+	}
+	if metrics.durations != 1 {
+		t.Errorf("Expected analysis duration to be recorded once, got %d", metrics.durations)
+	}
+}
+
+func TestNewAnalyzerWithOptionsReporter(t *testing.T) {
+	var reported []Issue
+	a := NewAnalyzerWithOptions(WithReporter(func(issue Issue) {
+		reported = append(reported, issue)
+	}))
+
+	pass, diagnostics := newTestPass(t, optionsTestCode)
+	if _, err := a.Run(pass); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if len(reported) == 0 || len(reported) != len(*diagnostics) {
+		t.Errorf("Expected reporter to see every issue, got %d of %d", len(reported), len(*diagnostics))
+	}
+}
+
+func TestNewAnalyzerWithOptionsOverlayAndAIClient(t *testing.T) {
+	pass, _ := newTestPass(t, optionsTestCode)
+	filename := pass.Fset.File(pass.Files[0].Pos()).Name()
+
+	overlay := map[string][]byte{
+		filename: []byte(strings.Replace(optionsTestCode, "_ = s", "_ = s // from overlay", 1)),
+	}
+	aiClient := &recordingAIClient{}
+
+	a := NewAnalyzerWithOptions(WithAIClient(aiClient), WithOverlay(overlay))
+	if _, err := a.Run(pass); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if len(aiClient.snippets) == 0 {
+		t.Fatal("Expected the AI client to be consulted")
+	}
+	for _, snippet := range aiClient.snippets {
+		if !strings.Contains(snippet, "from overlay") {
+			t.Errorf("Expected snippet to come from the overlay, got %q", snippet)
+		}
+	}
+}
+
+func TestNewAnalyzerWithOptionsLogger(t *testing.T) {
+	core, logs := observer.New(zapcore.WarnLevel)
+
+	pass, _ := newTestPass(t, optionsTestCode)
+	filename := pass.Fset.File(pass.Files[0].Pos()).Name()
+
+	// Remove the file so reading it for a suggestion logs a warning
+	a := NewAnalyzerWithOptions(
+		WithAIClient(&recordingAIClient{}),
+		WithLogger(zap.New(core)),
+	)
+	if err := os.Remove(filename); err != nil {
+		t.Fatalf("Failed to remove test file: %v", err)
+	}
+
+	if _, err := a.Run(pass); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if logs.Len() == 0 {
+		t.Error("Expected warnings to go to the provided logger")
+	}
+}
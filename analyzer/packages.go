@@ -0,0 +1,122 @@
+//go:build !js
+
+package analyzer
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/harriteja/gostackallocator/internal"
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/packages"
+)
+
+// loadMode is what AnalyzePackages needs go/packages to load: the syntax and
+// type information of each package, and the types of the packages it imports
+// so that it can be type checked
+const loadMode = packages.NeedName | packages.NeedFiles | packages.NeedCompiledGoFiles |
+	packages.NeedImports | packages.NeedDeps |
+	packages.NeedSyntax | packages.NeedTypes | packages.NeedTypesInfo | packages.NeedTypesSizes
+
+// AnalyzePackages loads the packages matching patterns with go/packages, as
+// the go command run in dir would resolve them, and analyzes them in one pass.
+// Inside a go.work workspace the patterns may span all of its modules. Unlike
+// go vet, it doesn't pass escape facts between packages, so calls into other
+// packages are treated as if their arguments escape.
+func AnalyzePackages(dir string, patterns []string, config *Config) ([]Result, error) {
+	results, _, err := AnalyzePackagesWithMetrics(dir, patterns, config)
+	return results, err
+}
+
+// AnalysisMetrics describes a single run of AnalyzePackagesWithMetrics. Unlike
+// the Prometheus counters, it covers only that run, so embedders analyzing
+// repeatedly can inspect each run on its own.
+type AnalysisMetrics struct {
+	FilesAnalyzed int64         // files analyzed, across all packages
+	IssuesFound   int64         // issues found, one per result
+	Duration      time.Duration // time spent analyzing, after loading the packages
+}
+
+// CountPackages analyzes the packages matching patterns like AnalyzePackages,
+// but returns only the counts -report=counts prints
+func CountPackages(dir string, patterns []string, config *Config) (Counts, error) {
+	if config == nil {
+		config = DefaultConfig()
+	}
+	results, metrics, err := AnalyzePackagesWithMetrics(dir, patterns, config)
+	if err != nil {
+		return Counts{}, err
+	}
+
+	counts := Counts{Files: int(metrics.FilesAnalyzed)}
+	for _, r := range results {
+		if r.Severity >= config.ErrorSeverity || r.Severity >= config.WarnSeverity {
+			counts.Add(r.Issue)
+		}
+	}
+	return counts, nil
+}
+
+// AnalyzePackagesWithMetrics is like AnalyzePackages, also returning the
+// metrics of the run
+func AnalyzePackagesWithMetrics(dir string, patterns []string, config *Config) ([]Result, AnalysisMetrics, error) {
+	if config == nil {
+		config = DefaultConfig()
+	}
+	if err := config.Validate(); err != nil {
+		return nil, AnalysisMetrics{}, err
+	}
+	warnConfig(config, internal.GetLogger())
+	if _, err := loadProfile(config.Pprof); err != nil {
+		return nil, AnalysisMetrics{}, err
+	}
+
+	pkgs, err := packages.Load(&packages.Config{Mode: loadMode, Dir: dir}, patterns...)
+	if err != nil {
+		return nil, AnalysisMetrics{}, fmt.Errorf("failed to load packages: %w", err)
+	}
+
+	var loadErrors []string
+	for _, pkg := range pkgs {
+		for _, e := range pkg.Errors {
+			loadErrors = append(loadErrors, e.Error())
+		}
+	}
+	if len(loadErrors) > 0 {
+		return nil, AnalysisMetrics{}, fmt.Errorf("failed to load packages: %s", strings.Join(loadErrors, "; "))
+	}
+
+	logger := internal.GetLogger()
+	metrics := internal.NewAnalysisMetrics(logger)
+	sources := NewSourceCache(logger)
+	var results []Result
+	for _, pkg := range pkgs {
+		pass := &analysis.Pass{
+			Fset:       pkg.Fset,
+			Files:      pkg.Syntax,
+			Pkg:        pkg.Types,
+			TypesInfo:  pkg.TypesInfo,
+			TypesSizes: pkg.TypesSizes,
+		}
+		info := newPackageInfo(pass, config)
+		for _, file := range analyzedFiles(pkg.Syntax, pkg.Fset, config, logger) {
+			metrics.IncrementFilesAnalyzed()
+			issues := capFileIssues(sampleIssues(analyzeFileWithLogger(file, pkg.TypesInfo, info, pkg.Fset, config, logger), config), config)
+			if config.IncludeSource {
+				issues = withSources(issues, sources)
+			}
+			for range issues {
+				metrics.IncrementIssuesFound()
+			}
+			results = append(results, newResults(file, pkg.TypesInfo, info, pkg.Fset, config, issues)...)
+		}
+	}
+	metrics.RecordAnalysisDuration(time.Since(metrics.StartTime))
+
+	return results, AnalysisMetrics{
+		FilesAnalyzed: metrics.FilesAnalyzed,
+		IssuesFound:   metrics.IssuesFound,
+		Duration:      metrics.AnalysisDuration,
+	}, nil
+}
@@ -0,0 +1,128 @@
+package analyzer
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// workspaceFixture writes a go.work workspace of two modules, each with a
+// package that allocates and imports the standard library, and returns its root
+func workspaceFixture(t *testing.T) string {
+	t.Helper()
+
+	root := t.TempDir()
+	files := map[string]string{
+		"go.work":      "go 1.22\n\nuse (\n\t./alpha\n\t./beta\n)\n",
+		"alpha/go.mod": "module example.com/alpha\n\ngo 1.22\n",
+		"alpha/alpha.go": `package alpha
+
+import "fmt"
+
+func Count() *int {
+	return new(int)
+}
+
+func Label(n int) string {
+	return fmt.Sprint(n)
+}
+`,
+		"beta/go.mod": "module example.com/beta\n\ngo 1.22\n",
+		"beta/beta.go": `package beta
+
+import (
+	"context"
+
+	"example.com/alpha"
+)
+
+func Total(ctx context.Context) int {
+	if ctx.Err() != nil {
+		return 0
+	}
+	parts := new([4]int)
+	return *alpha.Count() + parts[0]
+}
+`,
+	}
+	for name, content := range files {
+		path := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// -mod=mod can't be used in workspace mode
+	t.Setenv("GOFLAGS", "")
+	return root
+}
+
+func TestAnalyzePackagesWorkspace(t *testing.T) {
+	root := workspaceFixture(t)
+
+	results, err := AnalyzePackages(root, []string{"example.com/alpha/...", "example.com/beta/..."}, nil)
+	if err != nil {
+		t.Fatalf("AnalyzePackages failed: %v", err)
+	}
+
+	funcs := make(map[string]Result)
+	for _, r := range results {
+		funcs[filepath.Base(r.Pos.Filename)+" "+r.Func] = r
+	}
+	alpha, ok := funcs["alpha.go Count"]
+	if !ok {
+		t.Fatalf("Expected a result in alpha.Count, got %v", results)
+	}
+	beta, ok := funcs["beta.go Total"]
+	if !ok {
+		t.Fatalf("Expected a result in beta.Total, got %v", results)
+	}
+
+	// Both modules are type checked, so sizes are known
+	if alpha.Bytes != 8 {
+		t.Errorf("Expected new(int) to be 8 bytes, got %d", alpha.Bytes)
+	}
+	if beta.Bytes != 32 {
+		t.Errorf("Expected new([4]int) to be 32 bytes, got %d", beta.Bytes)
+	}
+}
+
+func TestAnalyzePackagesErrors(t *testing.T) {
+	root := workspaceFixture(t)
+
+	if _, err := AnalyzePackages(root, []string{"example.com/missing"}, nil); err == nil {
+		t.Error("Expected an error for a package outside the workspace")
+	}
+
+	config := DefaultConfig()
+	config.DisablePatterns = []string{"no-such-rule"}
+	if _, err := AnalyzePackages(root, []string{"example.com/alpha/..."}, config); err == nil || !strings.Contains(err.Error(), `unknown rule "no-such-rule" in -disable-patterns`) {
+		t.Errorf("Expected an error for an unknown rule, got %v", err)
+	}
+}
+
+func TestAnalyzePackagesWithMetrics(t *testing.T) {
+	root := workspaceFixture(t)
+	patterns := []string{"example.com/alpha/...", "example.com/beta/..."}
+
+	var runs []AnalysisMetrics
+	for i := 0; i < 2; i++ {
+		results, metrics, err := AnalyzePackagesWithMetrics(root, patterns, nil)
+		if err != nil {
+			t.Fatalf("AnalyzePackagesWithMetrics failed: %v", err)
+		}
+		if metrics.IssuesFound != int64(len(results)) || metrics.Duration <= 0 {
+			t.Errorf("Expected %d issues and a duration, got %+v", len(results), metrics)
+		}
+		runs = append(runs, metrics)
+	}
+
+	// Each run counts only itself
+	if runs[0].FilesAnalyzed != 2 || runs[1].FilesAnalyzed != 2 || runs[0].IssuesFound != runs[1].IssuesFound {
+		t.Errorf("Expected two files and the same issues in each run, got %+v and %+v", runs[0], runs[1])
+	}
+}
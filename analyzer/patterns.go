@@ -1,10 +1,14 @@
 package analyzer
 
 import (
+	"fmt"
 	"go/ast"
 	"go/token"
 	"go/types"
 	"strings"
+	"text/template"
+
+	"golang.org/x/tools/go/analysis"
 )
 
 // AllocationPattern represents different types of allocation patterns
@@ -32,6 +36,13 @@ type PatternDetector struct {
 	fset    *token.FileSet
 	config  *Config
 	tracker *usageTracker
+	sizes   types.Sizes        // sizes used to compute allocation sizes; defaultSizes if nil
+	escapes *escapeFacts       // which parameters of called functions escape, if known
+	weights map[string]float64 // profile weights of the package's functions under -pprof, by declKey
+	stack   []ast.Node         // ancestors of the node currently being inspected
+	emit    func(Issue)        // receives issues reported with reportRule, if set
+
+	templates map[string]*template.Template // message templates from config, parsed on first use
 }
 
 // NewPatternDetector creates a new pattern detector
@@ -45,10 +56,34 @@ func NewPatternDetector(info *types.Info, fset *token.FileSet, config *Config, t
 }
 
 // DetectPattern analyzes a node and detects allocation patterns
-func (pd *PatternDetector) DetectPattern(node ast.Node, report func(pos token.Pos, msg string)) {
+func (pd *PatternDetector) DetectPattern(node ast.Node, report func(node ast.Node, msg string)) {
 	switch n := node.(type) {
 	case *ast.CallExpr:
-		pd.detectCallPatterns(n, report)
+		pd.detectStdlibIdioms(n, report)
+		pd.detectSortSliceClosure(n, report)
+		pd.detectJSONInLoop(n, report)
+		pd.detectRandSourceInLoop(n, report)
+		pd.detectGetenvInLoop(n, report)
+		pd.detectTimeFormatLoop(n, report)
+		pd.detectStrconvFormatHotPath(n, report)
+		pd.detectRepeatInLoop(n, report)
+		pd.detectPathJoinLoop(n, report)
+		pd.detectBuilderNoGrow(n, report)
+		pd.detectStringerSprintf(n, report)
+		pd.detectHandlerBodyAlloc(n, report)
+		pd.detectBufioPerCall(n, report)
+		pd.detectHTTPClientPerCall(n, report)
+		pd.detectContextWithValueChain(n, report)
+		pd.detectUnconditionalErrorf(n, report)
+		pd.detectErrorStringCompare(n, report)
+		pd.detectPanicAllocArg(n, report)
+		pd.detectOversplit(n, report)
+		pd.detectEscapingArguments(n, report)
+		pd.detectRetainedArguments(n, report)
+		if !pd.detectFormatThenConvert(n, report) {
+			pd.detectCompositeConversion(n, report)
+		}
+		pd.detectGenericCallPatterns(n, report)
 	case *ast.CompositeLit:
 		pd.detectCompositeLiteralPatterns(n, report)
 	case *ast.BinaryExpr:
@@ -57,14 +92,46 @@ func (pd *PatternDetector) DetectPattern(node ast.Node, report func(pos token.Po
 		pd.detectTypeAssertionPatterns(n, report)
 	case *ast.FuncLit:
 		pd.detectClosurePatterns(n, report)
+	case *ast.BlockStmt:
+		pd.detectBlockPatterns(n, report)
+	case *ast.SendStmt:
+		pd.detectChanSendLoop(n, report)
+	case *ast.DeferStmt:
+		pd.detectDeferLogArgs(n, report)
+	case *ast.AssignStmt:
+		pd.detectInterfaceWriteLoop(n, report)
+	case *ast.FuncDecl:
+		pd.detectGetterAlloc(n, report)
+	case *ast.TypeSwitchStmt:
+		pd.detectTypeSwitchInLoop(n, report)
+	case *ast.RangeStmt:
+		pd.detectLargeArrayRange(n, report)
+	case *ast.ReturnStmt:
+		pd.detectLargeArrayReturn(n, report)
+	}
+}
+
+// reportRule reports an issue on behalf of a named rule, along with any fixes
+// the detector can provide, with msg replaced by the rule's template from
+// Config.MessageTemplates if there is one. Without an emitter it falls back
+// to report.
+func (pd *PatternDetector) reportRule(report func(node ast.Node, msg string), rule string, node ast.Node, msg string, fixes ...analysis.SuggestedFix) {
+	msg = pd.override(MessageData{Pattern: rule, Message: msg, Size: -1})
+	if pd.emit == nil {
+		report(node, msg)
+		return
 	}
+	issue := newIssue(pd.fset, node, msg)
+	issue.Pattern = rule
+	issue.SuggestedFixes = fixes
+	pd.emit(issue)
 }
 
 // detectCallPatterns detects allocation patterns in function calls
-func (pd *PatternDetector) detectCallPatterns(call *ast.CallExpr, report func(pos token.Pos, msg string)) {
+func (pd *PatternDetector) detectCallPatterns(call *ast.CallExpr, report func(node ast.Node, msg string)) {
 	// new(T) calls
 	if pd.isNewCall(call) {
-		report(call.Pos(), "new(T) always allocates on heap; consider using stack allocation if object doesn't escape")
+		pd.detectNewPatterns(call, report)
 		return
 	}
 
@@ -82,7 +149,7 @@ func (pd *PatternDetector) detectCallPatterns(call *ast.CallExpr, report func(po
 
 	// reflect.New() and similar reflection calls
 	if pd.isReflectAllocation(call) {
-		report(call.Pos(), "reflection-based allocation always uses heap; consider avoiding if performance critical")
+		report(call, pd.message("reflect-new", MessageData{Size: -1}))
 		return
 	}
 
@@ -94,180 +161,316 @@ func (pd *PatternDetector) detectCallPatterns(call *ast.CallExpr, report func(po
 
 	// Interface method calls that may box values
 	if pd.isBoxingCall(call) {
-		report(call.Pos(), "value may be boxed when passed to interface; consider using pointer receiver if appropriate")
+		msg := pd.message("boxing-call", MessageData{Size: -1})
+		if note := pd.genericParamsNote(call); note != "" {
+			msg += "; " + note
+		}
+		report(call, msg)
+		return
+	}
+}
+
+// detectNewPatterns reports new(T) calls allocating at most -max-alloc-size
+// bytes. When the size of T isn't known every call is reported.
+func (pd *PatternDetector) detectNewPatterns(call *ast.CallExpr, report func(node ast.Node, msg string)) {
+	if len(call.Args) == 0 || pd.detectNewValueType(call, report) {
 		return
 	}
+
+	t := pd.info.TypeOf(call.Args[0])
+	size, ok := pd.sizeof(t)
+	if !ok {
+		report(call, pd.message("new-call", MessageData{Type: typeName(t), Size: -1}))
+		return
+	}
+	if pd.isSmallAlloc(size) {
+		report(call, pd.message("new-call", MessageData{Type: typeName(t), Size: size}))
+	}
 }
 
 // detectMakePatterns detects patterns in make() calls
-func (pd *PatternDetector) detectMakePatterns(call *ast.CallExpr, report func(pos token.Pos, msg string)) {
+func (pd *PatternDetector) detectMakePatterns(call *ast.CallExpr, report func(node ast.Node, msg string)) {
 	if len(call.Args) == 0 {
 		return
 	}
 
 	// Get the type being made
 	typeExpr := call.Args[0]
+	t := pd.info.TypeOf(typeExpr)
 
 	switch pd.getTypeKind(typeExpr) {
 	case "slice":
 		if len(call.Args) >= 2 {
-			// make([]T, size) or make([]T, size, capacity)
-			if pd.isSmallConstantSize(call.Args[1]) {
-				report(call.Pos(), "small slice allocation with make(); consider using array or stack allocation")
-			} else if pd.isLargeSize(call.Args[1]) {
-				report(call.Pos(), "large slice allocation may cause GC pressure; consider pre-allocation or streaming")
+			// make([]T, size) or make([]T, size, capacity) allocates capacity elements
+			n := call.Args[len(call.Args)-1]
+			size, known := pd.makeSliceSize(call)
+			switch {
+			case known && pd.isSmallAlloc(size):
+				report(call, pd.message("make-slice-small", MessageData{Type: typeName(t), Size: size}))
+			case !known && pd.isSmallConstantSize(n):
+				report(call, pd.message("make-slice-small", MessageData{Type: typeName(t), Size: -1}))
+			case pd.isLargeSize(n):
+				report(call, pd.message("make-slice-large", MessageData{Type: typeName(t), Size: -1}))
 			}
 		} else {
-			report(call.Pos(), "make([]T) creates zero-length slice; consider using nil slice or array")
+			report(call, pd.message("make-slice-empty", MessageData{Type: typeName(t), Size: -1}))
 		}
 
 	case "map":
 		if len(call.Args) >= 2 {
 			if pd.isSmallConstantSize(call.Args[1]) {
-				report(call.Pos(), "small map with known size; consider using struct or array for better performance")
+				report(call, pd.message("make-map-small", MessageData{Type: typeName(t), Size: -1}))
 			}
 		} else {
-			report(call.Pos(), "make(map[K]V) without size hint; consider providing capacity for better performance")
+			report(call, pd.message("make-map-no-hint", MessageData{Type: typeName(t), Size: -1}))
 		}
 
 	case "chan":
+		if pd.config.IsPatternEnabled("chan-in-loop") {
+			if pd.isInLoop(call) {
+				pd.reportRule(report, "chan-in-loop", call, "channel created on every loop iteration allocates each time; consider creating it once outside the loop and reusing it")
+				return
+			}
+			if pd.isInHTTPHandler() {
+				pd.reportRule(report, "chan-in-loop", call, "channel created on every request allocates each time; consider reusing a long-lived channel or buffering it")
+				return
+			}
+		}
 		if len(call.Args) >= 2 {
 			if pd.isZeroOrSmallSize(call.Args[1]) {
-				report(call.Pos(), "unbuffered or small buffered channel; consider if synchronous communication is needed")
+				report(call, pd.message("make-chan-small", MessageData{Type: typeName(t), Size: -1}))
 			}
 		}
 	}
 }
 
 // detectCompositeLiteralPatterns detects patterns in composite literals
-func (pd *PatternDetector) detectCompositeLiteralPatterns(lit *ast.CompositeLit, report func(pos token.Pos, msg string)) {
+func (pd *PatternDetector) detectCompositeLiteralPatterns(lit *ast.CompositeLit, report func(node ast.Node, msg string)) {
+	pd.detectAddressOfLiteral(lit, report)
+
+	t := typeName(pd.info.TypeOf(lit))
 	switch pd.getCompositeLiteralType(lit) {
 	case "slice":
-		if pd.isSmallSliceLiteral(lit) {
-			report(lit.Pos(), "small slice literal; consider using array for stack allocation")
+		if size, ok := pd.sliceLiteralSize(lit); ok {
+			if len(lit.Elts) > 0 && pd.isSmallAlloc(size) {
+				report(lit, pd.message("slice-literal-small", MessageData{Type: t, Size: size}))
+			}
+		} else if pd.isSmallSliceLiteral(lit) {
+			report(lit, pd.message("slice-literal-small", MessageData{Type: t, Size: -1}))
 		}
 		if pd.hasComplexElements(lit) {
-			report(lit.Pos(), "slice literal with complex elements may cause multiple allocations")
+			report(lit, pd.message("slice-literal-complex", MessageData{Type: t, Size: -1}))
 		}
 
 	case "map":
 		if pd.isSmallMapLiteral(lit) {
-			report(lit.Pos(), "small map literal; consider using struct or switch statement for better performance")
+			report(lit, pd.message("map-literal-small", MessageData{Type: t, Size: -1}))
 		}
 
 	case "struct":
+		pd.detectInterfaceFieldBoxing(lit, report)
 		if pd.isLargeStructLiteral(lit) {
-			report(lit.Pos(), "large struct literal; consider using pointer or breaking into smaller structs")
+			report(lit, pd.message("struct-literal-large", MessageData{Type: t, Size: -1}))
 		}
 		if pd.hasEscapingStructLiteral(lit) {
-			report(lit.Pos(), "struct literal address taken; consider stack allocation if lifetime allows")
+			report(lit, pd.message("struct-literal-address", MessageData{Type: t, Size: -1}))
 		}
 	}
 }
 
 // detectBinaryExprPatterns detects allocation patterns in binary expressions
-func (pd *PatternDetector) detectBinaryExprPatterns(expr *ast.BinaryExpr, report func(pos token.Pos, msg string)) {
+func (pd *PatternDetector) detectBinaryExprPatterns(expr *ast.BinaryExpr, report func(node ast.Node, msg string)) {
 	if expr.Op == token.ADD {
 		// String concatenation
 		if pd.isStringType(expr.X) && pd.isStringType(expr.Y) {
-			report(expr.Pos(), "string concatenation with + operator allocates; consider using strings.Builder for multiple concatenations")
+			report(expr, pd.message("string-concat", MessageData{Type: "string", Size: -1}))
 		}
 	}
 }
 
-// detectTypeAssertionPatterns detects allocation patterns in type assertions
-func (pd *PatternDetector) detectTypeAssertionPatterns(assert *ast.TypeAssertExpr, report func(pos token.Pos, msg string)) {
-	if pd.isInterfaceToConcreteAssertion(assert) {
-		report(assert.Pos(), "type assertion may cause allocation if value was boxed; consider avoiding interface{} when possible")
+// detectTypeAssertionPatterns detects allocation patterns in type assertions.
+// Assertions repeated in a loop are reported by the type-assertion-in-loop
+// rule; one-off assertions are only reported with -verbose.
+func (pd *PatternDetector) detectTypeAssertionPatterns(assert *ast.TypeAssertExpr, report func(node ast.Node, msg string)) {
+	if !pd.isInterfaceToConcreteAssertion(assert) {
+		return
+	}
+
+	if pd.isInLoop(assert) {
+		if pd.config.IsPatternEnabled("type-assertion-in-loop") {
+			pd.reportRule(report, "type-assertion-in-loop", assert, "type assertion from interface{} on every loop iteration may allocate each time if the value was boxed; consider asserting once outside the loop or using a concrete type")
+		}
+		return
+	}
+
+	if pd.config.Verbose {
+		report(assert, pd.message("type-assertion", MessageData{Type: typeName(pd.info.TypeOf(assert.Type)), Size: -1}))
 	}
 }
 
 // detectAppendPatterns detects allocation patterns in append calls
-func (pd *PatternDetector) detectAppendPatterns(call *ast.CallExpr, report func(pos token.Pos, msg string)) {
+func (pd *PatternDetector) detectAppendPatterns(call *ast.CallExpr, report func(node ast.Node, msg string)) {
 	if len(call.Args) < 2 {
 		return
 	}
 
 	// Check if appending to nil or small slice
 	if pd.isNilSlice(call.Args[0]) {
-		report(call.Pos(), "appending to nil slice causes allocation; consider pre-allocating with make()")
+		report(call, pd.message("append-nil", MessageData{Type: typeName(pd.info.TypeOf(call)), Size: -1}))
 	}
 
+	pd.detectAppendSpreadTemp(call, report)
+	pd.detectSubsliceAppendAlias(call, report)
+	pd.detectAppendCopyIdiom(call, report)
+	pd.detectAppendBoxing(call, report)
+
 	// Check if appending many elements at once
 	if len(call.Args) > 3 {
-		report(call.Pos(), "appending multiple elements may cause multiple reallocations; consider pre-allocating capacity")
+		report(call, pd.message("append-multiple", MessageData{Type: typeName(pd.info.TypeOf(call)), Size: -1}))
 	}
 
-	// Check for append in loop (common performance issue)
-	if pd.isInLoop(call) {
-		report(call.Pos(), "append in loop may cause multiple reallocations; consider pre-allocating slice capacity")
+	// Check for append in loop (common performance issue), unless a more
+	// specific rule already reported it
+	scoped := pd.detectByteAppendLoop(call, report) || pd.detectFieldAppendLoop(call, report) || pd.detectConstructorAppend(call, report)
+	if !scoped && pd.isInLoop(call) {
+		report(call, pd.message("append-in-loop", MessageData{Type: typeName(pd.info.TypeOf(call)), Size: -1}))
 	}
+
+	pd.detectSliceOfEscapingPointers(call, report)
+}
+
+// detectSliceOfEscapingPointers detects []*T slices built in a loop from
+// addresses of variables declared inside the loop body. Every such variable
+// is moved to the heap, so a []T avoids one allocation per element when the
+// pointers aren't needed for sharing or mutation.
+func (pd *PatternDetector) detectSliceOfEscapingPointers(call *ast.CallExpr, report func(node ast.Node, msg string)) {
+	if !pd.config.IsPatternEnabled("slice-of-escaping-pointers") || call.Ellipsis.IsValid() {
+		return
+	}
+
+	body := pd.enclosingLoopBody(call)
+	if body == nil {
+		return
+	}
+
+	t := pd.info.TypeOf(call.Args[0])
+	if t == nil {
+		return
+	}
+	slice, ok := t.Underlying().(*types.Slice)
+	if !ok {
+		return
+	}
+	ptr, ok := slice.Elem().Underlying().(*types.Pointer)
+	if !ok {
+		return
+	}
+
+	for _, arg := range call.Args[1:] {
+		unary, ok := arg.(*ast.UnaryExpr)
+		if !ok || unary.Op != token.AND {
+			continue
+		}
+		ident, ok := unary.X.(*ast.Ident)
+		if !ok {
+			continue
+		}
+		obj := pd.info.ObjectOf(ident)
+		if !isLocalVar(obj) || !withinRange(body, obj.Pos()) {
+			continue
+		}
+		// Stay conservative: a second address-of means the pointer is shared
+		if pd.countAddressOf(body, obj) > 1 {
+			continue
+		}
+
+		elem := types.TypeString(ptr.Elem(), types.RelativeTo(obj.Pkg()))
+		pd.reportRule(report, "slice-of-escaping-pointers", call, fmt.Sprintf("slice of pointers built from loop-local %s allocates every element on the heap; consider []%s if the pointers aren't needed for sharing or mutation", ident.Name, elem))
+		return
+	}
+}
+
+// countAddressOf counts the &obj expressions within node
+func (pd *PatternDetector) countAddressOf(node ast.Node, obj types.Object) int {
+	count := 0
+	ast.Inspect(node, func(n ast.Node) bool {
+		if unary, ok := n.(*ast.UnaryExpr); ok && unary.Op == token.AND {
+			if ident, ok := unary.X.(*ast.Ident); ok && pd.info.ObjectOf(ident) == obj {
+				count++
+			}
+		}
+		return true
+	})
+	return count
 }
 
 // detectClosurePatterns detects allocation patterns in closures
-func (pd *PatternDetector) detectClosurePatterns(fn *ast.FuncLit, report func(pos token.Pos, msg string)) {
+func (pd *PatternDetector) detectClosurePatterns(fn *ast.FuncLit, report func(node ast.Node, msg string)) {
 	// Check if closure captures variables (may cause allocation)
 	if pd.capturesVariables(fn) {
-		report(fn.Pos(), "closure captures variables and may allocate; consider passing values as parameters")
+		report(fn, pd.message("closure-capture", MessageData{Size: -1}))
 	}
 
 	// Check if closure is assigned to interface
 	if pd.isClosureToInterface(fn) {
-		report(fn.Pos(), "closure assigned to interface causes allocation; consider using concrete function type")
+		report(fn, pd.message("closure-to-interface", MessageData{Size: -1}))
 	}
 }
 
 // detectStringFormattingPatterns detects allocation patterns in string formatting
-func (pd *PatternDetector) detectStringFormattingPatterns(call *ast.CallExpr, report func(pos token.Pos, msg string)) {
+func (pd *PatternDetector) detectStringFormattingPatterns(call *ast.CallExpr, report func(node ast.Node, msg string)) {
 	funcName := pd.getFunctionName(call)
 
 	switch funcName {
 	case "fmt.Sprintf", "fmt.Errorf":
 		if pd.isSimpleStringFormatting(call) {
-			report(call.Pos(), "simple string formatting; consider using string concatenation or strings.Builder")
+			report(call, pd.message("format-simple", MessageData{Type: "string", Size: -1}))
 		}
 	case "fmt.Sprint", "fmt.Sprintln":
-		report(call.Pos(), "fmt.Sprint family functions allocate; consider using strings.Builder or direct conversion")
+		report(call, pd.message("format-sprint", MessageData{Type: "string", Size: -1}))
 	case "strconv.Itoa":
 		if pd.isInHotPath(call) {
-			report(call.Pos(), "strconv.Itoa allocates; consider using strconv.AppendInt with pre-allocated buffer")
+			report(call, pd.message("format-itoa", MessageData{Type: "string", Size: -1}))
+		}
+	default:
+		if pd.isCustomFormatCall(call) {
+			report(call, pd.message("format-custom", MessageData{Type: "string", Size: -1}))
 		}
 	}
 }
 
 // Helper methods for pattern detection
 
-func (pd *PatternDetector) isNewCall(call *ast.CallExpr) bool {
-	if ident, ok := call.Fun.(*ast.Ident); ok {
-		if obj := pd.info.ObjectOf(ident); obj != nil {
-			if builtin, ok := obj.(*types.Builtin); ok {
-				return builtin.Name() == "new"
-			}
-		}
+// isBuiltinCall reports whether call calls the named builtin. Without type
+// information, such as for cgo files or AnalyzeSource, an identifier with that
+// name that isn't declared in the file is assumed to be the builtin.
+func (pd *PatternDetector) isBuiltinCall(call *ast.CallExpr, name string) bool {
+	ident, ok := call.Fun.(*ast.Ident)
+	if !ok || ident.Name != name {
+		return false
 	}
-	return false
+	if obj := pd.info.ObjectOf(ident); obj != nil {
+		_, ok := obj.(*types.Builtin)
+		return ok
+	}
+	return !pd.hasTypes() && ident.Obj == nil
+}
+
+// hasTypes reports whether type information is available
+func (pd *PatternDetector) hasTypes() bool {
+	return pd.info.Types != nil || pd.info.Uses != nil
+}
+
+func (pd *PatternDetector) isNewCall(call *ast.CallExpr) bool {
+	return pd.isBuiltinCall(call, "new")
 }
 
 func (pd *PatternDetector) isMakeCall(call *ast.CallExpr) bool {
-	if ident, ok := call.Fun.(*ast.Ident); ok {
-		if obj := pd.info.ObjectOf(ident); obj != nil {
-			if builtin, ok := obj.(*types.Builtin); ok {
-				return builtin.Name() == "make"
-			}
-		}
-	}
-	return false
+	return pd.isBuiltinCall(call, "make")
 }
 
 func (pd *PatternDetector) isAppendCall(call *ast.CallExpr) bool {
-	if ident, ok := call.Fun.(*ast.Ident); ok {
-		if obj := pd.info.ObjectOf(ident); obj != nil {
-			if builtin, ok := obj.(*types.Builtin); ok {
-				return builtin.Name() == "append"
-			}
-		}
-	}
-	return false
+	return pd.isBuiltinCall(call, "append")
 }
 
 func (pd *PatternDetector) isReflectAllocation(call *ast.CallExpr) bool {
@@ -281,7 +484,27 @@ func (pd *PatternDetector) isReflectAllocation(call *ast.CallExpr) bool {
 func (pd *PatternDetector) isStringFormattingCall(call *ast.CallExpr) bool {
 	funcName := pd.getFunctionName(call)
 	return strings.HasPrefix(funcName, "fmt.") ||
-		strings.HasPrefix(funcName, "strconv.")
+		strings.HasPrefix(funcName, "strconv.") ||
+		pd.isCustomFormatCall(call)
+}
+
+// isCustomFormatCall reports whether call calls a function registered with
+// Config.RegisterFormatFuncs
+func (pd *PatternDetector) isCustomFormatCall(call *ast.CallExpr) bool {
+	if len(pd.config.FormatFuncs) == 0 {
+		return false
+	}
+	var ident *ast.Ident
+	switch fun := ast.Unparen(call.Fun).(type) {
+	case *ast.Ident:
+		ident = fun
+	case *ast.SelectorExpr:
+		ident = fun.Sel
+	default:
+		return false
+	}
+	fn, ok := pd.info.Uses[ident].(*types.Func)
+	return ok && pd.config.isFormatFunc(fn.Origin().FullName())
 }
 
 func (pd *PatternDetector) isBoxingCall(call *ast.CallExpr) bool {
@@ -312,6 +535,36 @@ func (pd *PatternDetector) getFunctionName(call *ast.CallExpr) string {
 	return ""
 }
 
+// pkgFunc resolves a call of the form pkg.Func to the imported package path and function name
+func (pd *PatternDetector) pkgFunc(call *ast.CallExpr) (string, string) {
+	sel, ok := call.Fun.(*ast.SelectorExpr)
+	if !ok {
+		return "", ""
+	}
+	ident, ok := sel.X.(*ast.Ident)
+	if !ok {
+		return "", ""
+	}
+	if pkgName, ok := pd.info.ObjectOf(ident).(*types.PkgName); ok {
+		return pkgName.Imported().Path(), sel.Sel.Name
+	}
+	return "", ""
+}
+
+// isPkgFunc reports whether call invokes one of the named functions of the package at path
+func (pd *PatternDetector) isPkgFunc(call *ast.CallExpr, path string, names ...string) bool {
+	pkgPath, name := pd.pkgFunc(call)
+	if pkgPath != path {
+		return false
+	}
+	for _, n := range names {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
+
 func (pd *PatternDetector) getTypeKind(expr ast.Expr) string {
 	if t := pd.info.TypeOf(expr); t != nil {
 		switch t.Underlying().(type) {
@@ -322,6 +575,19 @@ func (pd *PatternDetector) getTypeKind(expr ast.Expr) string {
 		case *types.Chan:
 			return "chan"
 		}
+		return "unknown"
+	}
+
+	// Without type information, fall back to the spelling of type literals
+	switch t := expr.(type) {
+	case *ast.ArrayType:
+		if t.Len == nil {
+			return "slice"
+		}
+	case *ast.MapType:
+		return "map"
+	case *ast.ChanType:
+		return "chan"
 	}
 	return "unknown"
 }
@@ -405,6 +671,14 @@ func (pd *PatternDetector) isStringType(expr ast.Expr) bool {
 }
 
 func (pd *PatternDetector) isInterfaceToConcreteAssertion(assert *ast.TypeAssertExpr) bool {
+	// x.(type) in a type switch and assertions to interface types are not concrete
+	if assert.Type == nil {
+		return false
+	}
+	if t := pd.info.TypeOf(assert.Type); t == nil || types.IsInterface(t) {
+		return false
+	}
+
 	// Check if asserting from interface{} to concrete type
 	if t := pd.info.TypeOf(assert.X); t != nil {
 		if iface, ok := t.Underlying().(*types.Interface); ok {
@@ -421,10 +695,10 @@ func (pd *PatternDetector) isNilSlice(expr ast.Expr) bool {
 	return false
 }
 
-func (pd *PatternDetector) isInLoop(call *ast.CallExpr) bool {
-	// This would need parent node tracking to determine if we're in a loop
-	// Simplified implementation
-	return false
+// isInLoop reports whether node runs on every iteration of an enclosing loop,
+// by the rules of enclosingLoopBody
+func (pd *PatternDetector) isInLoop(node ast.Node) bool {
+	return pd.enclosingLoopBody(node) != nil
 }
 
 func (pd *PatternDetector) capturesVariables(fn *ast.FuncLit) bool {
@@ -450,10 +724,20 @@ func (pd *PatternDetector) isSimpleStringFormatting(call *ast.CallExpr) bool {
 	return false
 }
 
+// isInHotPath reports whether call runs often: under -pprof, whether the
+// function declaring it is hot enough in the profile, and otherwise whether
+// it's in a loop
 func (pd *PatternDetector) isInHotPath(call *ast.CallExpr) bool {
-	// This would need profiling data or heuristics
-	// For now, assume it's in hot path if in a loop
-	return pd.isInLoop(call)
+	if pd.weights == nil {
+		return pd.isInLoop(call)
+	}
+	for _, n := range pd.stack {
+		if decl, ok := n.(*ast.FuncDecl); ok {
+			weight, ok := pd.weights[declKey(decl)]
+			return hotEnough(weight, ok, pd.config)
+		}
+	}
+	return false
 }
 
 func (pd *PatternDetector) isValueTypeToInterface(expr ast.Expr) bool {
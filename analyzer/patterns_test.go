@@ -0,0 +1,334 @@
+package analyzer
+
+import (
+	"flag"
+	"go/ast"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"io"
+	"testing"
+)
+
+// inspectSource parses and type-checks code, then returns all reported messages
+func inspectSource(t *testing.T, code string, config *Config) []string {
+	t.Helper()
+
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "test.go", code, parser.ParseComments)
+	if err != nil {
+		t.Fatalf("Failed to parse code: %v", err)
+	}
+
+	info := &types.Info{
+		Types:     make(map[ast.Expr]types.TypeAndValue),
+		Defs:      make(map[*ast.Ident]types.Object),
+		Uses:      make(map[*ast.Ident]types.Object),
+		Instances: make(map[*ast.Ident]types.Instance),
+	}
+
+	typesConfig := &types.Config{Importer: importer.Default()}
+	if _, err := typesConfig.Check("test", fset, []*ast.File{file}, info); err != nil {
+		t.Fatalf("Failed to type check code: %v", err)
+	}
+
+	var issues []string
+	InspectFileWithConfig(file, info, fset, config, func(pos token.Pos, msg string) {
+		issues = append(issues, msg)
+	})
+	return issues
+}
+
+// countMatching counts the messages containing substr
+func countMatching(issues []string, substr string) int {
+	count := 0
+	for _, issue := range issues {
+		if contains(issue, substr) {
+			count++
+		}
+	}
+	return count
+}
+
+func TestSliceOfEscapingPointers(t *testing.T) {
+	tests := []struct {
+		name     string
+		code     string
+		expected int
+	}{
+		{
+			name: "loop-local address appended",
+			code: `
+package main
+
+import "fmt"
+
+func complexCase() []*string {
+	var results []*string
+	for i := 0; i < 3; i++ {
+		s := fmt.Sprintf("item-%d", i)
+		results = append(results, &s)
+	}
+	return results
+}
+`,
+			expected: 1,
+		},
+		{
+			name: "pointer shared elsewhere in the loop",
+			code: `
+package main
+
+func shared(index map[string]*string) []*string {
+	var results []*string
+	for k := range index {
+		s := k
+		index[k] = &s
+		results = append(results, &s)
+	}
+	return results
+}
+`,
+			expected: 0,
+		},
+		{
+			name: "variable declared outside the loop",
+			code: `
+package main
+
+func outer() []*int {
+	var results []*int
+	x := 1
+	for i := 0; i < 3; i++ {
+		results = append(results, &x)
+	}
+	return results
+}
+`,
+			expected: 0,
+		},
+		{
+			name: "append outside a loop",
+			code: `
+package main
+
+func single() []*int {
+	x := 1
+	return append([]*int(nil), &x)
+}
+`,
+			expected: 0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			issues := inspectSource(t, tt.code, DefaultConfig())
+			if got := countMatching(issues, "slice of pointers built from loop-local"); got != tt.expected {
+				t.Errorf("Expected %d slice-of-escaping-pointers issues, got %d: %v", tt.expected, got, issues)
+			}
+		})
+	}
+}
+
+func TestSliceOfEscapingPointersDisabled(t *testing.T) {
+	code := `
+package main
+
+func build(n int) []*int {
+	var results []*int
+	for i := 0; i < n; i++ {
+		v := i
+		results = append(results, &v)
+	}
+	return results
+}
+`
+	config := DefaultConfig()
+	config.DisablePatterns = []string{"slice-of-escaping-pointers"}
+
+	issues := inspectSource(t, code, config)
+	if got := countMatching(issues, "slice of pointers"); got != 0 {
+		t.Errorf("Expected disabled pattern to be skipped, got: %v", issues)
+	}
+}
+
+func TestLoopChecks(t *testing.T) {
+	code := `
+package main
+
+import "strconv"
+
+func count(n int) int {
+	var out []int
+	var labels []string
+	for i := 0; i < n; i++ {
+		out = append(out, i)
+		labels = append(labels, strconv.Itoa(i))
+		func() {
+			labels = append(labels, strconv.Itoa(i))
+		}()
+	}
+	out = append(out, n)
+	labels = append(labels, strconv.Itoa(n))
+	return len(out) + len(labels)
+}
+`
+	issues := inspectSource(t, code, DefaultConfig())
+
+	// Only the calls directly in the loop body count; the closure body and
+	// the calls after the loop don't
+	if got := countMatching(issues, "append in loop may cause multiple reallocations"); got != 2 {
+		t.Errorf("Expected 2 append-in-loop issues, got %d: %v", got, issues)
+	}
+	if got := countMatching(issues, "strconv.Itoa allocates"); got != 1 {
+		t.Errorf("Expected 1 format-itoa issue, got %d: %v", got, issues)
+	}
+}
+
+func TestChanInLoop(t *testing.T) {
+	code := `
+package main
+
+import "net/http"
+
+func workers(jobs []int) {
+	for range jobs {
+		done := make(chan struct{})
+		close(done)
+	}
+
+	results := make(chan int, 1)
+	_ = results
+}
+
+func handle(w http.ResponseWriter, r *http.Request) {
+	done := make(chan bool)
+	close(done)
+}
+`
+	issues := inspectSource(t, code, DefaultConfig())
+
+	if got := countMatching(issues, "channel created on every loop iteration"); got != 1 {
+		t.Errorf("Expected 1 chan-in-loop issue, got %d: %v", got, issues)
+	}
+	if got := countMatching(issues, "channel created on every request"); got != 1 {
+		t.Errorf("Expected 1 per-request channel issue, got %d: %v", got, issues)
+	}
+	if got := countMatching(issues, "unbuffered or small buffered channel"); got != 1 {
+		t.Errorf("Expected the generic message for the channel outside loops, got %d: %v", got, issues)
+	}
+
+	config := DefaultConfig()
+	config.DisablePatterns = []string{"chan-in-loop"}
+	issues = inspectSource(t, code, config)
+	if got := countMatching(issues, "channel created on every"); got != 0 {
+		t.Errorf("Expected chan-in-loop to be disabled, got %v", issues)
+	}
+}
+
+func TestTypeAssertionInLoop(t *testing.T) {
+	code := `
+package main
+
+type shape interface{ Area() float64 }
+
+func sum(values []interface{}) int {
+	total := 0
+	for _, v := range values {
+		if n, ok := v.(int); ok {
+			total += n
+		}
+		if _, ok := v.(shape); ok {
+			total++
+		}
+		switch v.(type) {
+		case string:
+			total++
+		}
+	}
+	return total
+}
+
+func once(v interface{}) int {
+	n, _ := v.(int)
+	return n
+}
+`
+	const loopMsg = "type assertion from interface{} on every loop iteration"
+	const genericMsg = "type assertion may cause allocation if value was boxed"
+
+	tests := []struct {
+		name     string
+		verbose  bool
+		disabled []string
+		loop     int
+		generic  int
+	}{
+		{"loop only by default", false, nil, 1, 0},
+		{"verbose keeps the one-off assertion", true, nil, 1, 1},
+		{"loop rule disabled", false, []string{"type-assertion-in-loop"}, 0, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := DefaultConfig()
+			config.Verbose = tt.verbose
+			config.DisablePatterns = tt.disabled
+
+			issues := inspectSource(t, code, config)
+			if got := countMatching(issues, loopMsg); got != tt.loop {
+				t.Errorf("Expected %d loop assertion issues, got %d: %v", tt.loop, got, issues)
+			}
+			if got := countMatching(issues, genericMsg); got != tt.generic {
+				t.Errorf("Expected %d generic assertion issues, got %d: %v", tt.generic, got, issues)
+			}
+		})
+	}
+}
+
+func TestRegisterFormatFuncs(t *testing.T) {
+	code := `package main
+
+import "fmt"
+
+type Logger struct{}
+
+func (l *Logger) Debugf(format string, args ...interface{}) { fmt.Printf(format, args...) }
+
+func Infof(format string, args ...interface{}) { fmt.Printf(format, args...) }
+
+func Plain(format string, args ...interface{}) {}
+
+func main() {
+	l := &Logger{}
+	n := 42
+	Infof("n=%d", n)
+	l.Debugf("n=%d", n)
+	Plain("n=%d", n)
+}
+`
+	const msg = "formatting function allocates like fmt"
+	if got := countMatching(inspectSource(t, code, DefaultConfig()), msg); got != 0 {
+		t.Errorf("Expected no custom formatting issues without registered functions, got %d", got)
+	}
+
+	config := DefaultConfig()
+	config.RegisterFormatFuncs("test.Infof", " (*test.Logger).Debugf ", "")
+	if got := countMatching(inspectSource(t, code, config), msg); got != 2 {
+		t.Errorf("Expected 2 custom formatting issues, got %d", got)
+	}
+
+	config = DefaultConfig()
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	config.SetupFlags(fs)
+	if err := fs.Parse([]string{"-format-funcs=test.Infof,test.Plain"}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	config.ParseFlags(fs)
+	if got := countMatching(inspectSource(t, code, config), msg); got != 2 {
+		t.Errorf("Expected 2 custom formatting issues from -format-funcs, got %d", got)
+	}
+}
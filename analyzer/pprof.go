@@ -0,0 +1,299 @@
+package analyzer
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"go/ast"
+	"go/token"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// cpuProfile is what pprof-guided analysis, enabled with -pprof, needs from a
+// pprof profile: the weight of each function, the fraction of the total
+// sample value spent in it or in the functions it calls. Closures are folded
+// into the function declaring them, since that's where findings are reported.
+type cpuProfile struct {
+	weights map[string]float64 // by pprof name, such as example.com/pkg.(*T).M
+}
+
+// profiles caches the profiles loaded by loadProfile by path, since every
+// package analyzed in a run is weighed against the same one
+var profiles = struct {
+	sync.Mutex
+	m map[string]*cpuProfile
+}{m: make(map[string]*cpuProfile)}
+
+// loadProfile reads the pprof profile at path, gzip-compressed as written by
+// runtime/pprof or not, or returns nil if path is empty
+func loadProfile(path string) (*cpuProfile, error) {
+	if path == "" {
+		return nil, nil
+	}
+
+	profiles.Lock()
+	defer profiles.Unlock()
+	if p, ok := profiles.m[path]; ok {
+		return p, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read -pprof profile: %w", err)
+	}
+	p, err := parseProfile(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse -pprof profile %s: %w", path, err)
+	}
+	profiles.m[path] = p
+	return p, nil
+}
+
+// parseProfile decodes the profile.proto message in data, weighing functions
+// by the last sample value, which is what pprof shows by default: CPU time
+// for CPU profiles
+func parseProfile(data []byte) (*cpuProfile, error) {
+	if bytes.HasPrefix(data, []byte{0x1f, 0x8b}) {
+		r, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		if data, err = io.ReadAll(r); err != nil {
+			return nil, err
+		}
+	}
+
+	var (
+		samples   [][]byte
+		locations = make(map[uint64][]uint64) // location ID to function IDs, inlined ones included
+		functions = make(map[uint64]int64)    // function ID to name index
+		strs      []string
+	)
+	err := eachField(data, func(num protowire.Number, v []byte, _ uint64) error {
+		switch num {
+		case 2: // Profile.sample
+			samples = append(samples, v)
+		case 4: // Profile.location
+			return parseLocation(v, locations)
+		case 5: // Profile.function
+			var id uint64
+			var name int64
+			err := eachField(v, func(num protowire.Number, _ []byte, n uint64) error {
+				switch num {
+				case 1:
+					id = n
+				case 2:
+					name = int64(n)
+				}
+				return nil
+			})
+			functions[id] = name
+			return err
+		case 6: // Profile.string_table
+			strs = append(strs, string(v))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	name := func(function uint64) string {
+		if i, ok := functions[function]; ok && i >= 0 && i < int64(len(strs)) {
+			return foldClosures(strs[i])
+		}
+		return ""
+	}
+
+	totals := make(map[string]int64)
+	var total int64
+	for _, sample := range samples {
+		var locs []uint64
+		var values []int64
+		err := eachField(sample, func(num protowire.Number, v []byte, n uint64) error {
+			switch num {
+			case 1:
+				if v == nil {
+					locs = append(locs, n)
+					return nil
+				}
+				return eachVarint(v, func(n uint64) { locs = append(locs, n) })
+			case 2:
+				if v == nil {
+					values = append(values, int64(n))
+					return nil
+				}
+				return eachVarint(v, func(n uint64) { values = append(values, int64(n)) })
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		if len(values) == 0 {
+			continue
+		}
+		value := values[len(values)-1]
+		total += value
+
+		// A function is counted once per sample, however often it recurses
+		seen := make(map[string]bool)
+		for _, loc := range locs {
+			for _, function := range locations[loc] {
+				if name := name(function); name != "" && !seen[name] {
+					seen[name] = true
+					totals[name] += value
+				}
+			}
+		}
+	}
+
+	p := &cpuProfile{weights: make(map[string]float64, len(totals))}
+	if total == 0 {
+		return p, nil
+	}
+	for name, value := range totals {
+		p.weights[name] = float64(value) / float64(total)
+	}
+	return p, nil
+}
+
+// parseLocation records the functions of the Location message in v, from
+// each of its lines
+func parseLocation(v []byte, locations map[uint64][]uint64) error {
+	var id uint64
+	var functions []uint64
+	err := eachField(v, func(num protowire.Number, v []byte, n uint64) error {
+		switch num {
+		case 1: // Location.id
+			id = n
+		case 4: // Location.line
+			return eachField(v, func(num protowire.Number, _ []byte, n uint64) error {
+				if num == 1 { // Line.function_id
+					functions = append(functions, n)
+				}
+				return nil
+			})
+		}
+		return nil
+	})
+	locations[id] = functions
+	return err
+}
+
+// eachField calls fn with each field of the protobuf message in data, passing
+// length-delimited values as v and varints as n. Other wire types are skipped.
+func eachField(data []byte, fn func(num protowire.Number, v []byte, n uint64) error) error {
+	for len(data) > 0 {
+		num, typ, n := protowire.ConsumeTag(data)
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		data = data[n:]
+
+		var err error
+		switch typ {
+		case protowire.VarintType:
+			var value uint64
+			value, n = protowire.ConsumeVarint(data)
+			if n >= 0 {
+				err = fn(num, nil, value)
+			}
+		case protowire.BytesType:
+			var value []byte
+			value, n = protowire.ConsumeBytes(data)
+			if n >= 0 {
+				err = fn(num, value, 0)
+			}
+		default:
+			n = protowire.ConsumeFieldValue(num, typ, data)
+		}
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		if err != nil {
+			return err
+		}
+		data = data[n:]
+	}
+	return nil
+}
+
+// eachVarint calls fn with each varint of a packed repeated field
+func eachVarint(data []byte, fn func(n uint64)) error {
+	for len(data) > 0 {
+		value, n := protowire.ConsumeVarint(data)
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		fn(value)
+		data = data[n:]
+	}
+	return nil
+}
+
+var (
+	// typeArgs matches the instantiation pprof prints for generic functions
+	// and methods, as in pkg.Map[...] or pkg.(*List[...]).Push
+	typeArgs = regexp.MustCompile(`\[[^\]]*\]`)
+
+	// closureSuffix matches the names the compiler gives function literals
+	// and the wrappers of go and defer statements, as in pkg.F.func1.2
+	closureSuffix = regexp.MustCompile(`(\.(func|gowrap|deferwrap)\d+|\.\d+)+$`)
+)
+
+// foldClosures returns the name of the function declaration a function named
+// as pprof prints it belongs to, without type arguments
+func foldClosures(name string) string {
+	return closureSuffix.ReplaceAllString(typeArgs.ReplaceAllString(name, ""), "")
+}
+
+// packageWeights returns the weights of the functions declared in the package
+// with the given path and name, keyed as in F, T.M or (*T).M. Profiles of
+// commands name their functions main.F whatever the import path.
+func (p *cpuProfile) packageWeights(path, name string) map[string]float64 {
+	prefix := path + "."
+	if name == "main" {
+		prefix = "main."
+	}
+
+	weights := make(map[string]float64)
+	for fn, weight := range p.weights {
+		if rest, ok := strings.CutPrefix(fn, prefix); ok && !strings.Contains(rest, "/") {
+			weights[rest] = weight
+		}
+	}
+	return weights
+}
+
+// declKey names decl as packageWeights keys it
+func declKey(decl *ast.FuncDecl) string {
+	if decl.Recv != nil && len(decl.Recv.List) > 0 {
+		return receiverName(decl.Recv.List[0].Type) + "." + decl.Name.Name
+	}
+	return decl.Name.Name
+}
+
+// profileWeight returns the profile weight of the function declaration in f
+// containing pos, and whether it appears in the profile at all
+func profileWeight(weights map[string]float64, f *ast.File, pos token.Pos) (float64, bool) {
+	for _, decl := range f.Decls {
+		if fd, ok := decl.(*ast.FuncDecl); ok && withinRange(fd, pos) {
+			weight, ok := weights[declKey(fd)]
+			return weight, ok
+		}
+	}
+	return 0, false
+}
+
+// hotEnough reports whether a function of the given weight passes
+// -pprof-threshold, given as a percentage of the profile's samples
+func hotEnough(weight float64, ok bool, config *Config) bool {
+	return ok && weight*100 >= config.PprofThreshold
+}
@@ -0,0 +1,179 @@
+package analyzer
+
+import (
+	"bytes"
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// writeProfile writes a gzip-compressed profile.proto to a temporary file in
+// which each stack, listed from leaf to root by function name, was sampled
+// with the given CPU time
+func writeProfile(t *testing.T, stacks map[string]int64) string {
+	t.Helper()
+
+	message := func(fields ...func([]byte) []byte) []byte {
+		var b []byte
+		for _, field := range fields {
+			b = field(b)
+		}
+		return b
+	}
+	varint := func(num protowire.Number, v uint64) func([]byte) []byte {
+		return func(b []byte) []byte {
+			return protowire.AppendVarint(protowire.AppendTag(b, num, protowire.VarintType), v)
+		}
+	}
+	bytesField := func(num protowire.Number, v []byte) func([]byte) []byte {
+		return func(b []byte) []byte {
+			return protowire.AppendBytes(protowire.AppendTag(b, num, protowire.BytesType), v)
+		}
+	}
+
+	strs := []string{"", "samples", "count", "cpu", "nanoseconds"}
+	ids := make(map[string]uint64)
+	var profile []byte
+	profile = append(profile, bytesField(1, message(varint(1, 1), varint(2, 2)))(nil)...)
+	profile = append(profile, bytesField(1, message(varint(1, 3), varint(2, 4)))(nil)...)
+	for stack, nanos := range stacks {
+		var locs []byte
+		for _, fn := range strings.Split(stack, ";") {
+			id, ok := ids[fn]
+			if !ok {
+				id = uint64(len(ids) + 1)
+				ids[fn] = id
+				strs = append(strs, fn)
+				profile = bytesField(5, message(varint(1, id), varint(2, uint64(len(strs)-1))))(profile)
+				profile = bytesField(4, message(varint(1, id), bytesField(4, message(varint(1, id)))))(profile)
+			}
+			locs = protowire.AppendVarint(locs, id)
+		}
+		values := protowire.AppendVarint(protowire.AppendVarint(nil, 1), uint64(nanos))
+		profile = bytesField(2, message(bytesField(1, locs), bytesField(2, values)))(profile)
+	}
+	for _, s := range strs {
+		profile = bytesField(6, []byte(s))(profile)
+	}
+
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	w.Write(profile)
+	w.Close()
+
+	path := filepath.Join(t.TempDir(), "cpu.pprof")
+	if err := os.WriteFile(path, buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestParseProfile(t *testing.T) {
+	path := writeProfile(t, map[string]int64{
+		"example.com/svc.(*Server).handle.func1;example.com/svc.(*Server).handle;main.main": 60,
+		"example.com/svc.Map[...];example.com/svc.encode;main.main":                         30,
+		"example.com/svc.encode;example.com/svc.encode;main.main":                           10,
+	})
+	profile, err := loadProfile(path)
+	if err != nil {
+		t.Fatalf("Failed to load profile: %v", err)
+	}
+
+	weights := profile.packageWeights("example.com/svc", "svc")
+	tests := []struct {
+		name   string
+		weight float64
+	}{
+		{"(*Server).handle", 0.6}, // closures fold into their function
+		{"encode", 0.4},           // recursion counts once per sample
+		{"Map", 0.3},              // without type arguments
+	}
+	for _, tt := range tests {
+		if got := weights[tt.name]; got != tt.weight {
+			t.Errorf("Expected weight %v for %s, got %v", tt.weight, tt.name, got)
+		}
+	}
+	if _, ok := weights["main"]; ok || len(weights) != len(tests) {
+		t.Errorf("Expected only the functions of example.com/svc, got %v", weights)
+	}
+
+	if _, err := loadProfile(filepath.Join(t.TempDir(), "missing.pprof")); err == nil {
+		t.Error("Expected an error for a missing profile")
+	}
+}
+
+func TestPprofGuided(t *testing.T) {
+	code := `package main
+
+type server struct{}
+
+func (s *server) handle() {
+	go func() {
+		p := new(int)
+		_ = p
+	}()
+}
+
+func hot() {
+	p := new(int)
+	_ = p
+}
+
+func cold() {
+	p := new(int)
+	_ = p
+}
+
+func main() {}
+`
+	path := writeProfile(t, map[string]int64{
+		"main.hot;main.main":                         80,
+		"main.(*server).handle.func1;runtime.goexit": 20,
+	})
+
+	tests := []struct {
+		name      string
+		threshold float64
+		expected  []string
+	}{
+		{"functions in the profile", 0, []string{"(pprof: 80.0% of samples)", "(pprof: 20.0% of samples)"}},
+		{"above the threshold", 50, []string{"(pprof: 80.0% of samples)"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pass, diagnostics := newTestPass(t, code)
+			config := DefaultConfig()
+			config.OpenAIDisable = true
+			config.Pprof = path
+			config.PprofThreshold = tt.threshold
+			if _, err := runWithDeps(pass, newAnalyzerOptions(WithConfig(config))); err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			counts := make(map[string]int)
+			for _, d := range *diagnostics {
+				for _, want := range tt.expected {
+					if strings.HasSuffix(d.Message, want) {
+						counts[want]++
+					}
+				}
+				if !strings.Contains(d.Message, "(pprof: ") {
+					t.Errorf("Expected only findings in profiled functions, got %q", d.Message)
+				}
+			}
+			for _, want := range tt.expected {
+				if counts[want] == 0 {
+					t.Errorf("Expected findings annotated with %q, got %v", want, *diagnostics)
+				}
+			}
+			if len(counts) != len(tt.expected) {
+				t.Errorf("Expected findings from %d functions, got %v", len(tt.expected), *diagnostics)
+			}
+		})
+	}
+}
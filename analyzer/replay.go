@@ -0,0 +1,206 @@
+package analyzer
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"go/token"
+	"io"
+	"os"
+	"sort"
+
+	"golang.org/x/tools/go/analysis"
+)
+
+// SavedFixes is the set of edits saved by -save-fixes for a single file. A
+// saved fix file holds one JSON object per line, since go vet runs a separate
+// process for each package and each appends its own files.
+type SavedFixes struct {
+	File   string      `json:"file"`
+	SHA256 string      `json:"sha256"` // Hash of the file's contents when the fixes were saved
+	Edits  []SavedEdit `json:"edits"`
+}
+
+// SavedEdit is a single edit addressed by byte offsets, along with the text it
+// replaces so that it can be checked against the current file
+type SavedEdit struct {
+	Offset  int    `json:"offset"`
+	End     int    `json:"end"`
+	OldText string `json:"oldText"`
+	NewText string `json:"newText"`
+}
+
+// SaveFixes appends the tracked fixes to the saved fix file at path
+func (ft *FixTracker) SaveFixes(fset *token.FileSet, path string) error {
+	ft.mu.Lock()
+	defer ft.mu.Unlock()
+
+	filenames := make([]string, 0, len(ft.fixes))
+	for filename := range ft.fixes {
+		filenames = append(filenames, filename)
+	}
+	sort.Strings(filenames)
+
+	var buf bytes.Buffer
+	for _, filename := range filenames {
+		content, err := os.ReadFile(filename)
+		if err != nil {
+			return fmt.Errorf("failed to save fixes for %s: %v", filename, err)
+		}
+
+		saved := SavedFixes{File: filename, SHA256: contentHash(content)}
+		for _, edit := range ft.fixes[filename] {
+			start, end := fset.Position(edit.Pos), fset.Position(edit.End)
+			if start.Filename != filename || end.Filename != filename || start.Offset > end.Offset || end.Offset > len(content) {
+				continue
+			}
+			saved.Edits = append(saved.Edits, SavedEdit{
+				Offset:  start.Offset,
+				End:     end.Offset,
+				OldText: string(content[start.Offset:end.Offset]),
+				NewText: string(edit.NewText),
+			})
+		}
+		if len(saved.Edits) == 0 {
+			continue
+		}
+
+		line, err := json.Marshal(saved)
+		if err != nil {
+			return err
+		}
+		buf.Write(line)
+		buf.WriteByte('\n')
+	}
+	if buf.Len() == 0 {
+		return nil
+	}
+
+	// Write each package's fixes in a single append so concurrent go vet
+	// processes don't interleave
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to save fixes: %v", err)
+	}
+	if _, err := f.Write(buf.Bytes()); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to save fixes: %v", err)
+	}
+	return f.Close()
+}
+
+// ApplySavedFixes applies the fixes saved at path to the current files with
+// the AutoFixer. Edits whose original text no longer matches the file are
+// skipped with a warning written to warnings. It returns the number of edits
+// applied.
+func ApplySavedFixes(path string, warnings io.Writer) (int, error) {
+	sets, err := loadSavedFixes(path)
+	if err != nil {
+		return 0, err
+	}
+
+	applied := 0
+	for _, set := range sets {
+		content, err := os.ReadFile(set.File)
+		if err != nil {
+			return applied, fmt.Errorf("failed to apply fixes to %s: %v", set.File, err)
+		}
+		unchanged := contentHash(content) == set.SHA256
+
+		fset := token.NewFileSet()
+		file := fset.AddFile(set.File, -1, len(content))
+		file.SetLinesForContent(content)
+
+		var edits []analysis.TextEdit
+		for _, edit := range set.Edits {
+			if !unchanged && !edit.matches(content) {
+				fmt.Fprintf(warnings, "%s: skipping stale fix at offset %d: file changed since fixes were saved\n", set.File, edit.Offset)
+				continue
+			}
+			edits = append(edits, analysis.TextEdit{
+				Pos:     file.Pos(edit.Offset),
+				End:     file.Pos(edit.End),
+				NewText: []byte(edit.NewText),
+			})
+		}
+		if len(edits) == 0 {
+			continue
+		}
+
+		if err := NewAutoFixer(fset).ApplyFixesToFile(set.File, edits); err != nil {
+			return applied, fmt.Errorf("failed to apply fixes to %s: %v", set.File, err)
+		}
+		applied += len(edits)
+	}
+	return applied, nil
+}
+
+// loadSavedFixes reads a saved fix file. Edits saved for the same file by
+// different packages, such as a package and its test variant, are merged, and
+// fixes saved against an older version of a file are replaced by newer ones.
+func loadSavedFixes(path string) ([]SavedFixes, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load saved fixes: %v", err)
+	}
+	defer f.Close()
+
+	var sets []SavedFixes
+	index := make(map[string]int)
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(nil, 64*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+
+		var set SavedFixes
+		if err := json.Unmarshal(scanner.Bytes(), &set); err != nil {
+			return nil, fmt.Errorf("%s:%d: invalid saved fixes: %v", path, line, err)
+		}
+
+		i, ok := index[set.File]
+		switch {
+		case !ok:
+			index[set.File] = len(sets)
+			sets = append(sets, set)
+		case sets[i].SHA256 != set.SHA256:
+			sets[i] = set
+		default:
+			for _, edit := range set.Edits {
+				if !containsEdit(sets[i].Edits, edit) {
+					sets[i].Edits = append(sets[i].Edits, edit)
+				}
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to load saved fixes: %v", err)
+	}
+	return sets, nil
+}
+
+// containsEdit reports whether edits already holds edit
+func containsEdit(edits []SavedEdit, edit SavedEdit) bool {
+	for _, e := range edits {
+		if e == edit {
+			return true
+		}
+	}
+	return false
+}
+
+// matches reports whether content still holds the text the edit replaces.
+// Pure insertions replace nothing, so there is nothing to check them against.
+func (e SavedEdit) matches(content []byte) bool {
+	return e.OldText != "" && e.Offset >= 0 && e.Offset <= e.End && e.End <= len(content) && string(content[e.Offset:e.End]) == e.OldText
+}
+
+// contentHash returns the hex-encoded SHA-256 of content
+func contentHash(content []byte) string {
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
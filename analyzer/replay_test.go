@@ -0,0 +1,97 @@
+package analyzer
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const replayTestCode = `package main
+
+import "time"
+
+func elapsed(start time.Time) time.Duration {
+	return time.Now().Sub(start)
+}
+`
+
+// saveReplayFixes analyzes replayTestCode with -save-fixes and returns the
+// analyzed file and the saved fix file
+func saveReplayFixes(t *testing.T) (string, string) {
+	t.Helper()
+
+	pass, _ := newTestPass(t, replayTestCode)
+	filename := pass.Fset.File(pass.Files[0].Pos()).Name()
+	saved := filepath.Join(t.TempDir(), "fixes.jsonl")
+
+	config := DefaultConfig()
+	config.EnablePatterns = []string{"stdlib-idioms"}
+	config.SaveFixes = saved
+	config.OpenAIDisable = true
+
+	// Save the same fixes twice, as go vet does for a package and its test variant
+	for i := 0; i < 2; i++ {
+		if _, err := runWithDeps(pass, newAnalyzerOptions(WithConfig(config))); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+
+	content, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatalf("Failed to read test file: %v", err)
+	}
+	if string(content) != replayTestCode {
+		t.Errorf("Expected -save-fixes not to modify the file, got:\n%s", content)
+	}
+	return filename, saved
+}
+
+func TestSaveAndApplyFixes(t *testing.T) {
+	filename, saved := saveReplayFixes(t)
+
+	var warnings bytes.Buffer
+	applied, err := ApplySavedFixes(saved, &warnings)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if applied != 1 || warnings.Len() != 0 {
+		t.Errorf("Expected 1 fix applied without warnings, got %d: %s", applied, warnings.String())
+	}
+
+	content, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatalf("Failed to read test file: %v", err)
+	}
+	if !strings.Contains(string(content), "return time.Since(start)") {
+		t.Errorf("Expected the saved fix to be applied, got:\n%s", content)
+	}
+}
+
+func TestApplySavedFixesSkipsStaleEdits(t *testing.T) {
+	filename, saved := saveReplayFixes(t)
+
+	// Shift the fixed expression so the saved offsets no longer point at it
+	changed := strings.Replace(replayTestCode, "func elapsed", "// elapsed reports the time since start\nfunc elapsed", 1)
+	if err := os.WriteFile(filename, []byte(changed), 0644); err != nil {
+		t.Fatalf("Failed to write test file: %v", err)
+	}
+
+	var warnings bytes.Buffer
+	applied, err := ApplySavedFixes(saved, &warnings)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if applied != 0 || !strings.Contains(warnings.String(), "skipping stale fix") {
+		t.Errorf("Expected the stale fix to be skipped with a warning, got %d applied: %q", applied, warnings.String())
+	}
+
+	content, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatalf("Failed to read test file: %v", err)
+	}
+	if string(content) != changed {
+		t.Errorf("Expected the changed file to be left alone, got:\n%s", content)
+	}
+}
@@ -1,29 +1,40 @@
 package analyzer
 
 import (
+	"bytes"
 	"context"
 	"fmt"
 	"go/token"
-	"io/ioutil"
+	"io"
+	"sort"
 	"strings"
 	"sync"
 
+	"github.com/harriteja/gostackallocator/internal"
 	"golang.org/x/tools/go/analysis"
 )
 
-// FixTracker tracks fixes to be applied to files
+// FixTracker tracks fixes to be applied to files during a single run
 type FixTracker struct {
-	mu    sync.Mutex
-	fixes map[string][]analysis.TextEdit // filename -> list of fixes
+	mu      sync.Mutex
+	fixes   map[string][]analysis.TextEdit // filename -> list of fixes
+	sources *SourceCache                   // source reads shared by all issues in the run
+	pkg     *fixPackage                    // package being fixed, for -validate-fixes to type check fixes in
 }
 
 // NewFixTracker creates a new fix tracker
 func NewFixTracker() *FixTracker {
 	return &FixTracker{
-		fixes: make(map[string][]analysis.TextEdit),
+		fixes:   make(map[string][]analysis.TextEdit),
+		sources: NewSourceCache(internal.GetLogger()),
 	}
 }
 
+// Sources returns the source cache shared by the run
+func (ft *FixTracker) Sources() *SourceCache {
+	return ft.sources
+}
+
 // AddFix adds a fix for a specific file
 func (ft *FixTracker) AddFix(filename string, edits []analysis.TextEdit) {
 	ft.mu.Lock()
@@ -35,6 +46,15 @@ func (ft *FixTracker) AddFix(filename string, edits []analysis.TextEdit) {
 		// Check if this edit overlaps with existing ones
 		overlaps := false
 		for i, existingEdit := range existingEdits {
+			// Insertions at the same position, such as two annotations for one
+			// line, are combined unless the text is already being inserted
+			if isInsertion(newEdit) && isInsertion(existingEdit) && newEdit.Pos == existingEdit.Pos {
+				if !bytes.Contains(existingEdit.NewText, newEdit.NewText) {
+					existingEdits[i].NewText = append(append([]byte(nil), existingEdit.NewText...), newEdit.NewText...)
+				}
+				overlaps = true
+				break
+			}
 			if newEdit.Pos <= existingEdit.End && newEdit.End >= existingEdit.Pos {
 				// Overlapping edit found - replace if the new one is better
 				if len(newEdit.NewText) > 0 && !strings.Contains(string(newEdit.NewText), "TODO") {
@@ -54,6 +74,11 @@ func (ft *FixTracker) AddFix(filename string, edits []analysis.TextEdit) {
 	ft.fixes[filename] = existingEdits
 }
 
+// isInsertion reports whether edit inserts text without replacing any
+func isInsertion(edit analysis.TextEdit) bool {
+	return edit.Pos == edit.End
+}
+
 // ApplyAllFixes applies all tracked fixes using the provided AutoFixer
 func (ft *FixTracker) ApplyAllFixes(autoFixer *AutoFixer) error {
 	ft.mu.Lock()
@@ -84,48 +109,94 @@ func (ft *FixTracker) GetFilesWithFixes() []string {
 	return files
 }
 
+// checkPendingFixes lists the files that have tracked fixes, one per line like
+// gofmt -l, and returns an error if there are any
+func checkPendingFixes(fixTracker *FixTracker, w io.Writer) error {
+	files := fixTracker.GetFilesWithFixes()
+	if len(files) == 0 {
+		return nil
+	}
+
+	sort.Strings(files)
+	for _, filename := range files {
+		fmt.Fprintln(w, filename)
+	}
+	return fmt.Errorf("autofix would modify %d file(s)", len(files))
+}
+
 // FormatIssue converts an Issue into an analysis.Diagnostic
 func FormatIssue(issue Issue, aiClient AIClient, fset *token.FileSet, config *Config) analysis.Diagnostic {
+	return formatIssue(issue, aiClient, fset, config, NewSourceCache(internal.GetLogger()), nil)
+}
+
+// tokenPos converts position back to a token.Pos in fset, or token.NoPos if
+// it is unset or its file isn't in fset
+func tokenPos(fset *token.FileSet, position token.Position) token.Pos {
+	if !position.IsValid() || fset == nil {
+		return token.NoPos
+	}
+
+	pos := token.NoPos
+	fset.Iterate(func(f *token.File) bool {
+		if f.Name() != position.Filename {
+			return true
+		}
+		if position.Offset <= f.Size() {
+			pos = f.Pos(position.Offset)
+		}
+		return false
+	})
+	return pos
+}
+
+// formatIssue converts an Issue into an analysis.Diagnostic, reading source
+// through sources. pkg is the issue's package, or nil if it isn't known.
+func formatIssue(issue Issue, aiClient AIClient, fset *token.FileSet, config *Config, sources *SourceCache, pkg *fixPackage) analysis.Diagnostic {
 	diagnostic := analysis.Diagnostic{
-		Pos:      token.Pos(issue.Pos.Offset),
+		Pos:      tokenPos(fset, issue.Pos),
+		End:      tokenPos(fset, issue.End),
 		Message:  issue.Message,
 		Category: "stackalloc",
 	}
 
-	// Add AI-powered suggestion if enabled
+	// Annotations replace every other kind of fix
+	if config.Annotate {
+		if fix, ok := annotationFix(issue, fset, sources); ok {
+			diagnostic.SuggestedFixes = []analysis.SuggestedFix{fix}
+		}
+		return diagnostic
+	}
+
+	// Fixes provided by the detector are deterministic and take precedence over AI suggestions
+	if len(issue.SuggestedFixes) > 0 {
+		diagnostic.SuggestedFixes = issue.SuggestedFixes
+		return withFixPreview(diagnostic, fset, config, sources)
+	}
+
+	// So are the fixes the AutoFixer derives from the issue alone, such as
+	// new(string) to "", which don't depend on AI being available
+	if config.GeneratesFixes() || config.IncludeFixes || config.ShowFix {
+		if fixes := generateCodeFixes(issue, "", fset, config, sources, pkg); len(fixes) > 0 {
+			diagnostic.SuggestedFixes = fixes
+			return withFixPreview(diagnostic, fset, config, sources)
+		}
+	}
+
+	// Add AI-powered suggestion if enabled, for issues without a
+	// deterministic fix. Only suggestions that can be turned into code become
+	// fixes; the rest are attached as related information, since a fix that
+	// only inserts a comment is no use as an editor's quick fix.
 	if !config.OpenAIDisable && aiClient != nil {
-		if suggestion := getAISuggestion(issue, aiClient, fset, config); suggestion != "" {
-			// Generate automatic fixes if enabled
-			if config.AutoFix {
-				if fixes := generateCodeFixes(issue, suggestion, fset); len(fixes) > 0 {
-					diagnostic.SuggestedFixes = fixes
-				} else {
-					// Fallback to comment-based suggestion
-					diagnostic.SuggestedFixes = []analysis.SuggestedFix{
-						{
-							Message: "AI-suggested improvement (enable -autofix for automatic fixes)",
-							TextEdits: []analysis.TextEdit{
-								{
-									Pos:     token.Pos(issue.Pos.Offset),
-									End:     token.Pos(issue.Pos.Offset),
-									NewText: []byte(fmt.Sprintf("// AI suggestion: %s\n", suggestion)),
-								},
-							},
-						},
-					}
-				}
-			} else {
-				// Just add suggestion as comment
-				diagnostic.SuggestedFixes = []analysis.SuggestedFix{
+		if suggestion := getAISuggestion(issue, aiClient, fset, config, sources); suggestion != "" {
+			if config.GeneratesFixes() || config.IncludeFixes {
+				diagnostic.SuggestedFixes = generateCodeFixes(issue, suggestion, fset, config, sources, pkg)
+			}
+			if len(diagnostic.SuggestedFixes) == 0 {
+				diagnostic.Related = []analysis.RelatedInformation{
 					{
-						Message: "AI-suggested improvement (enable -autofix for automatic fixes)",
-						TextEdits: []analysis.TextEdit{
-							{
-								Pos:     token.Pos(issue.Pos.Offset),
-								End:     token.Pos(issue.Pos.Offset),
-								NewText: []byte(fmt.Sprintf("// AI suggestion: %s\n", suggestion)),
-							},
-						},
+						Pos:     diagnostic.Pos,
+						End:     diagnostic.End,
+						Message: fmt.Sprintf("AI suggestion: %s", suggestion),
 					},
 				}
 			}
@@ -135,20 +206,52 @@ func FormatIssue(issue Issue, aiClient AIClient, fset *token.FileSet, config *Co
 	return diagnostic
 }
 
+// withFixPreview appends the edits of the diagnostic's first fix to its message
+// under -show-fix, one line each, such as `→ replace with: ""`. It is only
+// used for deterministic fixes; AI suggestions are never shown as code.
+func withFixPreview(diagnostic analysis.Diagnostic, fset *token.FileSet, config *Config, sources *SourceCache) analysis.Diagnostic {
+	if !config.ShowFix || len(diagnostic.SuggestedFixes) == 0 {
+		return diagnostic
+	}
+
+	// Continuation lines of multi-line code are indented under the first
+	indent := func(code string) string {
+		return strings.ReplaceAll(strings.TrimRight(code, "\n"), "\n", "\n\t  ")
+	}
+
+	var preview strings.Builder
+	for _, edit := range diagnostic.SuggestedFixes[0].TextEdits {
+		switch {
+		case len(edit.NewText) == 0:
+			var oldText string
+			start, end := fset.Position(edit.Pos), fset.Position(edit.End)
+			if src, ok := sources.Read(start.Filename); ok && start.Offset <= end.Offset && end.Offset <= len(src) {
+				oldText = string(src[start.Offset:end.Offset])
+			}
+			fmt.Fprintf(&preview, "\n\t→ remove: %s", indent(oldText))
+		case !edit.End.IsValid() || edit.End == edit.Pos:
+			fmt.Fprintf(&preview, "\n\t→ insert: %s", indent(string(edit.NewText)))
+		default:
+			fmt.Fprintf(&preview, "\n\t→ replace with: %s", indent(string(edit.NewText)))
+		}
+	}
+	diagnostic.Message += preview.String()
+	return diagnostic
+}
+
 // FormatIssueWithFixTracker converts an Issue into an analysis.Diagnostic and tracks fixes
 func FormatIssueWithFixTracker(issue Issue, aiClient AIClient, fset *token.FileSet, config *Config, fixTracker *FixTracker) analysis.Diagnostic {
-	diagnostic := FormatIssue(issue, aiClient, fset, config)
+	diagnostic := formatIssue(issue, aiClient, fset, config, fixTracker.Sources(), fixTracker.pkg)
 
 	// If autofix is enabled and we have suggested fixes, track them for later application
-	if config.AutoFix && len(diagnostic.SuggestedFixes) > 0 {
-		position := fset.Position(token.Pos(issue.Pos.Offset))
-		if position.Filename != "" {
+	if config.GeneratesFixes() && len(diagnostic.SuggestedFixes) > 0 {
+		if issue.Pos.Filename != "" {
 			// Collect all text edits from all suggested fixes
 			var allEdits []analysis.TextEdit
 			for _, fix := range diagnostic.SuggestedFixes {
 				allEdits = append(allEdits, fix.TextEdits...)
 			}
-			fixTracker.AddFix(position.Filename, allEdits)
+			fixTracker.AddFix(issue.Pos.Filename, allEdits)
 		}
 	}
 
@@ -156,11 +259,11 @@ func FormatIssueWithFixTracker(issue Issue, aiClient AIClient, fset *token.FileS
 }
 
 // getAISuggestion gets an AI-powered code suggestion for the issue
-func getAISuggestion(issue Issue, aiClient AIClient, fset *token.FileSet, config *Config) string {
+func getAISuggestion(issue Issue, aiClient AIClient, fset *token.FileSet, config *Config, sources *SourceCache) string {
 	ctx := context.Background()
 
 	// Get code snippet around the issue
-	snippet := getCodeSnippetFromPosition(issue.Pos, fset)
+	snippet := getCodeSnippetFromPosition(issue.Pos, fset, sources)
 	if snippet == "" {
 		return ""
 	}
@@ -176,14 +279,10 @@ func getAISuggestion(issue Issue, aiClient AIClient, fset *token.FileSet, config
 }
 
 // getCodeSnippetFromPosition extracts code snippet from file position
-func getCodeSnippetFromPosition(pos token.Position, fset *token.FileSet) string {
-	if pos.Filename == "" {
-		return ""
-	}
-
+func getCodeSnippetFromPosition(pos token.Position, fset *token.FileSet, sources *SourceCache) string {
 	// Read the source file
-	src, err := ioutil.ReadFile(pos.Filename)
-	if err != nil {
+	src, ok := sources.Read(pos.Filename)
+	if !ok {
 		return ""
 	}
 
@@ -211,33 +310,83 @@ func getCodeSnippetFromPosition(pos token.Position, fset *token.FileSet) string
 	return GetCodeSnippet(fset, token.Pos(tokenPos), src)
 }
 
-// generateCodeFixes attempts to generate actual code fixes based on AI suggestions
-func generateCodeFixes(issue Issue, suggestion string, fset *token.FileSet) []analysis.SuggestedFix {
-	// Use the new AutoFixer for more sophisticated fixes
-	autoFixer := NewAutoFixer(fset)
-	fixes := autoFixer.GenerateAutoFixes(issue, suggestion)
+// maxSourceLineLength caps each line -include-source embeds, so that minified
+// or generated code can't bloat a report
+const maxSourceLineLength = 200
 
-	if len(fixes) > 0 {
-		return fixes
+// withSources attaches the source around each issue, read from sources, for
+// -include-source. Issues in files that can't be read are left without it.
+func withSources(issues []Issue, sources *SourceCache) []Issue {
+	for i := range issues {
+		issues[i].Source = issueSource(issues[i].Pos, sources)
 	}
+	return issues
+}
 
-	// Fallback to simple fixes if AutoFixer doesn't handle the case
-	var fallbackFixes []analysis.SuggestedFix
+// issueSource returns the line at pos and the lines around it, with the same
+// context as GetCodeSnippet, or nil if they can't be read
+func issueSource(pos token.Position, sources *SourceCache) *IssueSource {
+	src, ok := sources.Read(pos.Filename)
+	if !ok {
+		return nil
+	}
+	lines := splitLines(src)
+	if pos.Line <= 0 || pos.Line > len(lines) {
+		return nil
+	}
 
-	if strings.Contains(issue.Message, "new(T)") {
-		fallbackFixes = append(fallbackFixes, analysis.SuggestedFix{
-			Message: "Replace new(T) with stack allocation",
-			TextEdits: []analysis.TextEdit{
-				{
-					Pos:     token.Pos(issue.Pos.Offset),
-					End:     token.Pos(issue.Pos.Offset + 10),
-					NewText: []byte("/* TODO: Replace with stack allocation */"),
-				},
-			},
-		})
+	start := max(0, pos.Line-3)
+	end := min(len(lines), pos.Line+2)
+	around := make([]string, 0, end-start)
+	for _, line := range lines[start:end] {
+		around = append(around, internal.TruncateString(line, maxSourceLineLength))
+	}
+	return &IssueSource{
+		Line:      around[pos.Line-1-start],
+		StartLine: start + 1,
+		Context:   strings.Join(around, "\n"),
 	}
+}
 
-	return fallbackFixes
+// issueFixes converts the suggested fixes for an issue in filename to edits by
+// byte offset, for -include-fixes. Edits outside filename, and fixes left
+// without edits, are dropped.
+func issueFixes(fixes []analysis.SuggestedFix, filename string, fset *token.FileSet, sources *SourceCache) []IssueFix {
+	src, _ := sources.Read(filename)
+
+	var converted []IssueFix
+	for _, fix := range fixes {
+		issueFix := IssueFix{Message: fix.Message}
+		for _, edit := range fix.TextEdits {
+			start, end := fset.Position(edit.Pos), fset.Position(edit.End)
+			if start.Filename != filename || end.Filename != filename || start.Offset > end.Offset {
+				continue
+			}
+			saved := SavedEdit{Offset: start.Offset, End: end.Offset, NewText: string(edit.NewText)}
+			if end.Offset <= len(src) {
+				saved.OldText = string(src[start.Offset:end.Offset])
+			}
+			issueFix.Edits = append(issueFix.Edits, saved)
+		}
+		if len(issueFix.Edits) > 0 {
+			converted = append(converted, issueFix)
+		}
+	}
+	return converted
+}
+
+// generateCodeFixes generates the AutoFixer's code fixes for issue, built
+// from the AI suggestion if there is one, with edits positioned in fset. With
+// -validate-fixes, fixes that would leave the file unparseable, or failing to
+// type check in pkg, are discarded.
+func generateCodeFixes(issue Issue, suggestion string, fset *token.FileSet, config *Config, sources *SourceCache, pkg *fixPackage) []analysis.SuggestedFix {
+	autoFixer := NewAutoFixer(fset)
+	autoFixer.sources = sources
+	fixes := autoFixer.GenerateAutoFixes(issue, suggestion)
+	if config.ValidateFixes {
+		fixes = validateFixes(issue, fixes, fset, sources, pkg)
+	}
+	return fixes
 }
 
 // ReportIssue is a helper function to report an issue with proper formatting
@@ -0,0 +1,246 @@
+package analyzer
+
+import (
+	"encoding/json"
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"io"
+	"strings"
+
+	"golang.org/x/tools/go/ast/astutil"
+)
+
+// Result is an Issue along with the metadata programmatic callers need to
+// triage it: which rule reported it, how much it allocates and where. The
+// issue's severity and suggested fixes come with the embedded Issue.
+type Result struct {
+	Issue
+	Rule        string // name of the rule that reported the issue, or "" for heuristic detectors
+	Category    string // rule ID, as used in SARIF: the rule name, or "stackalloc" for heuristic detectors
+	Description string // one-line description of the rule
+	Bytes       int64  // estimated bytes allocated by the offending expression, or -1 if not known
+	Func        string // enclosing function, such as "parse" or "(*Server).Handle"; "" at package level
+}
+
+// newResults describes the issues found in file
+func newResults(file *ast.File, info *types.Info, pkg *packageInfo, fset *token.FileSet, config *Config, issues []Issue) []Result {
+	if config == nil {
+		config = DefaultConfig()
+	}
+	detector := NewPatternDetector(info, fset, config, nil)
+	if pkg != nil {
+		detector.sizes = pkg.sizes
+	}
+	tokenFile := fset.File(file.Pos())
+
+	results := make([]Result, 0, len(issues))
+	for _, issue := range issues {
+		result := Result{
+			Issue:       issue,
+			Rule:        issue.Pattern,
+			Category:    sarifRuleID,
+			Description: sarifRuleDescription,
+			Bytes:       -1,
+		}
+		if rule, ok := LookupRule(issue.Pattern); ok {
+			result.Category = rule.Name
+			result.Description = rule.Description
+		}
+
+		if tokenFile != nil && tokenFile.Name() == issue.Pos.Filename && issue.End.IsValid() {
+			start, end := tokenFile.Pos(issue.Pos.Offset), tokenFile.Pos(issue.End.Offset)
+			path, exact := astutil.PathEnclosingInterval(file, start, end)
+			if exact && len(path) > 0 {
+				result.Bytes = detector.estimatedBytes(path[0])
+				path = path[1:]
+			}
+			result.Func = enclosingFuncName(path)
+		}
+		results = append(results, result)
+	}
+	return results
+}
+
+// estimatedBytes returns the bytes allocated by node when it is new(T),
+// make([]T, ...), a composite literal or the address of one, or -1 if it isn't
+// one of those or its size isn't known statically
+func (pd *PatternDetector) estimatedBytes(node ast.Node) int64 {
+	var size int64
+	ok := false
+
+	switch n := node.(type) {
+	case *ast.CallExpr:
+		switch {
+		case pd.isNewCall(n) && len(n.Args) == 1:
+			size, ok = pd.sizeof(pd.info.TypeOf(n.Args[0]))
+		case pd.isMakeCall(n) && len(n.Args) >= 2 && pd.getTypeKind(n.Args[0]) == "slice":
+			size, ok = pd.makeSliceSize(n)
+		}
+	case *ast.UnaryExpr:
+		if lit, isLit := ast.Unparen(n.X).(*ast.CompositeLit); isLit && n.Op == token.AND {
+			return pd.estimatedBytes(lit)
+		}
+	case *ast.CompositeLit:
+		if pd.getCompositeLiteralType(n) == "slice" {
+			size, ok = pd.sliceLiteralSize(n)
+		} else {
+			size, ok = pd.sizeof(pd.info.TypeOf(n))
+		}
+	}
+
+	if !ok {
+		return -1
+	}
+	return size
+}
+
+// enclosingFuncName names the innermost function declaration in path, the
+// ancestors of a node from innermost to outermost, noting when the node is
+// inside a function literal
+func enclosingFuncName(path []ast.Node) string {
+	literal := false
+	for _, node := range path {
+		switch fn := node.(type) {
+		case *ast.FuncLit:
+			literal = true
+		case *ast.FuncDecl:
+			name := fn.Name.Name
+			if fn.Recv != nil && len(fn.Recv.List) > 0 {
+				name = receiverName(fn.Recv.List[0].Type) + "." + name
+			}
+			if literal {
+				name += " (func literal)"
+			}
+			return name
+		}
+	}
+	return ""
+}
+
+// receiverName renders a method receiver type as in (*T).M or T.M, without
+// type parameters
+func receiverName(expr ast.Expr) string {
+	switch t := expr.(type) {
+	case *ast.StarExpr:
+		return "(*" + receiverName(t.X) + ")"
+	case *ast.IndexExpr:
+		return receiverName(t.X)
+	case *ast.IndexListExpr:
+		return receiverName(t.X)
+	case *ast.Ident:
+		return t.Name
+	}
+	return "?"
+}
+
+// JSONResult is the JSON form of a Result
+type JSONResult struct {
+	JSONIssue
+	Description string `json:"description"`
+	Bytes       *int64 `json:"bytes,omitempty"`
+	Func        string `json:"func,omitempty"`
+	Fixes       int    `json:"fixes,omitempty"`
+}
+
+// JSON converts the result to its JSON form
+func (r Result) JSON() JSONResult {
+	result := JSONResult{
+		JSONIssue:   newJSONIssue(r.Issue),
+		Description: r.Description,
+		Func:        r.Func,
+		Fixes:       len(r.SuggestedFixes),
+	}
+	if r.Bytes >= 0 {
+		bytes := r.Bytes
+		result.Bytes = &bytes
+	}
+	return result
+}
+
+// Text renders the result as a file:line:col: message line followed by its
+// rule, size and function when known
+func (r Result) Text() string {
+	var details []string
+	if r.Rule != "" {
+		details = append(details, r.Rule)
+	}
+	details = append(details, r.Severity.String())
+	if r.Bytes >= 0 {
+		details = append(details, fmt.Sprintf("%d bytes", r.Bytes))
+	}
+	if r.Func != "" {
+		details = append(details, "in "+r.Func)
+	}
+	return fmt.Sprintf("%s: %s [%s]", r.Pos, r.Message, strings.Join(details, ", "))
+}
+
+// sarif converts the result to a SARIF result, with its size and function as
+// properties
+func (r Result) sarif() sarifResult {
+	result := newSARIFResult(r.Issue)
+	if result.Properties == nil {
+		result.Properties = map[string]interface{}{}
+	}
+	if r.Bytes >= 0 {
+		result.Properties["bytes"] = r.Bytes
+	}
+	if r.Func != "" {
+		result.Properties["func"] = r.Func
+	}
+	if len(result.Properties) == 0 {
+		result.Properties = nil
+	}
+	return result
+}
+
+// WriteResults writes results to w in one of the output formats: "text",
+// with one line per result, "json", as an array of JSONResult, "jsonl", with
+// one JSONResult per line, "sarif",
+// "github", as GitHub Actions workflow commands, or "csv", with one row per
+// result and its estimated size
+func WriteResults(w io.Writer, format string, results []Result) error {
+	switch format {
+	case "text":
+		for _, r := range results {
+			if _, err := fmt.Fprintln(w, r.Text()); err != nil {
+				return err
+			}
+		}
+		return nil
+	case "json":
+		out := make([]JSONResult, 0, len(results))
+		for _, r := range results {
+			out = append(out, r.JSON())
+		}
+		return json.NewEncoder(w).Encode(out)
+	case "jsonl":
+		enc := json.NewEncoder(w)
+		for _, r := range results {
+			if err := enc.Encode(r.JSON()); err != nil {
+				return err
+			}
+		}
+		return nil
+	case "sarif":
+		sink := NewSARIFSink(w)
+		for _, r := range results {
+			sink.results = append(sink.results, r.sarif())
+		}
+		return sink.Flush()
+	case "github":
+		sink := NewGitHubSink(w)
+		for _, r := range results {
+			sink.Report(r.Issue)
+		}
+		return sink.Flush()
+	case "csv":
+		sink := NewCSVSink(w)
+		for _, r := range results {
+			sink.write(r.Issue, r.Bytes)
+		}
+		return sink.Flush()
+	}
+	return fmt.Errorf("unknown output format %q (want text, json, jsonl, sarif, github or csv)", format)
+}
@@ -0,0 +1,245 @@
+package analyzer
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+const resultTestCode = `package test
+
+type Server struct{ buf []int }
+
+type point struct{ x, y int64 }
+
+func (s *Server) Handle() *point {
+	return new(point)
+}
+
+func parse(a []int, x, y int) []int {
+	return append(a, []int{x, y}...)
+}
+
+func later() func() *point {
+	return func() *point {
+		return &point{}
+	}
+}
+`
+
+// resultTestResults runs the analyzer over resultTestCode, collecting results
+func resultTestResults(t *testing.T) []Result {
+	t.Helper()
+	pass, _ := newTestPass(t, resultTestCode)
+	var results []Result
+	options := newAnalyzerOptions(WithResultReporter(func(r Result) {
+		results = append(results, r)
+	}))
+	if _, err := runWithDeps(pass, options); err != nil {
+		t.Fatalf("runWithDeps failed: %v", err)
+	}
+	return results
+}
+
+func TestResults(t *testing.T) {
+	results := resultTestResults(t)
+
+	find := func(line int, node string) Result {
+		t.Helper()
+		for _, r := range results {
+			if r.Pos.Line == line && r.Node == node {
+				return r
+			}
+		}
+		t.Fatalf("no %s result on line %d in %+v", node, line, results)
+		return Result{}
+	}
+
+	tests := []struct {
+		name        string
+		line        int
+		node        string
+		rule        string
+		category    string
+		description string
+		severity    Severity
+		bytes       int64
+		fixes       int
+		fn          string
+	}{
+		{"heuristic detector in method", 8, "CallExpr", "", "stackalloc", sarifRuleDescription, SeverityPossible, 16, 0, "(*Server).Handle"},
+		{"rule with fix", 12, "CallExpr", "append-spread-temp", "append-spread-temp", "append(a, b...) where b is a slice literal or make([]T, n, c) built only to be appended", SeverityPossible, -1, 1, "parse"},
+		{"slice literal size", 12, "CompositeLit", "", "stackalloc", sarifRuleDescription, SeverityPossible, 16, 0, "parse"},
+		{"func literal itself", 16, "FuncLit", "", "stackalloc", sarifRuleDescription, SeverityPossible, -1, 0, "later"},
+		{"inside func literal", 17, "UnaryExpr", "return-address-of-literal", "return-address-of-literal", "&T{...} returned or stored somewhere that outlives the function", SeverityLikely, 16, 0, "later (func literal)"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := find(tt.line, tt.node)
+			if r.Rule != tt.rule {
+				t.Errorf("Rule = %q, want %q", r.Rule, tt.rule)
+			}
+			if r.Category != tt.category {
+				t.Errorf("Category = %q, want %q", r.Category, tt.category)
+			}
+			if r.Description != tt.description {
+				t.Errorf("Description = %q, want %q", r.Description, tt.description)
+			}
+			if r.Severity != tt.severity {
+				t.Errorf("Severity = %v, want %v", r.Severity, tt.severity)
+			}
+			if r.Bytes != tt.bytes {
+				t.Errorf("Bytes = %d, want %d", r.Bytes, tt.bytes)
+			}
+			if len(r.SuggestedFixes) != tt.fixes {
+				t.Errorf("got %d fixes, want %d", len(r.SuggestedFixes), tt.fixes)
+			}
+			if r.Func != tt.fn {
+				t.Errorf("Func = %q, want %q", r.Func, tt.fn)
+			}
+		})
+	}
+}
+
+func TestAnalyzeSourceResults(t *testing.T) {
+	results, err := AnalyzeSourceResults("test.go", []byte(resultTestCode), nil)
+	if err != nil {
+		t.Fatalf("AnalyzeSourceResults failed: %v", err)
+	}
+	if len(results) == 0 {
+		t.Fatal("expected results")
+	}
+	for _, r := range results {
+		if r.Bytes != -1 {
+			t.Errorf("Bytes = %d without type information at %s, want -1", r.Bytes, r.Pos)
+		}
+		if r.Pos.Line == 8 && r.Func != "(*Server).Handle" {
+			t.Errorf("Func = %q at %s, want (*Server).Handle", r.Func, r.Pos)
+		}
+	}
+}
+
+func TestWriteResults(t *testing.T) {
+	results := []Result{
+		{
+			Issue:       Issue{Message: "sized", Pattern: "append-spread-temp", Severity: SeverityLikely},
+			Rule:        "append-spread-temp",
+			Category:    "append-spread-temp",
+			Description: "spread",
+			Bytes:       16,
+			Func:        "parse",
+		},
+		{
+			Issue:       Issue{Message: "unsized", Severity: SeverityPossible},
+			Category:    sarifRuleID,
+			Description: sarifRuleDescription,
+			Bytes:       -1,
+		},
+	}
+
+	t.Run("text", func(t *testing.T) {
+		var buf bytes.Buffer
+		if err := WriteResults(&buf, "text", results); err != nil {
+			t.Fatalf("WriteResults failed: %v", err)
+		}
+		lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+		if len(lines) != 2 {
+			t.Fatalf("got %d lines, want 2:\n%s", len(lines), buf.String())
+		}
+		if !strings.HasSuffix(lines[0], "sized [append-spread-temp, likely, 16 bytes, in parse]") {
+			t.Errorf("unexpected line %q", lines[0])
+		}
+		if !strings.HasSuffix(lines[1], "unsized [possible]") {
+			t.Errorf("unexpected line %q", lines[1])
+		}
+	})
+
+	t.Run("json", func(t *testing.T) {
+		var buf bytes.Buffer
+		if err := WriteResults(&buf, "json", results); err != nil {
+			t.Fatalf("WriteResults failed: %v", err)
+		}
+		var decoded []map[string]interface{}
+		if err := json.Unmarshal(buf.Bytes(), &decoded); err != nil {
+			t.Fatalf("invalid JSON: %v\n%s", err, buf.String())
+		}
+		if len(decoded) != 2 {
+			t.Fatalf("got %d results, want 2", len(decoded))
+		}
+		if decoded[0]["bytes"] != float64(16) || decoded[0]["func"] != "parse" || decoded[0]["description"] != "spread" {
+			t.Errorf("unexpected first result %v", decoded[0])
+		}
+		if _, ok := decoded[1]["bytes"]; ok {
+			t.Errorf("unknown size should be omitted, got %v", decoded[1])
+		}
+	})
+
+	t.Run("jsonl", func(t *testing.T) {
+		var buf bytes.Buffer
+		if err := WriteResults(&buf, "jsonl", results); err != nil {
+			t.Fatalf("WriteResults failed: %v", err)
+		}
+		lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+		if len(lines) != 2 {
+			t.Fatalf("got %d lines, want 2:\n%s", len(lines), buf.String())
+		}
+		var first map[string]interface{}
+		if err := json.Unmarshal([]byte(lines[0]), &first); err != nil {
+			t.Fatalf("invalid JSON line: %v\n%s", err, lines[0])
+		}
+		if first["bytes"] != float64(16) || first["func"] != "parse" {
+			t.Errorf("unexpected first result %v", first)
+		}
+	})
+
+	t.Run("sarif", func(t *testing.T) {
+		var buf bytes.Buffer
+		if err := WriteResults(&buf, "sarif", results); err != nil {
+			t.Fatalf("WriteResults failed: %v", err)
+		}
+		var log sarifLog
+		if err := json.Unmarshal(buf.Bytes(), &log); err != nil {
+			t.Fatalf("invalid SARIF: %v\n%s", err, buf.String())
+		}
+		got := log.Runs[0].Results
+		if len(got) != 2 {
+			t.Fatalf("got %d results, want 2", len(got))
+		}
+		if got[0].RuleID != "append-spread-temp" || got[0].Properties["bytes"] != float64(16) || got[0].Properties["func"] != "parse" {
+			t.Errorf("unexpected first result %+v", got[0])
+		}
+		if got[1].RuleID != sarifRuleID || got[1].Properties != nil {
+			t.Errorf("unexpected second result %+v", got[1])
+		}
+	})
+
+	t.Run("github", func(t *testing.T) {
+		var buf bytes.Buffer
+		if err := WriteResults(&buf, "github", results); err != nil {
+			t.Fatalf("WriteResults failed: %v", err)
+		}
+		lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+		if len(lines) != 2 || !strings.HasPrefix(lines[0], "::error ") || !strings.HasSuffix(lines[1], "title=stackalloc::unsized") {
+			t.Errorf("unexpected workflow commands:\n%s", buf.String())
+		}
+	})
+
+	t.Run("csv", func(t *testing.T) {
+		var buf bytes.Buffer
+		if err := WriteResults(&buf, "csv", results); err != nil {
+			t.Fatalf("WriteResults failed: %v", err)
+		}
+		expected := "file,line,column,rule,severity,message,estimated_bytes\n,0,0,append-spread-temp,likely,sized,16\n,0,0,,possible,unsized,\n"
+		if buf.String() != expected {
+			t.Errorf("Expected:\n%s\ngot:\n%s", expected, buf.String())
+		}
+	})
+
+	t.Run("unknown", func(t *testing.T) {
+		if err := WriteResults(&bytes.Buffer{}, "xml", results); err == nil {
+			t.Error("expected an error for an unknown format")
+		}
+	})
+}
@@ -0,0 +1,88 @@
+package analyzer
+
+import (
+	"bytes"
+	"go/ast"
+	"go/printer"
+	"go/token"
+	"strings"
+
+	"golang.org/x/tools/go/analysis"
+)
+
+// replaceStmts returns an edit replacing the statements from first through
+// last, which must be consecutive in one statement list, with stmts. Fixes
+// that restructure code build the new statements as syntax trees, reusing
+// nodes of the original where they like, rather than splicing source text.
+//
+// The statements are printed as gofmt lays them out and indented to match
+// first, assuming the file is indented with tabs. It fails if comments inside
+// the range would be lost, or if a multi-line raw string in stmts would be
+// changed by indenting it.
+func (pd *PatternDetector) replaceStmts(first, last ast.Stmt, stmts ...ast.Stmt) (analysis.TextEdit, bool) {
+	if file, ok := pd.stack[0].(*ast.File); ok {
+		for _, group := range file.Comments {
+			if group.Pos() < last.End() && group.End() > first.Pos() {
+				return analysis.TextEdit{}, false
+			}
+		}
+	}
+	pos := pd.fset.Position(first.Pos())
+	if !pos.IsValid() {
+		return analysis.TextEdit{}, false
+	}
+
+	text, ok := printStmts(stmts, strings.Repeat("\t", pos.Column-1))
+	if !ok {
+		return analysis.TextEdit{}, false
+	}
+	return analysis.TextEdit{Pos: first.Pos(), End: last.End(), NewText: text}, true
+}
+
+// printStmts prints stmts one per line, starting every line but the first
+// with indent. Positions in the nodes are ignored, so reused and newly built
+// nodes are laid out alike.
+func printStmts(stmts []ast.Stmt, indent string) ([]byte, bool) {
+	multiline := false
+	for _, stmt := range stmts {
+		ast.Inspect(stmt, func(n ast.Node) bool {
+			if lit, ok := n.(*ast.BasicLit); ok && lit.Kind == token.STRING && strings.Contains(lit.Value, "\n") {
+				multiline = true
+			}
+			return !multiline
+		})
+	}
+	if multiline {
+		return nil, false
+	}
+
+	config := &printer.Config{Mode: printer.UseSpaces | printer.TabIndent, Tabwidth: 8}
+	var buf bytes.Buffer
+	for i, stmt := range stmts {
+		var node bytes.Buffer
+		if err := config.Fprint(&node, token.NewFileSet(), stmt); err != nil {
+			return nil, false
+		}
+		if i > 0 {
+			buf.WriteString("\n" + indent)
+		}
+		buf.WriteString(strings.ReplaceAll(node.String(), "\n", "\n"+indent))
+	}
+	return buf.Bytes(), true
+}
+
+// inStmtList reports whether stmt is directly in a block or case clause, so
+// replaceStmts can turn it into several statements
+func (pd *PatternDetector) inStmtList(stmt ast.Stmt) bool {
+	for i := len(pd.stack) - 1; i > 0; i-- {
+		if pd.stack[i] != stmt {
+			continue
+		}
+		switch pd.stack[i-1].(type) {
+		case *ast.BlockStmt, *ast.CaseClause, *ast.CommClause:
+			return true
+		}
+		return false
+	}
+	return false
+}
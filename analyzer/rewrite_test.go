@@ -0,0 +1,161 @@
+package analyzer
+
+import (
+	"go/ast"
+	"go/token"
+	"testing"
+
+	"golang.org/x/tools/go/analysis"
+)
+
+func TestReplaceStmts(t *testing.T) {
+	code := `package main
+
+func fill(n int) []int {
+	if n > 0 {
+		s := make([]int, 3)
+		s[0] = n
+		s[1] = n * 2
+		return s
+	}
+	// nothing to fill
+	return nil
+}
+`
+	pass, _ := newTestPass(t, code)
+	file := pass.Files[0]
+	pd := NewPatternDetector(pass.TypesInfo, pass.Fset, DefaultConfig(), newUsageTracker())
+	pd.stack = []ast.Node{file}
+
+	body := file.Decls[0].(*ast.FuncDecl).Body
+	inner := body.List[0].(*ast.IfStmt).Body.List
+	n := inner[1].(*ast.AssignStmt).Rhs[0]
+	double := inner[2].(*ast.AssignStmt).Rhs[0]
+
+	// Replace make and the fills with a literal built from the original values,
+	// plus a statement spanning several lines
+	lit := &ast.AssignStmt{
+		Lhs: []ast.Expr{ast.NewIdent("s")},
+		Tok: token.DEFINE,
+		Rhs: []ast.Expr{&ast.CompositeLit{
+			Type: &ast.ArrayType{Elt: ast.NewIdent("int")},
+			Elts: []ast.Expr{n, double, &ast.BasicLit{Kind: token.INT, Value: "0"}},
+		}},
+	}
+	check := &ast.IfStmt{
+		Cond: &ast.BinaryExpr{X: ast.NewIdent("n"), Op: token.GTR, Y: &ast.BasicLit{Kind: token.INT, Value: "9"}},
+		Body: &ast.BlockStmt{List: []ast.Stmt{&ast.ExprStmt{X: &ast.CallExpr{Fun: ast.NewIdent("println"), Args: []ast.Expr{ast.NewIdent("n")}}}}},
+	}
+	edit, ok := pd.replaceStmts(inner[0], inner[2], lit, check)
+	if !ok {
+		t.Fatal("Expected the statements to be replaced")
+	}
+
+	filename := pass.Fset.Position(file.Pos()).Filename
+	fixed, err := applyFileEdits(pass.Fset, filename, []byte(code), []analysis.TextEdit{edit})
+	if err != nil {
+		t.Fatalf("Failed to apply edit: %v", err)
+	}
+	want := `package main
+
+func fill(n int) []int {
+	if n > 0 {
+		s := []int{n, n * 2, 0}
+		if n > 9 {
+			println(n)
+		}
+		return s
+	}
+	// nothing to fill
+	return nil
+}
+`
+	if string(fixed) != want {
+		t.Errorf("Expected:\n%s\ngot:\n%s", want, fixed)
+	}
+
+	// Comments in the range would be lost
+	if _, ok := pd.replaceStmts(body.List[0], body.List[1], lit); ok {
+		t.Error("Expected replacing statements around a comment to fail")
+	}
+
+	// Indenting a multi-line raw string would change its value
+	raw := &ast.ExprStmt{X: &ast.CallExpr{Fun: ast.NewIdent("println"), Args: []ast.Expr{&ast.BasicLit{Kind: token.STRING, Value: "`a\nb`"}}}}
+	if _, ok := pd.replaceStmts(inner[0], inner[0], raw); ok {
+		t.Error("Expected a multi-line raw string to be refused")
+	}
+}
+
+func TestNewValueTypeSplitFix(t *testing.T) {
+	code := `package main
+
+import "bytes"
+
+func render(names []string) string {
+	for _, name := range names {
+		n, buf := len(name), new(bytes.Buffer)
+		buf.Grow(n)
+		return buf.String()
+	}
+	var err error
+	err, out := nil, new(bytes.Buffer)
+	_ = err
+	return out.String()
+}
+
+func inHeader() {
+	if n, buf := 1, new(bytes.Buffer); n > 0 {
+		buf.Reset()
+	}
+}
+`
+	pass, _ := newTestPass(t, code)
+
+	var edits []analysis.TextEdit
+	var fixes int
+	for _, issue := range analyzeFile(pass.Files[0], pass.TypesInfo, pass.Fset, DefaultConfig()) {
+		if issue.Pattern == "new-value-type" {
+			for _, fix := range issue.SuggestedFixes {
+				edits = append(edits, fix.TextEdits...)
+				fixes++
+			}
+		}
+	}
+	// An if statement's header holds a single statement, so the last one can't be split
+	if fixes != 2 {
+		t.Fatalf("Expected 2 new-value-type fixes, got %d", fixes)
+	}
+
+	filename := pass.Fset.Position(pass.Files[0].Pos()).Filename
+	fixed, err := applyFileEdits(pass.Fset, filename, []byte(code), edits)
+	if err != nil {
+		t.Fatalf("Failed to apply fixes: %v", err)
+	}
+	want := `package main
+
+import "bytes"
+
+func render(names []string) string {
+	for _, name := range names {
+		n := len(name)
+		var buf bytes.Buffer
+		buf.Grow(n)
+		return buf.String()
+	}
+	var err error
+	err = nil
+	var out bytes.Buffer
+	_ = err
+	return out.String()
+}
+
+func inHeader() {
+	if n, buf := 1, new(bytes.Buffer); n > 0 {
+		buf.Reset()
+	}
+}
+`
+	if string(fixed) != want {
+		t.Errorf("Expected:\n%s\ngot:\n%s", want, fixed)
+	}
+}
@@ -0,0 +1,321 @@
+package analyzer
+
+// Rule describes a named detector that can be enabled or disabled by name
+type Rule struct {
+	Name           string   // Name used with -disable-patterns and -enable-patterns
+	Description    string   // One-line summary of what the rule detects
+	DefaultEnabled bool     // Whether the rule runs without being explicitly enabled
+	Severity       Severity // Severity of the issues the rule reports
+	Verified       bool     // Whether the allocations the rule reports are proven rather than guessed, so -strict keeps them
+}
+
+// rules is the registry of named detectors
+var rules = []Rule{
+	{
+		Name:           "slice-of-escaping-pointers",
+		Description:    "[]*T built in a loop from addresses of loop-local variables",
+		DefaultEnabled: true,
+		Severity:       SeverityLikely,
+		Verified:       true,
+	},
+	{
+		Name:           "chan-in-loop",
+		Description:    "make(chan T) inside a loop body or per-request HTTP handler",
+		DefaultEnabled: true,
+		Severity:       SeverityPossible,
+	},
+	{
+		Name:           "byte-append-loop",
+		Description:    "[]byte accumulated one byte at a time with append inside a loop",
+		DefaultEnabled: true,
+		Severity:       SeverityPossible,
+	},
+	{
+		Name:           "constructor-append",
+		Description:    "append to a nil or unsized slice inside a NewXxx or slice-returning function",
+		DefaultEnabled: true,
+		Severity:       SeverityPossible,
+	},
+	{
+		Name:           "append-spread-temp",
+		Description:    "append(a, b...) where b is a slice literal or make([]T, n, c) built only to be appended",
+		DefaultEnabled: true,
+		Severity:       SeverityPossible,
+	},
+	{
+		Name:           "append-copy-idiom",
+		Description:    "append([]T(nil), s...) copying s, where slices.Clone is clearer or the copy may not be needed",
+		DefaultEnabled: true,
+		Severity:       SeverityInfo,
+	},
+	{
+		Name:           "return-address-of-literal",
+		Description:    "&T{...} returned or stored somewhere that outlives the function",
+		DefaultEnabled: true,
+		Severity:       SeverityLikely,
+		Verified:       true,
+	},
+	{
+		Name:           "escaping-argument",
+		Description:    "&x passed to a parameter that escapes, according to the called function's facts, even across packages",
+		DefaultEnabled: true,
+		Severity:       SeverityLikely,
+		Verified:       true,
+	},
+	{
+		Name:           "new-value-type",
+		Description:    "new(T) for types such as bytes.Buffer and big.Int whose zero value is ready to use, so they can be declared with var",
+		DefaultEnabled: true,
+		Severity:       SeverityPossible,
+	},
+	{
+		Name:           "retained-argument",
+		Description:    "slice allocated with make or a literal passed to a parameter that the called function retains",
+		DefaultEnabled: true,
+		Severity:       SeverityLikely,
+		Verified:       true,
+	},
+	{
+		Name:           "interface-write-loop",
+		Description:    "concrete values written to elements of a []any or map[K]any inside a loop, boxing each one",
+		DefaultEnabled: true,
+		Severity:       SeverityPossible,
+	},
+	{
+		Name:           "type-assertion-in-loop",
+		Description:    "type assertion from interface{} to a concrete type inside a loop",
+		DefaultEnabled: true,
+		Severity:       SeverityLikely,
+	},
+	{
+		Name:           "sort-slice-closure",
+		Description:    "sort.Slice or sort.SliceStable called inside a loop or per-request HTTP handler",
+		DefaultEnabled: true,
+		Severity:       SeverityPossible,
+	},
+	{
+		Name:           "json-in-loop",
+		Description:    "json.Marshal, json.MarshalIndent or json.Unmarshal called inside a loop",
+		DefaultEnabled: true,
+		Severity:       SeverityPossible,
+	},
+	{
+		Name:           "repeat-in-loop",
+		Description:    "strings.Repeat or bytes.Repeat called inside a loop with loop-invariant arguments",
+		DefaultEnabled: true,
+		Severity:       SeverityPossible,
+	},
+	{
+		Name:           "builder-no-grow",
+		Description:    "strings.Builder or bytes.Buffer written to in a loop with a known iteration count and no Grow call",
+		DefaultEnabled: true,
+		Severity:       SeverityPossible,
+	},
+	{
+		Name:           "stringer-sprintf",
+		Description:    "fmt.Sprintf in a String() string method, which runs every time the value is printed",
+		DefaultEnabled: true,
+		Severity:       SeverityPossible,
+	},
+	{
+		Name:           "handler-body-alloc",
+		Description:    "io.ReadAll or bytes.NewBuffer in an HTTP handler, allocating a buffer per request",
+		DefaultEnabled: true,
+		Severity:       SeverityPossible,
+	},
+	{
+		Name:           "defer-log-args",
+		Description:    "deferred fmt or log call whose non-constant arguments are evaluated and boxed when the defer runs",
+		DefaultEnabled: true,
+		Severity:       SeverityPossible,
+	},
+	{
+		Name:           "format-then-convert",
+		Description:    "[]byte(fmt.Sprintf(...)) and similar conversions of a formatted string that allocate twice",
+		DefaultEnabled: true,
+		Severity:       SeverityPossible,
+	},
+	{
+		Name:           "composite-conversion",
+		Description:    "string and []byte or []rune conversions that copy; with -verbose, free conversions between identical underlying types",
+		DefaultEnabled: true,
+		Severity:       SeverityPossible,
+	},
+	{
+		Name:           "bool-set-map",
+		Description:    "map[K]bool only ever assigned true and read for membership, where map[K]struct{} would do",
+		DefaultEnabled: true,
+		Severity:       SeverityInfo,
+	},
+	{
+		Name:           "dynamic-container-boxing",
+		Description:    "local map or slice of interface elements that concrete values are written to in several places, reported once with the total",
+		DefaultEnabled: true,
+		Severity:       SeverityPossible,
+	},
+	{
+		Name:           "oversplit",
+		Description:    "strings.Split or bytes.Split when only the first few fields are used, where SplitN or Cut is cheaper",
+		DefaultEnabled: true,
+		Severity:       SeverityPossible,
+	},
+	{
+		Name:           "error-string-compare",
+		Description:    "err.Error() compared against a string or called in a loop, where errors.Is or errors.As would do",
+		DefaultEnabled: true,
+		Severity:       SeverityPossible,
+	},
+	{
+		Name:           "context-withvalue-chain",
+		Description:    "context.WithValue called more than once in a function, allocating a context per value",
+		DefaultEnabled: true,
+		Severity:       SeverityPossible,
+	},
+	{
+		Name:           "rand-source-in-loop",
+		Description:    "math/rand source or generator created inside a loop body",
+		DefaultEnabled: true,
+		Severity:       SeverityPossible,
+	},
+	{
+		Name:           "path-join-loop",
+		Description:    "filepath.Join or path.Join in a loop with loop-invariant leading arguments that could be joined once",
+		DefaultEnabled: true,
+		Severity:       SeverityPossible,
+	},
+	{
+		Name:           "append-boxing",
+		Description:    "concrete value appended to a slice of interfaces, boxing it on every call",
+		DefaultEnabled: true,
+		Severity:       SeverityPossible,
+	},
+	{
+		Name:           "make-copy-clone",
+		Description:    "slice cloned with make and copy, where slices.Clone is clearer or the copy may not be needed",
+		DefaultEnabled: true,
+		Severity:       SeverityInfo,
+	},
+	{
+		Name:           "getenv-in-loop",
+		Description:    "os.Getenv or os.LookupEnv with a constant key, and any parsing of it, repeated inside a loop",
+		DefaultEnabled: true,
+		Severity:       SeverityInfo,
+	},
+	{
+		Name:           "time-format-loop",
+		Description:    "time.Time.Format called inside a loop body, allocating a string per iteration",
+		DefaultEnabled: true,
+		Severity:       SeverityPossible,
+	},
+	{
+		Name:           "bufio-per-call",
+		Description:    "bufio.NewReader, NewWriter or NewScanner in a loop body or HTTP handler, allocating a buffer per call",
+		DefaultEnabled: true,
+		Severity:       SeverityPossible,
+	},
+	{
+		Name:           "large-array-copy",
+		Description:    "array larger than 256 bytes ranged over with a value variable or returned by value, copying it whole",
+		DefaultEnabled: true,
+		Severity:       SeverityInfo,
+	},
+	{
+		Name:           "field-append-loop",
+		Description:    "x.f = append(x.f, v) growing a struct's slice field inside a loop without pre-sizing it",
+		DefaultEnabled: true,
+		Severity:       SeverityPossible,
+	},
+	{
+		Name:           "typeswitch-in-loop",
+		Description:    "type switch on an interface{} value inside a loop, dispatching on values that were boxed",
+		DefaultEnabled: true,
+		Severity:       SeverityPossible,
+	},
+	{
+		Name:           "manual-grow",
+		Description:    "slice grown by hand with make of twice its size and copy, where append or slices.Grow does the growing",
+		DefaultEnabled: true,
+		Severity:       SeverityInfo,
+	},
+	{
+		Name:           "strconv-format-hotpath",
+		Description:    "strconv.FormatInt, FormatUint or FormatFloat in a loop or hot function, where the Append variant reuses a buffer",
+		DefaultEnabled: true,
+		Severity:       SeverityPossible,
+	},
+	{
+		Name:           "http-client-per-call",
+		Description:    "http.Get, Post or another http.DefaultClient request in a loop body or HTTP handler, instead of a shared configured client",
+		DefaultEnabled: true,
+		Severity:       SeverityPossible,
+	},
+	{
+		Name:           "repeated-key-sort",
+		Description:    "map keys collected and sorted on every iteration of an outer loop",
+		DefaultEnabled: false,
+		Severity:       SeverityPossible,
+	},
+	{
+		Name:           "stdlib-idioms",
+		Description:    "standard library calls with a cheaper idiomatic spelling, such as time.Since",
+		DefaultEnabled: false,
+		Severity:       SeverityInfo,
+	},
+	{
+		Name:           "chan-send-loop",
+		Description:    "values sent on a channel one at a time inside a loop, where sending batches may be cheaper",
+		DefaultEnabled: false,
+		Severity:       SeverityInfo,
+	},
+	{
+		Name:           "unconditional-errorf",
+		Description:    "fmt.Errorf evaluated on every path whose error is discarded, compared or only used on an error path",
+		DefaultEnabled: false,
+		Severity:       SeverityPossible,
+	},
+	{
+		Name:           "subslice-append-alias",
+		Description:    "append to a subslice a[i:j] that overwrites elements of a still used afterwards",
+		DefaultEnabled: false,
+		Severity:       SeverityPossible,
+	},
+	{
+		Name:           "interface-field-boxing",
+		Description:    "struct literal built in a loop or HTTP handler storing a concrete value in an interface-typed field",
+		DefaultEnabled: false,
+		Severity:       SeverityPossible,
+	},
+	{
+		Name:           "panic-alloc-arg",
+		Description:    "panic with a value built by fmt.Sprintf, fmt.Errorf or errors.New, noting panics used for control flow",
+		DefaultEnabled: true,
+		Severity:       SeverityInfo,
+	},
+	{
+		Name:           "getter-alloc",
+		Description:    "method without parameters returning a slice or map it builds on every call, such as a defensive copy",
+		DefaultEnabled: true,
+		Severity:       SeverityInfo,
+	},
+	{
+		Name:           "generic-instance-alloc",
+		Description:    "new(T) or make([]T, ...) of a type parameter instantiated with a type above -max-alloc-size, with -generic-instances",
+		DefaultEnabled: true,
+		Severity:       SeverityPossible,
+	},
+}
+
+// Rules returns all registered rules
+func Rules() []Rule {
+	return append([]Rule(nil), rules...)
+}
+
+// LookupRule returns the registered rule with the given name
+func LookupRule(name string) (Rule, bool) {
+	for _, rule := range rules {
+		if rule.Name == name {
+			return rule, true
+		}
+	}
+	return Rule{}, false
+}
@@ -0,0 +1,210 @@
+package analyzer
+
+import (
+	"fmt"
+	"hash/fnv"
+	"math"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Severity ranks how likely a finding is to cost a heap allocation
+type Severity int
+
+const (
+	SeverityInfo     Severity = iota // Situational or stylistic advice
+	SeverityPossible                 // Allocation depends on escape analysis or usage
+	SeverityLikely                   // Allocation is all but guaranteed
+)
+
+var severityNames = []string{"info", "possible", "likely"}
+
+// String returns the lower-case name of the severity
+func (s Severity) String() string {
+	if s < 0 || int(s) >= len(severityNames) {
+		return fmt.Sprintf("Severity(%d)", int(s))
+	}
+	return severityNames[s]
+}
+
+// Set parses a severity name, so a Severity can be used as a flag.Value
+func (s *Severity) Set(value string) error {
+	severity, err := ParseSeverity(value)
+	if err != nil {
+		return err
+	}
+	*s = severity
+	return nil
+}
+
+// MarshalText encodes the severity as its name
+func (s Severity) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// UnmarshalText decodes a severity name
+func (s *Severity) UnmarshalText(text []byte) error {
+	return s.Set(string(text))
+}
+
+// ParseSeverity parses a severity name, ignoring case
+func ParseSeverity(name string) (Severity, error) {
+	for i, severityName := range severityNames {
+		if strings.EqualFold(strings.TrimSpace(name), severityName) {
+			return Severity(i), nil
+		}
+	}
+	return 0, fmt.Errorf("unknown severity %q (want one of %s)", name, strings.Join(severityNames, ", "))
+}
+
+// ruleSeverity returns the severity of issues reported by the named rule,
+// taken from overrides if it's listed there. Issues not attributed to a
+// registered rule are SeverityPossible.
+func ruleSeverity(name string, overrides map[string]Severity) Severity {
+	if severity, ok := overrides[name]; ok {
+		return severity
+	}
+	if rule, ok := LookupRule(name); ok {
+		return rule.Severity
+	}
+	return SeverityPossible
+}
+
+// ruleSeverities is a flag.Value for Config.RuleSeverities, parsing a
+// comma-separated list of rule=severity pairs such as
+// new-value-type=info,chan-in-loop=likely. Rule names are checked by
+// Config.ValidatePatterns, since the map can also be set directly.
+type ruleSeverities struct {
+	m *map[string]Severity
+}
+
+// String returns the pairs sorted by rule name
+func (r ruleSeverities) String() string {
+	if r.m == nil {
+		return ""
+	}
+	pairs := make([]string, 0, len(*r.m))
+	for name, severity := range *r.m {
+		pairs = append(pairs, name+"="+severity.String())
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
+
+// Set parses the pairs, replacing any set before
+func (r ruleSeverities) Set(value string) error {
+	severities := make(map[string]Severity)
+	for _, pair := range strings.Split(value, ",") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+		name, level, ok := strings.Cut(pair, "=")
+		if !ok {
+			return fmt.Errorf("invalid rule severity %q (want rule=severity)", pair)
+		}
+		severity, err := ParseSeverity(level)
+		if err != nil {
+			return err
+		}
+		severities[strings.TrimSpace(name)] = severity
+	}
+	*r.m = severities
+	return nil
+}
+
+// ruleVerified reports whether the named rule only reports allocations it has
+// proven, such as an address that is returned or stored in an escaping
+// parameter. Issues not attributed to a registered rule come from heuristics.
+func ruleVerified(name string) bool {
+	rule, ok := LookupRule(name)
+	return ok && rule.Verified
+}
+
+// classifyIssues splits issues into those that fail the run and those that are
+// only printed as warnings; issues below WarnSeverity are dropped. When no more
+// than MaxIssues issues reach ErrorSeverity they are downgraded to warnings.
+func classifyIssues(issues []Issue, config *Config) (errors, warnings []Issue) {
+	for _, issue := range issues {
+		switch {
+		case issue.Severity >= config.ErrorSeverity:
+			errors = append(errors, issue)
+		case issue.Severity >= config.WarnSeverity:
+			warnings = append(warnings, issue)
+		}
+	}
+
+	if len(errors) > 0 && len(errors) <= config.MaxIssues {
+		warnings = append(warnings, errors...)
+		errors = nil
+	}
+
+	return errors, warnings
+}
+
+// reportedIssues returns the issues that classifyIssues doesn't drop, in order
+func reportedIssues(issues []Issue, config *Config) []Issue {
+	var reported []Issue
+	for _, issue := range issues {
+		if issue.Severity >= config.ErrorSeverity || issue.Severity >= config.WarnSeverity {
+			reported = append(reported, issue)
+		}
+	}
+	return reported
+}
+
+// capFileIssues limits the issues reported for a single file to
+// -max-issues-per-file, replacing the rest with one note saying how many were
+// suppressed. The note takes the highest severity among them, so capping never
+// turns a failing run into a passing one.
+func capFileIssues(issues []Issue, config *Config) []Issue {
+	if config.MaxIssuesPerFile <= 0 {
+		return issues
+	}
+	issues = reportedIssues(issues, config)
+	if len(issues) <= config.MaxIssuesPerFile {
+		return issues
+	}
+
+	suppressed := issues[config.MaxIssuesPerFile:]
+	note := Issue{
+		Pos:     suppressed[0].Pos,
+		Node:    "File",
+		Message: fmt.Sprintf("too many issues in this file (+%d more suppressed)", len(suppressed)),
+	}
+	for _, issue := range suppressed {
+		if issue.Severity > note.Severity {
+			note.Severity = issue.Severity
+		}
+	}
+	return append(issues[:config.MaxIssuesPerFile:config.MaxIssuesPerFile], note)
+}
+
+// sampleIssues keeps the fraction -sample-rate of issues, choosing each one by
+// a hash of its file name, position and rule so the same findings are kept on
+// every run and every checkout. Only the base name of the file is hashed, so
+// the sample doesn't depend on where the code is checked out.
+func sampleIssues(issues []Issue, config *Config) []Issue {
+	if config.SampleRate <= 0 || config.SampleRate >= 1 {
+		return issues
+	}
+
+	var sampled []Issue
+	for _, issue := range issues {
+		h := fnv.New64a()
+		fmt.Fprintf(h, "%s:%d:%d:%s", filepath.Base(issue.Pos.Filename), issue.Pos.Line, issue.Pos.Column, issue.Pattern)
+		if float64(h.Sum64())/math.MaxUint64 < config.SampleRate {
+			sampled = append(sampled, issue)
+		}
+	}
+	return sampled
+}
+
+// ExitCode returns the exit status the severity policy in config assigns to
+// issues: 1 if any of them fail the run, 0 otherwise
+func ExitCode(issues []Issue, config *Config) int {
+	if errors, _ := classifyIssues(issues, config); len(errors) > 0 {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,359 @@
+package analyzer
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"go/token"
+	"io"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseSeverity(t *testing.T) {
+	tests := []struct {
+		name     string
+		expected Severity
+		wantErr  bool
+	}{
+		{"info", SeverityInfo, false},
+		{"Possible", SeverityPossible, false},
+		{" LIKELY ", SeverityLikely, false},
+		{"critical", 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseSeverity(tt.name)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Expected error %v, got %v", tt.wantErr, err)
+			}
+			if got != tt.expected {
+				t.Errorf("Expected %s, got %s", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestSeverityFlags(t *testing.T) {
+	config := DefaultConfig()
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	config.SetupFlags(fs)
+
+	if err := fs.Parse([]string{"-error-severity=likely", "-warn-severity=possible", "-max-issues=3"}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	config.ParseFlags(fs)
+
+	if config.ErrorSeverity != SeverityLikely || config.WarnSeverity != SeverityPossible || config.MaxIssues != 3 {
+		t.Errorf("Expected likely/possible/3, got %s/%s/%d", config.ErrorSeverity, config.WarnSeverity, config.MaxIssues)
+	}
+
+	if err := fs.Parse([]string{"-error-severity=critical"}); err == nil {
+		t.Error("Expected an unknown severity to be rejected")
+	}
+}
+
+func TestClassifyIssuesThresholds(t *testing.T) {
+	issues := []Issue{
+		{Message: "info", Severity: SeverityInfo},
+		{Message: "possible", Severity: SeverityPossible},
+		{Message: "likely", Severity: SeverityLikely},
+	}
+
+	tests := []struct {
+		name          string
+		errorSeverity Severity
+		warnSeverity  Severity
+		maxIssues     int
+		errors        int
+		warnings      int
+		exitCode      int
+	}{
+		{"default fails on everything", SeverityInfo, SeverityInfo, 0, 3, 0, 1},
+		{"fail on possible", SeverityPossible, SeverityInfo, 0, 2, 1, 1},
+		{"fail only on likely", SeverityLikely, SeverityInfo, 0, 1, 2, 1},
+		{"fail on likely, warn on possible", SeverityLikely, SeverityPossible, 0, 1, 1, 1},
+		{"warn only", SeverityLikely + 1, SeverityInfo, 0, 0, 3, 0},
+		{"max-issues tolerates errors", SeverityLikely, SeverityInfo, 1, 0, 3, 0},
+		{"max-issues exceeded", SeverityPossible, SeverityInfo, 1, 2, 1, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := DefaultConfig()
+			config.ErrorSeverity = tt.errorSeverity
+			config.WarnSeverity = tt.warnSeverity
+			config.MaxIssues = tt.maxIssues
+
+			errors, warnings := classifyIssues(issues, config)
+			if len(errors) != tt.errors || len(warnings) != tt.warnings {
+				t.Errorf("Expected %d errors and %d warnings, got %d and %d", tt.errors, tt.warnings, len(errors), len(warnings))
+			}
+			if got := ExitCode(issues, config); got != tt.exitCode {
+				t.Errorf("Expected exit code %d, got %d", tt.exitCode, got)
+			}
+		})
+	}
+}
+
+func TestRuleSeverity(t *testing.T) {
+	code := `package main
+
+func main() {
+	var ptrs []*int
+	for i := 0; i < 3; i++ {
+		v := i
+		ptrs = append(ptrs, &v)
+	}
+	_ = ptrs
+	s := new(string)
+	_ = s
+}
+`
+	pass, _ := newTestPass(t, code)
+	issues := analyzeFile(pass.Files[0], pass.TypesInfo, pass.Fset, DefaultConfig())
+
+	var sawRule, sawLegacy bool
+	for _, issue := range issues {
+		switch issue.Pattern {
+		case "slice-of-escaping-pointers":
+			sawRule = true
+			if issue.Severity != SeverityLikely {
+				t.Errorf("Expected rule severity likely, got %s", issue.Severity)
+			}
+		case "":
+			sawLegacy = true
+			if issue.Severity != SeverityPossible {
+				t.Errorf("Expected unnamed issues to be possible, got %s", issue.Severity)
+			}
+		}
+	}
+	if !sawRule || !sawLegacy {
+		t.Errorf("Expected both rule and unnamed issues, got %+v", issues)
+	}
+}
+
+func TestRuleSeverityOverrides(t *testing.T) {
+	config := DefaultConfig()
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	config.SetupFlags(fs)
+
+	if err := fs.Parse([]string{"-rule-severity=slice-of-escaping-pointers=info, new-value-type=likely"}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	config.ParseFlags(fs)
+	if err := config.ValidatePatterns(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	code := `package main
+
+import "bytes"
+
+func main() {
+	var ptrs []*int
+	for i := 0; i < 3; i++ {
+		v := i
+		ptrs = append(ptrs, &v)
+	}
+	_ = ptrs
+	buf := new(bytes.Buffer)
+	buf.Reset()
+	s := new(string)
+	_ = s
+}
+`
+	pass, _ := newTestPass(t, code)
+	want := map[string]Severity{
+		"slice-of-escaping-pointers": SeverityInfo,
+		"new-value-type":             SeverityLikely,
+		"":                           SeverityPossible,
+	}
+	seen := make(map[string]bool)
+	for _, issue := range analyzeFile(pass.Files[0], pass.TypesInfo, pass.Fset, config) {
+		if severity, ok := want[issue.Pattern]; ok {
+			seen[issue.Pattern] = true
+			if issue.Severity != severity {
+				t.Errorf("Expected %q issues to be %s, got %s", issue.Pattern, severity, issue.Severity)
+			}
+		}
+	}
+	if len(seen) != len(want) {
+		t.Errorf("Expected issues of %d rules, saw %v", len(want), seen)
+	}
+
+	if got := fs.Lookup("rule-severity").Value.String(); got != "new-value-type=likely,slice-of-escaping-pointers=info" {
+		t.Errorf("Expected the overrides sorted by rule, got %q", got)
+	}
+	for _, arg := range []string{"-rule-severity=new-value-type", "-rule-severity=new-value-type=critical"} {
+		if err := fs.Parse([]string{arg}); err == nil {
+			t.Errorf("Expected %s to be rejected", arg)
+		}
+	}
+
+	config.RuleSeverities = map[string]Severity{"no-such-rule": SeverityInfo}
+	if err := config.ValidatePatterns(); err == nil || !strings.Contains(err.Error(), `unknown rule "no-such-rule" in -rule-severity`) {
+		t.Errorf("Expected an unknown rule to be rejected, got %v", err)
+	}
+}
+
+func TestSeverityWarningsAreNotDiagnostics(t *testing.T) {
+	config := DefaultConfig()
+	config.ErrorSeverity = SeverityLikely
+
+	var warnings bytes.Buffer
+	options := newAnalyzerOptions(WithConfig(config))
+	options.warnings = &warnings
+
+	pass, diagnostics := newTestPass(t, optionsTestCode)
+	if _, err := runWithDeps(pass, options); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if len(*diagnostics) != 0 {
+		t.Errorf("Expected no diagnostics below the error severity, got %d", len(*diagnostics))
+	}
+	if !strings.Contains(warnings.String(), ": warning: ") {
+		t.Errorf("Expected issues to be printed as warnings, got %q", warnings.String())
+	}
+}
+
+func TestMaxIssuesPerFile(t *testing.T) {
+	code := `package main
+
+func main() {
+	a := new(string)
+	b := new(string)
+	c := new(string)
+	d := new(string)
+	e := new(string)
+	_, _, _, _, _ = a, b, c, d, e
+}
+`
+	pass, diagnostics := newTestPass(t, code)
+	total := len(analyzeFile(pass.Files[0], pass.TypesInfo, pass.Fset, DefaultConfig()))
+	if total <= 2 {
+		t.Fatalf("Expected the file to produce more than 2 issues, got %d", total)
+	}
+
+	config := DefaultConfig()
+	config.MaxIssuesPerFile = 2
+	var reported []Issue
+	options := newAnalyzerOptions(WithConfig(config), WithReporter(func(issue Issue) {
+		reported = append(reported, issue)
+	}))
+	if _, err := runWithDeps(pass, options); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if len(reported) != 3 || len(*diagnostics) != 3 {
+		t.Fatalf("Expected 2 issues and a suppression note, got %d issues and %d diagnostics: %v", len(reported), len(*diagnostics), reported)
+	}
+	note := reported[2]
+	want := fmt.Sprintf("(+%d more suppressed)", total-2)
+	if !strings.Contains(note.Message, want) || note.Pos.Line != reported[1].Pos.Line+1 {
+		t.Errorf("Expected a note containing %q at the first suppressed issue, got %q at line %d", want, note.Message, note.Pos.Line)
+	}
+	if note.Severity != SeverityPossible {
+		t.Errorf("Expected the note to keep the suppressed issues' severity, got %s", note.Severity)
+	}
+}
+
+func TestSampleIssues(t *testing.T) {
+	var issues []Issue
+	for line := 1; line <= 2000; line++ {
+		issues = append(issues, Issue{
+			Pos:     token.Position{Filename: "/src/app/main.go", Line: line, Column: 2},
+			Pattern: "new-allocation",
+		})
+	}
+
+	config := DefaultConfig()
+	config.SampleRate = 0.1
+	first := sampleIssues(issues, config)
+	if len(first) < 140 || len(first) > 260 {
+		t.Errorf("Expected about 200 of 2000 issues at rate 0.1, got %d", len(first))
+	}
+	if second := sampleIssues(issues, config); !reflect.DeepEqual(first, second) {
+		t.Errorf("Expected sampling to be deterministic, got %d then %d issues", len(first), len(second))
+	}
+
+	moved := make([]Issue, len(issues))
+	for i, issue := range issues {
+		issue.Pos.Filename = "/home/ci/checkout/main.go"
+		moved[i] = issue
+	}
+	movedSample := sampleIssues(moved, config)
+	if len(movedSample) != len(first) {
+		t.Fatalf("Expected the sample not to depend on the checkout directory, got %d issues instead of %d", len(movedSample), len(first))
+	}
+	for i, issue := range movedSample {
+		if issue.Pos.Line != first[i].Pos.Line {
+			t.Fatalf("Expected the sample not to depend on the checkout directory, got line %d for %d", issue.Pos.Line, first[i].Pos.Line)
+		}
+	}
+
+	for _, rate := range []float64{0, 1} {
+		config.SampleRate = rate
+		if got := len(sampleIssues(issues, config)); got != len(issues) {
+			t.Errorf("Expected rate %g to keep all %d issues, got %d", rate, len(issues), got)
+		}
+	}
+
+	config.SampleRate = 1.5
+	if err := config.ValidatePatterns(); err == nil || !strings.Contains(err.Error(), "-sample-rate") {
+		t.Errorf("Expected an error for -sample-rate=1.5, got %v", err)
+	}
+}
+
+func TestStrictMode(t *testing.T) {
+	code, err := os.ReadFile("../examples/allocation_patterns_demo.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	pass, _ := newTestPass(t, string(code))
+
+	// By default the heuristic findings, such as new(T) and interface boxing,
+	// are reported alongside the proven one
+	all := analyzeFile(pass.Files[0], pass.TypesInfo, pass.Fset, DefaultConfig())
+	heuristic := 0
+	for _, issue := range all {
+		if !ruleVerified(issue.Pattern) {
+			heuristic++
+		}
+	}
+	if heuristic == 0 {
+		t.Fatalf("Expected heuristic findings in the demo by default, got %v", all)
+	}
+
+	config := DefaultConfig()
+	config.Strict = true
+	strict := analyzeFile(pass.Files[0], pass.TypesInfo, pass.Fset, config)
+	if len(strict) != 1 || strict[0].Pattern != "return-address-of-literal" {
+		t.Fatalf("Expected only the returned address in NewPoint with -strict, got %v", strict)
+	}
+	if line := splitLines(code)[strict[0].Pos.Line-1]; !strings.Contains(line, "return &Point{") {
+		t.Errorf("Expected the -strict finding on the return in NewPoint, got line %q", line)
+	}
+}
+
+func TestVerifiedRules(t *testing.T) {
+	var verified []string
+	for _, rule := range Rules() {
+		if rule.Verified {
+			verified = append(verified, rule.Name)
+		}
+	}
+	want := []string{"slice-of-escaping-pointers", "return-address-of-literal", "escaping-argument", "retained-argument"}
+	if strings.Join(verified, ",") != strings.Join(want, ",") {
+		t.Errorf("Expected verified rules %v, got %v", want, verified)
+	}
+	if ruleVerified("") {
+		t.Error("Expected issues without a rule to be unverified")
+	}
+}
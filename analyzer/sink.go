@@ -0,0 +1,516 @@
+package analyzer
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// IssueSink receives the issues reported during a run, for routing findings to
+// files, chat, issue trackers or anything else. Report is called once per
+// issue and Flush once after each package has been analyzed.
+type IssueSink interface {
+	Report(issue Issue)
+	Flush() error
+}
+
+// NewFormatSink returns the built-in sink for an output format: "text",
+// "json", "jsonl", "sarif", "github" or "csv"
+func NewFormatSink(format string, w io.Writer) (IssueSink, error) {
+	switch format {
+	case "text":
+		return NewTextSink(w), nil
+	case "json":
+		return NewJSONSink(w), nil
+	case "jsonl":
+		return NewJSONLinesSink(w), nil
+	case "sarif":
+		return NewSARIFSink(w), nil
+	case "github":
+		return NewGitHubSink(w), nil
+	case "csv":
+		return NewCSVSink(w), nil
+	}
+	return nil, fmt.Errorf("unknown output format %q (want text, json, jsonl, sarif, github or csv)", format)
+}
+
+// TextSink writes one file:line:col: message line per issue, like go vet
+type TextSink struct {
+	w   io.Writer
+	err error
+}
+
+// NewTextSink creates a sink writing plain text to w
+func NewTextSink(w io.Writer) *TextSink {
+	return &TextSink{w: w}
+}
+
+// Report writes the issue
+func (s *TextSink) Report(issue Issue) {
+	if s.err == nil {
+		_, s.err = fmt.Fprintf(s.w, "%s: %s\n", issue.Pos, issue.Message)
+	}
+}
+
+// Flush returns the first write error, if any
+func (s *TextSink) Flush() error {
+	err := s.err
+	s.err = nil
+	return err
+}
+
+// GitHubSink writes one GitHub Actions workflow command per issue, such as
+// ::warning file=a.go,line=3,col=2::message, which Actions shows as an
+// annotation on the pull request
+type GitHubSink struct {
+	w    io.Writer
+	root string // workspace that file paths are made relative to, if set
+	err  error
+}
+
+// NewGitHubSink creates a sink writing workflow commands to w. File paths are
+// made relative to $GITHUB_WORKSPACE, as annotations expect.
+func NewGitHubSink(w io.Writer) *GitHubSink {
+	return &GitHubSink{w: w, root: os.Getenv("GITHUB_WORKSPACE")}
+}
+
+// Report writes the issue
+func (s *GitHubSink) Report(issue Issue) {
+	if s.err == nil {
+		_, s.err = fmt.Fprintln(s.w, githubCommand(issue, s.root))
+	}
+}
+
+// Flush returns the first write error, if any
+func (s *GitHubSink) Flush() error {
+	err := s.err
+	s.err = nil
+	return err
+}
+
+// githubCommand formats issue as a workflow command, with its file relative
+// to root if it is inside it
+func githubCommand(issue Issue, root string) string {
+	file := issue.Pos.Filename
+	if root != "" {
+		if rel, err := filepath.Rel(root, file); err == nil && !strings.HasPrefix(rel, "..") {
+			file = filepath.ToSlash(rel)
+		}
+	}
+	title := issue.Pattern
+	if title == "" {
+		title = sarifRuleID
+	}
+
+	props := []string{"file=" + githubEscapeProperty(file), fmt.Sprintf("line=%d", issue.Pos.Line)}
+	if issue.Pos.Column > 0 {
+		props = append(props, fmt.Sprintf("col=%d", issue.Pos.Column))
+	}
+	if issue.End.Line > 0 {
+		props = append(props, fmt.Sprintf("endLine=%d", issue.End.Line))
+		if issue.End.Line == issue.Pos.Line && issue.End.Column > 0 {
+			props = append(props, fmt.Sprintf("endColumn=%d", issue.End.Column))
+		}
+	}
+	props = append(props, "title="+githubEscapeProperty(title))
+
+	return fmt.Sprintf("::%s %s::%s", githubLevel(issue.Severity), strings.Join(props, ","), githubEscapeData(issue.Message))
+}
+
+// githubLevel maps a severity to a workflow command
+func githubLevel(severity Severity) string {
+	switch severity {
+	case SeverityLikely:
+		return "error"
+	case SeverityPossible:
+		return "warning"
+	}
+	return "notice"
+}
+
+// githubEscapeData escapes the message of a workflow command
+func githubEscapeData(s string) string {
+	return strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A").Replace(s)
+}
+
+// githubEscapeProperty escapes a property value of a workflow command
+func githubEscapeProperty(s string) string {
+	return strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A", ":", "%3A", ",", "%2C").Replace(s)
+}
+
+// csvHeader names the columns of CSV output
+var csvHeader = []string{"file", "line", "column", "rule", "severity", "message", "estimated_bytes"}
+
+// CSVSink writes a header row followed by one row per issue, for triaging
+// findings in a spreadsheet. Issues carry no size, so estimated_bytes is left
+// empty; WriteResults fills it in from each Result.
+type CSVSink struct {
+	mu     sync.Mutex
+	w      *csv.Writer
+	header bool // the header row has been written
+}
+
+// NewCSVSink creates a sink writing CSV to w
+func NewCSVSink(w io.Writer) *CSVSink {
+	return &CSVSink{w: csv.NewWriter(w)}
+}
+
+// Report writes the issue's row
+func (s *CSVSink) Report(issue Issue) {
+	s.write(issue, -1)
+}
+
+// write writes the row for issue, with bytes as its estimated size unless it
+// is negative
+func (s *CSVSink) write(issue Issue, bytes int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.writeHeader()
+	size := ""
+	if bytes >= 0 {
+		size = strconv.FormatInt(bytes, 10)
+	}
+	s.w.Write([]string{
+		issue.Pos.Filename,
+		strconv.Itoa(issue.Pos.Line),
+		strconv.Itoa(issue.Pos.Column),
+		issue.Pattern,
+		issue.Severity.String(),
+		issue.Message,
+		size,
+	})
+}
+
+// writeHeader writes the header row unless it has been written already
+func (s *CSVSink) writeHeader() {
+	if !s.header {
+		s.w.Write(csvHeader)
+		s.header = true
+	}
+}
+
+// Flush writes the header if no issue has been reported, so that an empty
+// report still names its columns, and returns the first write error, if any
+func (s *CSVSink) Flush() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.writeHeader()
+	s.w.Flush()
+	return s.w.Error()
+}
+
+// JSONIssue is the JSON form of an Issue
+type JSONIssue struct {
+	File      string       `json:"file"`
+	Line      int          `json:"line"`
+	Column    int          `json:"column"`
+	EndLine   int          `json:"endLine,omitempty"`
+	EndColumn int          `json:"endColumn,omitempty"`
+	Node      string       `json:"node,omitempty"`
+	Message   string       `json:"message"`
+	Rule      string       `json:"rule,omitempty"`
+	Severity  Severity     `json:"severity"`
+	Source    *IssueSource `json:"source,omitempty"`
+	Fixes     []IssueFix   `json:"fixes,omitempty"`
+	Tags      []string     `json:"tags,omitempty"`
+}
+
+// newJSONIssue converts issue to its JSON form
+func newJSONIssue(issue Issue) JSONIssue {
+	return JSONIssue{
+		File:      issue.Pos.Filename,
+		Line:      issue.Pos.Line,
+		Column:    issue.Pos.Column,
+		EndLine:   issue.End.Line,
+		EndColumn: issue.End.Column,
+		Node:      issue.Node,
+		Message:   issue.Message,
+		Rule:      issue.Pattern,
+		Severity:  issue.Severity,
+		Source:    issue.Source,
+		Fixes:     issue.Fixes,
+		Tags:      issue.Tags,
+	}
+}
+
+// JSONSink collects issues and writes them as a JSON array of JSONIssue on Flush
+type JSONSink struct {
+	mu     sync.Mutex
+	w      io.Writer
+	issues []JSONIssue
+}
+
+// NewJSONSink creates a sink writing JSON to w
+func NewJSONSink(w io.Writer) *JSONSink {
+	return &JSONSink{w: w}
+}
+
+// Report collects the issue
+func (s *JSONSink) Report(issue Issue) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.issues = append(s.issues, newJSONIssue(issue))
+}
+
+// Flush writes the collected issues and resets the sink
+func (s *JSONSink) Flush() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	issues := s.issues
+	if issues == nil {
+		issues = []JSONIssue{}
+	}
+	s.issues = nil
+
+	return json.NewEncoder(s.w).Encode(issues)
+}
+
+// JSONLinesSink writes each issue as a JSONIssue on a line of its own as it is
+// reported. Unlike the array JSONSink writes, the output of several packages,
+// such as the reports go vet concatenates, is still valid JSON Lines.
+type JSONLinesSink struct {
+	mu  sync.Mutex
+	enc *json.Encoder
+	err error
+}
+
+// NewJSONLinesSink creates a sink writing JSON Lines to w
+func NewJSONLinesSink(w io.Writer) *JSONLinesSink {
+	return &JSONLinesSink{enc: json.NewEncoder(w)}
+}
+
+// Report writes the issue's line
+func (s *JSONLinesSink) Report(issue Issue) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.err == nil {
+		s.err = s.enc.Encode(newJSONIssue(issue))
+	}
+}
+
+// Flush returns the first write error, if any
+func (s *JSONLinesSink) Flush() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	err := s.err
+	s.err = nil
+	return err
+}
+
+// SARIFSink collects issues and writes them as a SARIF 2.1.0 log on Flush
+type SARIFSink struct {
+	mu      sync.Mutex
+	w       io.Writer
+	results []sarifResult
+}
+
+// NewSARIFSink creates a sink writing SARIF to w
+func NewSARIFSink(w io.Writer) *SARIFSink {
+	return &SARIFSink{w: w}
+}
+
+type sarifLog struct {
+	Version string     `json:"version"`
+	Schema  string     `json:"$schema"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool    sarifTool     `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name           string      `json:"name"`
+	Version        string      `json:"version"`
+	InformationURI string      `json:"informationUri"`
+	Rules          []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID               string       `json:"id"`
+	ShortDescription sarifMessage `json:"shortDescription"`
+}
+
+type sarifResult struct {
+	RuleID    string          `json:"ruleId"`
+	Level     string          `json:"level"`
+	Message   sarifMessage    `json:"message"`
+	Locations []sarifLocation `json:"locations"`
+
+	Properties map[string]interface{} `json:"properties,omitempty"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+	Region           sarifRegion           `json:"region"`
+	ContextRegion    *sarifRegion          `json:"contextRegion,omitempty"`
+}
+
+type sarifArtifactLocation struct {
+	URI string `json:"uri"`
+}
+
+type sarifRegion struct {
+	StartLine   int `json:"startLine"`
+	StartColumn int `json:"startColumn,omitempty"`
+	EndLine     int `json:"endLine,omitempty"`
+	EndColumn   int `json:"endColumn,omitempty"`
+
+	Snippet *sarifMessage `json:"snippet,omitempty"`
+}
+
+// sarifRuleID is the rule ID of issues that aren't attributed to a named rule,
+// and sarifRuleDescription its description
+const (
+	sarifRuleID          = "stackalloc"
+	sarifRuleDescription = "heap allocation that could be avoided"
+)
+
+// Report collects the issue
+func (s *SARIFSink) Report(issue Issue) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.results = append(s.results, newSARIFResult(issue))
+}
+
+// newSARIFResult converts issue to a SARIF result
+func newSARIFResult(issue Issue) sarifResult {
+	ruleID := issue.Pattern
+	if ruleID == "" {
+		ruleID = sarifRuleID
+	}
+
+	location := sarifPhysicalLocation{
+		ArtifactLocation: sarifArtifactLocation{URI: issue.Pos.Filename},
+		Region: sarifRegion{
+			StartLine:   issue.Pos.Line,
+			StartColumn: issue.Pos.Column,
+			EndLine:     issue.End.Line,
+			EndColumn:   issue.End.Column,
+		},
+	}
+	// -include-source embeds the surrounding lines as the context region
+	if issue.Source != nil {
+		location.ContextRegion = &sarifRegion{
+			StartLine: issue.Source.StartLine,
+			EndLine:   issue.Source.StartLine + strings.Count(issue.Source.Context, "\n"),
+			Snippet:   &sarifMessage{Text: issue.Source.Context},
+		}
+	}
+
+	result := sarifResult{
+		RuleID:    ruleID,
+		Level:     sarifLevel(issue.Severity),
+		Message:   sarifMessage{Text: issue.Message},
+		Locations: []sarifLocation{{PhysicalLocation: location}},
+	}
+	// -path-tags go in the tags property SARIF viewers already understand
+	if len(issue.Tags) > 0 {
+		result.Properties = map[string]interface{}{"tags": issue.Tags}
+	}
+	return result
+}
+
+// Flush writes the collected results and resets the sink
+func (s *SARIFSink) Flush() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	results := s.results
+	if results == nil {
+		results = []sarifResult{}
+	}
+	s.results = nil
+
+	driverRules := []sarifRule{{ID: sarifRuleID, ShortDescription: sarifMessage{Text: sarifRuleDescription}}}
+	for _, rule := range Rules() {
+		driverRules = append(driverRules, sarifRule{ID: rule.Name, ShortDescription: sarifMessage{Text: rule.Description}})
+	}
+
+	log := sarifLog{
+		Version: "2.1.0",
+		Schema:  "https://json.schemastore.org/sarif-2.1.0.json",
+		Runs: []sarifRun{{
+			Tool: sarifTool{Driver: sarifDriver{
+				Name:           "stackalloc",
+				Version:        GetVersion(),
+				InformationURI: "https://github.com/harriteja/gostackallocator",
+				Rules:          driverRules,
+			}},
+			Results: results,
+		}},
+	}
+
+	encoder := json.NewEncoder(s.w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(log)
+}
+
+// sarifLevel maps a severity to a SARIF result level
+func sarifLevel(severity Severity) string {
+	switch severity {
+	case SeverityLikely:
+		return "error"
+	case SeverityPossible:
+		return "warning"
+	}
+	return "note"
+}
+
+// formatSinks returns the sink for the -format flag. Text output is already
+// produced by go vet from the diagnostics, so it needs no sink. Under
+// -report=counts the only sink is one writing counts in that format.
+func formatSinks(config *Config, w io.Writer) ([]IssueSink, error) {
+	if config.Report == "counts" {
+		sink, err := NewCountsSink(w, config.Format)
+		if err != nil {
+			return nil, err
+		}
+		return []IssueSink{sink}, nil
+	}
+	if config.Format == "" || config.Format == "text" {
+		return nil, nil
+	}
+	sink, err := NewFormatSink(config.Format, w)
+	if err != nil {
+		return nil, err
+	}
+	return []IssueSink{sink}, nil
+}
+
+// reportToSinks sends issues to every sink and flushes them, returning the
+// first flush error
+func reportToSinks(sinks []IssueSink, issues []Issue) error {
+	var firstErr error
+	for _, sink := range sinks {
+		for _, issue := range issues {
+			sink.Report(issue)
+		}
+		if err := sink.Flush(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
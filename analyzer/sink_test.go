@@ -0,0 +1,365 @@
+package analyzer
+
+import (
+	"bytes"
+	"encoding/json"
+	"go/token"
+	"os"
+	"strings"
+	"testing"
+)
+
+// memorySink collects issues in memory
+type memorySink struct {
+	issues  []Issue
+	flushes int
+}
+
+func (m *memorySink) Report(issue Issue) { m.issues = append(m.issues, issue) }
+func (m *memorySink) Flush() error       { m.flushes++; return nil }
+
+var sinkTestIssues = []Issue{
+	{Pos: token.Position{Filename: "a.go", Line: 3, Column: 2}, Message: "new(T) always allocates", Severity: SeverityPossible},
+	{Pos: token.Position{Filename: "b.go", Line: 7, Column: 9}, End: token.Position{Filename: "b.go", Line: 7, Column: 19}, Node: "UnaryExpr", Message: "returning &point{...}", Pattern: "return-address-of-literal", Severity: SeverityLikely},
+}
+
+func TestWithSink(t *testing.T) {
+	first, second := &memorySink{}, &memorySink{}
+	a := NewAnalyzerWithOptions(WithSink(first), WithSink(nil), WithSink(second))
+
+	pass, diagnostics := newTestPass(t, optionsTestCode)
+	if _, err := a.Run(pass); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	for _, sink := range []*memorySink{first, second} {
+		if len(sink.issues) == 0 || len(sink.issues) != len(*diagnostics) {
+			t.Errorf("Expected the sink to see every issue, got %d of %d", len(sink.issues), len(*diagnostics))
+		}
+		if sink.flushes != 1 {
+			t.Errorf("Expected the sink to be flushed once, got %d", sink.flushes)
+		}
+	}
+}
+
+func TestSinkSkipsDroppedIssues(t *testing.T) {
+	config := DefaultConfig()
+	config.ErrorSeverity = SeverityLikely
+	config.WarnSeverity = SeverityLikely
+
+	sink := &memorySink{}
+	options := newAnalyzerOptions(WithConfig(config), WithSink(sink))
+	options.warnings = &bytes.Buffer{}
+
+	pass, _ := newTestPass(t, optionsTestCode)
+	if _, err := runWithDeps(pass, options); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(sink.issues) != 0 {
+		t.Errorf("Expected issues below -warn-severity to be dropped, got %v", sink.issues)
+	}
+}
+
+func TestTextSink(t *testing.T) {
+	var buf bytes.Buffer
+	sink := NewTextSink(&buf)
+	for _, issue := range sinkTestIssues {
+		sink.Report(issue)
+	}
+	if err := sink.Flush(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	expected := "a.go:3:2: new(T) always allocates\nb.go:7:9: returning &point{...}\n"
+	if buf.String() != expected {
+		t.Errorf("Expected %q, got %q", expected, buf.String())
+	}
+}
+
+func TestJSONSink(t *testing.T) {
+	var buf bytes.Buffer
+	sink := NewJSONSink(&buf)
+	for _, issue := range sinkTestIssues {
+		sink.Report(issue)
+	}
+	if err := sink.Flush(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	var issues []JSONIssue
+	if err := json.Unmarshal(buf.Bytes(), &issues); err != nil {
+		t.Fatalf("Expected valid JSON, got %s: %v", buf.String(), err)
+	}
+	if len(issues) != 2 || issues[1].Rule != "return-address-of-literal" || issues[1].Severity != SeverityLikely || issues[0].Line != 3 ||
+		issues[1].EndLine != 7 || issues[1].EndColumn != 19 || issues[1].Node != "UnaryExpr" {
+		t.Errorf("Unexpected JSON issues: %+v", issues)
+	}
+	if !strings.Contains(buf.String(), `"severity":"possible"`) {
+		t.Errorf("Expected severities encoded by name, got %s", buf.String())
+	}
+}
+
+func TestJSONLinesSink(t *testing.T) {
+	// Two packages under go vet, each with its own sink writing to stdout
+	var buf bytes.Buffer
+	for _, issue := range sinkTestIssues {
+		sink := NewJSONLinesSink(&buf)
+		sink.Report(issue)
+		if err := sink.Flush(); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("Expected one line per issue, got %q", buf.String())
+	}
+	for i, line := range lines {
+		var issue JSONIssue
+		if err := json.Unmarshal([]byte(line), &issue); err != nil {
+			t.Fatalf("Expected line %d to be valid JSON, got %s: %v", i+1, line, err)
+		}
+		if issue.Line != sinkTestIssues[i].Pos.Line || issue.Rule != sinkTestIssues[i].Pattern {
+			t.Errorf("Unexpected issue on line %d: %+v", i+1, issue)
+		}
+	}
+}
+
+func TestSARIFSink(t *testing.T) {
+	var buf bytes.Buffer
+	sink := NewSARIFSink(&buf)
+	for _, issue := range sinkTestIssues {
+		sink.Report(issue)
+	}
+	if err := sink.Flush(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	var log sarifLog
+	if err := json.Unmarshal(buf.Bytes(), &log); err != nil {
+		t.Fatalf("Expected valid SARIF, got %s: %v", buf.String(), err)
+	}
+	if log.Version != "2.1.0" || len(log.Runs) != 1 {
+		t.Fatalf("Expected one SARIF 2.1.0 run, got %+v", log)
+	}
+
+	run := log.Runs[0]
+	if run.Tool.Driver.Name != "stackalloc" || len(run.Tool.Driver.Rules) != len(Rules())+1 {
+		t.Errorf("Expected the driver to describe every rule, got %+v", run.Tool.Driver)
+	}
+
+	expected := []struct{ ruleID, level, uri string }{
+		{"stackalloc", "warning", "a.go"},
+		{"return-address-of-literal", "error", "b.go"},
+	}
+	if len(run.Results) != len(expected) {
+		t.Fatalf("Expected %d results, got %d", len(expected), len(run.Results))
+	}
+	for i, want := range expected {
+		got := run.Results[i]
+		if got.RuleID != want.ruleID || got.Level != want.level || got.Locations[0].PhysicalLocation.ArtifactLocation.URI != want.uri {
+			t.Errorf("Expected result %d to be %+v, got %+v", i, want, got)
+		}
+	}
+	if region := run.Results[1].Locations[0].PhysicalLocation.Region; region.EndLine != 7 || region.EndColumn != 19 {
+		t.Errorf("Expected the region to end at 7:19, got %+v", region)
+	}
+}
+
+func TestGitHubSink(t *testing.T) {
+	t.Setenv("GITHUB_WORKSPACE", "/work/repo")
+
+	issues := append([]Issue{
+		{Pos: token.Position{Filename: "/work/repo/pkg/c.go", Line: 12, Column: 5}, Message: "100% of calls allocate,\nsee docs", Pattern: "string-concat-loop", Severity: SeverityInfo},
+		{Pos: token.Position{Filename: "/elsewhere/d,e.go", Line: 1}, Message: "outside: the workspace", Severity: SeverityPossible},
+	}, sinkTestIssues...)
+
+	var buf bytes.Buffer
+	sink := NewGitHubSink(&buf)
+	for _, issue := range issues {
+		sink.Report(issue)
+	}
+	if err := sink.Flush(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	expected := `::notice file=pkg/c.go,line=12,col=5,title=string-concat-loop::100%25 of calls allocate,%0Asee docs
+::warning file=/elsewhere/d%2Ce.go,line=1,title=stackalloc::outside: the workspace
+::warning file=a.go,line=3,col=2,title=stackalloc::new(T) always allocates
+::error file=b.go,line=7,col=9,endLine=7,endColumn=19,title=return-address-of-literal::returning &point{...}
+`
+	if buf.String() != expected {
+		t.Errorf("Expected:\n%s\ngot:\n%s", expected, buf.String())
+	}
+}
+
+func TestCSVSink(t *testing.T) {
+	issues := append([]Issue{
+		{Pos: token.Position{Filename: "pkg/c.go", Line: 12, Column: 5}, Message: `building "key", then value, per call,
+on every iteration`, Pattern: "string-concat-loop", Severity: SeverityInfo},
+	}, sinkTestIssues...)
+
+	var buf bytes.Buffer
+	sink := NewCSVSink(&buf)
+	for _, issue := range issues {
+		sink.Report(issue)
+	}
+	if err := sink.Flush(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	expected := `file,line,column,rule,severity,message,estimated_bytes
+pkg/c.go,12,5,string-concat-loop,info,"building ""key"", then value, per call,
+on every iteration",
+a.go,3,2,,possible,new(T) always allocates,
+b.go,7,9,return-address-of-literal,likely,returning &point{...},
+`
+	if buf.String() != expected {
+		t.Errorf("Expected:\n%s\ngot:\n%s", expected, buf.String())
+	}
+
+	buf.Reset()
+	if err := NewCSVSink(&buf).Flush(); err != nil || buf.String() != "file,line,column,rule,severity,message,estimated_bytes\n" {
+		t.Errorf("Expected just the header without issues, got %q (%v)", buf.String(), err)
+	}
+}
+
+func TestNewFormatSink(t *testing.T) {
+	for _, format := range []string{"text", "json", "jsonl", "sarif", "github", "csv"} {
+		if _, err := NewFormatSink(format, &bytes.Buffer{}); err != nil {
+			t.Errorf("Expected format %s to be supported, got %v", format, err)
+		}
+	}
+
+	if _, err := NewFormatSink("xml", &bytes.Buffer{}); err == nil {
+		t.Error("Expected an unknown format to be rejected")
+	}
+
+	config := DefaultConfig()
+	config.Format = "xml"
+	pass, _ := newTestPass(t, optionsTestCode)
+	if _, err := NewAnalyzerWithOptions(WithConfig(config)).Run(pass); err == nil {
+		t.Error("Expected the analyzer to reject an unknown format")
+	}
+}
+
+func TestIncludeSource(t *testing.T) {
+	long := "\t_ = new(string) // " + strings.Repeat("x", 300)
+	code := "package main\n\nfunc main() {\n\tn := 1\n" + long + "\n\t_ = n\n}\n"
+	lines := strings.Split(code, "\n")
+
+	run := func(includeSource bool) []Issue {
+		config := DefaultConfig()
+		config.IncludeSource = includeSource
+		sink := &memorySink{}
+		pass, _ := newTestPass(t, code)
+		if _, err := runWithDeps(pass, newAnalyzerOptions(WithConfig(config), WithSink(sink))); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		return sink.issues
+	}
+
+	for _, issue := range run(false) {
+		if issue.Source != nil {
+			t.Errorf("Expected no source without -include-source, got %+v", issue.Source)
+		}
+	}
+
+	var issue *Issue
+	issues := run(true)
+	for i := range issues {
+		if issues[i].Pos.Line == 5 {
+			issue = &issues[i]
+		}
+	}
+	if issue == nil || issue.Source == nil {
+		t.Fatalf("Expected an issue with its source on line 5, got %v", issues)
+	}
+
+	source := issue.Source
+	if len(source.Line) != maxSourceLineLength || source.Line != long[:maxSourceLineLength-3]+"..." {
+		t.Errorf("Expected the line truncated to %d bytes, got %q", maxSourceLineLength, source.Line)
+	}
+	want := strings.Join([]string{lines[2], lines[3], source.Line, lines[5], lines[6]}, "\n")
+	if source.StartLine != 3 || source.Context != want {
+		t.Errorf("Expected lines 3-7 as context, got line %d: %q", source.StartLine, source.Context)
+	}
+
+	var buf bytes.Buffer
+	jsonSink := NewJSONSink(&buf)
+	jsonSink.Report(*issue)
+	jsonSink.Flush()
+	var decoded []JSONIssue
+	if err := json.Unmarshal(buf.Bytes(), &decoded); err != nil || len(decoded) != 1 || decoded[0].Source == nil || *decoded[0].Source != *source {
+		t.Errorf("Expected the JSON issue to embed the source, got %s", buf.String())
+	}
+
+	buf.Reset()
+	sarifSink := NewSARIFSink(&buf)
+	sarifSink.Report(*issue)
+	sarifSink.Flush()
+	var log sarifLog
+	if err := json.Unmarshal(buf.Bytes(), &log); err != nil {
+		t.Fatalf("Expected valid SARIF, got %s: %v", buf.String(), err)
+	}
+	region := log.Runs[0].Results[0].Locations[0].PhysicalLocation.ContextRegion
+	if region == nil || region.StartLine != 3 || region.EndLine != 7 || region.Snippet == nil || region.Snippet.Text != want {
+		t.Errorf("Expected a context region covering lines 3-7, got %+v", region)
+	}
+}
+
+func TestIncludeFixes(t *testing.T) {
+	code := "package main\n\nfunc main() {\n\ts := new(string)\n\tprintln(*s)\n}\n"
+
+	run := func(includeFixes bool) []JSONIssue {
+		config := DefaultConfig()
+		config.IncludeFixes = includeFixes
+		var buf bytes.Buffer
+		pass, _ := newTestPass(t, code)
+		if _, err := runWithDeps(pass, newAnalyzerOptions(WithConfig(config), WithAIClient(&MockAIClient{}), WithSink(NewJSONSink(&buf)))); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		// Fixes are only reported, never written
+		if content, err := os.ReadFile(pass.Fset.File(pass.Files[0].Pos()).Name()); err != nil || string(content) != code {
+			t.Errorf("Expected the file to be left untouched, got:\n%s", content)
+		}
+		var decoded []JSONIssue
+		if err := json.Unmarshal(buf.Bytes(), &decoded); err != nil {
+			t.Fatalf("Expected valid JSON, got %s: %v", buf.String(), err)
+		}
+		return decoded
+	}
+
+	for _, issue := range run(false) {
+		if issue.Fixes != nil {
+			t.Errorf("Expected no fixes without -include-fixes, got %+v", issue.Fixes)
+		}
+	}
+
+	var fix *IssueFix
+	for _, issue := range run(true) {
+		if issue.Line == 4 && len(issue.Fixes) > 0 {
+			fix = &issue.Fixes[0]
+		}
+	}
+	if fix == nil || len(fix.Edits) != 2 {
+		t.Fatalf("Expected a fix replacing new(string) and *s for the new(string) issue, got %+v", fix)
+	}
+
+	offset := strings.Index(code, "new(string)")
+	deref := strings.Index(code, "*s")
+	want := []SavedEdit{
+		{Offset: offset, End: offset + len("new(string)"), OldText: "new(string)", NewText: `""`},
+		{Offset: deref, End: deref + len("*s"), OldText: "*s", NewText: "s"},
+	}
+	for i := range want {
+		if fix.Edits[i] != want[i] {
+			t.Errorf("Expected edit %+v, got %+v", want[i], fix.Edits[i])
+		}
+	}
+	fixed := code[:deref] + "s" + code[deref+len("*s"):]
+	fixed = fixed[:offset] + `""` + fixed[offset+len("new(string)"):]
+	if !strings.Contains(fixed, "\ts := \"\"\n\tprintln(s)\n") {
+		t.Errorf("Expected the edits to replace new(string) and *s, got:\n%s", fixed)
+	}
+}
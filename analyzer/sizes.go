@@ -0,0 +1,97 @@
+package analyzer
+
+import (
+	"go/ast"
+	"go/constant"
+	"go/types"
+)
+
+// defaultSizes is used when the driver doesn't provide type sizes, such as for
+// AnalyzeSource
+var defaultSizes = types.SizesFor("gc", "amd64")
+
+// sizeof returns the size in bytes of a value of type t, or false if it can't
+// be determined statically, such as for type parameters or without type information
+func (pd *PatternDetector) sizeof(t types.Type) (int64, bool) {
+	if t == nil || hasTypeParam(t) {
+		return 0, false
+	}
+	if basic, ok := t.Underlying().(*types.Basic); ok && basic.Kind() == types.Invalid {
+		return 0, false
+	}
+	sizes := pd.sizes
+	if sizes == nil {
+		sizes = defaultSizes
+	}
+	return sizes.Sizeof(t), true
+}
+
+// allocSize returns the bytes allocated for n values of type elem
+func (pd *PatternDetector) allocSize(elem types.Type, n int64) (int64, bool) {
+	size, ok := pd.sizeof(elem)
+	if !ok {
+		return 0, false
+	}
+	return size * n, true
+}
+
+// constInt returns the value of expr if it is an integer constant
+func (pd *PatternDetector) constInt(expr ast.Expr) (int64, bool) {
+	tv, ok := pd.info.Types[expr]
+	if !ok || tv.Value == nil {
+		return 0, false
+	}
+	return constant.Int64Val(constant.ToInt(tv.Value))
+}
+
+// isSmallAlloc reports whether size is at most -max-alloc-size bytes
+func (pd *PatternDetector) isSmallAlloc(size int64) bool {
+	return size <= int64(pd.config.MaxAllocSize)
+}
+
+// makeSliceSize returns the bytes allocated by make([]T, len) or
+// make([]T, len, cap) when the capacity is a constant
+func (pd *PatternDetector) makeSliceSize(call *ast.CallExpr) (int64, bool) {
+	t := pd.info.TypeOf(call.Args[0])
+	if t == nil {
+		return 0, false
+	}
+	slice, ok := t.Underlying().(*types.Slice)
+	if !ok {
+		return 0, false
+	}
+	n, ok := pd.constInt(call.Args[len(call.Args)-1])
+	if !ok {
+		return 0, false
+	}
+	return pd.allocSize(slice.Elem(), n)
+}
+
+// sliceLiteralSize returns the bytes allocated for the backing array of a
+// slice literal, whose length is one past its highest index
+func (pd *PatternDetector) sliceLiteralSize(lit *ast.CompositeLit) (int64, bool) {
+	t := pd.info.TypeOf(lit)
+	if t == nil {
+		return 0, false
+	}
+	slice, ok := t.Underlying().(*types.Slice)
+	if !ok {
+		return 0, false
+	}
+
+	var n, next int64
+	for _, elt := range lit.Elts {
+		if kv, ok := elt.(*ast.KeyValueExpr); ok {
+			index, ok := pd.constInt(kv.Key)
+			if !ok {
+				return 0, false
+			}
+			next = index
+		}
+		next++
+		if next > n {
+			n = next
+		}
+	}
+	return pd.allocSize(slice.Elem(), n)
+}
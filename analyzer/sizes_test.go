@@ -0,0 +1,114 @@
+package analyzer
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+const sizesTestCode = `
+package main
+
+type pair struct{ a, b int64 }
+
+type big struct{ buf [64]byte }
+
+const four = 4
+
+func alloc[T any]() *T {
+	return new(T)
+}
+
+func main() {
+	_ = new(pair)
+	_ = new(big)
+	_ = make([]int32, four)
+	_ = make([]int64, 2, 16)
+	_ = []int64{1, 2, 3, 4}
+	_ = []int64{1, 2, 3}
+	_ = []string{5: "x"}
+}
+`
+
+func TestAllocationSizes(t *testing.T) {
+	issues := inspectSource(t, sizesTestCode, DefaultConfig())
+
+	tests := []struct {
+		name     string
+		contains string
+		expected int
+	}{
+		{"new(T) within -max-alloc-size", "new(T) always allocates on heap; consider using stack allocation if object doesn't escape", 2},
+		{"new(T) of a type parameter has no known size", "new(T) always allocates on heap; consider using stack allocation if object doesn't escape; size not known statically", 1},
+		{"make with a named constant length", "small slice allocation with make() (16 bytes)", 1},
+		{"make judged by capacity", "small slice allocation with make()", 1},
+		{"slice literal at the limit", "small slice literal (32 bytes)", 1},
+		{"smaller slice literal", "small slice literal (24 bytes)", 1},
+		{"only small slice literals", "small slice literal", 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := countMatching(issues, tt.contains); got != tt.expected {
+				t.Errorf("Expected %d issues containing %q, got %d: %v", tt.expected, tt.contains, got, issues)
+			}
+		})
+	}
+
+	// A larger limit also covers the 64-byte struct and the indexed literal
+	config := DefaultConfig()
+	config.MaxAllocSize = 128
+	issues = inspectSource(t, sizesTestCode, config)
+	if got := countMatching(issues, "new(T) always allocates on heap"); got != 3 {
+		t.Errorf("Expected 3 new(T) issues with -max-alloc-size=128, got %d: %v", got, issues)
+	}
+	if got := countMatching(issues, "small slice literal (96 bytes)"); got != 1 {
+		t.Errorf("Expected the indexed literal to be sized by its highest index, got %v", issues)
+	}
+}
+
+func TestAllocationSizesFromDriver(t *testing.T) {
+	code := `
+package main
+
+func main() {
+	_ = make([]int, 6)
+}
+`
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "test.go", code, 0)
+	if err != nil {
+		t.Fatalf("Failed to parse code: %v", err)
+	}
+	info := &types.Info{
+		Types: make(map[ast.Expr]types.TypeAndValue),
+		Uses:  make(map[*ast.Ident]types.Object),
+	}
+	if _, err := (&types.Config{}).Check("test", fset, []*ast.File{file}, info); err != nil {
+		t.Fatalf("Failed to type check code: %v", err)
+	}
+
+	tests := []struct {
+		arch     string
+		expected string
+	}{
+		{"amd64", ""},
+		{"386", "small slice allocation with make() (24 bytes)"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.arch, func(t *testing.T) {
+			var got string
+			inspectFile(file, info, &packageInfo{sizes: types.SizesFor("gc", tt.arch)}, fset, DefaultConfig(), zap.NewNop(), func(issue Issue) {
+				got = issue.Message
+			})
+			if (tt.expected == "") != (got == "") || !contains(got, tt.expected) {
+				t.Errorf("Expected %q, got %q", tt.expected, got)
+			}
+		})
+	}
+}
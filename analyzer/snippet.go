@@ -0,0 +1,58 @@
+package analyzer
+
+import (
+	"fmt"
+	"go/ast"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+
+	"github.com/harriteja/gostackallocator/internal"
+)
+
+// snippetFilename is the file name the positions of RunDetector's issues refer to
+const snippetFilename = "snippet.go"
+
+// RunDetector parses and type-checks src, the source of a single file that
+// may import the standard library, and returns the issues the named rule
+// reports in it, off-by-default rules included. No other rule or unnamed
+// detector runs, and AI suggestions are disabled, which makes it the quickest
+// way to test a rule:
+//
+//	issues, err := analyzer.RunDetector("time-format-loop", src)
+//
+// It returns an error for an unknown rule or source that doesn't compile.
+func RunDetector(name string, src string) ([]Issue, error) {
+	if _, ok := LookupRule(name); !ok {
+		return nil, fmt.Errorf("unknown rule %q", name)
+	}
+
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, snippetFilename, src, parser.ParseComments)
+	if err != nil {
+		return nil, err
+	}
+	info := &types.Info{
+		Types:      make(map[ast.Expr]types.TypeAndValue),
+		Defs:       make(map[*ast.Ident]types.Object),
+		Uses:       make(map[*ast.Ident]types.Object),
+		Implicits:  make(map[ast.Node]types.Object),
+		Selections: make(map[*ast.SelectorExpr]*types.Selection),
+		Instances:  make(map[*ast.Ident]types.Instance),
+	}
+	typesConfig := &types.Config{Importer: importer.Default()}
+	if _, err := typesConfig.Check("snippet", fset, []*ast.File{file}, info); err != nil {
+		return nil, fmt.Errorf("failed to type-check snippet: %w", err)
+	}
+
+	config := DefaultConfig()
+	config.OpenAIDisable = true
+	config.OnlyPatterns = []string{name}
+
+	var issues []Issue
+	inspectFile(file, info, nil, fset, config, internal.GetLogger(), func(issue Issue) {
+		issues = append(issues, issue)
+	})
+	return issues, nil
+}
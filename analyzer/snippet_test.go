@@ -0,0 +1,43 @@
+package analyzer
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRunDetector(t *testing.T) {
+	src := `package main
+
+func ids(n int) []*int {
+	var out []*int
+	for i := 0; i < n; i++ {
+		out = append(out, new(int))
+	}
+	return out
+}
+`
+	// Only the named rule runs, not the detectors reporting new(int)
+	issues, err := RunDetector("time-format-loop", src)
+	if err != nil || len(issues) != 0 {
+		t.Errorf("Expected no time-format-loop issues, got %v (%v)", issues, err)
+	}
+
+	tests := []struct {
+		name    string
+		rule    string
+		src     string
+		wantErr string
+	}{
+		{"unknown rule", "no-such-rule", src, `unknown rule "no-such-rule"`},
+		{"syntax error", "time-format-loop", "package main\n\nfunc {", "snippet.go:3:6"},
+		{"type error", "time-format-loop", "package main\n\nvar x int = \"s\"\n", "failed to type-check snippet"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := RunDetector(tt.rule, tt.src); err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Expected an error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
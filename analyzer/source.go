@@ -0,0 +1,99 @@
+package analyzer
+
+import (
+	"fmt"
+	"go/token"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/harriteja/gostackallocator/internal"
+	"go.uber.org/zap"
+)
+
+// SourceCache reads source files at most once per run and remembers failures
+// so they can be surfaced instead of silently degrading suggestions
+type SourceCache struct {
+	mu       sync.Mutex
+	files    map[string][]byte
+	failures map[string]error
+	overlay  map[string][]byte // in-memory contents that take precedence over disk
+	readFile func(pos token.Position) ([]byte, error)
+	logger   *zap.Logger
+}
+
+// NewSourceCache creates a source cache backed by internal.ReadSourceFile
+func NewSourceCache(logger *zap.Logger) *SourceCache {
+	if logger == nil {
+		logger = zap.NewNop()
+	}
+
+	return &SourceCache{
+		files:    make(map[string][]byte),
+		failures: make(map[string]error),
+		readFile: internal.ReadSourceFile,
+		logger:   logger,
+	}
+}
+
+// Read returns the contents of filename, or false if it cannot be read
+func (c *SourceCache) Read(filename string) ([]byte, bool) {
+	if filename == "" {
+		return nil, false
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if src, ok := c.overlay[filename]; ok {
+		return src, true
+	}
+	if src, ok := c.files[filename]; ok {
+		return src, true
+	}
+	if _, failed := c.failures[filename]; failed {
+		return nil, false
+	}
+
+	src, err := c.readFile(token.Position{Filename: filename})
+	if err != nil {
+		c.failures[filename] = err
+		c.logger.Warn("Failed to read source file; suggestions for it will be incomplete",
+			zap.String("file", filename),
+			zap.Error(err),
+		)
+		return nil, false
+	}
+
+	c.files[filename] = src
+	return src, true
+}
+
+// Failures returns the files that could not be read and their errors
+func (c *SourceCache) Failures() map[string]error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	failures := make(map[string]error, len(c.failures))
+	for filename, err := range c.failures {
+		failures[filename] = err
+	}
+	return failures
+}
+
+// Warning returns a single aggregated warning for all unreadable files, or ""
+func (c *SourceCache) Warning() string {
+	failures := c.Failures()
+	if len(failures) == 0 {
+		return ""
+	}
+
+	files := make([]string, 0, len(failures))
+	for filename := range failures {
+		files = append(files, filename)
+	}
+	sort.Strings(files)
+
+	return fmt.Sprintf("stackalloc could not read %d source file(s), suggestions may be incomplete: %s",
+		len(files), strings.Join(files, ", "))
+}
@@ -0,0 +1,83 @@
+package analyzer
+
+import (
+	"errors"
+	"go/token"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestSourceCacheReadFailure(t *testing.T) {
+	core, logs := observer.New(zapcore.WarnLevel)
+	sources := NewSourceCache(zap.New(core))
+
+	reads := 0
+	sources.readFile = func(pos token.Position) ([]byte, error) {
+		reads++
+		return nil, errors.New("permission denied")
+	}
+
+	pos := token.Position{Filename: "locked.go", Line: 3}
+	fset := token.NewFileSet()
+
+	// Several issues in the same unreadable file should only read it once
+	for i := 0; i < 3; i++ {
+		if snippet := getCodeSnippetFromPosition(pos, fset, sources); snippet != "" {
+			t.Errorf("Expected empty snippet for unreadable file, got %q", snippet)
+		}
+	}
+
+	if reads != 1 {
+		t.Errorf("Expected 1 read attempt, got %d", reads)
+	}
+
+	if logs.Len() != 1 {
+		t.Fatalf("Expected 1 warning log entry, got %d", logs.Len())
+	}
+	if entry := logs.All()[0]; entry.ContextMap()["file"] != "locked.go" {
+		t.Errorf("Expected warning to name locked.go, got %v", entry.ContextMap())
+	}
+
+	warning := sources.Warning()
+	if !strings.Contains(warning, "1 source file(s)") || !strings.Contains(warning, "locked.go") {
+		t.Errorf("Unexpected aggregated warning: %q", warning)
+	}
+}
+
+func TestSourceCacheCachesReads(t *testing.T) {
+	sources := NewSourceCache(nil)
+
+	src := []byte("package main\n\nfunc main() {\n\ts := new(string)\n\t_ = s\n}\n")
+	reads := 0
+	sources.readFile = func(pos token.Position) ([]byte, error) {
+		reads++
+		return src, nil
+	}
+
+	fset := token.NewFileSet()
+	fset.AddFile("main.go", -1, len(src)).SetLinesForContent(src)
+	autoFixer := NewAutoFixer(fset)
+	autoFixer.sources = sources
+
+	issue := Issue{
+		Pos:     token.Position{Filename: "main.go", Line: 4, Column: 7},
+		Node:    "CallExpr",
+		Message: "new(T) always allocates on heap",
+	}
+	for i := 0; i < 2; i++ {
+		if fixes := autoFixer.GenerateAutoFixes(issue, ""); len(fixes) != 1 {
+			t.Fatalf("Expected 1 fix, got %d", len(fixes))
+		}
+	}
+
+	if reads != 1 {
+		t.Errorf("Expected 1 read attempt, got %d", reads)
+	}
+	if warning := sources.Warning(); warning != "" {
+		t.Errorf("Expected no warning, got %q", warning)
+	}
+}
@@ -0,0 +1,576 @@
+package analyzer
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/token"
+	"go/types"
+	"strings"
+
+	"golang.org/x/tools/go/analysis"
+)
+
+// detectStdlibIdioms detects allocation-adjacent misuse of standard library APIs
+// that has a more idiomatic, cheaper spelling
+func (pd *PatternDetector) detectStdlibIdioms(call *ast.CallExpr, report func(node ast.Node, msg string)) {
+	if !pd.config.IsPatternEnabled("stdlib-idioms") {
+		return
+	}
+
+	pd.detectTimeNowSub(call, report)
+}
+
+// detectTimeNowSub detects time.Now().Sub(x), which is spelled time.Since(x)
+func (pd *PatternDetector) detectTimeNowSub(call *ast.CallExpr, report func(node ast.Node, msg string)) {
+	sel, ok := call.Fun.(*ast.SelectorExpr)
+	if !ok || sel.Sel.Name != "Sub" || len(call.Args) != 1 {
+		return
+	}
+	now, ok := sel.X.(*ast.CallExpr)
+	if !ok || len(now.Args) != 0 || !pd.isPkgFunc(now, "time", "Now") {
+		return
+	}
+
+	msg := "time.Now().Sub(x) can be written as time.Since(x)"
+
+	arg, ok := pd.nodeSource(call.Args[0])
+	if !ok {
+		pd.reportRule(report, "stdlib-idioms", call, msg)
+		return
+	}
+
+	// Keep whatever name the time package was imported under
+	pkg := now.Fun.(*ast.SelectorExpr).X.(*ast.Ident).Name
+	replacement := fmt.Sprintf("%s.Since(%s)", pkg, arg)
+
+	pd.reportRule(report, "stdlib-idioms", call, msg, analysis.SuggestedFix{
+		Message: fmt.Sprintf("Replace with %s", replacement),
+		TextEdits: []analysis.TextEdit{
+			{
+				Pos:     call.Pos(),
+				End:     call.End(),
+				NewText: []byte(replacement),
+			},
+		},
+	})
+}
+
+// detectStringerSprintf detects fmt.Sprintf and friends in a String() string
+// method. fmt calls String implicitly whenever the value is printed, so a type
+// that is printed often formats and allocates on every print.
+func (pd *PatternDetector) detectStringerSprintf(call *ast.CallExpr, report func(node ast.Node, msg string)) {
+	if !pd.config.IsPatternEnabled("stringer-sprintf") || !pd.isPkgFunc(call, "fmt", "Sprintf", "Sprint", "Sprintln") {
+		return
+	}
+	decl, ok := pd.enclosingFunc().(*ast.FuncDecl)
+	if !ok || decl.Recv == nil || len(decl.Recv.List) != 1 || !pd.isStringer(decl) {
+		return
+	}
+
+	_, name := pd.pkgFunc(call)
+	pd.reportRule(report, "stringer-sprintf", call, fmt.Sprintf("fmt.%s in %s.String formats and allocates every time the value is printed; consider appending the parts to a []byte with strconv.AppendInt, strconv.AppendQuote and the like", name, receiverName(decl.Recv.List[0].Type)))
+}
+
+// isStringer reports whether decl is a method with fmt.Stringer's signature,
+// String() string
+func (pd *PatternDetector) isStringer(decl *ast.FuncDecl) bool {
+	fn, ok := pd.info.Defs[decl.Name].(*types.Func)
+	if !ok || fn.Name() != "String" {
+		return false
+	}
+	sig := fn.Type().(*types.Signature)
+	return sig.Recv() != nil && sig.Params().Len() == 0 && sig.Results().Len() == 1 &&
+		types.Identical(sig.Results().At(0).Type(), types.Typ[types.String])
+}
+
+// detectHandlerBodyAlloc detects io.ReadAll and bytes.NewBuffer in HTTP
+// handlers. Both allocate a fresh buffer for every request, which adds up
+// under load where a pooled buffer would be reused.
+func (pd *PatternDetector) detectHandlerBodyAlloc(call *ast.CallExpr, report func(node ast.Node, msg string)) {
+	if !pd.config.IsPatternEnabled("handler-body-alloc") {
+		return
+	}
+
+	var msg string
+	switch {
+	case pd.isPkgFunc(call, "io", "ReadAll"), pd.isPkgFunc(call, "io/ioutil", "ReadAll"):
+		path, _ := pd.pkgFunc(call)
+		msg = fmt.Sprintf("%s.ReadAll in an HTTP handler allocates a buffer for every request and reallocates it as it grows; consider reading into a buffer from a sync.Pool, or decoding straight from the reader", path[strings.LastIndex(path, "/")+1:])
+	case pd.isPkgFunc(call, "bytes", "NewBuffer", "NewBufferString"):
+		_, name := pd.pkgFunc(call)
+		msg = fmt.Sprintf("bytes.%s in an HTTP handler allocates a buffer for every request; consider taking a *bytes.Buffer from a sync.Pool and resetting it", name)
+	default:
+		return
+	}
+	if !pd.isInHTTPHandler() {
+		return
+	}
+
+	pd.reportRule(report, "handler-body-alloc", call, msg)
+}
+
+// detectBufioPerCall detects bufio readers, writers and scanners created in a
+// loop body or an HTTP handler. Each one allocates its buffer, 4096 bytes by
+// default, so code that runs repeatedly should keep one around and point it at
+// the next reader or writer with Reset.
+func (pd *PatternDetector) detectBufioPerCall(call *ast.CallExpr, report func(node ast.Node, msg string)) {
+	if !pd.config.IsPatternEnabled("bufio-per-call") || !pd.isPkgFunc(call, "bufio", "NewReader", "NewReaderSize", "NewWriter", "NewWriterSize", "NewScanner") {
+		return
+	}
+
+	var where, reuse string
+	switch {
+	case pd.isInLoop(call):
+		where, reuse = "in a loop", "create it once before the loop"
+	case pd.isInHTTPHandler():
+		where, reuse = "in an HTTP handler", "keep one in a sync.Pool"
+	default:
+		return
+	}
+
+	pkg := call.Fun.(*ast.SelectorExpr).X.(*ast.Ident).Name
+	_, name := pd.pkgFunc(call)
+	if name == "NewScanner" {
+		pd.reportRule(report, "bufio-per-call", call, fmt.Sprintf("%s.NewScanner %s allocates a new buffer every time; Scanner has no Reset, so consider handing it a reused slice through its Buffer method", pkg, where))
+		return
+	}
+	pd.reportRule(report, "bufio-per-call", call, fmt.Sprintf("%s.%s %s allocates a new buffer every time; %s and call its Reset method to switch it to the next %s", pkg, name, where, reuse, strings.ToLower(strings.TrimSuffix(strings.TrimPrefix(name, "New"), "Size"))))
+}
+
+// detectHTTPClientPerCall detects requests sent through http.DefaultClient,
+// by http.Get, Head, Post and PostForm or by calling its methods directly, in
+// a loop body or an HTTP handler. DefaultClient has no timeout, and its
+// Transport keeps only two idle connections per host, so code sending many
+// requests should share a client configured for them.
+func (pd *PatternDetector) detectHTTPClientPerCall(call *ast.CallExpr, report func(node ast.Node, msg string)) {
+	if !pd.config.IsPatternEnabled("http-client-per-call") {
+		return
+	}
+	name, ok := pd.defaultClientCall(call)
+	if !ok {
+		return
+	}
+
+	var where string
+	switch {
+	case pd.isInLoop(call):
+		where = "in a loop"
+	case pd.isInHTTPHandler():
+		where = "in an HTTP handler"
+	default:
+		return
+	}
+	pd.reportRule(report, "http-client-per-call", call, fmt.Sprintf("%s %s sends every request through http.DefaultClient, which has no timeout and keeps at most 2 idle connections per host; consider a *http.Client with a Timeout and a Transport sized for the hosts it calls, created once and shared, and drain and close each response body so its connection can be reused", name, where))
+}
+
+// defaultClientCall returns call as written, such as http.Get or
+// http.DefaultClient.Do, if it sends a request through http.DefaultClient
+func (pd *PatternDetector) defaultClientCall(call *ast.CallExpr) (string, bool) {
+	sel, ok := call.Fun.(*ast.SelectorExpr)
+	if !ok {
+		return "", false
+	}
+	if pd.isPkgFunc(call, "net/http", "Get", "Head", "Post", "PostForm") {
+		return sel.X.(*ast.Ident).Name + "." + sel.Sel.Name, true
+	}
+
+	switch sel.Sel.Name {
+	case "Do", "Get", "Head", "Post", "PostForm":
+	default:
+		return "", false
+	}
+	recv, ok := ast.Unparen(sel.X).(*ast.SelectorExpr)
+	if !ok {
+		return "", false
+	}
+	pkg, ok := recv.X.(*ast.Ident)
+	if !ok {
+		return "", false
+	}
+	v, ok := pd.info.ObjectOf(recv.Sel).(*types.Var)
+	if !ok || v.Pkg() == nil || v.Pkg().Path() != "net/http" || v.Name() != "DefaultClient" {
+		return "", false
+	}
+	return pkg.Name + ".DefaultClient." + sel.Sel.Name, true
+}
+
+// detectContextWithValueChain detects functions calling context.WithValue more
+// than once. Each call allocates a new context wrapping the previous one, and
+// every Value lookup walks the chain, so a single struct stored under one key
+// is cheaper. The function is reported once, at its first call.
+func (pd *PatternDetector) detectContextWithValueChain(call *ast.CallExpr, report func(node ast.Node, msg string)) {
+	if !pd.config.IsPatternEnabled("context-withvalue-chain") || !pd.isPkgFunc(call, "context", "WithValue") {
+		return
+	}
+	fn := pd.enclosingFunc()
+	if fn == nil {
+		return
+	}
+
+	var calls []*ast.CallExpr
+	ast.Inspect(fn, func(n ast.Node) bool {
+		if lit, ok := n.(*ast.FuncLit); ok && lit != fn {
+			return false
+		}
+		if c, ok := n.(*ast.CallExpr); ok && pd.isPkgFunc(c, "context", "WithValue") {
+			calls = append(calls, c)
+		}
+		return true
+	})
+	if len(calls) < 2 || calls[0] != call {
+		return
+	}
+
+	pd.reportRule(report, "context-withvalue-chain", call, fmt.Sprintf("context.WithValue is called %d times in this function; each call allocates a new context that every Value lookup walks through, so consider carrying one struct with all the values under a single key", len(calls)))
+}
+
+// nodeSource renders node back to Go source
+func (pd *PatternDetector) nodeSource(node ast.Node) (string, bool) {
+	var buf bytes.Buffer
+	if err := format.Node(&buf, pd.fset, node); err != nil {
+		return "", false
+	}
+	return buf.String(), true
+}
+
+// detectDeferLogArgs detects deferred fmt and log calls with non-constant
+// arguments. Arguments to a deferred call are evaluated, and boxed into
+// interfaces, when the defer statement runs rather than when the function
+// returns, which both allocates up front and logs stale values.
+func (pd *PatternDetector) detectDeferLogArgs(stmt *ast.DeferStmt, report func(node ast.Node, msg string)) {
+	if !pd.config.IsPatternEnabled("defer-log-args") {
+		return
+	}
+
+	call := stmt.Call
+	if !pd.isPkgFunc(call, "fmt", "Print", "Printf", "Println", "Fprint", "Fprintf", "Fprintln") &&
+		!pd.isPkgFunc(call, "log", "Print", "Printf", "Println", "Fatal", "Fatalf", "Fatalln", "Panic", "Panicf", "Panicln") {
+		return
+	}
+
+	// The io.Writer of Fprint* is an interface already, so evaluating it
+	// early boxes nothing
+	args := call.Args
+	if pd.isPkgFunc(call, "fmt", "Fprint", "Fprintf", "Fprintln") && len(args) > 0 {
+		args = args[1:]
+	}
+	for _, arg := range args {
+		if tv, ok := pd.info.Types[arg]; ok && tv.Value != nil {
+			continue
+		}
+
+		pkg, name := pd.pkgFunc(call)
+		pd.reportRule(report, "defer-log-args", stmt, fmt.Sprintf("deferred %s.%s evaluates and boxes its arguments when the defer statement runs, not when the function returns, so it allocates up front and logs the values at that point; consider defer func() { ... }() to evaluate them on return", pkg, name))
+		return
+	}
+}
+
+// detectOversplit detects strings.Split and bytes.Split calls of which only the
+// first few fields are needed: the result is indexed at a constant right away,
+// or stored in a variable that is only indexed at constants and has its length
+// compared against constants. SplitN stops splitting after the fields that are
+// needed, and Cut allocates nothing when only the first one is.
+func (pd *PatternDetector) detectOversplit(call *ast.CallExpr, report func(node ast.Node, msg string)) {
+	if !pd.config.IsPatternEnabled("oversplit") || len(call.Args) != 2 {
+		return
+	}
+	if !pd.isPkgFunc(call, "strings", "Split") && !pd.isPkgFunc(call, "bytes", "Split") {
+		return
+	}
+
+	var n int64
+	switch parent := pd.ancestor(0).(type) {
+	case *ast.IndexExpr:
+		k, ok := pd.constInt(parent.Index)
+		if !ok || parent.X != call || k < 0 {
+			return
+		}
+		n = k + 2
+	case *ast.AssignStmt:
+		if parent.Tok != token.DEFINE || len(parent.Lhs) != 1 || len(parent.Rhs) != 1 {
+			return
+		}
+		n = pd.splitFieldsNeeded(parent.Lhs[0])
+	case *ast.ValueSpec:
+		if len(parent.Names) != 1 || len(parent.Values) != 1 {
+			return
+		}
+		n = pd.splitFieldsNeeded(parent.Names[0])
+	}
+	if n < 2 {
+		return
+	}
+
+	pkg, _ := pd.pkgFunc(call)
+	msg := fmt.Sprintf("%s.Split allocates a slice holding every field, but only the first %d are needed; %s.SplitN(s, sep, %d) stops splitting after them", pkg, n-1, pkg, n)
+	if n == 2 {
+		msg = fmt.Sprintf("%s.Split allocates a slice holding every field, but only the first is needed; %s.Cut returns it without allocating, or %s.SplitN(s, sep, 2) stops splitting after it", pkg, pkg, pkg)
+	}
+
+	sel := call.Fun.(*ast.SelectorExpr)
+	pd.reportRule(report, "oversplit", call, msg, analysis.SuggestedFix{
+		Message: fmt.Sprintf("Replace with %s.SplitN(..., %d)", pkg, n),
+		TextEdits: []analysis.TextEdit{
+			{Pos: sel.Sel.Pos(), End: sel.Sel.End(), NewText: []byte("SplitN")},
+			{Pos: call.Rparen, End: call.Rparen, NewText: []byte(fmt.Sprintf(", %d", n))},
+		},
+	})
+}
+
+// splitFieldsNeeded returns how many fields SplitN has to produce for every use
+// of the variable declared by ident to see the same values as with Split: one
+// more than the largest constant it is indexed at, and at least one more than
+// any constant its length is compared against. It returns 0 if the variable is
+// used any other way.
+func (pd *PatternDetector) splitFieldsNeeded(ident ast.Expr) int64 {
+	id, ok := ident.(*ast.Ident)
+	if !ok {
+		return 0
+	}
+	v, ok := pd.info.Defs[id].(*types.Var)
+	fn := pd.enclosingFunc()
+	if !ok || fn == nil {
+		return 0
+	}
+
+	var n int64
+	need := func(fields int64) {
+		if fields > n {
+			n = fields
+		}
+	}
+
+	var path []ast.Node
+	valid := true
+	ast.Inspect(fn, func(node ast.Node) bool {
+		if node == nil {
+			path = path[:len(path)-1]
+			return true
+		}
+		if !valid {
+			return false
+		}
+		if use, ok := node.(*ast.Ident); ok && pd.info.Uses[use] == v {
+			valid = pd.splitUse(use, path, need)
+		}
+		path = append(path, node)
+		return true
+	})
+
+	if !valid {
+		return 0
+	}
+	return n
+}
+
+// splitUse reports whether use, a use of a Split result whose ancestors are
+// path, is a constant index or a length compared against a constant, calling
+// need with the number of fields it needs
+func (pd *PatternDetector) splitUse(use *ast.Ident, path []ast.Node, need func(int64)) bool {
+	if len(path) < 2 {
+		return false
+	}
+
+	switch parent := path[len(path)-1].(type) {
+	case *ast.IndexExpr:
+		k, ok := pd.constInt(parent.Index)
+		if !ok || parent.X != use || k < 0 {
+			return false
+		}
+		need(k + 2)
+		return true
+	case *ast.CallExpr:
+		if !pd.isBuiltinCall(parent, "len") {
+			return false
+		}
+		cmp, ok := path[len(path)-2].(*ast.BinaryExpr)
+		if !ok {
+			return false
+		}
+		switch cmp.Op {
+		case token.EQL, token.NEQ, token.LSS, token.LEQ, token.GTR, token.GEQ:
+		default:
+			return false
+		}
+		other := cmp.Y
+		if other == parent {
+			other = cmp.X
+		}
+		c, ok := pd.constInt(other)
+		if !ok || c < 0 {
+			return false
+		}
+		need(c + 1)
+		return true
+	}
+	return false
+}
+
+// valueTypes are types that are idiomatically declared as values, with var,
+// rather than allocated with new: their zero value is ready to use and their
+// methods take pointer receivers, so calls on the variable work unchanged.
+// Config.ValueTypes adds to them.
+var valueTypes = []string{
+	"bytes.Buffer",
+	"strings.Builder",
+	"math/big.Int",
+	"math/big.Float",
+	"math/big.Rat",
+	"sync.WaitGroup",
+}
+
+// isValueType reports whether t, fully qualified like math/big.Int, is one of
+// valueTypes or Config.ValueTypes
+func (pd *PatternDetector) isValueType(t string) bool {
+	for _, name := range valueTypes {
+		if name == t {
+			return true
+		}
+	}
+	for _, name := range pd.config.ValueTypes {
+		if name == t {
+			return true
+		}
+	}
+	return false
+}
+
+// detectNewValueType detects new(T) for types that are idiomatically declared
+// as values, such as bytes.Buffer and big.Int. When the call initializes a
+// local variable, the fix declares the variable as a value and takes its
+// address wherever it was used as a pointer. It reports whether the call was
+// reported, so the generic new(T) message can be skipped.
+func (pd *PatternDetector) detectNewValueType(call *ast.CallExpr, report func(node ast.Node, msg string)) bool {
+	if !pd.config.IsPatternEnabled("new-value-type") || len(call.Args) != 1 {
+		return false
+	}
+	t := pd.info.TypeOf(call.Args[0])
+	if t == nil || !pd.isValueType(types.TypeString(t, nil)) {
+		return false
+	}
+
+	typ := types.TypeString(t, (*types.Package).Name)
+	msg := fmt.Sprintf("new(%s) is heap allocated unless it doesn't escape; %s is ready to use as a zero value, so declare it with var x %s and pass &x where a pointer is needed", typ, typ, typ)
+	fix, ok := pd.newValueTypeFix(call)
+	if !ok {
+		pd.reportRule(report, "new-value-type", call, msg)
+		return true
+	}
+	pd.reportRule(report, "new-value-type", call, msg, fix)
+	return true
+}
+
+// newValueTypeFix rewrites `x := new(T)` or `var x = new(T)` to `var x T`,
+// replacing *x with x and other uses of x with &x. Method calls and field
+// accesses through x work on the value as they are. When x is defined along
+// with other variables, it is split out into a declaration of its own. There's
+// no fix if x is ever reassigned or its address is taken.
+func (pd *PatternDetector) newValueTypeFix(call *ast.CallExpr) (analysis.SuggestedFix, bool) {
+	var name *ast.Ident
+	var decl ast.Node
+	var split *ast.AssignStmt
+	switch parent := pd.ancestor(0).(type) {
+	case *ast.AssignStmt:
+		if parent.Tok == token.DEFINE && len(parent.Lhs) == 1 && len(parent.Rhs) == 1 {
+			name, _ = parent.Lhs[0].(*ast.Ident)
+			decl = parent
+		} else if parent.Tok == token.DEFINE && len(parent.Lhs) == len(parent.Rhs) && pd.inStmtList(parent) {
+			for i, rhs := range parent.Rhs {
+				if rhs == call {
+					name, _ = parent.Lhs[i].(*ast.Ident)
+				}
+			}
+			decl, split = parent, parent
+		}
+	case *ast.ValueSpec:
+		if len(parent.Names) == 1 && len(parent.Values) == 1 && parent.Type == nil {
+			if gen, ok := pd.ancestor(1).(*ast.GenDecl); ok && len(gen.Specs) == 1 {
+				name, decl = parent.Names[0], gen
+			}
+		}
+	}
+	fn := pd.enclosingFunc()
+	if name == nil || name.Name == "_" || fn == nil {
+		return analysis.SuggestedFix{}, false
+	}
+	obj := pd.info.ObjectOf(name)
+	typ, ok := pd.nodeSource(call.Args[0])
+	if !isLocalVar(obj) || !ok || pd.info.Defs[name] == nil {
+		return analysis.SuggestedFix{}, false
+	}
+
+	edits := []analysis.TextEdit{{
+		Pos:     decl.Pos(),
+		End:     decl.End(),
+		NewText: []byte(fmt.Sprintf("var %s %s", name.Name, typ)),
+	}}
+	if split != nil {
+		// a, x := f(), new(T) becomes a := f() followed by var x T
+		edit, ok := pd.replaceStmts(split, split, pd.splitDefine(split, name, call.Args[0])...)
+		if !ok {
+			return analysis.SuggestedFix{}, false
+		}
+		edits[0] = edit
+	}
+	var stack []ast.Node
+	fixable := true
+	ast.Inspect(fn, func(n ast.Node) bool {
+		if n == nil {
+			stack = stack[:len(stack)-1]
+			return true
+		}
+		if !fixable {
+			return false
+		}
+		if ident, ok := n.(*ast.Ident); ok && pd.info.Uses[ident] == obj {
+			switch parent := stack[len(stack)-1].(type) {
+			case *ast.SelectorExpr:
+				// x.f and x.M() work on the value as they are
+			case *ast.StarExpr:
+				edits = append(edits, analysis.TextEdit{Pos: parent.Pos(), End: parent.End(), NewText: []byte(name.Name)})
+			case *ast.UnaryExpr:
+				fixable = parent.Op != token.AND
+			case *ast.AssignStmt:
+				for _, lhs := range parent.Lhs {
+					fixable = fixable && lhs != ident
+				}
+				if fixable {
+					edits = append(edits, analysis.TextEdit{Pos: ident.Pos(), End: ident.End(), NewText: []byte("&" + name.Name)})
+				}
+			default:
+				edits = append(edits, analysis.TextEdit{Pos: ident.Pos(), End: ident.End(), NewText: []byte("&" + name.Name)})
+			}
+		}
+		stack = append(stack, n)
+		return true
+	})
+	if !fixable {
+		return analysis.SuggestedFix{}, false
+	}
+	return analysis.SuggestedFix{
+		Message:   fmt.Sprintf("Declare %s as a %s value", name.Name, typ),
+		TextEdits: edits,
+	}, true
+}
+
+// splitDefine splits name, which assign defines as new(typ), out of assign
+// into a declaration of its own that follows the rest of it
+func (pd *PatternDetector) splitDefine(assign *ast.AssignStmt, name *ast.Ident, typ ast.Expr) []ast.Stmt {
+	rest := &ast.AssignStmt{Tok: token.ASSIGN}
+	for i, lhs := range assign.Lhs {
+		if lhs == name {
+			continue
+		}
+		if ident, ok := lhs.(*ast.Ident); ok && pd.info.Defs[ident] != nil {
+			rest.Tok = token.DEFINE
+		}
+		rest.Lhs = append(rest.Lhs, lhs)
+		rest.Rhs = append(rest.Rhs, assign.Rhs[i])
+	}
+	return []ast.Stmt{rest, &ast.DeclStmt{Decl: &ast.GenDecl{
+		Tok:   token.VAR,
+		Specs: []ast.Spec{&ast.ValueSpec{Names: []*ast.Ident{ast.NewIdent(name.Name)}, Type: typ}},
+	}}}
+}
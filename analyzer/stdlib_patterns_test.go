@@ -0,0 +1,620 @@
+package analyzer
+
+import (
+	"testing"
+)
+
+func TestStdlibIdiomsTimeSince(t *testing.T) {
+	code := `package main
+
+import (
+	clock "time"
+)
+
+func elapsed(start clock.Time) clock.Duration {
+	return clock.Now().Sub(start)
+}
+
+func between(a, b clock.Time) clock.Duration {
+	return b.Sub(a)
+}
+`
+	const msg = "time.Now().Sub(x) can be written as time.Since(x)"
+
+	pass, _ := newTestPass(t, code)
+
+	// Off by default
+	for _, issue := range analyzeFile(pass.Files[0], pass.TypesInfo, pass.Fset, DefaultConfig()) {
+		if issue.Pattern == "stdlib-idioms" {
+			t.Errorf("Expected stdlib-idioms to be off by default, got %v", issue)
+		}
+	}
+
+	config := DefaultConfig()
+	config.EnablePatterns = []string{"stdlib-idioms"}
+
+	var found []Issue
+	for _, issue := range analyzeFile(pass.Files[0], pass.TypesInfo, pass.Fset, config) {
+		if issue.Pattern == "stdlib-idioms" {
+			found = append(found, issue)
+		}
+	}
+	if len(found) != 1 {
+		t.Fatalf("Expected 1 stdlib-idioms issue, got %d: %v", len(found), found)
+	}
+
+	issue := found[0]
+	if issue.Message != msg {
+		t.Errorf("Unexpected message: %q", issue.Message)
+	}
+	if issue.Pos.Line != 8 {
+		t.Errorf("Expected issue on line 8, got %d", issue.Pos.Line)
+	}
+	if len(issue.SuggestedFixes) != 1 || len(issue.SuggestedFixes[0].TextEdits) != 1 {
+		t.Fatalf("Expected a single-edit fix, got %v", issue.SuggestedFixes)
+	}
+
+	edit := issue.SuggestedFixes[0].TextEdits[0]
+	if got := string(edit.NewText); got != "clock.Since(start)" {
+		t.Errorf("Expected replacement clock.Since(start), got %q", got)
+	}
+	start, end := pass.Fset.Position(edit.Pos), pass.Fset.Position(edit.End)
+	if got := code[start.Offset:end.Offset]; got != "clock.Now().Sub(start)" {
+		t.Errorf("Expected edit to cover the whole call, got %q", got)
+	}
+}
+
+func TestStdlibIdiomsFixUsedForDiagnostic(t *testing.T) {
+	code := `package main
+
+import "time"
+
+func elapsed(start time.Time) time.Duration {
+	return time.Now().Sub(start)
+}
+`
+	pass, diagnostics := newTestPass(t, code)
+
+	config := DefaultConfig()
+	config.EnablePatterns = []string{"stdlib-idioms"}
+	if _, err := runWithDeps(pass, newAnalyzerOptions(WithConfig(config))); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	for _, d := range *diagnostics {
+		if d.Message == "time.Now().Sub(x) can be written as time.Since(x)" {
+			if len(d.SuggestedFixes) != 1 || string(d.SuggestedFixes[0].TextEdits[0].NewText) != "time.Since(start)" {
+				t.Errorf("Expected the deterministic fix on the diagnostic, got %v", d.SuggestedFixes)
+			}
+			return
+		}
+	}
+	t.Errorf("Expected a stdlib-idioms diagnostic, got %v", *diagnostics)
+}
+
+func TestDeferLogArgs(t *testing.T) {
+	code := `
+package main
+
+import (
+	"fmt"
+	stdlog "log"
+	"os"
+)
+
+type logger struct{}
+
+func (logger) Printf(format string, args ...interface{}) {}
+
+func process(name string, items []int) (count int) {
+	defer stdlog.Printf("processed %s: %d items", name, count)
+	defer fmt.Println(len(items))
+	defer fmt.Fprintln(os.Stderr, "done")
+	defer fmt.Fprintf(os.Stderr, "%s done\n", name)
+	defer fmt.Println("done", 42)
+	defer logger{}.Printf("processed %s", name)
+	defer func() {
+		stdlog.Printf("processed %s: %d items", name, count)
+	}()
+	count = len(items)
+	return count
+}
+`
+	issues := inspectSource(t, code, DefaultConfig())
+
+	tests := []struct {
+		name     string
+		contains string
+		expected int
+	}{
+		{"log.Printf through an aliased import", "deferred log.Printf evaluates and boxes its arguments", 1},
+		{"fmt.Println with a computed argument", "deferred fmt.Println evaluates and boxes its arguments", 1},
+		{"fmt.Fprintf with a computed argument", "deferred fmt.Fprintf evaluates", 1},
+		{"not the writer of fmt.Fprintln", "deferred fmt.Fprintln evaluates", 0},
+		{"not constant arguments, lookalike methods or deferred closures", "when the defer statement runs", 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := countMatching(issues, tt.contains); got != tt.expected {
+				t.Errorf("Expected %d issues containing %q, got %d: %v", tt.expected, tt.contains, got, issues)
+			}
+		})
+	}
+}
+
+func TestStringerSprintf(t *testing.T) {
+	code := `
+package main
+
+import "fmt"
+
+type Point struct{ X, Y int }
+
+func (p Point) String() string {
+	return fmt.Sprintf("(%d, %d)", p.X, p.Y)
+}
+
+type Span struct{ Start, End int }
+
+func (s *Span) String() string {
+	return fmt.Sprint(s.Start, "-", s.End)
+}
+
+type Label string
+
+func (l Label) String() Label {
+	return Label(fmt.Sprintf("<%s>", string(l)))
+}
+
+func (p Point) Describe() string {
+	return fmt.Sprintf("point %d, %d", p.X, p.Y)
+}
+
+func (p Point) Format(verbose bool) string {
+	return fmt.Sprintf("%v", verbose)
+}
+`
+	issues := inspectSource(t, code, DefaultConfig())
+
+	tests := []struct {
+		name     string
+		contains string
+		expected int
+	}{
+		{"Sprintf in a value receiver's String", "fmt.Sprintf in Point.String formats and allocates", 1},
+		{"Sprint in a pointer receiver's String", "fmt.Sprint in (*Span).String formats and allocates", 1},
+		{"not other signatures or other methods", "every time the value is printed", 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := countMatching(issues, tt.contains); got != tt.expected {
+				t.Errorf("Expected %d issues containing %q, got %d: %v", tt.expected, tt.contains, got, issues)
+			}
+		})
+	}
+}
+
+func TestHandlerBodyAlloc(t *testing.T) {
+	code := `
+package main
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"net/http"
+)
+
+type server struct{}
+
+func upload(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	buf := bytes.NewBuffer(body)
+	w.Write(buf.Bytes())
+}
+
+func (s *server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	old, _ := ioutil.ReadAll(r.Body)
+	w.Write(bytes.NewBufferString(string(old)).Bytes())
+}
+
+func register(mux *http.ServeMux) {
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		io.ReadAll(r.Body)
+	})
+}
+
+func load(rd io.Reader) []byte {
+	data, _ := io.ReadAll(rd)
+	return bytes.NewBuffer(data).Bytes()
+}
+`
+	issues := inspectSource(t, code, DefaultConfig())
+
+	tests := []struct {
+		name     string
+		contains string
+		expected int
+	}{
+		{"io.ReadAll in handler functions and literals", "io.ReadAll in an HTTP handler allocates a buffer for every request", 2},
+		{"ioutil.ReadAll in a ServeHTTP method", "ioutil.ReadAll in an HTTP handler", 1},
+		{"bytes.NewBuffer", "bytes.NewBuffer in an HTTP handler allocates a buffer for every request", 1},
+		{"bytes.NewBufferString", "bytes.NewBufferString in an HTTP handler", 1},
+		{"not outside handlers", "in an HTTP handler", 5},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := countMatching(issues, tt.contains); got != tt.expected {
+				t.Errorf("Expected %d issues containing %q, got %d: %v", tt.expected, tt.contains, got, issues)
+			}
+		})
+	}
+}
+
+func TestBufioPerCall(t *testing.T) {
+	code := `
+package main
+
+import (
+	"bufio"
+	"io"
+	"net/http"
+	"os"
+)
+
+func copyAll(files []*os.File, out io.Writer) {
+	for _, f := range files {
+		r := bufio.NewReader(f)
+		w := bufio.NewWriterSize(out, 1<<16)
+		r.WriteTo(w)
+		w.Flush()
+		sc := bufio.NewScanner(f)
+		_ = sc
+	}
+}
+
+func echo(w http.ResponseWriter, r *http.Request) {
+	bw := bufio.NewWriter(w)
+	bw.Flush()
+}
+
+func once(f *os.File) *bufio.Reader {
+	return bufio.NewReader(f)
+}
+`
+	issues := inspectSource(t, code, DefaultConfig())
+
+	tests := []struct {
+		name     string
+		contains string
+		expected int
+	}{
+		{"reader in a loop", "bufio.NewReader in a loop allocates a new buffer every time; create it once before the loop and call its Reset method to switch it to the next reader", 1},
+		{"sized writer in a loop", "bufio.NewWriterSize in a loop allocates a new buffer every time", 1},
+		{"scanner in a loop", "bufio.NewScanner in a loop allocates a new buffer every time; Scanner has no Reset", 1},
+		{"writer in a handler", "bufio.NewWriter in an HTTP handler allocates a new buffer every time; keep one in a sync.Pool and call its Reset method to switch it to the next writer", 1},
+		{"not in code that runs once", "allocates a new buffer every time", 4},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := countMatching(issues, tt.contains); got != tt.expected {
+				t.Errorf("Expected %d issues containing %q, got %d: %v", tt.expected, tt.contains, got, issues)
+			}
+		})
+	}
+}
+
+func TestHTTPClientPerCall(t *testing.T) {
+	code := `
+package main
+
+import (
+	"io"
+	"net/http"
+	"strings"
+)
+
+var client = &http.Client{}
+
+func fetchAll(urls []string) {
+	for _, u := range urls {
+		resp, err := http.Get(u)
+		if err != nil {
+			continue
+		}
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+	}
+	for _, u := range urls {
+		if resp, err := client.Get(u); err == nil {
+			resp.Body.Close()
+		}
+	}
+}
+
+func proxy(w http.ResponseWriter, r *http.Request) {
+	req, _ := http.NewRequest("POST", "http://backend/", strings.NewReader("x"))
+	if resp, err := http.DefaultClient.Do(req); err == nil {
+		resp.Body.Close()
+	}
+}
+
+func health() error {
+	resp, err := http.Head("http://localhost/")
+	if err == nil {
+		resp.Body.Close()
+	}
+	return err
+}
+`
+	issues := inspectSource(t, code, DefaultConfig())
+
+	tests := []struct {
+		name     string
+		contains string
+		expected int
+	}{
+		{"http.Get in a range loop", "http.Get in a loop sends every request through http.DefaultClient, which has no timeout and keeps at most 2 idle connections per host; consider a *http.Client with a Timeout and a Transport sized for the hosts it calls, created once and shared, and drain and close each response body so its connection can be reused", 1},
+		{"DefaultClient in a handler", "http.DefaultClient.Do in an HTTP handler sends every request through http.DefaultClient", 1},
+		{"not a shared client, or code that runs once", "sends every request through http.DefaultClient", 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := countMatching(issues, tt.contains); got != tt.expected {
+				t.Errorf("Expected %d issues containing %q, got %d: %v", tt.expected, tt.contains, got, issues)
+			}
+		})
+	}
+}
+
+func TestContextWithValueChain(t *testing.T) {
+	code := `
+package main
+
+import "context"
+
+type key int
+
+func chained(ctx context.Context, user, tenant, trace string) context.Context {
+	ctx = context.WithValue(ctx, key(0), user)
+	ctx = context.WithValue(ctx, key(1), tenant)
+	return context.WithValue(ctx, key(2), trace)
+}
+
+func nested(ctx context.Context) context.Context {
+	return context.WithValue(context.WithValue(ctx, key(0), 1), key(1), 2)
+}
+
+func single(ctx context.Context) context.Context {
+	handle := func(ctx context.Context) context.Context {
+		return context.WithValue(ctx, key(1), 2)
+	}
+	return handle(context.WithValue(ctx, key(0), 1))
+}
+`
+	issues := inspectSource(t, code, DefaultConfig())
+
+	tests := []struct {
+		name     string
+		contains string
+		expected int
+	}{
+		{"three chained calls", "context.WithValue is called 3 times in this function", 1},
+		{"nested calls", "context.WithValue is called 2 times in this function", 1},
+		{"reported once per function, not across literals", "context.WithValue is called", 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := countMatching(issues, tt.contains); got != tt.expected {
+				t.Errorf("Expected %d issues containing %q, got %d: %v", tt.expected, tt.contains, got, issues)
+			}
+		})
+	}
+}
+
+func TestOversplit(t *testing.T) {
+	code := `package main
+
+import (
+	"bytes"
+	"strings"
+)
+
+func host(addr string) string {
+	return strings.Split(addr, ":")[0]
+}
+
+func third(line []byte) []byte {
+	return bytes.Split(line, []byte(","))[2]
+}
+
+func pair(kv string) (string, string, bool) {
+	parts := strings.Split(kv, "=")
+	if len(parts) != 2 {
+		return "", "", false
+	}
+	return parts[0], parts[1], true
+}
+
+func fields(line string) []string {
+	parts := strings.Split(line, ",")
+	return parts
+}
+
+func last(path string) string {
+	parts := strings.Split(path, "/")
+	return parts[len(parts)-1]
+}
+
+func each(csv string) int {
+	var parts = strings.Split(csv, ",")
+	for range parts {
+	}
+	return len(parts)
+}
+`
+	pass, _ := newTestPass(t, code)
+
+	var found []Issue
+	for _, issue := range analyzeFile(pass.Files[0], pass.TypesInfo, pass.Fset, DefaultConfig()) {
+		if issue.Pattern == "oversplit" {
+			found = append(found, issue)
+		}
+	}
+
+	expected := []struct {
+		line  int
+		msg   string
+		fixed string
+	}{
+		{9, "strings.Split allocates a slice holding every field, but only the first is needed; strings.Cut returns it without allocating, or strings.SplitN(s, sep, 2) stops splitting after it", `	return strings.SplitN(addr, ":", 2)[0]`},
+		{13, "bytes.Split allocates a slice holding every field, but only the first 3 are needed; bytes.SplitN(s, sep, 4) stops splitting after them", `	return bytes.SplitN(line, []byte(","), 4)[2]`},
+		{17, "strings.Split allocates a slice holding every field, but only the first 2 are needed; strings.SplitN(s, sep, 3) stops splitting after them", `	parts := strings.SplitN(kv, "=", 3)`},
+	}
+	if len(found) != len(expected) {
+		t.Fatalf("Expected %d oversplit issues, got %d: %v", len(expected), len(found), found)
+	}
+
+	for i, want := range expected {
+		issue := found[i]
+		if issue.Pos.Line != want.line || issue.Message != want.msg {
+			t.Errorf("Expected %q on line %d, got %q on line %d", want.msg, want.line, issue.Message, issue.Pos.Line)
+		}
+		if len(issue.SuggestedFixes) != 1 {
+			t.Fatalf("Expected a fix, got %v", issue.SuggestedFixes)
+		}
+
+		fixed := []byte(code)
+		edits := issue.SuggestedFixes[0].TextEdits
+		for j := len(edits) - 1; j >= 0; j-- {
+			start, end := pass.Fset.Position(edits[j].Pos).Offset, pass.Fset.Position(edits[j].End).Offset
+			fixed = append(fixed[:start:start], append(edits[j].NewText, fixed[end:]...)...)
+		}
+		if got := splitLines(fixed)[want.line-1]; got != want.fixed {
+			t.Errorf("Expected line %d to become %q, got %q", want.line, want.fixed, got)
+		}
+	}
+}
+
+func TestNewValueType(t *testing.T) {
+	code := `package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math/big"
+	"sync"
+)
+
+func render(name string) string {
+	buf := new(bytes.Buffer)
+	buf.WriteString(name)
+	fmt.Fprintf(buf, "!")
+	var w io.Writer = buf
+	_ = w
+	return buf.String()
+}
+
+func square(n int64) string {
+	var x = new(big.Int)
+	x.SetInt64(n)
+	y := *x
+	return x.Mul(x, &y).String()
+}
+
+func reassigned() *bytes.Buffer {
+	buf := new(bytes.Buffer)
+	buf = new(bytes.Buffer)
+	return buf
+}
+
+func other() *sync.Mutex {
+	return new(sync.Mutex)
+}
+`
+	pass, _ := newTestPass(t, code)
+
+	var found []Issue
+	for _, issue := range analyzeFile(pass.Files[0], pass.TypesInfo, pass.Fset, DefaultConfig()) {
+		if issue.Pattern == "new-value-type" {
+			found = append(found, issue)
+		}
+	}
+
+	expected := []struct {
+		line   int
+		typ    string
+		hasFix bool
+	}{
+		{12, "bytes.Buffer", true},
+		{21, "big.Int", true},
+		{28, "bytes.Buffer", false},
+		{29, "bytes.Buffer", false},
+	}
+	if len(found) != len(expected) {
+		t.Fatalf("Expected %d new-value-type issues, got %d: %v", len(expected), len(found), found)
+	}
+	for i, want := range expected {
+		issue := found[i]
+		if issue.Pos.Line != want.line || !contains(issue.Message, "new("+want.typ+") is heap allocated") || !contains(issue.Message, "var x "+want.typ) {
+			t.Errorf("Expected new(%s) on line %d, got %q on line %d", want.typ, want.line, issue.Message, issue.Pos.Line)
+		}
+		if got := len(issue.SuggestedFixes) == 1; got != want.hasFix {
+			t.Errorf("Expected a fix on line %d: %v, got %v", want.line, want.hasFix, issue.SuggestedFixes)
+		}
+	}
+
+	// Apply both fixes, last first so earlier offsets stay valid
+	fixed := []byte(code)
+	for _, issue := range []Issue{found[1], found[0]} {
+		edits := issue.SuggestedFixes[0].TextEdits
+		for j := len(edits) - 1; j >= 0; j-- {
+			start, end := pass.Fset.Position(edits[j].Pos).Offset, pass.Fset.Position(edits[j].End).Offset
+			fixed = append(fixed[:start:start], append(edits[j].NewText, fixed[end:]...)...)
+		}
+	}
+	want := []string{
+		"\tvar buf bytes.Buffer",
+		"\tbuf.WriteString(name)",
+		"\tfmt.Fprintf(&buf, \"!\")",
+		"\tvar w io.Writer = &buf",
+		"\t_ = w",
+		"\treturn buf.String()",
+	}
+	lines := splitLines(fixed)
+	for i, line := range want {
+		if lines[11+i] != line {
+			t.Errorf("Expected line %d to become %q, got %q", 12+i, line, lines[11+i])
+		}
+	}
+	want = []string{
+		"\tvar x big.Int",
+		"\tx.SetInt64(n)",
+		"\ty := x",
+		"\treturn x.Mul(&x, &y).String()",
+	}
+	for i, line := range want {
+		if lines[20+i] != line {
+			t.Errorf("Expected line %d to become %q, got %q", 21+i, line, lines[20+i])
+		}
+	}
+
+	config := DefaultConfig()
+	config.ValueTypes = []string{"sync.Mutex"}
+	var mutex int
+	for _, issue := range analyzeFile(pass.Files[0], pass.TypesInfo, pass.Fset, config) {
+		if issue.Pattern == "new-value-type" && contains(issue.Message, "new(sync.Mutex)") {
+			mutex++
+		}
+	}
+	if mutex != 1 {
+		t.Errorf("Expected -value-types to add sync.Mutex, got %d issues", mutex)
+	}
+}
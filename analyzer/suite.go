@@ -0,0 +1,92 @@
+package analyzer
+
+import (
+	"reflect"
+	"strings"
+
+	"github.com/harriteja/gostackallocator/internal"
+	"golang.org/x/tools/go/analysis"
+)
+
+// suiteAnalyzer walks each package once on behalf of every analyzer returned
+// by Analyzers, with every registered rule enabled. Its result is the []Issue
+// found in the package, before any severity policy is applied.
+var suiteAnalyzer = &analysis.Analyzer{
+	Name:       "stackalloc_suite",
+	Doc:        "collects the findings of every stackalloc rule for the analyzers of the suite",
+	Run:        runSuite,
+	ResultType: reflect.TypeOf([]Issue(nil)),
+	FactTypes:  []analysis.Fact{new(EscapeFact)},
+}
+
+// suiteConfig returns the configuration the suite runs every rule under: the
+// defaults, with off-by-default rules enabled and AI suggestions disabled
+func suiteConfig() *Config {
+	config := DefaultConfig()
+	config.OpenAIDisable = true
+	for _, rule := range Rules() {
+		config.EnablePatterns = append(config.EnablePatterns, rule.Name)
+	}
+	return config
+}
+
+// runSuite analyzes the package's files with every rule enabled
+func runSuite(pass *analysis.Pass) (interface{}, error) {
+	config := suiteConfig()
+	logger := internal.GetLogger()
+	if err := checkTypesInfo(pass, config, logger); err != nil {
+		return nil, err
+	}
+
+	var issues []Issue
+	pkg := newPackageInfo(pass, config)
+	for _, file := range analyzedFiles(pass.Files, pass.Fset, config, logger) {
+		issues = append(issues, analyzeFileWithLogger(file, pass.TypesInfo, pkg, pass.Fset, config, logger)...)
+	}
+	return issues, nil
+}
+
+// Analyzers returns one *analysis.Analyzer per registered rule, in registry
+// order, for drivers such as multichecker that enable and disable analyzers
+// themselves. Each reports only its own rule's findings, with the rule's
+// deterministic fixes, while the packages are walked just once for all of
+// them. Every rule is included, off-by-default ones too; filter on
+// Rule.DefaultEnabled to leave those out. Findings not attributed to a rule
+// are only reported by Analyzer.
+//
+// Analyzer names are the rule names with dashes replaced by underscores, since
+// they must be identifiers, such as append_boxing for append-boxing.
+func Analyzers() []*analysis.Analyzer {
+	rules := Rules()
+	analyzers := make([]*analysis.Analyzer, 0, len(rules))
+	for _, rule := range rules {
+		analyzers = append(analyzers, newRuleAnalyzer(rule))
+	}
+	return analyzers
+}
+
+// newRuleAnalyzer returns an analyzer reporting the findings of rule collected
+// by suiteAnalyzer
+func newRuleAnalyzer(rule Rule) *analysis.Analyzer {
+	name := rule.Name
+	return &analysis.Analyzer{
+		Name:     strings.ReplaceAll(name, "-", "_"),
+		Doc:      rule.Description,
+		Requires: []*analysis.Analyzer{suiteAnalyzer},
+		Run: func(pass *analysis.Pass) (interface{}, error) {
+			for _, issue := range pass.ResultOf[suiteAnalyzer].([]Issue) {
+				if issue.Pattern != name {
+					continue
+				}
+				pass.Report(analysis.Diagnostic{
+					Pos:            tokenPos(pass.Fset, issue.Pos),
+					End:            tokenPos(pass.Fset, issue.End),
+					Category:       name,
+					Message:        issue.Message,
+					SuggestedFixes: issue.SuggestedFixes,
+				})
+			}
+			return nil, nil
+		},
+	}
+}
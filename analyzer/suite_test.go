@@ -0,0 +1,64 @@
+package analyzer
+
+import (
+	"strings"
+	"testing"
+
+	"golang.org/x/tools/go/analysis"
+)
+
+func TestAnalyzers(t *testing.T) {
+	analyzers := Analyzers()
+	if err := analysis.Validate(analyzers); err != nil {
+		t.Fatalf("Expected valid analyzers, got %v", err)
+	}
+	if len(analyzers) != len(Rules()) {
+		t.Fatalf("Expected one analyzer per rule, got %d for %d rules", len(analyzers), len(Rules()))
+	}
+
+	code := `package main
+
+import "bytes"
+
+func render(parts [][]byte, keys []string) []any {
+	buf := new(bytes.Buffer)
+	for _, p := range parts {
+		buf.Write(p)
+	}
+	var out []any
+	for _, k := range keys {
+		out = append(out, len(k))
+	}
+	return out
+}
+`
+	pass, diagnostics := newTestPass(t, code)
+	pass.Analyzer = suiteAnalyzer
+	result, err := suiteAnalyzer.Run(pass)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	pass.ResultOf = map[*analysis.Analyzer]interface{}{suiteAnalyzer: result}
+
+	reported := make(map[string]int)
+	for _, a := range analyzers {
+		*diagnostics = nil
+		pass.Analyzer = a
+		if _, err := a.Run(pass); err != nil {
+			t.Fatalf("%s: unexpected error: %v", a.Name, err)
+		}
+		for _, d := range *diagnostics {
+			// Each analyzer reports only its own rule
+			if strings.ReplaceAll(d.Category, "-", "_") != a.Name {
+				t.Errorf("%s reported a %s finding: %s", a.Name, d.Category, d.Message)
+			}
+			reported[d.Category]++
+		}
+	}
+
+	for _, rule := range []string{"new-value-type", "append-boxing"} {
+		if reported[rule] != 1 {
+			t.Errorf("Expected one %s finding, got %d", rule, reported[rule])
+		}
+	}
+}
@@ -0,0 +1,44 @@
+package analyzer
+
+import (
+	"go/parser"
+	"go/token"
+	"go/types"
+
+	"github.com/harriteja/gostackallocator/internal"
+)
+
+// AnalyzeSource runs the detectors that don't need type information over the
+// source text of a single file. It never reads from disk or loads packages, so
+// it can run wherever the Go runtime does, including WebAssembly, where the
+// playground package exposes it to JavaScript.
+func AnalyzeSource(filename string, src []byte, config *Config) ([]Issue, error) {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, filename, src, parser.ParseComments)
+	if err != nil {
+		return nil, err
+	}
+
+	var issues []Issue
+	inspectFile(file, &types.Info{}, nil, fset, config, internal.GetLogger(), func(issue Issue) {
+		issues = append(issues, issue)
+	})
+	return issues, nil
+}
+
+// AnalyzeSourceResults is AnalyzeSource with each issue described by a Result.
+// Without type information sizes aren't known, so Bytes is always -1.
+func AnalyzeSourceResults(filename string, src []byte, config *Config) ([]Result, error) {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, filename, src, parser.ParseComments)
+	if err != nil {
+		return nil, err
+	}
+
+	info := &types.Info{}
+	var issues []Issue
+	inspectFile(file, info, nil, fset, config, internal.GetLogger(), func(issue Issue) {
+		issues = append(issues, issue)
+	})
+	return newResults(file, info, nil, fset, config, issues), nil
+}
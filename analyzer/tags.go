@@ -0,0 +1,100 @@
+package analyzer
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/harriteja/gostackallocator/internal"
+)
+
+// pathTags is a flag.Value for Config.PathTags, parsing a comma-separated
+// list of prefix=tag pairs such as internal/billing=team-payments. A prefix
+// listed more than once gets every tag given for it.
+type pathTags struct {
+	m *map[string][]string
+}
+
+// String returns the pairs sorted by prefix
+func (p pathTags) String() string {
+	if p.m == nil {
+		return ""
+	}
+	var pairs []string
+	for prefix, tags := range *p.m {
+		for _, tag := range tags {
+			pairs = append(pairs, prefix+"="+tag)
+		}
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
+
+// Set parses the pairs, replacing any set before
+func (p pathTags) Set(value string) error {
+	tags := make(map[string][]string)
+	for _, pair := range strings.Split(value, ",") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+		prefix, tag, ok := strings.Cut(pair, "=")
+		prefix, tag = strings.TrimSpace(prefix), strings.TrimSpace(tag)
+		if !ok || prefix == "" || tag == "" {
+			return fmt.Errorf("invalid path tag %q (want prefix=tag)", pair)
+		}
+		tags[prefix] = append(tags[prefix], tag)
+	}
+	*p.m = tags
+	return nil
+}
+
+// fileTags returns the tags Config.PathTags gives the file at filename: those
+// of every prefix it is under, in order of prefix and without duplicates.
+// Prefixes are slash-separated paths relative to the module root, or absolute
+// paths, and match whole path segments, so internal/pay doesn't match
+// internal/payments; "." matches every file.
+func fileTags(filename string, tags map[string][]string) []string {
+	abs, err := filepath.Abs(filename)
+	if err != nil {
+		return nil
+	}
+	rel := ""
+	if root, err := internal.GetProjectRoot(filepath.Dir(abs)); err == nil {
+		if r, err := filepath.Rel(root, abs); err == nil && !strings.HasPrefix(r, "..") {
+			rel = filepath.ToSlash(r)
+		}
+	}
+
+	prefixes := make([]string, 0, len(tags))
+	for prefix := range tags {
+		prefixes = append(prefixes, prefix)
+	}
+	sort.Strings(prefixes)
+
+	var result []string
+	seen := make(map[string]bool)
+	for _, prefix := range prefixes {
+		file := rel
+		if filepath.IsAbs(prefix) {
+			file = filepath.ToSlash(abs)
+		}
+		if file == "" || !underPrefix(file, path.Clean(filepath.ToSlash(prefix))) {
+			continue
+		}
+		for _, tag := range tags[prefix] {
+			if !seen[tag] {
+				seen[tag] = true
+				result = append(result, tag)
+			}
+		}
+	}
+	return result
+}
+
+// underPrefix reports whether the slash-separated path file is prefix or
+// inside it
+func underPrefix(file, prefix string) bool {
+	return prefix == "." || file == prefix || strings.HasPrefix(file, strings.TrimSuffix(prefix, "/")+"/")
+}
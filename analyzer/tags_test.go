@@ -0,0 +1,121 @@
+package analyzer
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestPathTagsFlag(t *testing.T) {
+	var tags map[string][]string
+	if err := (pathTags{&tags}).Set("internal/billing=team-payments, internal/billing=pci,cmd=team-cli"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	want := map[string][]string{"internal/billing": {"team-payments", "pci"}, "cmd": {"team-cli"}}
+	if !reflect.DeepEqual(tags, want) {
+		t.Errorf("Expected %v, got %v", want, tags)
+	}
+	if got := (pathTags{&tags}).String(); got != "cmd=team-cli,internal/billing=pci,internal/billing=team-payments" {
+		t.Errorf("Unexpected String() %q", got)
+	}
+
+	for _, value := range []string{"internal/billing", "=team", "cmd="} {
+		if err := (pathTags{&tags}).Set(value); err == nil {
+			t.Errorf("Expected an error for %q", value)
+		}
+	}
+}
+
+func TestPathTags(t *testing.T) {
+	root := t.TempDir()
+	files := map[string]string{
+		"go.mod": "module example.com/shop\n\ngo 1.22\n",
+		"internal/billing/charge.go": `package billing
+
+func Charge() *int {
+	return new(int)
+}
+`,
+		"internal/payments/refund.go": `package payments
+
+func Refund() *int {
+	return new(int)
+}
+`,
+	}
+	for name, content := range files {
+		path := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	config := DefaultConfig()
+	config.OpenAIDisable = true
+	config.PathTags = map[string][]string{
+		"internal/billing": {"team-payments", "pci"},
+		"internal/pay":     {"unrelated"},
+		".":                {"shop"},
+	}
+	results, err := AnalyzePackages(root, []string{"./..."}, config)
+	if err != nil {
+		t.Fatalf("AnalyzePackages failed: %v", err)
+	}
+
+	var buf bytes.Buffer
+	if err := WriteResults(&buf, "json", results); err != nil {
+		t.Fatalf("WriteResults failed: %v", err)
+	}
+	var decoded []JSONResult
+	if err := json.Unmarshal(buf.Bytes(), &decoded); err != nil {
+		t.Fatalf("Invalid JSON: %v\n%s", err, buf.String())
+	}
+
+	expected := map[string][]string{
+		"charge.go": {"shop", "team-payments", "pci"},
+		"refund.go": {"shop"},
+	}
+	seen := make(map[string]bool)
+	for _, r := range decoded {
+		name := filepath.Base(r.File)
+		seen[name] = true
+		if want := expected[name]; !reflect.DeepEqual(r.Tags, want) {
+			t.Errorf("Expected tags %v for %s, got %v", want, name, r.Tags)
+		}
+	}
+	if len(seen) != len(expected) {
+		t.Errorf("Expected findings in %d files, got %s", len(expected), buf.String())
+	}
+
+	buf.Reset()
+	if err := WriteResults(&buf, "sarif", results); err != nil {
+		t.Fatalf("WriteResults failed: %v", err)
+	}
+	var log struct {
+		Runs []struct {
+			Results []struct {
+				Properties struct {
+					Tags []string `json:"tags"`
+				} `json:"properties"`
+			} `json:"results"`
+		} `json:"runs"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &log); err != nil {
+		t.Fatalf("Invalid SARIF: %v", err)
+	}
+	tagged := 0
+	for _, r := range log.Runs[0].Results {
+		if reflect.DeepEqual(r.Properties.Tags, expected["charge.go"]) {
+			tagged++
+		}
+	}
+	if tagged == 0 {
+		t.Errorf("Expected the tags as a SARIF result property, got %s", buf.String())
+	}
+}
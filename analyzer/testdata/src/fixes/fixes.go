@@ -0,0 +1,33 @@
+package fixes
+
+import (
+	"bytes"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+func extend(a []int, x, y int) []int {
+	return append(a, []int{x, y}...) // want `append\(a, \[\]T\{...\}...\) builds a temporary slice`
+}
+
+func clone(a []int) []int {
+	return append([]int(nil), a...) // want `append\(\[\]int\(nil\), a...\) allocates a copy of a`
+}
+
+func label(id int) []byte {
+	return []byte(fmt.Sprintf("id-%d", id)) // want `\[\]byte\(fmt.Sprintf\(...\)\) allocates the string`
+}
+
+func key(s string) string {
+	return strings.Split(s, "=")[0] // want `strings.Split`
+}
+
+func render(n int) string {
+	buf := new(bytes.Buffer) // want `new\(bytes.Buffer\)`
+	fmt.Fprintf(buf, "%d", n)
+	return buf.String()
+}
+
+// slices is imported for the fix to use
+var _ = slices.Clone[[]int]
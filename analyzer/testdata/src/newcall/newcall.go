@@ -0,0 +1,7 @@
+package newcall
+
+func greeting(name string) string {
+	s := new(string) // want `new\(T\) always allocates on heap` `new\(T\) in return/assignment`
+	*s = name
+	return *s
+}
@@ -3,25 +3,93 @@ package analyzer
 import (
 	"context"
 	"go/token"
+	"time"
+
+	"golang.org/x/tools/go/analysis"
 )
 
 // Issue represents a detected allocation issue
 type Issue struct {
-	Pos     token.Position // file:line:col
-	Message string         // suggestion text
+	Pos            token.Position          // file:line:col
+	End            token.Position          // end of the offending node, if known
+	Node           string                  // kind of the offending node, such as "CallExpr"
+	Message        string                  // suggestion text
+	Pattern        string                  // name of the rule that reported the issue, if any
+	Severity       Severity                // how likely the issue is to cost an allocation
+	SuggestedFixes []analysis.SuggestedFix // deterministic fixes provided by the detector
+	Source         *IssueSource            // source around the issue, with -include-source
+	Fixes          []IssueFix              // fixes that would be applied, with -include-fixes
+	Tags           []string                // tags given to the issue's file by Config.PathTags
+}
+
+// IssueSource is the source text around an issue, which -include-source embeds
+// in JSON and SARIF output. Lines longer than maxSourceLineLength are
+// truncated.
+type IssueSource struct {
+	Line      string `json:"line"`      // the line the issue starts on
+	StartLine int    `json:"startLine"` // number of the first line of Context
+	Context   string `json:"context"`   // the issue's line and up to two lines either side
+}
+
+// IssueFix is a fix for an issue with its edits addressed by byte offsets in
+// the issue's file, which -include-fixes embeds in JSON output so that other
+// tools can present or apply it
+type IssueFix struct {
+	Message string      `json:"message"`
+	Edits   []SavedEdit `json:"edits"`
 }
 
 // Config holds configuration options for the analyzer
 type Config struct {
 	MaxAllocSize      int      // Maximum bytes to consider "small"
 	DisablePatterns   []string // List of detectors to skip
+	EnablePatterns    []string // List of off-by-default detectors to run
+	OnlyPatterns      []string // When set, run only these named detectors
 	MetricsEnabled    bool     // Expose Prometheus metrics
 	OpenAIAPIKey      string   // OpenAI API key
 	OpenAIModel       string   // OpenAI model to use
+	OpenAIFallbacks   []string // Models tried in order when OpenAIModel is unavailable or rate limited
 	OpenAIMaxTokens   int      // Maximum tokens for OpenAI response
 	OpenAITemperature float32  // Temperature for OpenAI requests
 	OpenAIDisable     bool     // Disable AI suggestions
+	AILogRequests     bool     // Log AI prompts and responses at debug level
+	AILogSnippets     bool     // Include code snippets in logged AI prompts instead of redacting them
 	AutoFix           bool     // Enable automatic code fixes
+	FailOnFix         bool     // Fail instead of writing when autofix would modify files
+	Annotate          bool     // Insert a TODO comment above each finding instead of rewriting code
+	Interactive       bool     // Confirm each fix on the terminal before applying it
+	ValidateFixes     bool     // Discard AutoFixer fixes, including AI ones, that don't parse or type check
+	SaveFixes         string   // File the generated fixes are appended to for -apply-fixes
+	SkipCgo           bool     // Skip cgo files instead of running syntactic detectors on them
+	IncludeInit       bool     // Report findings in init functions and package-level var initializers
+	RequireTypes      bool     // Fail instead of warning when the package has no type information
+	ErrorSeverity     Severity // Minimum severity that fails the run
+	WarnSeverity      Severity // Minimum severity printed as a warning
+	MaxIssues         int      // Error-level issues tolerated per package before failing
+	MaxIssuesPerFile  int      // Issues reported per file before the rest are suppressed; 0 means no limit
+	MaxDepth          int      // Deepest AST nesting analyzed; deeper declarations are skipped. 0 means no limit
+	MaxFileSize       int      // Largest file analyzed, in KB; larger files are skipped. 0 means no limit
+	MinLoopIterations int      // Loops with a constant iteration count below this aren't treated as repeating; 0 means every loop is
+	SampleRate        float64  // Fraction of issues reported, chosen by a hash of their position; 0 or 1 reports all
+	Pprof             string   // pprof profile limiting findings to the functions it samples, annotated with their weight
+	PprofThreshold    float64  // Percentage of the profile's samples a function needs for its findings to be reported under Pprof
+	Verbose           bool     // Also report low-signal findings that are suppressed by default
+	Strict            bool     // Report only findings of rules that prove the allocation, dropping heuristic ones
+	GenericInstances  bool     // List instantiations of generic functions in allocation issues
+	SuggestGenerics   bool     // Suggest type parameters in boxing issues where the declaration allows them
+	Format            string   // Output format: text, json, jsonl, sarif, github or csv
+	Report            string   // What to report: every issue, or "counts" for just the aggregate counts
+	IncludeSource     bool     // Embed the source lines around each issue in JSON and SARIF output
+	IncludeFixes      bool     // Embed the fixes for each issue in JSON output without applying them
+	ShowFix           bool     // Append the replacement of each issue's deterministic fix to its message
+	FormatFuncs       []string // Fully qualified functions that allocate like fmt, such as example.com/log.Infof
+	ValueTypes        []string // Fully qualified types, such as example.com/pool.Buffer, to declare with var rather than new
+	ExcludeFiles      []string // Paths left out of the analysis, in .gitignore syntax, after those of .stackallocignore
+
+	MessageTemplates map[string]string   // text/template per message or rule name, overriding the built-in wording
+	RuleSeverities   map[string]Severity // Severity per rule name, overriding the rule's default
+	PathTags         map[string][]string // Tags attached to the issues in files under each path prefix, relative to the module root
+	AITimeBudget     time.Duration       // Total time spent waiting for AI suggestions before the rest are skipped; 0 means no limit
 }
 
 // DefaultConfig returns a configuration with sensible defaults
@@ -29,12 +97,17 @@ func DefaultConfig() *Config {
 	return &Config{
 		MaxAllocSize:      32,
 		DisablePatterns:   []string{},
+		EnablePatterns:    []string{},
 		MetricsEnabled:    false,
 		OpenAIModel:       "gpt-4",
 		OpenAIMaxTokens:   512,
 		OpenAITemperature: 0.2,
 		OpenAIDisable:     false,
 		AutoFix:           false, // Disabled by default for safety
+		ErrorSeverity:     SeverityInfo,
+		WarnSeverity:      SeverityInfo,
+		MaxDepth:          defaultMaxDepth,
+		Format:            "text",
 	}
 }
 
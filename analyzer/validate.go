@@ -0,0 +1,174 @@
+package analyzer
+
+import (
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"sort"
+	"sync"
+
+	"go.uber.org/zap"
+	"golang.org/x/tools/go/analysis"
+)
+
+// fixPackage is the package of the files being fixed, used to type check a
+// fixed file along with the rest of the package
+type fixPackage struct {
+	pkg   *types.Package
+	files []string // names of the package's files
+	sizes types.Sizes
+
+	once     sync.Once
+	checkErr error // error type checking the package as it is
+}
+
+// newFixPackage describes the package analyzed by pass, or returns nil if its
+// type information is missing
+func newFixPackage(pass *analysis.Pass) *fixPackage {
+	if pass.Pkg == nil || pass.TypesInfo == nil {
+		return nil
+	}
+	fp := &fixPackage{pkg: pass.Pkg, sizes: pass.TypesSizes}
+	for _, file := range pass.Files {
+		fp.files = append(fp.files, pass.Fset.Position(file.Pos()).Filename)
+	}
+	return fp
+}
+
+// check type checks the package with the contents of filename replaced by
+// src, importing the packages the analyzed package imports
+func (fp *fixPackage) check(filename string, src []byte, sources *SourceCache) error {
+	fset := token.NewFileSet()
+	var files []*ast.File
+	for _, name := range fp.files {
+		content := src
+		if name != filename {
+			var ok bool
+			if content, ok = sources.Read(name); !ok {
+				return fmt.Errorf("can't read %s", name)
+			}
+		}
+		file, err := parser.ParseFile(fset, name, content, 0)
+		if err != nil {
+			return err
+		}
+		files = append(files, file)
+	}
+
+	imports := make(map[string]*types.Package)
+	for _, imported := range fp.pkg.Imports() {
+		imports[imported.Path()] = imported
+	}
+	conf := types.Config{
+		Importer: importerFunc(func(path string) (*types.Package, error) {
+			if imported, ok := imports[path]; ok {
+				return imported, nil
+			}
+			return nil, fmt.Errorf("package %s isn't imported by %s", path, fp.pkg.Path())
+		}),
+		Sizes:       fp.sizes,
+		FakeImportC: true,
+		GoVersion:   fp.pkg.GoVersion(),
+	}
+	_, err := conf.Check(fp.pkg.Path(), fset, files, nil)
+	return err
+}
+
+// typeCheck reports whether src, the fixed contents of filename, still type
+// checks with the rest of the package. Packages that don't type check as they
+// are, or whose other files can't be read, aren't checked.
+func (fp *fixPackage) typeCheck(filename string, src []byte, sources *SourceCache) error {
+	if fp == nil {
+		return nil
+	}
+	fp.once.Do(func() {
+		if original, ok := sources.Read(filename); ok {
+			fp.checkErr = fp.check(filename, original, sources)
+		} else {
+			fp.checkErr = fmt.Errorf("can't read %s", filename)
+		}
+	})
+	if fp.checkErr != nil {
+		return nil
+	}
+	if err := fp.check(filename, src, sources); err != nil {
+		return fmt.Errorf("fixed source doesn't type check: %v", err)
+	}
+	return nil
+}
+
+// importerFunc implements types.Importer with a function
+type importerFunc func(path string) (*types.Package, error)
+
+func (f importerFunc) Import(path string) (*types.Package, error) {
+	return f(path)
+}
+
+// validateFixes returns the fixes that leave the file containing issue
+// parseable, and type checking with the rest of pkg when it is known, logging
+// why each of the others was discarded. Edit positions are resolved in fset.
+func validateFixes(issue Issue, fixes []analysis.SuggestedFix, fset *token.FileSet, sources *SourceCache, pkg *fixPackage) []analysis.SuggestedFix {
+	src, ok := sources.Read(issue.Pos.Filename)
+	if !ok {
+		sources.logger.Info("Discarding fixes that can't be validated without the source",
+			zap.String("file", issue.Pos.Filename),
+		)
+		return nil
+	}
+
+	var valid []analysis.SuggestedFix
+	for _, fix := range fixes {
+		if err := validateFix(fset, issue.Pos.Filename, src, fix, sources, pkg); err != nil {
+			sources.logger.Info("Discarding invalid fix",
+				zap.String("file", issue.Pos.Filename),
+				zap.Int("line", issue.Pos.Line),
+				zap.String("fix", fix.Message),
+				zap.Error(err),
+			)
+			continue
+		}
+		valid = append(valid, fix)
+	}
+	return valid
+}
+
+// validateFix applies fix to a copy of src, the contents of filename, and
+// checks that the result parses and type checks in pkg
+func validateFix(fset *token.FileSet, filename string, src []byte, fix analysis.SuggestedFix, sources *SourceCache, pkg *fixPackage) error {
+	fixed, err := applyFileEdits(fset, filename, src, fix.TextEdits)
+	if err != nil {
+		return err
+	}
+	if _, err := parser.ParseFile(token.NewFileSet(), filename, fixed, parser.AllErrors); err != nil {
+		return fmt.Errorf("fixed source doesn't parse: %v", err)
+	}
+	return pkg.typeCheck(filename, fixed, sources)
+}
+
+// applyFileEdits returns a copy of src, the contents of filename, with edits
+// applied. It fails if an edit's positions aren't in filename in fset.
+func applyFileEdits(fset *token.FileSet, filename string, src []byte, edits []analysis.TextEdit) ([]byte, error) {
+	sorted := append([]analysis.TextEdit(nil), edits...)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Pos > sorted[j].Pos
+	})
+
+	result := append([]byte(nil), src...)
+	limit := len(src)
+	for _, edit := range sorted {
+		startPos, endPos := fset.Position(edit.Pos), fset.Position(edit.End)
+		if startPos.Filename != filename || endPos.Filename != filename {
+			return nil, errors.New("edit is outside the file")
+		}
+		start, end := startPos.Offset, endPos.Offset
+		if start > end || end > limit {
+			return nil, errors.New("edit is out of range or overlaps another edit")
+		}
+		result = append(result[:start], append(append([]byte(nil), edit.NewText...), result[end:]...)...)
+		limit = start
+	}
+	return result, nil
+}
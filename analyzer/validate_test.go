@@ -0,0 +1,214 @@
+package analyzer
+
+import (
+	"context"
+	"go/token"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+	"golang.org/x/tools/go/analysis"
+)
+
+const validateTestCode = `package main
+
+type item struct{ id int }
+
+func main() {
+	p := new(item)
+	s := new(string)
+	println(p.id, *s)
+}
+`
+
+// validateTestIssue writes validateTestCode to a temporary file, adds it to a
+// new file set and returns the new(T) issue for expr in it
+func validateTestIssue(t *testing.T, expr string) (Issue, *token.FileSet) {
+	t.Helper()
+
+	filename := filepath.Join(t.TempDir(), "main.go")
+	if err := os.WriteFile(filename, []byte(validateTestCode), 0644); err != nil {
+		t.Fatalf("Failed to write test file: %v", err)
+	}
+	// Another file of the package comes first, as it would under go vet
+	fset := token.NewFileSet()
+	fset.AddFile("other.go", -1, 1000)
+	fset.AddFile(filename, -1, len(validateTestCode)).SetLinesForContent([]byte(validateTestCode))
+
+	offset := strings.Index(validateTestCode, expr)
+	line := strings.Count(validateTestCode[:offset], "\n") + 1
+	column := offset - strings.LastIndex(validateTestCode[:offset], "\n")
+	return Issue{
+		Pos:     token.Position{Filename: filename, Offset: offset, Line: line, Column: column},
+		Node:    "CallExpr",
+		Message: "new(T) always allocates on heap; consider using stack allocation if object doesn't escape",
+	}, fset
+}
+
+func TestValidateFixes(t *testing.T) {
+	issue, fset := validateTestIssue(t, "new(item)")
+	start := tokenPos(fset, issue.Pos)
+	end := start + token.Pos(len("new(item)"))
+
+	fix := func(message, text string, start, end token.Pos) analysis.SuggestedFix {
+		return analysis.SuggestedFix{
+			Message:   message,
+			TextEdits: []analysis.TextEdit{{Pos: start, End: end, NewText: []byte(text)}},
+		}
+	}
+	fixes := []analysis.SuggestedFix{
+		fix("AI statement in expression position", "var value item; &value", start, end),
+		fix("composite literal", "&item{}", start, end),
+		// A byte offset isn't a position in the file set
+		fix("offset", "&item{}", token.Pos(issue.Pos.Offset), token.Pos(issue.Pos.Offset+len("new(item)"))),
+	}
+
+	core, logs := observer.New(zapcore.InfoLevel)
+	sources := NewSourceCache(zap.New(core))
+
+	valid := validateFixes(issue, fixes, fset, sources, nil)
+	if len(valid) != 1 || valid[0].Message != "composite literal" {
+		t.Fatalf("Expected only the composite literal fix to be kept, got %v", valid)
+	}
+
+	entries := logs.FilterMessage("Discarding invalid fix").All()
+	if len(entries) != 2 {
+		t.Fatalf("Expected the malformed and misplaced fixes to be logged, got %v", logs.All())
+	}
+	if fields := entries[0].ContextMap(); fields["fix"] != "AI statement in expression position" || !strings.Contains(fields["error"].(string), "doesn't parse") {
+		t.Errorf("Expected the discarded fix and reason to be logged, got %v", fields)
+	}
+	if fields := entries[1].ContextMap(); fields["fix"] != "offset" || !strings.Contains(fields["error"].(string), "outside the file") {
+		t.Errorf("Expected the misplaced fix and reason to be logged, got %v", fields)
+	}
+}
+
+func TestGenerateCodeFixesValidation(t *testing.T) {
+	config := DefaultConfig()
+	config.ValidateFixes = true
+	sources := NewSourceCache(nil)
+
+	// new(string) has a zero value to replace it with, positioned in the file
+	// set so editors can apply it
+	issue, fset := validateTestIssue(t, "new(string)")
+	fixes := generateCodeFixes(issue, "", fset, config, sources, nil)
+	if len(fixes) != 1 || fixes[0].Message != `Replace new(string) with ""` {
+		t.Fatalf("Expected a fix replacing new(string), got %v", fixes)
+	}
+	fixed, err := applyFileEdits(fset, issue.Pos.Filename, []byte(validateTestCode), fixes[0].TextEdits)
+	if err != nil {
+		t.Fatalf("Expected the fix to apply, got %v", err)
+	}
+	if !strings.Contains(string(fixed), "\ts := \"\"\n") {
+		t.Errorf("Expected new(string) to be replaced, got:\n%s", fixed)
+	}
+
+	// A type without a known zero value gets no fix rather than a placeholder
+	issue, fset = validateTestIssue(t, "new(item)")
+	if fixes := generateCodeFixes(issue, "nonsense", fset, config, sources, nil); len(fixes) != 0 {
+		t.Errorf("Expected no fix for new(item), got %v", fixes)
+	}
+}
+
+func TestValidateFixesTypeCheck(t *testing.T) {
+	code := `package main
+
+func label() string {
+	s := new(string)
+	*s = "x"
+	return *s
+}
+`
+	pass, _ := newTestPass(t, code)
+	filename := pass.Fset.File(pass.Files[0].Pos()).Name()
+	offset := strings.Index(code, "new(string)")
+	issue := Issue{Pos: token.Position{Filename: filename, Offset: offset, Line: 4, Column: 7}, Node: "CallExpr"}
+	start := tokenPos(pass.Fset, issue.Pos)
+
+	// Replacing new(string) alone leaves *s dereferencing a string
+	fixes := []analysis.SuggestedFix{{
+		Message:   "value only",
+		TextEdits: []analysis.TextEdit{{Pos: start, End: start + token.Pos(len("new(string)")), NewText: []byte(`""`)}},
+	}}
+	fixes = append(fixes, generateCodeFixes(issue, "", pass.Fset, DefaultConfig(), NewSourceCache(nil), nil)...)
+
+	core, logs := observer.New(zapcore.InfoLevel)
+	sources := NewSourceCache(zap.New(core))
+
+	// Both parse, so only the type check tells them apart
+	if valid := validateFixes(issue, fixes, pass.Fset, sources, nil); len(valid) != 2 {
+		t.Errorf("Expected both fixes to parse, got %v", valid)
+	}
+	valid := validateFixes(issue, fixes, pass.Fset, sources, newFixPackage(pass))
+	if len(valid) != 1 || valid[0].Message != `Replace new(string) with ""` {
+		t.Fatalf("Expected only the fix rewriting *s to be kept, got %v", valid)
+	}
+	entries := logs.FilterMessage("Discarding invalid fix").All()
+	if len(entries) != 1 || !strings.Contains(entries[0].ContextMap()["error"].(string), "doesn't type check") {
+		t.Errorf("Expected the type error to be logged, got %v", logs.All())
+	}
+}
+
+// scriptedAIClient answers every issue with the same suggestion
+type scriptedAIClient struct {
+	suggestion string
+}
+
+func (s *scriptedAIClient) SuggestFix(ctx context.Context, snippet, issueMsg string) (string, error) {
+	return s.suggestion, nil
+}
+
+func TestValidateAIFixes(t *testing.T) {
+	code := `package main
+
+type item struct{ id int }
+
+func main() {
+	p := new(item)
+	p.id = 1
+	println(p.id)
+}
+`
+	suggestion := func(after string) string {
+		return "Before:\n```go\np := new(item)\n```\n\nAfter:\n```go\n" + after + "\n```\n\nThe value can stay on the stack."
+	}
+
+	tests := []struct {
+		name     string
+		after    string
+		validate bool
+		want     string
+	}{
+		{"valid", "var value item\np := &value", true, "\tvar value item\n\tp := &value\n\tp.id = 1\n"},
+		{"doesn't parse", "p := &item{", true, "\tp := new(item)\n"},
+		{"doesn't type check", "p := &value", true, "\tp := new(item)\n"},
+		{"not validated", "p := &value", false, "\tp := &value\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pass, _ := newTestPass(t, code)
+			filename := pass.Fset.File(pass.Files[0].Pos()).Name()
+
+			config := DefaultConfig()
+			config.AutoFix = true
+			config.ValidateFixes = tt.validate
+			client := &scriptedAIClient{suggestion: suggestion(tt.after)}
+			if _, err := runWithDeps(pass, newAnalyzerOptions(WithConfig(config), WithAIClient(client))); err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			content, err := os.ReadFile(filename)
+			if err != nil {
+				t.Fatalf("Failed to read test file: %v", err)
+			}
+			if !strings.Contains(string(content), tt.want) {
+				t.Errorf("Expected the file to contain %q, got:\n%s", tt.want, content)
+			}
+		})
+	}
+}
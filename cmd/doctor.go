@@ -0,0 +1,117 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"strings"
+
+	"github.com/harriteja/gostackallocator/analyzer"
+	"github.com/harriteja/gostackallocator/internal"
+)
+
+// redacted replaces secrets in -doctor output
+const redacted = "[REDACTED]"
+
+// runDoctor prints the environment and the configuration the analyzer would
+// run with for args, for inclusion in bug reports
+func runDoctor(w io.Writer, args []string) error {
+	mode := "standard"
+	config := analyzer.DefaultConfig()
+	if shouldUseDI() {
+		mode = "dependency injection"
+		if err := buildContainer().Invoke(func(c *analyzer.Config) { config = c }); err != nil {
+			return err
+		}
+	} else {
+		parseStackallocArgs(config, args)
+	}
+
+	dir, err := os.Getwd()
+	if err != nil {
+		return err
+	}
+	return writeDiagnosis(w, config, mode, dir)
+}
+
+// writeDiagnosis writes the Go version, module root, AI and metrics status,
+// enabled detectors and resolved configuration. The OpenAI API key is never
+// written, only whether one is set.
+func writeDiagnosis(w io.Writer, config *analyzer.Config, mode, dir string) error {
+	root, err := internal.GetProjectRoot(dir)
+	if err != nil {
+		root = fmt.Sprintf("not found (%v)", err)
+	}
+	workspace, err := internal.GetWorkspaceRoot(dir)
+	if err != nil || workspace == root {
+		workspace = "none"
+	}
+
+	fmt.Fprintf(w, "stackalloc %s\n", analyzer.GetVersion())
+	fmt.Fprintf(w, "go version:   %s %s/%s\n", runtime.Version(), runtime.GOOS, runtime.GOARCH)
+	fmt.Fprintf(w, "module root:  %s\n", root)
+	fmt.Fprintf(w, "workspace:    %s\n", workspace)
+	fmt.Fprintf(w, "mode:         %s\n", mode)
+	fmt.Fprintf(w, "ai:           %s\n", aiStatus(config))
+	fmt.Fprintf(w, "metrics:      %s\n", enabled(config.MetricsEnabled))
+
+	var on, off []string
+	for _, rule := range analyzer.Rules() {
+		if config.IsPatternEnabled(rule.Name) {
+			on = append(on, rule.Name)
+		} else {
+			off = append(off, rule.Name)
+		}
+	}
+	fmt.Fprintf(w, "rules on:     %s\n", strings.Join(on, ", "))
+	fmt.Fprintf(w, "rules off:    %s\n", strings.Join(off, ", "))
+	fmt.Fprintf(w, "unnamed detectors: %s\n", enabled(len(config.OnlyPatterns) == 0))
+
+	safe := *config
+	if safe.OpenAIAPIKey != "" {
+		safe.OpenAIAPIKey = redacted
+	}
+	data, err := json.MarshalIndent(safe, "", "  ")
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "config: %s\n", data)
+	return nil
+}
+
+// aiStatus describes whether AI suggestions are active and why not
+func aiStatus(config *analyzer.Config) string {
+	key := "no API key"
+	if config.OpenAIAPIKey != "" {
+		key = "API key " + redacted
+	}
+	switch {
+	case config.OpenAIDisable:
+		return fmt.Sprintf("disabled by -openai-disable (%s)", key)
+	case config.OpenAIAPIKey == "":
+		return "disabled (no API key; set OPENAI_API_KEY or -openai-api-key)"
+	default:
+		return fmt.Sprintf("enabled, OpenAI model %s (%s)", config.OpenAIModel, key)
+	}
+}
+
+// enabled renders a boolean setting
+func enabled(on bool) string {
+	if on {
+		return "enabled"
+	}
+	return "disabled"
+}
+
+// hasFlag reports whether args set the boolean flag name
+func hasFlag(args []string, name string) bool {
+	for _, arg := range args {
+		arg = strings.TrimPrefix(strings.TrimPrefix(arg, "-"), "-")
+		if arg == name || arg == name+"=true" {
+			return true
+		}
+	}
+	return false
+}
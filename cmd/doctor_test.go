@@ -0,0 +1,127 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/harriteja/gostackallocator/analyzer"
+)
+
+func TestWriteDiagnosis(t *testing.T) {
+	const apiKey = "sk-test-0123456789"
+
+	config := analyzer.DefaultConfig()
+	config.OpenAIAPIKey = apiKey
+	config.EnablePatterns = []string{"chan-send-loop"}
+	config.DisablePatterns = []string{"json-in-loop"}
+
+	var out bytes.Buffer
+	if err := writeDiagnosis(&out, config, "standard", "."); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	diagnosis := out.String()
+
+	if strings.Contains(diagnosis, apiKey) {
+		t.Fatalf("Expected the API key to be redacted, got:\n%s", diagnosis)
+	}
+
+	for _, want := range []string{
+		"go version:   " + runtime.Version(),
+		"ai:           enabled, OpenAI model gpt-4 (API key [REDACTED])",
+		"metrics:      disabled",
+		`"OpenAIAPIKey": "[REDACTED]"`,
+		`"MaxAllocSize": 32`,
+		`"ErrorSeverity": "info"`,
+	} {
+		if !strings.Contains(diagnosis, want) {
+			t.Errorf("Expected the diagnosis to contain %q, got:\n%s", want, diagnosis)
+		}
+	}
+
+	// The module root is found from the cmd directory
+	if strings.Contains(diagnosis, "module root:  not found") {
+		t.Errorf("Expected the module root to be found, got:\n%s", diagnosis)
+	}
+
+	var on, off string
+	for _, line := range strings.Split(diagnosis, "\n") {
+		if rules, ok := strings.CutPrefix(line, "rules on:     "); ok {
+			on = rules
+		}
+		if rules, ok := strings.CutPrefix(line, "rules off:    "); ok {
+			off = rules
+		}
+	}
+	if !strings.Contains(on, "chan-send-loop") || strings.Contains(on, "json-in-loop") {
+		t.Errorf("Expected enabled rules to honor -enable-patterns and -disable-patterns, got %q", on)
+	}
+	if !strings.Contains(off, "json-in-loop") || !strings.Contains(off, "stdlib-idioms") {
+		t.Errorf("Expected disabled and off-by-default rules to be listed as off, got %q", off)
+	}
+}
+
+func TestWriteDiagnosisWorkspace(t *testing.T) {
+	t.Setenv("GOWORK", "")
+	root := t.TempDir()
+	module := filepath.Join(root, "alpha")
+	if err := os.MkdirAll(module, 0755); err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range map[string]string{
+		filepath.Join(root, "go.work"):  "go 1.22\n\nuse ./alpha\n",
+		filepath.Join(module, "go.mod"): "module example.com/alpha\n\ngo 1.22\n",
+	} {
+		if err := os.WriteFile(name, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var out bytes.Buffer
+	if err := writeDiagnosis(&out, analyzer.DefaultConfig(), "standard", module); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	for _, want := range []string{
+		"module root:  " + module + "\n",
+		"workspace:    " + root + "\n",
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("Expected the diagnosis to contain %q, got:\n%s", want, out.String())
+		}
+	}
+
+	// GOWORK=off disables the workspace, as it does for the go command
+	t.Setenv("GOWORK", "off")
+	out.Reset()
+	if err := writeDiagnosis(&out, analyzer.DefaultConfig(), "standard", module); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !strings.Contains(out.String(), "workspace:    none\n") {
+		t.Errorf("Expected no workspace with GOWORK=off, got:\n%s", out.String())
+	}
+}
+
+func TestAIStatus(t *testing.T) {
+	config := analyzer.DefaultConfig()
+	if got := aiStatus(config); !strings.HasPrefix(got, "disabled (no API key") {
+		t.Errorf("Expected AI to be disabled without a key, got %q", got)
+	}
+
+	config.OpenAIAPIKey = "sk-secret"
+	config.OpenAIDisable = true
+	if got := aiStatus(config); got != "disabled by -openai-disable (API key [REDACTED])" {
+		t.Errorf("Expected AI to be disabled by the flag, got %q", got)
+	}
+}
+
+func TestHasFlag(t *testing.T) {
+	if !hasFlag([]string{"-verbose", "--doctor"}, "doctor") || !hasFlag([]string{"-doctor=true"}, "doctor") {
+		t.Error("Expected -doctor to be recognized")
+	}
+	if hasFlag([]string{"-doctor=false", "-doctors"}, "doctor") {
+		t.Error("Expected only -doctor to be recognized")
+	}
+}
@@ -1,8 +1,11 @@
 package main
 
 import (
+	"bufio"
 	"context"
 	"flag"
+	"fmt"
+	"io"
 	"log"
 	"os"
 	"strings"
@@ -16,12 +19,57 @@ import (
 )
 
 func main() {
+	// -doctor describes the environment for bug reports instead of analyzing
+	if hasFlag(os.Args[1:], "doctor") {
+		if err := runDoctor(os.Stdout, os.Args[1:]); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	// Replaying saved fixes needs no analysis, so it runs directly rather than through go vet
+	if path := flagValue(os.Args[1:], "apply-fixes"); path != "" {
+		applied, err := analyzer.ApplySavedFixes(path, os.Stderr)
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Fprintf(os.Stderr, "applied %d saved fix(es) from %s\n", applied, path)
+		return
+	}
+
+	// Start profiling before analysis; profiles are flushed when it finishes
+	prof, err := startProfiling(os.Args[1:])
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// Package patterns given directly, rather than by go vet, are loaded with
+	// go/packages so that one run can cover every module of a workspace, and
+	// the severity policy, -max-issues included, is applied to all of their
+	// findings at once. Under go vet it can only apply to each package.
+	if config, patterns, ok := directArgs(os.Args[1:]); ok {
+		if err := config.Validate(); err != nil {
+			log.Fatalf("Invalid configuration:\n%v", err)
+		}
+		code := runPackages(os.Stdout, config, patterns)
+		if err := prof.stop(); err != nil {
+			log.Fatal(err)
+		}
+		os.Exit(code)
+	}
+
+	// Fixes are confirmed on the controlling terminal, since go vet doesn't
+	// connect the analyzer's stdin
+	confirmer := newTerminalConfirmer()
+	analyzer.SetFixConfirmer(confirmer)
+
 	// Check if we should use dependency injection mode
 	if shouldUseDI() {
-		runWithDI()
+		runWithDI(prof)
 	} else {
 		// Standard mode - use the default analyzer
-		unitchecker.Main(analyzer.Analyzer)
+		registerProfileFlags(analyzer.Analyzer)
+		unitchecker.Main(profiled(analyzer.Analyzer, prof))
 	}
 }
 
@@ -37,14 +85,16 @@ func shouldUseDI() bool {
 }
 
 // runWithDI runs the analyzer with dependency injection
-func runWithDI() {
+func runWithDI(prof *profiler) {
 	container := buildContainer()
 
 	err := container.Invoke(func(a *analysis.Analyzer) {
-		unitchecker.Main(a)
+		registerProfileFlags(a)
+		unitchecker.Main(profiled(a, prof))
 	})
 
 	if err != nil {
+		prof.stop()
 		log.Fatalf("Failed to run analyzer with DI: %v", err)
 	}
 }
@@ -54,7 +104,7 @@ func buildContainer() *dig.Container {
 	container := dig.New()
 
 	// Provide configuration
-	container.Provide(func() *analyzer.Config {
+	container.Provide(func() (*analyzer.Config, error) {
 		config := analyzer.DefaultConfig()
 
 		// Set the API key from environment if available
@@ -65,34 +115,12 @@ func buildContainer() *dig.Container {
 		// Set default model to gpt-4o-mini
 		config.OpenAIModel = "gpt-4o-mini"
 
-		// Parse command line flags
-		fs := flag.NewFlagSet("stackalloc", flag.ContinueOnError)
-		config.SetupFlags(fs)
-
-		// Parse the arguments (skip program name)
-		args := os.Args[1:]
-		// Filter out go vet specific args
-		var stackallocArgs []string
-		for i, arg := range args {
-			if strings.HasPrefix(arg, "-openai-") ||
-				strings.HasPrefix(arg, "-autofix") ||
-				strings.HasPrefix(arg, "-metrics-") ||
-				strings.HasPrefix(arg, "-max-alloc-") ||
-				strings.HasPrefix(arg, "-disable-") {
-				stackallocArgs = append(stackallocArgs, arg)
-				// Check if next arg is a value (not starting with -)
-				if i+1 < len(args) && !strings.HasPrefix(args[i+1], "-") {
-					stackallocArgs = append(stackallocArgs, args[i+1])
-				}
-			}
+		parseStackallocArgs(config, os.Args[1:])
+		if err := config.Validate(); err != nil {
+			return nil, fmt.Errorf("invalid configuration:\n%w", err)
 		}
 
-		if len(stackallocArgs) > 0 {
-			fs.Parse(stackallocArgs)
-			config.ParseFlags(fs)
-		}
-
-		return config
+		return config, nil
 	})
 
 	// Provide logger
@@ -111,13 +139,16 @@ func buildContainer() *dig.Container {
 			return &NoOpAIClient{}
 		}
 
-		return adapter.NewOpenAIAdapter(
+		openAI := adapter.NewOpenAIAdapter(
 			config.OpenAIAPIKey,
 			config.OpenAIModel,
 			config.OpenAIMaxTokens,
 			config.OpenAITemperature,
 			logger,
 		)
+		openAI.SetRequestLogging(config.RequestLogging())
+		openAI.SetModelFallbacks(config.OpenAIFallbacks)
+		return openAI
 	})
 
 	// Provide metrics client
@@ -130,14 +161,163 @@ func buildContainer() *dig.Container {
 	})
 
 	// Provide analyzer
-	container.Provide(analyzer.NewAnalyzer)
+	container.Provide(func(aiClient analyzer.AIClient, metricsClient analyzer.MetricsClient, config *analyzer.Config) *analysis.Analyzer {
+		return analyzer.NewAnalyzerWithOptions(
+			analyzer.WithAIClient(aiClient),
+			analyzer.WithMetrics(metricsClient),
+			analyzer.WithConfig(config),
+			analyzer.WithFixConfirmer(newTerminalConfirmer()),
+		)
+	})
 
 	return container
 }
 
+// parseStackallocArgs applies the analyzer's flags in args to config, ignoring
+// go vet's own arguments
+func parseStackallocArgs(config *analyzer.Config, args []string) {
+	fs := flag.NewFlagSet("stackalloc", flag.ContinueOnError)
+	config.SetupFlags(fs)
+
+	// Filter out go vet specific args
+	var stackallocArgs []string
+	for i, arg := range args {
+		if strings.HasPrefix(arg, "-openai-") ||
+			strings.HasPrefix(arg, "-ai-log-") ||
+			strings.HasPrefix(arg, "-ai-time-budget") ||
+			strings.HasPrefix(arg, "-autofix") ||
+			strings.HasPrefix(arg, "-metrics-") ||
+			strings.HasPrefix(arg, "-max-alloc-") ||
+			strings.HasPrefix(arg, "-disable-") ||
+			strings.HasPrefix(arg, "-enable-") ||
+			strings.HasPrefix(arg, "-only-") ||
+			strings.HasPrefix(arg, "-fail-on-fix") ||
+			strings.HasPrefix(arg, "-annotate") ||
+			strings.HasPrefix(arg, "-interactive") ||
+			strings.HasPrefix(arg, "-save-fixes") ||
+			strings.HasPrefix(arg, "-validate-fixes") ||
+			strings.HasPrefix(arg, "-skip-cgo") ||
+			strings.HasPrefix(arg, "-include-init") ||
+			strings.HasPrefix(arg, "-require-types") ||
+			strings.HasPrefix(arg, "-error-severity") ||
+			strings.HasPrefix(arg, "-warn-severity") ||
+			strings.HasPrefix(arg, "-rule-severity") ||
+			strings.HasPrefix(arg, "-path-tags") ||
+			strings.HasPrefix(arg, "-message-templates") ||
+			strings.HasPrefix(arg, "-max-issues") ||
+			strings.HasPrefix(arg, "-max-depth") ||
+			strings.HasPrefix(arg, "-max-file-size") ||
+			strings.HasPrefix(arg, "-min-loop-iterations") ||
+			strings.HasPrefix(arg, "-sample-rate") ||
+			strings.HasPrefix(arg, "-pprof") ||
+			strings.HasPrefix(arg, "-verbose") ||
+			strings.HasPrefix(arg, "-strict") ||
+			strings.HasPrefix(arg, "-value-types") ||
+			strings.HasPrefix(arg, "-exclude-files") ||
+			strings.HasPrefix(arg, "-generic-instances") ||
+			strings.HasPrefix(arg, "-suggest-generics") ||
+			strings.HasPrefix(arg, "-report") ||
+			strings.HasPrefix(arg, "-include-source") ||
+			strings.HasPrefix(arg, "-include-fixes") ||
+			strings.HasPrefix(arg, "-show-fix") ||
+			strings.HasPrefix(arg, "-format") {
+			stackallocArgs = append(stackallocArgs, arg)
+			// Check if next arg is a value (not starting with -)
+			if i+1 < len(args) && !strings.HasPrefix(args[i+1], "-") {
+				stackallocArgs = append(stackallocArgs, args[i+1])
+			}
+		}
+	}
+
+	if len(stackallocArgs) > 0 {
+		fs.Parse(stackallocArgs)
+		config.ParseFlags(fs)
+	}
+}
+
 // NoOpAIClient provides a no-op implementation when AI is disabled
 type NoOpAIClient struct{}
 
 func (n *NoOpAIClient) SuggestFix(ctx context.Context, snippet, issueMsg string) (string, error) {
 	return "", nil
 }
+
+// promptConfirmer asks whether to apply each fix, showing its diff first.
+// Answering "all" applies the rest without asking and "quit" skips them.
+type promptConfirmer struct {
+	in   *bufio.Reader
+	out  io.Writer
+	tty  io.Closer
+	open func() (io.ReadWriteCloser, error) // opens the terminal on first use
+	all  bool
+	quit bool
+}
+
+// newTerminalConfirmer creates a confirmer prompting on /dev/tty. Without a
+// terminal it applies every fix, like -autofix. go vet analyzes packages in
+// parallel processes, so the terminal is locked while it's open, and each
+// process asks about its package's fixes in turn.
+func newTerminalConfirmer() *promptConfirmer {
+	return &promptConfirmer{
+		open: func() (io.ReadWriteCloser, error) {
+			tty, err := os.OpenFile("/dev/tty", os.O_RDWR, 0)
+			if err != nil {
+				return nil, err
+			}
+			if err := lockTerminal(tty); err != nil {
+				tty.Close()
+				return nil, err
+			}
+			return tty, nil
+		},
+	}
+}
+
+// ConfirmFix implements analyzer.FixConfirmer
+func (p *promptConfirmer) ConfirmFix(fix analyzer.PendingFix) bool {
+	if p.in == nil && !p.all && !p.quit {
+		tty, err := p.open()
+		if err != nil {
+			p.all = true
+		} else {
+			p.in, p.out, p.tty = bufio.NewReader(tty), tty, tty
+		}
+	}
+	if p.all || p.quit {
+		return p.all
+	}
+
+	fmt.Fprintf(p.out, "%s:%d\n%s", fix.Filename, fix.Line, fix.Diff)
+	for {
+		fmt.Fprint(p.out, "Apply this fix? [y/n/all/quit] ")
+		answer, err := p.in.ReadString('\n')
+		if err != nil && answer == "" {
+			p.quit = true
+			return false
+		}
+
+		switch strings.ToLower(strings.TrimSpace(answer)) {
+		case "y", "yes":
+			return true
+		case "n", "no":
+			return false
+		case "a", "all":
+			p.all = true
+			return true
+		case "q", "quit":
+			p.quit = true
+			return false
+		}
+	}
+}
+
+// Close closes the terminal, once the fixes of a package have been confirmed,
+// letting other processes prompt. It is opened again if more fixes follow.
+func (p *promptConfirmer) Close() error {
+	if p.tty == nil {
+		return nil
+	}
+	err := p.tty.Close()
+	p.in, p.out, p.tty = nil, nil, nil
+	return err
+}
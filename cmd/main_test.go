@@ -0,0 +1,107 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/harriteja/gostackallocator/analyzer"
+)
+
+// fakeTerminal stands in for /dev/tty, recording whether it was closed
+type fakeTerminal struct {
+	io.Reader
+	io.Writer
+	closed bool
+}
+
+func (f *fakeTerminal) Close() error {
+	f.closed = true
+	return nil
+}
+
+func TestPromptConfirmer(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected []bool
+	}{
+		{"yes and no", "y\nn\nyes\n", []bool{true, false, true, false}},
+		{"unknown answers are asked again", "maybe\ny\n", []bool{true, false}},
+		{"all applies the rest", "n\nall\n", []bool{false, true, true, true}},
+		{"quit skips the rest", "y\nquit\n", []bool{true, false, false, false}},
+	}
+
+	fix := analyzer.PendingFix{Filename: "main.go", Line: 4, Diff: "-\ts := new(string)\n+\ts := \"\"\n"}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			p := &promptConfirmer{
+				open: func() (io.ReadWriteCloser, error) {
+					return &fakeTerminal{Reader: strings.NewReader(tt.input), Writer: &out}, nil
+				},
+			}
+
+			// Running out of input answers the remaining prompts with no
+			for i, want := range tt.expected {
+				if got := p.ConfirmFix(fix); got != want {
+					t.Errorf("Fix %d: expected %v, got %v", i, want, got)
+				}
+			}
+
+			if !strings.Contains(out.String(), "main.go:4\n-\ts := new(string)\n+\ts := \"\"\n") {
+				t.Errorf("Expected the diff to be shown, got:\n%s", out.String())
+			}
+		})
+	}
+}
+
+func TestPromptConfirmerWithoutTerminal(t *testing.T) {
+	p := &promptConfirmer{
+		open: func() (io.ReadWriteCloser, error) {
+			return nil, errors.New("no terminal")
+		},
+	}
+
+	for i := 0; i < 2; i++ {
+		if !p.ConfirmFix(analyzer.PendingFix{}) {
+			t.Errorf("Expected every fix to be applied without a terminal")
+		}
+	}
+	if p.in != nil {
+		t.Error("Expected no prompt without a terminal")
+	}
+}
+
+func TestPromptConfirmerClose(t *testing.T) {
+	var terminals []*fakeTerminal
+	p := &promptConfirmer{
+		open: func() (io.ReadWriteCloser, error) {
+			tty := &fakeTerminal{Reader: strings.NewReader("y\n"), Writer: io.Discard}
+			terminals = append(terminals, tty)
+			return tty, nil
+		},
+	}
+
+	// Each package's fixes are confirmed on a terminal that is closed after
+	if !p.ConfirmFix(analyzer.PendingFix{}) {
+		t.Fatal("Expected the first fix to be applied")
+	}
+	if err := p.Close(); err != nil || !terminals[0].closed {
+		t.Fatalf("Expected the terminal to be closed, got %v", err)
+	}
+	if !p.ConfirmFix(analyzer.PendingFix{}) {
+		t.Fatal("Expected the next package's fix to be applied")
+	}
+	if len(terminals) != 2 || terminals[1].closed {
+		t.Errorf("Expected the terminal to be opened again for the next package, got %d opened", len(terminals))
+	}
+
+	// Closing a confirmer that never prompted does nothing
+	if err := (&promptConfirmer{}).Close(); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+}
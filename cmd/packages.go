@@ -0,0 +1,75 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/harriteja/gostackallocator/analyzer"
+)
+
+// directArgs parses args as stackalloc flags followed by package patterns, for
+// running stackalloc directly rather than through go vet. It reports false for
+// go vet's own invocations, such as -V=full, -flags or a .cfg file.
+func directArgs(args []string) (*analyzer.Config, []string, bool) {
+	config := analyzer.DefaultConfig()
+	fs := flag.NewFlagSet("stackalloc", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	config.SetupFlags(fs)
+	for name, usage := range profileFlags {
+		fs.String(name, "", usage)
+	}
+
+	if err := fs.Parse(args); err != nil || fs.NArg() == 0 {
+		return nil, nil, false
+	}
+	for _, arg := range fs.Args() {
+		if strings.HasSuffix(arg, ".cfg") {
+			return nil, nil, false
+		}
+	}
+	config.ParseFlags(fs)
+	return config, fs.Args(), true
+}
+
+// runPackages analyzes the packages matching patterns, which may span the
+// modules of a go.work workspace, and writes every result to w in the
+// -format output format, or just their counts under -report=counts. It
+// returns the exit status for the results.
+func runPackages(w io.Writer, config *analyzer.Config, patterns []string) int {
+	dir, err := os.Getwd()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	if config.Report == "counts" {
+		counts, err := analyzer.CountPackages(dir, patterns, config)
+		if err == nil {
+			err = analyzer.WriteCounts(w, config.Format, counts)
+		}
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		return counts.ExitCode(config)
+	}
+
+	results, err := analyzer.AnalyzePackages(dir, patterns, config)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	if err := analyzer.WriteResults(w, config.Format, results); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	issues := make([]analyzer.Issue, len(results))
+	for i, result := range results {
+		issues[i] = result.Issue
+	}
+	return analyzer.ExitCode(issues, config)
+}
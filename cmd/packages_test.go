@@ -0,0 +1,161 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/harriteja/gostackallocator/analyzer"
+)
+
+func TestDirectArgs(t *testing.T) {
+	tests := []struct {
+		name     string
+		args     []string
+		patterns []string
+		ok       bool
+	}{
+		{"patterns", []string{"./alpha/...", "./beta/..."}, []string{"./alpha/...", "./beta/..."}, true},
+		{"flags then patterns", []string{"-format=json", "-verbose", "-max-alloc-size", "64", "./..."}, []string{"./..."}, true},
+		{"no patterns", []string{"-verbose"}, nil, false},
+		{"go vet config", []string{"-format=json", "/tmp/vet.cfg"}, nil, false},
+		{"go vet version query", []string{"-V=full"}, nil, false},
+		{"go vet flags query", []string{"-flags"}, nil, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config, patterns, ok := directArgs(tt.args)
+			if ok != tt.ok {
+				t.Fatalf("directArgs(%q) ok = %v, want %v", tt.args, ok, tt.ok)
+			}
+			if !ok {
+				return
+			}
+			if !reflect.DeepEqual(patterns, tt.patterns) {
+				t.Errorf("patterns = %q, want %q", patterns, tt.patterns)
+			}
+			if config == nil {
+				t.Fatal("Expected a config")
+			}
+		})
+	}
+
+	config, _, _ := directArgs([]string{"-format=json", "-max-alloc-size", "64", "./..."})
+	if config.Format != "json" || config.MaxAllocSize != 64 {
+		t.Errorf("Expected flags to be applied, got format %q and max alloc size %d", config.Format, config.MaxAllocSize)
+	}
+}
+
+// chdirFixture writes files to a temporary directory and makes it the working
+// directory for the rest of the test, as runPackages resolves patterns there
+func chdirFixture(t *testing.T, files map[string]string) string {
+	t.Helper()
+
+	root := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(root); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(wd) })
+	return root
+}
+
+func TestRunPackagesWorkspace(t *testing.T) {
+	chdirFixture(t, map[string]string{
+		"go.work":      "go 1.22\n\nuse (\n\t./alpha\n\t./beta\n)\n",
+		"alpha/go.mod": "module example.com/alpha\n\ngo 1.22\n",
+		"alpha/alpha.go": `package alpha
+
+import "fmt"
+
+func Label(n int) *string {
+	s := fmt.Sprint(n)
+	return &s
+}
+`,
+		"beta/go.mod": "module example.com/beta\n\ngo 1.22\n",
+		"beta/beta.go": `package beta
+
+import (
+	"context"
+
+	"example.com/alpha"
+)
+
+func Labels(ctx context.Context) []*string {
+	var out []*string
+	for i := 0; i < 3 && ctx.Err() == nil; i++ {
+		out = append(out, alpha.Label(i))
+	}
+	return out
+}
+`,
+	})
+	// -mod=mod can't be used in workspace mode
+	t.Setenv("GOFLAGS", "")
+
+	config := analyzer.DefaultConfig()
+	config.OpenAIDisable = true
+	config.Format = "json"
+	var out bytes.Buffer
+	runPackages(&out, config, []string{"example.com/alpha/...", "example.com/beta/..."})
+
+	// Packages importing the standard library load and are analyzed
+	var results []analyzer.JSONResult
+	if err := json.Unmarshal(out.Bytes(), &results); err != nil {
+		t.Fatalf("Expected JSON results, got %q: %v", out.String(), err)
+	}
+	files := make(map[string]bool)
+	for _, r := range results {
+		files[filepath.Base(r.File)] = true
+	}
+	if !files["alpha.go"] || !files["beta.go"] {
+		t.Errorf("Expected results in alpha.go and beta.go, got %+v", results)
+	}
+}
+
+func TestRunPackagesMaxIssuesAcrossPackages(t *testing.T) {
+	code := "package %s\n\nfunc Count() *int {\n\treturn new(int)\n}\n"
+	root := chdirFixture(t, map[string]string{
+		"go.mod": "module example.com/budget\n\ngo 1.22\n",
+		"a/a.go": fmt.Sprintf(code, "a"),
+		"b/b.go": fmt.Sprintf(code, "b"),
+	})
+
+	results, err := analyzer.AnalyzePackages(root, []string{"./a"}, nil)
+	if err != nil || len(results) == 0 {
+		t.Fatalf("Expected findings in one package, got %v (%v)", results, err)
+	}
+	perPackage := len(results)
+
+	// Each package is within the allowance on its own, but not the run
+	config := analyzer.DefaultConfig()
+	config.OpenAIDisable = true
+	config.MaxIssues = perPackage
+	if code := runPackages(io.Discard, config, []string{"./..."}); code != 1 {
+		t.Errorf("Expected -max-issues=%d to fail a run with %d findings, got exit status %d", perPackage, 2*perPackage, code)
+	}
+
+	config.MaxIssues = 2 * perPackage
+	if code := runPackages(io.Discard, config, []string{"./..."}); code != 0 {
+		t.Errorf("Expected -max-issues=%d to pass a run with %d findings, got exit status %d", 2*perPackage, 2*perPackage, code)
+	}
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+	"runtime/pprof"
+	"strings"
+	"sync"
+
+	"golang.org/x/tools/go/analysis"
+)
+
+// profileFlags are the flags that request profiles of the analyzer itself
+var profileFlags = map[string]string{
+	"cpuprofile": "write a CPU profile of the analyzer to `file`",
+	"memprofile": "write a heap profile of the analyzer to `file`",
+}
+
+// registerProfileFlags declares the profile flags on the analyzer, so go vet
+// accepts and forwards them as -stackalloc.cpuprofile and -stackalloc.memprofile.
+// Their values are read by startProfiling before unitchecker parses them.
+func registerProfileFlags(a *analysis.Analyzer) {
+	for name, usage := range profileFlags {
+		if a.Flags.Lookup(name) == nil {
+			a.Flags.String(name, "", usage)
+		}
+	}
+}
+
+// profiler writes CPU and heap profiles of the analyzer itself
+type profiler struct {
+	cpuFile *os.File
+	memPath string
+	once    sync.Once
+	err     error
+}
+
+// startProfiling starts the profiles requested by -cpuprofile and -memprofile
+// in args. It returns nil if neither was requested.
+func startProfiling(args []string) (*profiler, error) {
+	cpuPath := profileFlagValue(args, "cpuprofile")
+	memPath := profileFlagValue(args, "memprofile")
+	if cpuPath == "" && memPath == "" {
+		return nil, nil
+	}
+
+	p := &profiler{memPath: memPath}
+	if cpuPath != "" {
+		f, err := os.Create(cpuPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create CPU profile: %w", err)
+		}
+		if err := pprof.StartCPUProfile(f); err != nil {
+			f.Close()
+			return nil, fmt.Errorf("failed to start CPU profile: %w", err)
+		}
+		p.cpuFile = f
+	}
+	return p, nil
+}
+
+// stop flushes the profiles. It is safe to call more than once.
+func (p *profiler) stop() error {
+	if p == nil {
+		return nil
+	}
+
+	p.once.Do(func() {
+		if p.cpuFile != nil {
+			pprof.StopCPUProfile()
+			if err := p.cpuFile.Close(); err != nil {
+				p.err = fmt.Errorf("failed to write CPU profile: %w", err)
+			}
+		}
+
+		if p.memPath != "" {
+			f, err := os.Create(p.memPath)
+			if err != nil {
+				p.err = fmt.Errorf("failed to create heap profile: %w", err)
+				return
+			}
+			defer f.Close()
+
+			runtime.GC() // get up-to-date statistics
+			if err := pprof.WriteHeapProfile(f); err != nil {
+				p.err = fmt.Errorf("failed to write heap profile: %w", err)
+			}
+		}
+	})
+	return p.err
+}
+
+// profiled wraps the analyzer so profiles are flushed when its run finishes,
+// even on an early return, since unitchecker exits without running deferred calls
+func profiled(a *analysis.Analyzer, p *profiler) *analysis.Analyzer {
+	if p == nil {
+		return a
+	}
+
+	run := a.Run
+	a.Run = func(pass *analysis.Pass) (result interface{}, err error) {
+		defer func() {
+			if stopErr := p.stop(); stopErr != nil && err == nil {
+				err = stopErr
+			}
+		}()
+		return run(pass)
+	}
+	return a
+}
+
+// profileFlagValue returns the value of a profile flag in args, spelled either
+// -name or, as go vet passes it, -stackalloc.name
+func profileFlagValue(args []string, name string) string {
+	if value := flagValue(args, name); value != "" {
+		return value
+	}
+	return flagValue(args, "stackalloc."+name)
+}
+
+// flagValue returns the value of -name=value or -name value in args
+func flagValue(args []string, name string) string {
+	for i, arg := range args {
+		arg = strings.TrimPrefix(strings.TrimPrefix(arg, "-"), "-")
+		if value, ok := strings.CutPrefix(arg, name+"="); ok {
+			return value
+		}
+		if arg == name && i+1 < len(args) {
+			return args[i+1]
+		}
+	}
+	return ""
+}
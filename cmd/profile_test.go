@@ -0,0 +1,72 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/tools/go/analysis"
+)
+
+func TestProfilesWrittenOnEarlyReturn(t *testing.T) {
+	dir := t.TempDir()
+	cpuPath := filepath.Join(dir, "cpu.prof")
+	memPath := filepath.Join(dir, "mem.prof")
+
+	prof, err := startProfiling([]string{"-stackalloc.cpuprofile=" + cpuPath, "-memprofile", memPath, "unit.cfg"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	a := profiled(&analysis.Analyzer{
+		Name: "early",
+		Doc:  "returns before doing any work",
+		Run: func(pass *analysis.Pass) (interface{}, error) {
+			return nil, errors.New("early return")
+		},
+	}, prof)
+
+	if _, err := a.Run(&analysis.Pass{}); err == nil || err.Error() != "early return" {
+		t.Fatalf("Expected the analyzer error to be preserved, got %v", err)
+	}
+
+	for _, path := range []string{cpuPath, memPath} {
+		info, err := os.Stat(path)
+		if err != nil {
+			t.Fatalf("Expected profile %s to be written: %v", path, err)
+		}
+		if info.Size() == 0 {
+			t.Errorf("Expected profile %s to be non-empty", path)
+		}
+	}
+
+	// Stopping again is a no-op
+	if err := prof.stop(); err != nil {
+		t.Errorf("Unexpected error stopping twice: %v", err)
+	}
+}
+
+func TestRegisterProfileFlags(t *testing.T) {
+	a := &analysis.Analyzer{Name: "plain"}
+	registerProfileFlags(a)
+	registerProfileFlags(a)
+
+	for name := range profileFlags {
+		if a.Flags.Lookup(name) == nil {
+			t.Errorf("Expected flag %s to be registered", name)
+		}
+	}
+}
+
+func TestStartProfilingWithoutFlags(t *testing.T) {
+	prof, err := startProfiling([]string{"-autofix", "./..."})
+	if err != nil || prof != nil {
+		t.Fatalf("Expected no profiler without profile flags, got %v, %v", prof, err)
+	}
+
+	a := &analysis.Analyzer{Name: "plain"}
+	if profiled(a, prof) != a {
+		t.Error("Expected the analyzer to be returned unchanged")
+	}
+}
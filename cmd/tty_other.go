@@ -0,0 +1,10 @@
+//go:build !unix
+
+package main
+
+import "os"
+
+// lockTerminal does nothing where there's no /dev/tty to prompt on
+func lockTerminal(tty *os.File) error {
+	return nil
+}
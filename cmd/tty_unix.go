@@ -0,0 +1,14 @@
+//go:build unix
+
+package main
+
+import (
+	"os"
+	"syscall"
+)
+
+// lockTerminal takes an exclusive lock on tty, waiting for any other process
+// holding one. The lock is released when tty is closed.
+func lockTerminal(tty *os.File) error {
+	return syscall.Flock(int(tty.Fd()), syscall.LOCK_EX)
+}
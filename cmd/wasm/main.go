@@ -0,0 +1,38 @@
+//go:build js && wasm
+
+// Command wasm exposes the syntactic stackalloc detectors to JavaScript.
+//
+// Build it with:
+//
+//	GOOS=js GOARCH=wasm go build -o stackalloc.wasm ./cmd/wasm
+//
+// and call the global stackallocAnalyze(filename, source) from the page. It
+// returns a JSON array of issues, or an Error if the source doesn't parse.
+package main
+
+import (
+	"syscall/js"
+
+	"github.com/harriteja/gostackallocator/analyzer"
+	"github.com/harriteja/gostackallocator/playground"
+)
+
+func main() {
+	js.Global().Set("stackallocAnalyze", js.FuncOf(analyze))
+
+	// Keep the Go runtime alive so the callback stays valid
+	select {}
+}
+
+// analyze implements stackallocAnalyze(filename, source)
+func analyze(this js.Value, args []js.Value) interface{} {
+	if len(args) != 2 {
+		return js.Global().Get("Error").New("stackallocAnalyze(filename, source) takes 2 arguments")
+	}
+
+	out, err := playground.AnalyzeJSON(args[0].String(), []byte(args[1].String()), analyzer.DefaultConfig())
+	if err != nil {
+		return js.Global().Get("Error").New(err.Error())
+	}
+	return string(out)
+}
@@ -135,6 +135,15 @@ func DemoAllocationPatterns() {
 	_ = large
 }
 
+// Point is returned by address from NewPoint
+type Point struct{ X, Y int }
+
+// NewPoint demonstrates an allocation that is certain rather than likely
+func NewPoint(x, y int) *Point {
+	// 13. Returning the address of a literal always moves it to the heap
+	return &Point{X: x, Y: y}
+}
+
 // DemoOptimizedPatterns shows better alternatives
 func DemoOptimizedPatterns() {
 	// 1. Use zero values instead of new()
@@ -1,6 +1,6 @@
 module github.com/harriteja/gostackallocator
 
-go 1.22
+go 1.22.0
 
 toolchain go1.24.3
 
@@ -9,7 +9,8 @@ require (
 	github.com/sashabaranov/go-openai v1.40.0
 	go.uber.org/dig v1.19.0
 	go.uber.org/zap v1.27.0
-	golang.org/x/tools v0.12.0
+	golang.org/x/tools v0.26.0
+	google.golang.org/protobuf v1.36.5
 )
 
 require (
@@ -20,6 +21,7 @@ require (
 	github.com/prometheus/common v0.62.0 // indirect
 	github.com/prometheus/procfs v0.15.1 // indirect
 	go.uber.org/multierr v1.10.0 // indirect
+	golang.org/x/mod v0.21.0 // indirect
+	golang.org/x/sync v0.8.0 // indirect
 	golang.org/x/sys v0.30.0 // indirect
-	google.golang.org/protobuf v1.36.5 // indirect
 )
@@ -80,6 +80,35 @@ func GetProjectRoot(startPath string) (string, error) {
 	return "", fmt.Errorf("go.mod not found in any parent directory")
 }
 
+// GetWorkspaceRoot finds the root of the go.work workspace containing
+// startPath, honoring GOWORK as the go command does. Outside a workspace, or
+// with GOWORK=off, it is the module root found by GetProjectRoot.
+func GetWorkspaceRoot(startPath string) (string, error) {
+	switch gowork := os.Getenv("GOWORK"); gowork {
+	case "off":
+		return GetProjectRoot(startPath)
+	case "":
+	default:
+		return filepath.Dir(gowork), nil
+	}
+
+	dir, err := filepath.Abs(startPath)
+	if err != nil {
+		return "", err
+	}
+	for {
+		if FileExists(filepath.Join(dir, "go.work")) {
+			return dir, nil
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return GetProjectRoot(startPath)
+		}
+		dir = parent
+	}
+}
+
 // EnsureDir creates a directory if it doesn't exist
 func EnsureDir(path string) error {
 	if _, err := os.Stat(path); os.IsNotExist(err) {
@@ -0,0 +1,30 @@
+// Package playground runs the stackalloc detectors that don't need type
+// information on source text, for the WebAssembly build in cmd/wasm. It
+// depends on nothing but the detectors: in js/wasm builds the analyzer package
+// leaves out its OpenAI client and go/packages loading, so they aren't linked.
+package playground
+
+import (
+	"bytes"
+
+	"github.com/harriteja/gostackallocator/analyzer"
+)
+
+// AnalyzeJSON is analyzer.AnalyzeSource with the issues encoded as a JSON
+// array of analyzer.JSONIssue, for callers that can only exchange strings
+func AnalyzeJSON(filename string, src []byte, config *analyzer.Config) ([]byte, error) {
+	issues, err := analyzer.AnalyzeSource(filename, src, config)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	sink := analyzer.NewJSONSink(&buf)
+	for _, issue := range issues {
+		sink.Report(issue)
+	}
+	if err := sink.Flush(); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSpace(buf.Bytes()), nil
+}
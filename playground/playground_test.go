@@ -0,0 +1,69 @@
+package playground
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/harriteja/gostackallocator/analyzer"
+)
+
+func TestAnalyzeJSON(t *testing.T) {
+	src := []byte(`package main
+
+import "os"
+
+func main() {
+	s := new(string)
+	_ = s
+	jobs := []int{1, 2}
+	for range jobs {
+		done := make(chan struct{})
+		_ = done
+	}
+	os.Exit(0)
+}
+`)
+
+	out, err := AnalyzeJSON("playground.go", src, nil)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	var issues []analyzer.JSONIssue
+	if err := json.Unmarshal(out, &issues); err != nil {
+		t.Fatalf("Expected a JSON array of issues, got %s: %v", out, err)
+	}
+
+	var sawNew, sawRule bool
+	for _, issue := range issues {
+		if issue.File != "playground.go" || issue.Line == 0 || issue.Column == 0 {
+			t.Errorf("Expected a position in playground.go, got %+v", issue)
+		}
+		if issue.Line == 6 && strings.Contains(issue.Message, "new(T)") {
+			sawNew = true
+		}
+		if issue.Rule == "chan-in-loop" && issue.Severity == analyzer.SeverityPossible {
+			sawRule = true
+		}
+	}
+	if !sawNew || !sawRule {
+		t.Errorf("Expected new(T) and chan-in-loop issues, got %s", out)
+	}
+}
+
+func TestAnalyzeJSONParseError(t *testing.T) {
+	if _, err := AnalyzeJSON("broken.go", []byte("package main\nfunc {"), analyzer.DefaultConfig()); err == nil {
+		t.Error("Expected an error for source that doesn't parse")
+	}
+}
+
+func TestAnalyzeJSONEmpty(t *testing.T) {
+	out, err := AnalyzeJSON("empty.go", []byte("package main\n"), analyzer.DefaultConfig())
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if string(out) != "[]" {
+		t.Errorf("Expected an empty JSON array, got %s", out)
+	}
+}